@@ -3,16 +3,29 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/adversarial"
 	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
 	"github.com/randomizedcoder/clickhouse-otel-example/internal/health"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/logging"
 	"github.com/randomizedcoder/clickhouse-otel-example/internal/loop"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/schema"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/shape"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/sink"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/truth"
 )
 
 // version is set at build time via ldflags.
@@ -26,8 +39,36 @@ func run() int {
 	// Load configuration from flags and environment variables
 	cfg := config.Load()
 
-	// Initialize production JSON logger
-	logger, err := zap.NewProduction()
+	if cfg.HashPassword {
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			os.Stderr.WriteString("failed to read password: " + err.Error() + "\n")
+			return 1
+		}
+		hash, err := health.HashPassword(strings.TrimRight(password, "\r\n"))
+		if err != nil {
+			os.Stderr.WriteString("failed to hash password: " + err.Error() + "\n")
+			return 1
+		}
+		os.Stdout.WriteString(hash + "\n")
+		return 0
+	}
+
+	if cfg.AdversarialCatalog {
+		if err := adversarial.WriteCatalog(os.Stdout); err != nil {
+			os.Stderr.WriteString("failed to write catalog: " + err.Error() + "\n")
+			return 1
+		}
+		return 0
+	}
+
+	// Initialize the operational logger. Its level can be changed at
+	// runtime through /admin/log/level.
+	logger, level, err := logging.New(logging.Options{
+		Level:    cfg.LogLevel,
+		Encoding: cfg.LogEncoding,
+		Output:   cfg.LogOutput,
+	})
 	if err != nil {
 		// Fallback to stderr if logger creation fails
 		os.Stderr.WriteString("failed to create logger: " + err.Error() + "\n")
@@ -37,12 +78,31 @@ func run() int {
 		_ = logger.Sync()
 	}()
 
+	// Structured settings such as scenarios come from the optional config file
+	if err := cfg.ApplyFile(); err != nil {
+		logger.Error("failed to load config file", zap.String("path", cfg.ConfigFile), zap.Error(err))
+		return 1
+	}
+
+	// Generated records go to the sinks from the config file, or to stdout
+	// through the same queue and metrics as a stdout sink. Stdout sinks
+	// share one writer so their lines never interleave.
+	registry := metrics.NewRegistry()
+	stdout := zapcore.Lock(zapcore.AddSync(os.Stdout))
+
 	logger.Info("loggen starting",
 		zap.String("version", version),
 		zap.Int("max_number", cfg.MaxNumber),
 		zap.Int("num_strings", cfg.NumStrings),
 		zap.Duration("sleep_duration", cfg.SleepDuration),
-		zap.Int("health_port", cfg.HealthPort),
+		zap.String("health_addr", cfg.HealthListenAddr()),
+		zap.Bool("health_tls", cfg.HealthTLSCert != ""),
+		zap.String("log_level", level.String()),
+		zap.String("log_encoding", cfg.LogEncoding),
+		zap.String("config_file", cfg.ConfigFile),
+		zap.Stringer("mode", cfg.Mode),
+		zap.Stringer("record_size", cfg.RecordSize),
+		zap.String("output_format", cfg.OutputFormat),
 	)
 
 	// Create cancellable context for coordinated shutdown
@@ -50,7 +110,24 @@ func run() int {
 	defer cancel()
 
 	// Start health check server
-	healthServer := health.NewServer(cfg.HealthPort, logger)
+	healthServer := health.NewServerWithOptions(health.Options{
+		Addr: cfg.HealthListenAddr(),
+		TLS: health.TLSOptions{
+			CertFile:     cfg.HealthTLSCert,
+			KeyFile:      cfg.HealthTLSKey,
+			ClientCAFile: cfg.HealthTLSClientCA,
+		},
+		Auth: health.AuthOptions{
+			TokenFile: cfg.AdminTokenFile,
+			BasicFile: cfg.AdminBasicAuthFile,
+		},
+	}, logger)
+	healthServer.Handle("/metrics", registry)
+	healthServer.HandleAdmin("/admin/log/level", level)
+	if err := healthServer.Listen(); err != nil {
+		logger.Error("failed to start health server", zap.Error(err))
+		return 1
+	}
 	go func() {
 		if err := healthServer.Start(ctx); err != nil {
 			logger.Error("health server failed", zap.Error(err))
@@ -58,9 +135,74 @@ func run() int {
 		}
 	}()
 
+	// Optional ground-truth manifest for scoring detection queries
+	var loopOpts []loop.Option
+	var truthWriter *truth.Writer
+	if cfg.TruthFile != "" {
+		truthWriter, err = truth.Create(cfg.TruthFile, cfg.TruthWindow)
+		if err != nil {
+			logger.Error("failed to create ground truth file", zap.Error(err))
+			return 1
+		}
+		loopOpts = append(loopOpts, loop.WithGroundTruth(truthWriter))
+	}
+
+	// Optional custom record schema from the config file
+	if cfg.Schema != nil {
+		gen, err := schema.New(cfg.Schema)
+		if err != nil {
+			logger.Error("invalid record schema", zap.Error(err))
+			return 1
+		}
+		loopOpts = append(loopOpts, loop.WithSchema(gen))
+	}
+
+	// Without sinks in the config file, records go to stdout in the
+	// selected format
+	specs := cfg.Sinks
+	if len(specs) == 0 {
+		spec, err := cfg.StdoutSink()
+		if err != nil {
+			logger.Error("invalid stdout output", zap.Error(err))
+			return 1
+		}
+		specs = []config.Sink{spec}
+	}
+
+	// Optional padding of records to a size distribution, measured in the
+	// format of the sinks when they all share one
+	if cfg.RecordSize.Enabled() {
+		format, ok := sinkFormat(specs)
+		if !ok {
+			format = cfg.OutputFormat
+		}
+		shapeEnc, err := encode.New(format, encode.WithVersion(version))
+		if err != nil {
+			logger.Error("invalid sink format", zap.Error(err))
+			return 1
+		}
+		loopOpts = append(loopOpts, loop.WithShaper(shape.New(cfg.RecordSize, shapeEnc)))
+	}
+
+	// Each output gets its own queue and goroutine
+	sinks, err := sink.Open(specs,
+		sink.WithStdout(stdout),
+		sink.WithEncoderOptions(encode.WithVersion(version)),
+		sink.WithMetrics(registry),
+	)
+	if err != nil {
+		logger.Error("failed to open sinks", zap.Error(err))
+		return 1
+	}
+	loopOpts = append(loopOpts, loop.WithSink(sinks))
+
 	// Start main logging loop
-	looper := loop.New(cfg, logger)
-	go looper.Run(ctx)
+	looper := loop.New(cfg, logger, loopOpts...)
+	loopDone := make(chan struct{})
+	go func() {
+		looper.Run(ctx)
+		close(loopDone)
+	}()
 
 	// Wait for shutdown signal
 	sigChan := make(chan os.Signal, 1)
@@ -71,6 +213,17 @@ func run() int {
 
 	// Graceful shutdown
 	cancel()
+	<-loopDone
+
+	if err := sinks.Close(); err != nil {
+		logger.Error("failed to close sinks", zap.Error(err))
+	}
+
+	if truthWriter != nil {
+		if err := truthWriter.Close(time.Now()); err != nil {
+			logger.Error("failed to write ground truth file", zap.Error(err))
+		}
+	}
 
 	// Shutdown health server
 	if err := healthServer.Shutdown(context.Background()); err != nil {
@@ -80,3 +233,17 @@ func run() int {
 	logger.Info("loggen stopped")
 	return 0
 }
+
+// sinkFormat returns the format shared by every sink, if there are sinks
+// and they agree on one.
+func sinkFormat(sinks []config.Sink) (string, bool) {
+	if len(sinks) == 0 {
+		return "", false
+	}
+	for _, s := range sinks[1:] {
+		if s.Format != sinks[0].Format {
+			return "", false
+		}
+	}
+	return sinks[0].Format, true
+}
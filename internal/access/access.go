@@ -0,0 +1,195 @@
+// Package access simulates web-server access logs in the NCSA Common and
+// Combined Log Formats used by nginx and Apache, and as structured JSON
+// records. Requests are drawn from the gen package, so a seeded loop
+// produces the same traffic on every run.
+package access
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/gen"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// clfTime is the timestamp layout of the Common Log Format.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// Latency bounds of simulated requests.
+const (
+	MinLatency = time.Millisecond
+	MaxLatency = 2 * time.Second
+)
+
+// Request is one simulated HTTP request.
+type Request struct {
+	RemoteAddr string
+	User       string
+	Time       time.Time
+	Method     string
+	Path       string
+	Protocol   string
+	Status     int
+	Bytes      int64
+	Latency    time.Duration
+	Referer    string
+	UserAgent  string
+
+	// RequestID, TraceID and SpanID are set when trace context is enabled.
+	// TraceID and SpanID are lowercase hex, as in W3C traceparent.
+	RequestID string
+	TraceID   string
+	SpanID    string
+}
+
+var (
+	users     = []string{"alice", "bob", "carol", "svc-batch"}
+	protocols = []string{"HTTP/1.1", "HTTP/1.1", "HTTP/1.1", "HTTP/2.0", "HTTP/1.0"}
+	referers  = []string{"https://www.example.com/", "https://www.example.com/search", "https://news.example.org/article", "https://www.google.com/"}
+)
+
+// NewRequest draws a request at now. With trace set, the request carries
+// a request ID and trace context.
+func NewRequest(rng *rand.Rand, now time.Time, trace bool) Request {
+	r := Request{
+		RemoteAddr: gen.IPv4(rng).String(),
+		User:       "-",
+		Time:       now,
+		Method:     gen.HTTPMethod(rng),
+		Path:       gen.HTTPPath(rng),
+		Protocol:   protocols[rng.IntN(len(protocols))],
+		Status:     gen.HTTPStatus(rng),
+		Latency:    gen.Duration(rng, MinLatency, MaxLatency),
+		Referer:    "-",
+		UserAgent:  gen.UserAgent(rng),
+	}
+
+	if rng.IntN(10) == 0 {
+		r.User = users[rng.IntN(len(users))]
+	}
+	if rng.IntN(3) == 0 {
+		r.Referer = referers[rng.IntN(len(referers))]
+	}
+	r.Bytes = responseBytes(rng, r.Method, r.Status)
+
+	if trace {
+		r.RequestID = fmt.Sprintf("%016x%016x", rng.Uint64(), rng.Uint64())
+		r.TraceID = fmt.Sprintf("%016x%016x", rng.Uint64(), rng.Uint64())
+		r.SpanID = fmt.Sprintf("%016x", rng.Uint64())
+	}
+	return r
+}
+
+// responseBytes returns a body size: zero for responses without a body,
+// otherwise log-uniform between 200 bytes and 2 MB.
+func responseBytes(rng *rand.Rand, method string, status int) int64 {
+	if method == "HEAD" || status == 204 || status == 304 {
+		return 0
+	}
+	lo, hi := math.Log(200), math.Log(2<<20)
+	return int64(math.Exp(lo + rng.Float64()*(hi-lo)))
+}
+
+// FailWith turns r into a server error with status, as an error_spike
+// scenario does.
+func (r *Request) FailWith(status int) {
+	r.Status = status
+	r.Bytes = int64(len(http.StatusText(status)))
+}
+
+// Level maps the status to a log level: 5xx is error, 4xx is warn and
+// everything else is info.
+func (r *Request) Level() zapcore.Level {
+	switch {
+	case r.Status >= 500:
+		return zapcore.ErrorLevel
+	case r.Status >= 400:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// Common formats r in the Common Log Format:
+//
+//	203.0.113.7 - - [18/Feb/2026:12:00:00 +0000] "GET / HTTP/1.1" 200 512
+func (r *Request) Common() string {
+	var b strings.Builder
+	r.writeCommon(&b)
+	r.writeTrace(&b)
+	return b.String()
+}
+
+// Combined formats r in the Combined Log Format, which adds quoted referer
+// and user agent to the Common Log Format.
+func (r *Request) Combined() string {
+	var b strings.Builder
+	r.writeCommon(&b)
+	fmt.Fprintf(&b, " %q %q", r.Referer, r.UserAgent)
+	r.writeTrace(&b)
+	return b.String()
+}
+
+func (r *Request) writeCommon(b *strings.Builder) {
+	bytes := "-"
+	if r.Bytes > 0 {
+		bytes = strconv.FormatInt(r.Bytes, 10)
+	}
+	fmt.Fprintf(b, "%s - %s [%s] \"%s %s %s\" %d %s",
+		r.RemoteAddr, r.User, r.Time.Format(clfTime),
+		r.Method, r.Path, r.Protocol, r.Status, bytes)
+}
+
+// writeTrace appends the request ID and a W3C traceparent as two more
+// quoted fields, like nginx's $request_id and an added traceparent header.
+func (r *Request) writeTrace(b *strings.Builder) {
+	if r.RequestID == "" {
+		return
+	}
+	fmt.Fprintf(b, " %q %q", r.RequestID, r.Traceparent())
+}
+
+// Traceparent returns the W3C traceparent header value, or "" without
+// trace context.
+func (r *Request) Traceparent() string {
+	if r.TraceID == "" {
+		return ""
+	}
+	return "00-" + r.TraceID + "-" + r.SpanID + "-01"
+}
+
+// Fields returns r as structured record fields for JSON output.
+func (r *Request) Fields() []record.Field {
+	fields := []record.Field{
+		{Key: "remote_addr", Value: r.RemoteAddr},
+		{Key: "remote_user", Value: r.User},
+		{Key: "method", Value: r.Method},
+		{Key: "path", Value: r.Path},
+		{Key: "protocol", Value: r.Protocol},
+		{Key: "status", Value: int64(r.Status)},
+		{Key: "body_bytes_sent", Value: r.Bytes},
+		{Key: "request_time_ms", Value: float64(r.Latency.Microseconds()) / 1000},
+		{Key: "http_referer", Value: r.Referer},
+		{Key: "http_user_agent", Value: r.UserAgent},
+	}
+	if r.RequestID != "" {
+		fields = append(fields,
+			record.Field{Key: "request_id", Value: r.RequestID},
+			record.Field{Key: "trace_id", Value: r.TraceID},
+			record.Field{Key: "span_id", Value: r.SpanID},
+		)
+	}
+	return fields
+}
+
+// Message is the record message in JSON format, e.g. "GET /healthz 200".
+func (r *Request) Message() string {
+	return r.Method + " " + r.Path + " " + strconv.Itoa(r.Status)
+}
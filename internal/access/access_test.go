@@ -0,0 +1,123 @@
+package access
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var testNow = time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)
+
+func fixedRequest() Request {
+	return Request{
+		RemoteAddr: "203.0.113.7",
+		User:       "-",
+		Time:       testNow,
+		Method:     "GET",
+		Path:       "/api/v1/users/42",
+		Protocol:   "HTTP/1.1",
+		Status:     200,
+		Bytes:      512,
+		Latency:    12500 * time.Microsecond,
+		Referer:    "-",
+		UserAgent:  "curl/8.7.1",
+	}
+}
+
+func TestRequest_Formats(t *testing.T) {
+	r := fixedRequest()
+
+	if got, want := r.Common(), `203.0.113.7 - - [18/Feb/2026:12:00:00 +0000] "GET /api/v1/users/42 HTTP/1.1" 200 512`; got != want {
+		t.Errorf("Common() =\n%s\nwant\n%s", got, want)
+	}
+	if got, want := r.Combined(), `203.0.113.7 - - [18/Feb/2026:12:00:00 +0000] "GET /api/v1/users/42 HTTP/1.1" 200 512 "-" "curl/8.7.1"`; got != want {
+		t.Errorf("Combined() =\n%s\nwant\n%s", got, want)
+	}
+
+	r.Bytes = 0
+	r.RequestID = "0123456789abcdef0123456789abcdef"
+	r.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r.SpanID = "00f067aa0ba902b7"
+	want := `203.0.113.7 - - [18/Feb/2026:12:00:00 +0000] "GET /api/v1/users/42 HTTP/1.1" 200 - "0123456789abcdef0123456789abcdef" "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"`
+	if got := r.Common(); got != want {
+		t.Errorf("Common() with trace =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRequest_Fields(t *testing.T) {
+	r := fixedRequest()
+	fields := r.Fields()
+	if len(fields) != 10 {
+		t.Fatalf("got %d fields without trace, want 10", len(fields))
+	}
+	rec := map[string]any{}
+	for _, f := range fields {
+		rec[f.Key] = f.Value
+	}
+	if rec["status"] != int64(200) || rec["request_time_ms"] != 12.5 || rec["body_bytes_sent"] != int64(512) {
+		t.Errorf("Fields() = %v", rec)
+	}
+
+	r.RequestID, r.TraceID, r.SpanID = "r", "t", "s"
+	if got := len(r.Fields()); got != 13 {
+		t.Errorf("got %d fields with trace, want 13", got)
+	}
+	if r.Message() != "GET /api/v1/users/42 200" {
+		t.Errorf("Message() = %q", r.Message())
+	}
+}
+
+func TestRequest_Level(t *testing.T) {
+	tests := []struct {
+		status int
+		want   zapcore.Level
+	}{
+		{200, zapcore.InfoLevel},
+		{304, zapcore.InfoLevel},
+		{404, zapcore.WarnLevel},
+		{503, zapcore.ErrorLevel},
+	}
+	for _, tt := range tests {
+		r := Request{Status: tt.status}
+		if got := r.Level(); got != tt.want {
+			t.Errorf("Level(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	clf := regexp.MustCompile(`^\S+ - \S+ \[[^]]+\] "[A-Z]+ \S+ HTTP/\d\.\d" \d{3} (\d+|-) "[^"]*" "[^"]*" "[0-9a-f]{32}" "00-[0-9a-f]{32}-[0-9a-f]{16}-01"$`)
+
+	a := rand.New(rand.NewPCG(1, 2))
+	b := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 500; i++ {
+		r := NewRequest(a, testNow, true)
+		if line := r.Combined(); !clf.MatchString(line) {
+			t.Fatalf("Combined() = %q does not match the combined format", line)
+		}
+		if r.Latency < MinLatency || r.Latency > MaxLatency {
+			t.Fatalf("latency %v outside [%v, %v]", r.Latency, MinLatency, MaxLatency)
+		}
+		if (r.Status == 304 || r.Status == 204 || r.Method == "HEAD") && r.Bytes != 0 {
+			t.Fatalf("%s %d has %d body bytes, want 0", r.Method, r.Status, r.Bytes)
+		}
+		if other := NewRequest(b, testNow, true); other != r {
+			t.Fatalf("request %d differs with the same seed", i)
+		}
+	}
+
+	if r := NewRequest(a, testNow, false); r.RequestID != "" || r.Traceparent() != "" {
+		t.Errorf("request without trace has trace context %+v", r)
+	}
+}
+
+func TestRequest_FailWith(t *testing.T) {
+	r := fixedRequest()
+	r.FailWith(503)
+	if r.Status != 503 || r.Bytes != int64(len("Service Unavailable")) {
+		t.Errorf("FailWith(503) = status %d bytes %d", r.Status, r.Bytes)
+	}
+}
@@ -0,0 +1,195 @@
+// Package adversarial holds a catalog of records with hostile content,
+// such as backslashes, control characters, invalid UTF-8, duplicate keys
+// and huge numbers, together with the values a correct pipeline stores in
+// ClickHouse. It is used to harden the FluentBit transform before real
+// data reaches it.
+package adversarial
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// Expected holds the otel_logs column values a correct pipeline stores for
+// a case. Count is always the record's count and is not repeated here.
+type Expected struct {
+	Body         string `json:"Body"`
+	SeverityText string `json:"SeverityText"`
+	RandomString string `json:"RandomString"`
+	RandomNumber int64  `json:"RandomNumber"`
+}
+
+// Case is one adversarial record.
+type Case struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// Message and Str become msg and random_string; Number becomes
+	// random_number.
+	Message string `json:"-"`
+	Str     string `json:"-"`
+	Number  int64  `json:"-"`
+
+	// Extra fields are appended after the standard ones.
+	Extra []record.Field `json:"-"`
+
+	// raw, when set, builds a line that zap cannot produce, such as one
+	// with duplicate keys or invalid UTF-8. ts is the zap epoch timestamp.
+	raw func(ts string, seq uint64) string
+
+	Expected Expected `json:"expected"`
+}
+
+// Raw reports whether the case is written as a hand-built line rather
+// than encoded by zap.
+func (c *Case) Raw() bool {
+	return c.raw != nil
+}
+
+// Record builds the record for the case at time t with count seq.
+func (c *Case) Record(t time.Time, seq uint64) record.Record {
+	rec := record.Record{
+		Time:    t,
+		Level:   zapcore.InfoLevel,
+		Message: c.Message,
+		Fields: append([]record.Field{
+			{Key: "count", Value: seq},
+			{Key: "random_number", Value: c.Number},
+			{Key: "random_string", Value: c.Str},
+			{Key: "adversarial_case", Value: c.Name},
+		}, c.Extra...),
+	}
+	if c.raw != nil {
+		rec.Raw = c.raw(epoch(t), seq)
+	}
+	return rec
+}
+
+// epoch formats t like zap's default epoch time encoder.
+func epoch(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', -1, 64)
+}
+
+// LongValueSize is the length of the long_value case, larger than the
+// 16 KiB line limit of the Docker and CRI log drivers.
+const LongValueSize = 64 << 10
+
+// Cases is the catalog, in emission order. Record n (count, from 1) uses
+// Cases[(n-1) % len(Cases)].
+var Cases = buildCases()
+
+func buildCases() []Case {
+	long := strings.Repeat("0123456789abcdef", LongValueSize/16)
+	nested := nest(100)
+
+	cases := []Case{
+		{Name: "backslash", Description: "Backslashes, including sequences that look like escapes",
+			Message: `C:\new\table\x41\u0041 \\server\share`},
+		{Name: "quotes", Description: "Embedded double quotes",
+			Message: `he said "hello" and "goodbye"`},
+		{Name: "trailing_backslash", Description: "Value ending in a backslash, which breaks quote-only escaping",
+			Message: `path ends with \`},
+		{Name: "newline", Description: "Embedded line feeds",
+			Message: "first line\nsecond line\n"},
+		{Name: "crlf_tab", Description: "Carriage returns and tabs",
+			Message: "col1\tcol2\r\nrow2\tvalue"},
+		{Name: "control_chars", Description: "NUL, BEL, ESC colour codes and DEL",
+			Message: "nul\x00bel\x07esc\x1b[31mred\x1b[0m del\x7f"},
+		{Name: "emoji", Description: "Emoji including ZWJ sequences and flags",
+			Message: "deploy 🚀 ok ✅ family 👨‍👩‍👧 flag 🇳🇿"},
+		{Name: "unicode_mixed", Description: "Combining marks, CJK, Arabic and an RTL override",
+			Message: "naïve cafe\u0301 日志 مرحبا \u202eevil\u202c"},
+		{Name: "bom_whitespace", Description: "Byte order mark and non-ASCII whitespace",
+			Message: "\ufeffBOM then nbsp\u00a0em\u2003space "},
+		{Name: "empty", Description: "Empty message and random_string",
+			Message: ""},
+		{Name: "json_in_string", Description: "A JSON document inside msg that naive regex extraction picks up",
+			Message: `{"level":"error","ts":1,"msg":"injected","count":999}`},
+		{Name: "lua_pattern", Description: "Lua pattern and format specials",
+			Message: `%s %d %% [^"]+ .* %1 (capture)`},
+		{Name: "sql_injection", Description: "SQL metacharacters",
+			Message: `'); DROP TABLE otel_logs; -- ` + "`x`"},
+		{Name: "nan_strings", Description: "Strings that look like special floats",
+			Message: "NaN", Str: "-Infinity"},
+		{Name: "long_value", Description: "A 64 KiB value, beyond container runtime line limits",
+			Message: long},
+		{Name: "deeply_nested", Description: "Attribute object nested 100 levels deep",
+			Message: "deeply nested attributes",
+			Extra:   []record.Field{{Key: "nested", Value: nested}}},
+		{Name: "invalid_utf8", Description: "Invalid UTF-8 bytes, written raw; each invalid byte becomes U+FFFD",
+			Message:  "bad \xff\xfe bytes \xc3(",
+			Expected: Expected{Body: "bad \ufffd\ufffd bytes \ufffd("},
+			raw: func(ts string, seq uint64) string {
+				return fmt.Sprintf(`{"level":"info","ts":%s,"msg":"bad %s bytes %s(","count":%d,"random_number":0,"random_string":"","adversarial_case":"invalid_utf8"}`,
+					ts, "\xff\xfe", "\xc3", seq)
+			}},
+		{Name: "duplicate_keys", Description: "Duplicate msg and random_string keys, written raw; the last value wins",
+			Message: "second", Str: "second",
+			Expected: Expected{Body: "second", RandomString: "second"},
+			raw: func(ts string, seq uint64) string {
+				return fmt.Sprintf(`{"level":"info","ts":%s,"msg":"first","msg":"second","count":%d,"random_number":0,"random_string":"first","random_string":"second","adversarial_case":"duplicate_keys"}`,
+					ts, seq)
+			}},
+		{Name: "huge_number", Description: "random_number beyond uint64, written raw; it does not fit Int32 and must not wrap",
+			Message:  "huge number",
+			Expected: Expected{Body: "huge number"},
+			raw: func(ts string, seq uint64) string {
+				return fmt.Sprintf(`{"level":"info","ts":%s,"msg":"huge number","count":%d,"random_number":123456789012345678901234567890,"random_string":"","adversarial_case":"huge_number"}`,
+					ts, seq)
+			}},
+	}
+
+	for i := range cases {
+		c := &cases[i]
+		if c.raw != nil {
+			// Raw cases spell out what the pipeline should make of them.
+			c.Expected.SeverityText = "INFO"
+			continue
+		}
+		if c.Str == "" {
+			c.Str = c.Message
+		}
+		c.Expected = Expected{Body: c.Message, SeverityText: "INFO", RandomString: c.Str, RandomNumber: c.Number}
+	}
+	return cases
+}
+
+// nest builds an object nested depth levels deep: {"l":{"l":...{"leaf":true}}}.
+func nest(depth int) []record.Field {
+	obj := []record.Field{{Key: "leaf", Value: true}}
+	for i := 1; i < depth; i++ {
+		obj = []record.Field{{Key: "l", Value: obj}}
+	}
+	return obj
+}
+
+// For returns the case for count seq (from 1).
+func For(seq uint64) *Case {
+	return &Cases[(seq-1)%uint64(len(Cases))]
+}
+
+// WriteCatalog writes the catalog as JSON Lines, one case per line with
+// its index, so a verifier can match otel_logs rows by Count.
+func WriteCatalog(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for i := range Cases {
+		c := &Cases[i]
+		if err := enc.Encode(struct {
+			Index int `json:"index"`
+			*Case
+			Raw bool `json:"raw"`
+		}{i, c, c.Raw()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
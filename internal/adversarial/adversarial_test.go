@@ -0,0 +1,143 @@
+package adversarial
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var testNow = time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)
+
+// encode renders the case's record as loggen writes it: raw cases
+// verbatim, the rest through zap's production JSON encoder.
+func encode(t *testing.T, c *Case, seq uint64) []byte {
+	t.Helper()
+	rec := c.Record(testNow, seq)
+	if rec.Raw != "" {
+		return []byte(rec.Raw)
+	}
+
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	buf, err := enc.EncodeEntry(zapcore.Entry{Level: rec.Level, Time: rec.Time, Message: rec.Message}, rec.ZapFields())
+	if err != nil {
+		t.Fatalf("EncodeEntry() error = %v", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// TestCases_RoundTrip decodes every case with a strict JSON parser and
+// checks it yields the expected ClickHouse values.
+func TestCases_RoundTrip(t *testing.T) {
+	for i := range Cases {
+		c := &Cases[i]
+		t.Run(c.Name, func(t *testing.T) {
+			seq := uint64(i + 1)
+			line := encode(t, c, seq)
+			if bytes.ContainsAny(line, "\n\r") {
+				t.Fatalf("encoded line contains a raw line break: %q", line)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(line, &got); err != nil {
+				t.Fatalf("line is not valid JSON: %v\n%q", err, line)
+			}
+			if got["msg"] != c.Expected.Body {
+				t.Errorf("msg = %q, want Body %q", got["msg"], c.Expected.Body)
+			}
+			if got["random_string"] != c.Expected.RandomString {
+				t.Errorf("random_string = %q, want RandomString %q", got["random_string"], c.Expected.RandomString)
+			}
+			if got["count"] != float64(seq) {
+				t.Errorf("count = %v, want %d", got["count"], seq)
+			}
+			if got["level"] != "info" || c.Expected.SeverityText != "INFO" {
+				t.Errorf("level = %v, expected SeverityText %q", got["level"], c.Expected.SeverityText)
+			}
+
+			n, _ := got["random_number"].(float64)
+			if n > math.MaxInt32 {
+				if c.Expected.RandomNumber != 0 {
+					t.Errorf("out-of-range random_number expects %d, want 0", c.Expected.RandomNumber)
+				}
+			} else if int64(n) != c.Expected.RandomNumber {
+				t.Errorf("random_number = %v, want %d", n, c.Expected.RandomNumber)
+			}
+		})
+	}
+}
+
+func TestCases_Content(t *testing.T) {
+	byName := make(map[string]*Case)
+	for i := range Cases {
+		if byName[Cases[i].Name] != nil {
+			t.Errorf("duplicate case %q", Cases[i].Name)
+		}
+		byName[Cases[i].Name] = &Cases[i]
+	}
+
+	if got := len(byName["long_value"].Expected.Body); got != LongValueSize {
+		t.Errorf("long_value length = %d, want %d", got, LongValueSize)
+	}
+
+	line := encode(t, byName["deeply_nested"], 1)
+	var rec struct {
+		Nested map[string]any `json:"nested"`
+	}
+	if err := json.Unmarshal(line, &rec); err != nil {
+		t.Fatalf("deeply_nested: %v", err)
+	}
+	depth := 1
+	for m := rec.Nested; m["l"] != nil; m = m["l"].(map[string]any) {
+		depth++
+	}
+	if depth != 100 {
+		t.Errorf("deeply_nested depth = %d, want 100", depth)
+	}
+
+	for _, name := range []string{"invalid_utf8", "duplicate_keys", "huge_number"} {
+		if !byName[name].Raw() {
+			t.Errorf("%s is not a raw case", name)
+		}
+	}
+}
+
+func TestFor_Cycles(t *testing.T) {
+	if For(1) != &Cases[0] || For(uint64(len(Cases))) != &Cases[len(Cases)-1] || For(uint64(len(Cases))+1) != &Cases[0] {
+		t.Error("For() does not cycle through the catalog in order")
+	}
+}
+
+func TestWriteCatalog(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCatalog(&buf); err != nil {
+		t.Fatalf("WriteCatalog() error = %v", err)
+	}
+
+	sc := bufio.NewScanner(&buf)
+	sc.Buffer(nil, 1<<20)
+	n := 0
+	for sc.Scan() {
+		var entry struct {
+			Index    int      `json:"index"`
+			Name     string   `json:"name"`
+			Raw      bool     `json:"raw"`
+			Expected Expected `json:"expected"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &entry); err != nil {
+			t.Fatalf("catalog line %d: %v", n, err)
+		}
+		if entry.Index != n || entry.Name != Cases[n].Name || entry.Expected != Cases[n].Expected {
+			t.Errorf("catalog line %d = %+v", n, entry)
+		}
+		n++
+	}
+	if n != len(Cases) {
+		t.Errorf("catalog has %d lines, want %d", n, len(Cases))
+	}
+}
@@ -3,9 +3,15 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"strconv"
 	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/logging"
 )
 
 // Config holds all application configuration.
@@ -21,6 +27,108 @@ type Config struct {
 
 	// HealthPort is the port for health check endpoints.
 	HealthPort int
+
+	// HealthAddr overrides HealthPort with a full bind address, either
+	// "host:port" or "unix:/path/to.sock".
+	HealthAddr string
+
+	// HealthTLSCert and HealthTLSKey enable HTTPS on the health server.
+	// The files are re-read when they change on disk.
+	HealthTLSCert string
+	HealthTLSKey  string
+
+	// HealthTLSClientCA requires clients to present a certificate signed
+	// by one of the CAs in this PEM file.
+	HealthTLSClientCA string
+
+	// AdminTokenFile lists bearer tokens for /admin/ endpoints, one
+	// "token,user,role" entry per line.
+	AdminTokenFile string
+
+	// AdminBasicAuthFile lists basic auth users for /admin/ endpoints, one
+	// "user:<hash>:role" entry per line, where <hash> is a salted PBKDF2
+	// hash printed by -hash-password.
+	AdminBasicAuthFile string
+
+	// LogLevel is the initial level of the operational logger. Generated
+	// records are not affected by it.
+	LogLevel string
+
+	// LogEncoding is the operational log format: json, console or logfmt.
+	LogEncoding string
+
+	// LogOutput is where operational logs go: stderr, stdout or a file path.
+	LogOutput string
+
+	// LevelWeights is the weighted mix of levels for generated records.
+	// Empty means every record is logged at info.
+	LevelWeights LevelWeights
+
+	// LevelMessages overrides the message template for individual levels.
+	LevelMessages LevelMessages
+
+	// ConfigFile is an optional JSON file with structured settings such
+	// as scenarios. See File.
+	ConfigFile string
+
+	// Scenarios are scheduled anomalies loaded from ConfigFile.
+	Scenarios []Scenario
+
+	// Schema declares custom record fields loaded from ConfigFile. Nil
+	// keeps the built-in count, random_number and random_string record.
+	Schema *Schema
+
+	// Sinks are the outputs loaded from ConfigFile. Empty means stdout
+	// only, through StdoutSink.
+	Sinks []Sink
+
+	// StdoutQueue is the queue of the stdout output used when there are
+	// no Sinks.
+	StdoutQueue Queue
+
+	// Seed makes the generated stream reproducible. Zero picks a seed
+	// from the current time.
+	Seed uint64
+
+	// TruthFile, when set, receives a JSON Lines ground-truth manifest of
+	// everything generated.
+	TruthFile string
+
+	// TruthWindow is the aggregation window of the ground-truth manifest.
+	TruthWindow time.Duration
+
+	// Mode selects the kind of records generated.
+	Mode Mode
+
+	// AccessLogFormat is the line format in access_log mode.
+	AccessLogFormat AccessLogFormat
+
+	// AccessLogTrace adds a request ID and W3C trace context to every
+	// access log line.
+	AccessLogTrace bool
+
+	// StackTraceRate is the fraction of records replaced by a multi-line
+	// stack trace in records mode. Zero disables traces.
+	StackTraceRate float64
+
+	// StackTraceLanguages selects the trace styles to emit. Empty means
+	// all of them.
+	StackTraceLanguages StackTraceLanguages
+
+	// AdversarialCatalog prints the adversarial case catalog and exits.
+	AdversarialCatalog bool
+
+	// HashPassword reads a password from stdin, prints its hash for the
+	// basic auth file and exits.
+	HashPassword bool
+
+	// RecordSize pads records to a target size distribution. The zero
+	// value leaves records unpadded.
+	RecordSize RecordSize
+
+	// OutputFormat is the encoding of generated records on stdout: json,
+	// logfmt, plain, cef, gelf or otlp_json.
+	OutputFormat string
 }
 
 // Default values.
@@ -29,12 +137,23 @@ const (
 	DefaultNumStrings    = 10
 	DefaultSleepDuration = 5 * time.Second
 	DefaultHealthPort    = 8081
+	DefaultLogLevel      = "info"
+	DefaultLogEncoding   = logging.EncodingJSON
+	DefaultLogOutput     = logging.OutputStderr
+	DefaultTruthWindow   = time.Minute
+	DefaultOutputFormat  = encode.FormatJSON
+
+	DefaultMode            = ModeRecords
+	DefaultAccessLogFormat = AccessLogCombined
 )
 
 // Load parses configuration from flags and environment variables.
 // Environment variables override CLI flag defaults.
 func Load() *Config {
-	cfg := &Config{}
+	cfg := &Config{
+		Mode:            DefaultMode,
+		AccessLogFormat: DefaultAccessLogFormat,
+	}
 
 	flag.IntVar(&cfg.MaxNumber, "max-number", DefaultMaxNumber,
 		"Maximum random number (env: LOGGEN_MAX_NUMBER)")
@@ -44,6 +163,58 @@ func Load() *Config {
 		"Duration between log emissions (env: LOGGEN_SLEEP_DURATION)")
 	flag.IntVar(&cfg.HealthPort, "health-port", DefaultHealthPort,
 		"Port for health check server (env: LOGGEN_HEALTH_PORT)")
+	flag.StringVar(&cfg.HealthAddr, "health-addr", "",
+		"Health server bind address, host:port or unix:/path; overrides -health-port (env: LOGGEN_HEALTH_ADDR)")
+	flag.StringVar(&cfg.HealthTLSCert, "health-tls-cert", "",
+		"PEM certificate file enabling TLS on the health server (env: LOGGEN_HEALTH_TLS_CERT)")
+	flag.StringVar(&cfg.HealthTLSKey, "health-tls-key", "",
+		"PEM private key file for -health-tls-cert (env: LOGGEN_HEALTH_TLS_KEY)")
+	flag.StringVar(&cfg.HealthTLSClientCA, "health-tls-client-ca", "",
+		"PEM CA bundle used to verify client certificates (env: LOGGEN_HEALTH_TLS_CLIENT_CA)")
+	flag.StringVar(&cfg.AdminTokenFile, "admin-token-file", "",
+		"File of token,user,role bearer tokens for /admin/ endpoints (env: LOGGEN_ADMIN_TOKEN_FILE)")
+	flag.StringVar(&cfg.AdminBasicAuthFile, "admin-basic-auth-file", "",
+		"File of user:<hash>:role basic auth users for /admin/ endpoints; make <hash> with -hash-password (env: LOGGEN_ADMIN_BASIC_AUTH_FILE)")
+	flag.StringVar(&cfg.LogLevel, "log-level", DefaultLogLevel,
+		"Operational log level: debug, info, warn, error (env: LOGGEN_LOG_LEVEL)")
+	flag.StringVar(&cfg.LogEncoding, "log-encoding", DefaultLogEncoding,
+		"Operational log encoding: json, console, logfmt (env: LOGGEN_LOG_ENCODING)")
+	flag.StringVar(&cfg.LogOutput, "log-output", DefaultLogOutput,
+		"Operational log destination: stderr, stdout or a file path (env: LOGGEN_LOG_OUTPUT)")
+	flag.StringVar(&cfg.ConfigFile, "config-file", "",
+		"JSON file with structured settings such as scenarios (env: LOGGEN_CONFIG_FILE)")
+	flag.Uint64Var(&cfg.Seed, "seed", 0,
+		"Random seed for a reproducible stream, 0 for time-based (env: LOGGEN_SEED)")
+	flag.StringVar(&cfg.TruthFile, "truth-file", "",
+		"Write a JSON Lines ground-truth manifest to this path (env: LOGGEN_TRUTH_FILE)")
+	flag.DurationVar(&cfg.TruthWindow, "truth-window", DefaultTruthWindow,
+		"Aggregation window of the ground-truth manifest (env: LOGGEN_TRUTH_WINDOW)")
+	flag.Var(&cfg.LevelWeights, "level-weights",
+		"Weighted level mix for generated records, e.g. info=90,warn=7,error=2,debug=1 (env: LOGGEN_LEVEL_WEIGHTS)")
+	flag.Var(&cfg.LevelMessages, "level-messages",
+		"Per-level message templates, e.g. 'error=tick failed for {{.RandomString}};warn=slow' (env: LOGGEN_LEVEL_MESSAGES)")
+	flag.Var(&cfg.Mode, "mode",
+		"Generator mode: records, access_log or adversarial (env: LOGGEN_MODE)")
+	flag.Var(&cfg.AccessLogFormat, "access-log-format",
+		"Access log line format: common, combined or json (env: LOGGEN_ACCESS_LOG_FORMAT)")
+	flag.BoolVar(&cfg.AccessLogTrace, "access-log-trace", false,
+		"Add request IDs and trace context to access log lines (env: LOGGEN_ACCESS_LOG_TRACE)")
+	flag.Float64Var(&cfg.StackTraceRate, "stack-trace-rate", 0,
+		"Fraction of records replaced by multi-line stack traces, 0 to 1 (env: LOGGEN_STACK_TRACE_RATE)")
+	flag.Var(&cfg.StackTraceLanguages, "stack-trace-languages",
+		"Stack trace styles to emit: java,python,go (env: LOGGEN_STACK_TRACE_LANGUAGES)")
+	flag.Var(&cfg.RecordSize, "record-size",
+		"Pad records to a size distribution: fixed:1k, uniform:512-4k or longtail:256-1m (env: LOGGEN_RECORD_SIZE)")
+	flag.StringVar(&cfg.OutputFormat, "output-format", DefaultOutputFormat,
+		"Generated record format: json, logfmt, plain, cef, gelf or otlp_json (env: LOGGEN_OUTPUT_FORMAT)")
+	flag.IntVar(&cfg.StdoutQueue.Size, "stdout-queue-size", DefaultQueueSize,
+		"Records queued for stdout when no sinks are configured (env: LOGGEN_STDOUT_QUEUE_SIZE)")
+	flag.StringVar(&cfg.StdoutQueue.Overflow, "stdout-overflow", OverflowDropNewest,
+		"Stdout queue overflow policy: block, drop_newest, drop_oldest or sample (env: LOGGEN_STDOUT_OVERFLOW)")
+	flag.BoolVar(&cfg.AdversarialCatalog, "adversarial-catalog", false,
+		"Print the adversarial case catalog with expected ClickHouse values as JSON Lines and exit")
+	flag.BoolVar(&cfg.HashPassword, "hash-password", false,
+		"Read a password from stdin, print its hash for the admin basic auth file and exit")
 
 	flag.Parse()
 
@@ -60,11 +231,39 @@ func LoadWithDefaults() *Config {
 		NumStrings:    DefaultNumStrings,
 		SleepDuration: DefaultSleepDuration,
 		HealthPort:    DefaultHealthPort,
+		LogLevel:      DefaultLogLevel,
+		LogEncoding:   DefaultLogEncoding,
+		LogOutput:     DefaultLogOutput,
+		TruthWindow:   DefaultTruthWindow,
+		OutputFormat:  DefaultOutputFormat,
+		StdoutQueue:   Queue{Size: DefaultQueueSize, Overflow: OverflowDropNewest},
+
+		Mode:            DefaultMode,
+		AccessLogFormat: DefaultAccessLogFormat,
 	}
 	cfg.applyEnvOverrides()
 	return cfg
 }
 
+// HealthListenAddr returns the address the health server should bind to.
+func (c *Config) HealthListenAddr() string {
+	if c.HealthAddr != "" {
+		return c.HealthAddr
+	}
+	return fmt.Sprintf(":%d", c.HealthPort)
+}
+
+// StdoutSink returns the sink generated records go to when there are no
+// Sinks: stdout in OutputFormat, queued by StdoutQueue.
+func (c *Config) StdoutSink() (Sink, error) {
+	queue := c.StdoutQueue
+	s := Sink{Name: SinkStdout, Type: SinkStdout, Format: c.OutputFormat, Queue: &queue}
+	if err := s.validate(); err != nil {
+		return Sink{}, err
+	}
+	return s, nil
+}
+
 func (c *Config) applyEnvOverrides() {
 	if v := os.Getenv("LOGGEN_MAX_NUMBER"); v != "" {
 		if i, err := strconv.Atoi(v); err == nil && i >= 0 {
@@ -89,4 +288,127 @@ func (c *Config) applyEnvOverrides() {
 			c.HealthPort = i
 		}
 	}
+
+	if v := os.Getenv("LOGGEN_HEALTH_ADDR"); v != "" {
+		c.HealthAddr = v
+	}
+
+	if v := os.Getenv("LOGGEN_HEALTH_TLS_CERT"); v != "" {
+		c.HealthTLSCert = v
+	}
+
+	if v := os.Getenv("LOGGEN_HEALTH_TLS_KEY"); v != "" {
+		c.HealthTLSKey = v
+	}
+
+	if v := os.Getenv("LOGGEN_HEALTH_TLS_CLIENT_CA"); v != "" {
+		c.HealthTLSClientCA = v
+	}
+
+	if v := os.Getenv("LOGGEN_ADMIN_TOKEN_FILE"); v != "" {
+		c.AdminTokenFile = v
+	}
+
+	if v := os.Getenv("LOGGEN_ADMIN_BASIC_AUTH_FILE"); v != "" {
+		c.AdminBasicAuthFile = v
+	}
+
+	if v := os.Getenv("LOGGEN_LOG_LEVEL"); v != "" {
+		if _, err := zapcore.ParseLevel(v); err == nil {
+			c.LogLevel = v
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_LOG_ENCODING"); v != "" {
+		if logging.ValidEncoding(v) {
+			c.LogEncoding = v
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_OUTPUT_FORMAT"); v != "" {
+		if encode.Valid(v) {
+			c.OutputFormat = v
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_STDOUT_QUEUE_SIZE"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			c.StdoutQueue.Size = i
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_STDOUT_OVERFLOW"); v != "" {
+		switch v {
+		case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowSample:
+			c.StdoutQueue.Overflow = v
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_LOG_OUTPUT"); v != "" {
+		c.LogOutput = v
+	}
+
+	if v := os.Getenv("LOGGEN_CONFIG_FILE"); v != "" {
+		c.ConfigFile = v
+	}
+
+	if v := os.Getenv("LOGGEN_SEED"); v != "" {
+		if i, err := strconv.ParseUint(v, 10, 64); err == nil {
+			c.Seed = i
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_TRUTH_FILE"); v != "" {
+		c.TruthFile = v
+	}
+
+	if v := os.Getenv("LOGGEN_TRUTH_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.TruthWindow = d
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_LEVEL_WEIGHTS"); v != "" {
+		if w, err := ParseLevelWeights(v); err == nil {
+			c.LevelWeights = w
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_LEVEL_MESSAGES"); v != "" {
+		if m, err := ParseLevelMessages(v); err == nil {
+			c.LevelMessages = m
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_MODE"); v != "" {
+		_ = c.Mode.Set(v)
+	}
+
+	if v := os.Getenv("LOGGEN_ACCESS_LOG_FORMAT"); v != "" {
+		_ = c.AccessLogFormat.Set(v)
+	}
+
+	if v := os.Getenv("LOGGEN_ACCESS_LOG_TRACE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.AccessLogTrace = b
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_STACK_TRACE_RATE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			c.StackTraceRate = f
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_RECORD_SIZE"); v != "" {
+		if rs, err := ParseRecordSize(v); err == nil {
+			c.RecordSize = rs
+		}
+	}
+
+	if v := os.Getenv("LOGGEN_STACK_TRACE_LANGUAGES"); v != "" {
+		if l, err := ParseStackTraceLanguages(v); err == nil {
+			c.StackTraceLanguages = l
+		}
+	}
 }
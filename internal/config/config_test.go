@@ -100,6 +100,251 @@ func TestEnvOverrides(t *testing.T) {
 			check:    func(c *Config) bool { return c.HealthPort == DefaultHealthPort },
 			desc:     "HealthPort should remain default for out of range",
 		},
+		{
+			name:     "health addr override",
+			envKey:   "LOGGEN_HEALTH_ADDR",
+			envValue: "unix:/run/loggen.sock",
+			check:    func(c *Config) bool { return c.HealthAddr == "unix:/run/loggen.sock" },
+			desc:     "HealthAddr should be unix:/run/loggen.sock",
+		},
+		{
+			name:     "health tls cert override",
+			envKey:   "LOGGEN_HEALTH_TLS_CERT",
+			envValue: "/etc/loggen/tls.crt",
+			check:    func(c *Config) bool { return c.HealthTLSCert == "/etc/loggen/tls.crt" },
+			desc:     "HealthTLSCert should be /etc/loggen/tls.crt",
+		},
+		{
+			name:     "health tls key override",
+			envKey:   "LOGGEN_HEALTH_TLS_KEY",
+			envValue: "/etc/loggen/tls.key",
+			check:    func(c *Config) bool { return c.HealthTLSKey == "/etc/loggen/tls.key" },
+			desc:     "HealthTLSKey should be /etc/loggen/tls.key",
+		},
+		{
+			name:     "health tls client ca override",
+			envKey:   "LOGGEN_HEALTH_TLS_CLIENT_CA",
+			envValue: "/etc/loggen/ca.crt",
+			check:    func(c *Config) bool { return c.HealthTLSClientCA == "/etc/loggen/ca.crt" },
+			desc:     "HealthTLSClientCA should be /etc/loggen/ca.crt",
+		},
+		{
+			name:     "admin token file override",
+			envKey:   "LOGGEN_ADMIN_TOKEN_FILE",
+			envValue: "/etc/loggen/tokens.csv",
+			check:    func(c *Config) bool { return c.AdminTokenFile == "/etc/loggen/tokens.csv" },
+			desc:     "AdminTokenFile should be /etc/loggen/tokens.csv",
+		},
+		{
+			name:     "admin basic auth file override",
+			envKey:   "LOGGEN_ADMIN_BASIC_AUTH_FILE",
+			envValue: "/etc/loggen/users",
+			check:    func(c *Config) bool { return c.AdminBasicAuthFile == "/etc/loggen/users" },
+			desc:     "AdminBasicAuthFile should be /etc/loggen/users",
+		},
+		{
+			name:     "log level override",
+			envKey:   "LOGGEN_LOG_LEVEL",
+			envValue: "debug",
+			check:    func(c *Config) bool { return c.LogLevel == "debug" },
+			desc:     "LogLevel should be debug",
+		},
+		{
+			name:     "invalid log level ignored",
+			envKey:   "LOGGEN_LOG_LEVEL",
+			envValue: "loud",
+			check:    func(c *Config) bool { return c.LogLevel == DefaultLogLevel },
+			desc:     "LogLevel should remain default",
+		},
+		{
+			name:     "log encoding override",
+			envKey:   "LOGGEN_LOG_ENCODING",
+			envValue: "logfmt",
+			check:    func(c *Config) bool { return c.LogEncoding == "logfmt" },
+			desc:     "LogEncoding should be logfmt",
+		},
+		{
+			name:     "invalid log encoding ignored",
+			envKey:   "LOGGEN_LOG_ENCODING",
+			envValue: "xml",
+			check:    func(c *Config) bool { return c.LogEncoding == DefaultLogEncoding },
+			desc:     "LogEncoding should remain default",
+		},
+		{
+			name:     "log output override",
+			envKey:   "LOGGEN_LOG_OUTPUT",
+			envValue: "stdout",
+			check:    func(c *Config) bool { return c.LogOutput == "stdout" },
+			desc:     "LogOutput should be stdout",
+		},
+		{
+			name:     "config file override",
+			envKey:   "LOGGEN_CONFIG_FILE",
+			envValue: "/etc/loggen/loggen.json",
+			check:    func(c *Config) bool { return c.ConfigFile == "/etc/loggen/loggen.json" },
+			desc:     "ConfigFile should be /etc/loggen/loggen.json",
+		},
+		{
+			name:     "seed override",
+			envKey:   "LOGGEN_SEED",
+			envValue: "42",
+			check:    func(c *Config) bool { return c.Seed == 42 },
+			desc:     "Seed should be 42",
+		},
+		{
+			name:     "invalid seed ignored",
+			envKey:   "LOGGEN_SEED",
+			envValue: "-1",
+			check:    func(c *Config) bool { return c.Seed == 0 },
+			desc:     "Seed should remain 0",
+		},
+		{
+			name:     "truth file override",
+			envKey:   "LOGGEN_TRUTH_FILE",
+			envValue: "/tmp/truth.jsonl",
+			check:    func(c *Config) bool { return c.TruthFile == "/tmp/truth.jsonl" },
+			desc:     "TruthFile should be /tmp/truth.jsonl",
+		},
+		{
+			name:     "truth window override",
+			envKey:   "LOGGEN_TRUTH_WINDOW",
+			envValue: "10s",
+			check:    func(c *Config) bool { return c.TruthWindow == 10*time.Second },
+			desc:     "TruthWindow should be 10s",
+		},
+		{
+			name:     "invalid truth window ignored",
+			envKey:   "LOGGEN_TRUTH_WINDOW",
+			envValue: "0s",
+			check:    func(c *Config) bool { return c.TruthWindow == DefaultTruthWindow },
+			desc:     "TruthWindow should remain default",
+		},
+		{
+			name:     "level weights override",
+			envKey:   "LOGGEN_LEVEL_WEIGHTS",
+			envValue: "info=9,error=1",
+			check:    func(c *Config) bool { return c.LevelWeights.String() == "info=9,error=1" },
+			desc:     "LevelWeights should be info=9,error=1",
+		},
+		{
+			name:     "invalid level weights ignored",
+			envKey:   "LOGGEN_LEVEL_WEIGHTS",
+			envValue: "info=x",
+			check:    func(c *Config) bool { return c.LevelWeights == nil },
+			desc:     "LevelWeights should remain empty",
+		},
+		{
+			name:     "level messages override",
+			envKey:   "LOGGEN_LEVEL_MESSAGES",
+			envValue: "error=boom {{.Count}}",
+			check:    func(c *Config) bool { return c.LevelMessages.String() == "error=boom {{.Count}}" },
+			desc:     "LevelMessages should contain the error template",
+		},
+		{
+			name:     "mode override",
+			envKey:   "LOGGEN_MODE",
+			envValue: "access_log",
+			check:    func(c *Config) bool { return c.Mode == ModeAccessLog },
+			desc:     "Mode should be access_log",
+		},
+		{
+			name:     "invalid mode ignored",
+			envKey:   "LOGGEN_MODE",
+			envValue: "chaos",
+			check:    func(c *Config) bool { return c.Mode == DefaultMode },
+			desc:     "Mode should remain default",
+		},
+		{
+			name:     "access log format override",
+			envKey:   "LOGGEN_ACCESS_LOG_FORMAT",
+			envValue: "json",
+			check:    func(c *Config) bool { return c.AccessLogFormat == AccessLogJSON },
+			desc:     "AccessLogFormat should be json",
+		},
+		{
+			name:     "access log trace override",
+			envKey:   "LOGGEN_ACCESS_LOG_TRACE",
+			envValue: "true",
+			check:    func(c *Config) bool { return c.AccessLogTrace },
+			desc:     "AccessLogTrace should be true",
+		},
+		{
+			name:     "stack trace rate override",
+			envKey:   "LOGGEN_STACK_TRACE_RATE",
+			envValue: "0.25",
+			check:    func(c *Config) bool { return c.StackTraceRate == 0.25 },
+			desc:     "StackTraceRate should be 0.25",
+		},
+		{
+			name:     "invalid stack trace rate ignored",
+			envKey:   "LOGGEN_STACK_TRACE_RATE",
+			envValue: "2",
+			check:    func(c *Config) bool { return c.StackTraceRate == 0 },
+			desc:     "StackTraceRate should remain 0",
+		},
+		{
+			name:     "output format override",
+			envKey:   "LOGGEN_OUTPUT_FORMAT",
+			envValue: "gelf",
+			check:    func(c *Config) bool { return c.OutputFormat == "gelf" },
+			desc:     "OutputFormat should be gelf",
+		},
+		{
+			name:     "invalid output format ignored",
+			envKey:   "LOGGEN_OUTPUT_FORMAT",
+			envValue: "xml",
+			check:    func(c *Config) bool { return c.OutputFormat == DefaultOutputFormat },
+			desc:     "OutputFormat should remain json",
+		},
+		{
+			name:     "stdout queue size override",
+			envKey:   "LOGGEN_STDOUT_QUEUE_SIZE",
+			envValue: "64",
+			check:    func(c *Config) bool { return c.StdoutQueue.Size == 64 },
+			desc:     "StdoutQueue.Size should be 64",
+		},
+		{
+			name:     "invalid stdout queue size ignored",
+			envKey:   "LOGGEN_STDOUT_QUEUE_SIZE",
+			envValue: "0",
+			check:    func(c *Config) bool { return c.StdoutQueue.Size == DefaultQueueSize },
+			desc:     "StdoutQueue.Size should remain 1024",
+		},
+		{
+			name:     "stdout overflow override",
+			envKey:   "LOGGEN_STDOUT_OVERFLOW",
+			envValue: "block",
+			check:    func(c *Config) bool { return c.StdoutQueue.Overflow == OverflowBlock },
+			desc:     "StdoutQueue.Overflow should be block",
+		},
+		{
+			name:     "invalid stdout overflow ignored",
+			envKey:   "LOGGEN_STDOUT_OVERFLOW",
+			envValue: "spill",
+			check:    func(c *Config) bool { return c.StdoutQueue.Overflow == OverflowDropNewest },
+			desc:     "StdoutQueue.Overflow should remain drop_newest",
+		},
+		{
+			name:     "record size override",
+			envKey:   "LOGGEN_RECORD_SIZE",
+			envValue: "uniform:1k-2k",
+			check:    func(c *Config) bool { return c.RecordSize == RecordSize{SizeUniform, 1024, 2048} },
+			desc:     "RecordSize should be uniform:1024-2048",
+		},
+		{
+			name:     "invalid record size ignored",
+			envKey:   "LOGGEN_RECORD_SIZE",
+			envValue: "huge",
+			check:    func(c *Config) bool { return !c.RecordSize.Enabled() },
+			desc:     "RecordSize should remain disabled",
+		},
+		{
+			name:     "stack trace languages override",
+			envKey:   "LOGGEN_STACK_TRACE_LANGUAGES",
+			envValue: "python, go",
+			check:    func(c *Config) bool { return c.StackTraceLanguages.String() == "python,go" },
+			desc:     "StackTraceLanguages should be python,go",
+		},
 	}
 
 	for _, tt := range tests {
@@ -122,3 +367,60 @@ func TestEnvOverrides(t *testing.T) {
 		})
 	}
 }
+
+func TestHealthListenAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"port only", Config{HealthPort: 8081}, ":8081"},
+		{"explicit addr", Config{HealthPort: 8081, HealthAddr: "127.0.0.1:9000"}, "127.0.0.1:9000"},
+		{"unix socket", Config{HealthPort: 8081, HealthAddr: "unix:/tmp/h.sock"}, "unix:/tmp/h.sock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.HealthListenAddr(); got != tt.want {
+				t.Errorf("HealthListenAddr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStdoutSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		queue   Queue
+		want    Queue
+		wantErr bool
+	}{
+		{"defaults", Queue{Size: DefaultQueueSize, Overflow: OverflowDropNewest}, Queue{Size: DefaultQueueSize, Overflow: OverflowDropNewest}, false},
+		{"block", Queue{Size: 10, Overflow: OverflowBlock}, Queue{Size: 10, Overflow: OverflowBlock}, false},
+		{"sample default", Queue{Size: 10, Overflow: OverflowSample}, Queue{Size: 10, Overflow: OverflowSample, Sample: DefaultQueueSample}, false},
+		{"bad overflow", Queue{Size: 10, Overflow: "spill"}, Queue{}, true},
+		{"negative size", Queue{Size: -1, Overflow: OverflowBlock}, Queue{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{OutputFormat: "logfmt", StdoutQueue: tt.queue}
+			s, err := cfg.StdoutSink()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("StdoutSink() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StdoutSink() error = %v", err)
+			}
+			if s.Type != SinkStdout || s.Format != "logfmt" {
+				t.Errorf("StdoutSink() = %s in %s, want stdout in logfmt", s.Type, s.Format)
+			}
+			if *s.Queue != tt.want {
+				t.Errorf("StdoutSink() queue = %+v, want %+v", *s.Queue, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Duration is a time.Duration that reads and writes as a Go duration
+// string ("90s", "5m") in the config file.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// File is the structure of the JSON config file. It holds the settings
+// that are too structured for flags; scalar settings stay on flags and
+// environment variables.
+type File struct {
+	Scenarios []Scenario `json:"scenarios,omitempty"`
+	Schema    *Schema    `json:"schema,omitempty"`
+	Sinks     []Sink     `json:"sinks,omitempty"`
+}
+
+// ApplyFile loads c.ConfigFile, if set, and copies its sections into c.
+func (c *Config) ApplyFile() error {
+	if c.ConfigFile == "" {
+		return nil
+	}
+
+	f, err := LoadFile(c.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	c.Scenarios = f.Scenarios
+	c.Schema = f.Schema
+	c.Sinks = f.Sinks
+	return nil
+}
+
+// LoadFile reads and validates a JSON config file. Unknown fields are
+// rejected so typos do not silently disable a section.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	return ParseFile(data)
+}
+
+// ParseFile decodes and validates config file contents.
+func ParseFile(data []byte) (*File, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var f File
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	return &f, nil
+}
+
+func (f *File) validate() error {
+	names := make(map[string]bool)
+	for i := range f.Scenarios {
+		s := &f.Scenarios[i]
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("scenarios[%d]: %w", i, err)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("scenarios[%d]: duplicate name %q", i, s.Name)
+		}
+		names[s.Name] = true
+	}
+	if f.Schema != nil {
+		if err := f.Schema.validate(); err != nil {
+			return err
+		}
+	}
+	sinks := make(map[string]bool)
+	for i := range f.Sinks {
+		s := &f.Sinks[i]
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		if sinks[s.Name] {
+			return fmt.Errorf("sinks[%d]: duplicate name %q", i, s.Name)
+		}
+		sinks[s.Name] = true
+	}
+	return nil
+}
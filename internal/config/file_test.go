@@ -0,0 +1,196 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFile_Scenarios(t *testing.T) {
+	data := []byte(`{
+		"scenarios": [
+			{"name": "errors", "type": "error_spike", "start": "2m", "duration": "5m", "intensity": 0.5},
+			{"name": "slow", "type": "latency_outlier", "start": "10m", "duration": "1m"},
+			{"name": "hot", "type": "dominant_string", "start": "0s", "duration": "30s", "value": "alpha"},
+			{"name": "outage", "type": "silence", "start": "20m", "duration": "2m"}
+		]
+	}`)
+
+	f, err := ParseFile(data)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	if len(f.Scenarios) != 4 {
+		t.Fatalf("got %d scenarios, want 4", len(f.Scenarios))
+	}
+
+	errs := f.Scenarios[0]
+	if time.Duration(errs.Start) != 2*time.Minute || time.Duration(errs.Duration) != 5*time.Minute {
+		t.Errorf("errors window = %v+%v, want 2m+5m", time.Duration(errs.Start), time.Duration(errs.Duration))
+	}
+	if errs.Intensity != 0.5 {
+		t.Errorf("errors intensity = %v, want 0.5", errs.Intensity)
+	}
+
+	slow := f.Scenarios[1]
+	if slow.Intensity != 1 {
+		t.Errorf("default intensity = %v, want 1", slow.Intensity)
+	}
+	if slow.Multiplier != DefaultOutlierMultiplier {
+		t.Errorf("default multiplier = %d, want %d", slow.Multiplier, DefaultOutlierMultiplier)
+	}
+}
+
+func TestParseFile_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"invalid json", `{`, "unexpected EOF"},
+		{"unknown field", `{"scenaros": []}`, "unknown field"},
+		{"bad duration", `{"scenarios": [{"name": "a", "type": "silence", "start": "soon", "duration": "1m"}]}`, "invalid duration"},
+		{"numeric duration", `{"scenarios": [{"name": "a", "type": "silence", "start": 5, "duration": "1m"}]}`, "duration must be a string"},
+		{"missing name", `{"scenarios": [{"type": "silence", "duration": "1m"}]}`, "name is required"},
+		{"unknown type", `{"scenarios": [{"name": "a", "type": "meteor", "duration": "1m"}]}`, "unknown type"},
+		{"zero duration", `{"scenarios": [{"name": "a", "type": "silence"}]}`, "duration must be positive"},
+		{"negative start", `{"scenarios": [{"name": "a", "type": "silence", "start": "-1m", "duration": "1m"}]}`, "start must not be negative"},
+		{"intensity too high", `{"scenarios": [{"name": "a", "type": "silence", "duration": "1m", "intensity": 1.5}]}`, "intensity"},
+		{"multiplier too small", `{"scenarios": [{"name": "a", "type": "latency_outlier", "duration": "1m", "multiplier": 1}]}`, "multiplier"},
+		{"duplicate names", `{"scenarios": [{"name": "a", "type": "silence", "duration": "1m"}, {"name": "a", "type": "silence", "duration": "1m"}]}`, "duplicate name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFile([]byte(tt.data))
+			if err == nil {
+				t.Fatal("ParseFile() succeeded, want error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseFile() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFile_Schema(t *testing.T) {
+	data := []byte(`{
+		"schema": {
+			"builtin": false,
+			"fields": [
+				{"name": "service", "type": "enum", "values": ["api", "web"], "weights": [3, 1]},
+				{"name": "status", "type": "int", "min": 200, "max": 599},
+				{"name": "request", "type": "object", "fields": [
+					{"name": "id", "type": "uuid"},
+					{"name": "at", "type": "timestamp", "format": "unix_ms", "jitter": "2s"}
+				]},
+				{"name": "tags", "type": "array", "max_items": 3, "items": {"type": "enum", "values": ["a", "b"]}},
+				{"name": "client", "type": "fake", "generator": "ipv4", "cidr": "203.0.113.0/24"},
+				{"name": "summary", "type": "template", "template": "{{.service}} {{.status}}"}
+			]
+		}
+	}`)
+
+	f, err := ParseFile(data)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	if f.Schema.BuiltinEnabled() {
+		t.Error("BuiltinEnabled() = true, want false")
+	}
+	if len(f.Schema.Fields) != 6 {
+		t.Fatalf("got %d fields, want 6", len(f.Schema.Fields))
+	}
+	if at := f.Schema.Fields[2].Fields[1]; time.Duration(at.Jitter) != 2*time.Second {
+		t.Errorf("jitter = %v, want 2s", time.Duration(at.Jitter))
+	}
+	if (*Schema)(nil).BuiltinEnabled() != true {
+		t.Error("nil schema BuiltinEnabled() = false, want true")
+	}
+}
+
+func TestParseFile_SchemaErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"no fields", `{"schema": {"fields": []}}`, "at least one field"},
+		{"missing name", `{"schema": {"fields": [{"type": "int"}]}}`, "name is required"},
+		{"unknown type", `{"schema": {"fields": [{"name": "a", "type": "blob"}]}}`, "unknown type"},
+		{"reserved name", `{"schema": {"fields": [{"name": "random_number", "type": "int"}]}}`, "reserved"},
+		{"duplicate name", `{"schema": {"fields": [{"name": "a", "type": "int"}, {"name": "a", "type": "bool"}]}}`, "duplicate"},
+		{"inverted range", `{"schema": {"fields": [{"name": "a", "type": "int", "min": 5, "max": 1}]}}`, "max must be >= min"},
+		{"int range beyond 2^53", `{"schema": {"fields": [{"name": "a", "type": "int", "min": -9e18, "max": 9e18}]}}`, "within ±2^53"},
+		{"float range overflows", `{"schema": {"fields": [{"name": "a", "type": "float", "min": -1.7e308, "max": 1.7e308}]}}`, "too large"},
+		{"bad probability", `{"schema": {"fields": [{"name": "a", "type": "bool", "probability": 2}]}}`, "probability"},
+		{"empty enum", `{"schema": {"fields": [{"name": "a", "type": "enum"}]}}`, "values are required"},
+		{"nested enum value", `{"schema": {"fields": [{"name": "a", "type": "enum", "values": [{"x": 1}]}]}}`, "values must be"},
+		{"weights mismatch", `{"schema": {"fields": [{"name": "a", "type": "enum", "values": ["x"], "weights": [1, 2]}]}}`, "weights must match"},
+		{"zero weights", `{"schema": {"fields": [{"name": "a", "type": "enum", "values": ["x"], "weights": [0]}]}}`, "positive"},
+		{"bad layout", `{"schema": {"fields": [{"name": "a", "type": "timestamp", "format": "yesterday"}]}}`, "not a time layout"},
+		{"empty object", `{"schema": {"fields": [{"name": "a", "type": "object"}]}}`, "object needs fields"},
+		{"array without items", `{"schema": {"fields": [{"name": "a", "type": "array", "max_items": 2}]}}`, "array needs items"},
+		{"bad item count", `{"schema": {"fields": [{"name": "a", "type": "array", "min_items": 3, "max_items": 1, "items": {"type": "int"}}]}}`, "min_items"},
+		{"unknown generator", `{"schema": {"fields": [{"name": "a", "type": "fake", "generator": "ssn"}]}}`, "unknown generator"},
+		{"cidr on non-ip", `{"schema": {"fields": [{"name": "a", "type": "fake", "generator": "email", "cidr": "10.0.0.0/8"}]}}`, "cidr only applies"},
+		{"cidr family", `{"schema": {"fields": [{"name": "a", "type": "fake", "generator": "ipv6", "cidr": "10.0.0.0/8"}]}}`, "does not match"},
+		{"bad cidr", `{"schema": {"fields": [{"name": "a", "type": "fake", "generator": "ipv4", "cidr": "10.0.0.0/33"}]}}`, "prefix length out of range"},
+		{"bad template", `{"schema": {"fields": [{"name": "a", "type": "template", "template": "{{.x"}]}}`, "unclosed action"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFile([]byte(tt.data))
+			if err == nil {
+				t.Fatal("ParseFile() succeeded, want error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseFile() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyFile(t *testing.T) {
+	t.Run("no file", func(t *testing.T) {
+		cfg := &Config{}
+		if err := cfg.ApplyFile(); err != nil {
+			t.Errorf("ApplyFile() without file error = %v", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		cfg := &Config{ConfigFile: filepath.Join(t.TempDir(), "nope.json")}
+		if err := cfg.ApplyFile(); err == nil {
+			t.Error("ApplyFile() with missing file succeeded, want error")
+		}
+	})
+
+	t.Run("scenarios loaded", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "loggen.json")
+		data := `{"scenarios": [{"name": "outage", "type": "silence", "start": "1m", "duration": "1m"}]}`
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		cfg := &Config{ConfigFile: path}
+		if err := cfg.ApplyFile(); err != nil {
+			t.Fatalf("ApplyFile() error = %v", err)
+		}
+		if len(cfg.Scenarios) != 1 || cfg.Scenarios[0].Name != "outage" {
+			t.Errorf("Scenarios = %+v, want one named outage", cfg.Scenarios)
+		}
+	})
+}
+
+func TestDuration_MarshalJSON(t *testing.T) {
+	b, err := Duration(90 * time.Second).MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	if string(b) != `"1m30s"` {
+		t.Errorf("MarshalJSON() = %s, want \"1m30s\"", b)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelWeight is the relative weight of one level in the generated mix.
+type LevelWeight struct {
+	Level  zapcore.Level
+	Weight int
+}
+
+// LevelWeights is a weighted distribution of levels for generated records,
+// written as "info=90,warn=7,error=2,debug=1". It implements flag.Value.
+type LevelWeights []LevelWeight
+
+// ParseLevelWeights parses a comma separated list of level=weight pairs.
+// Weights must be non-negative and at least one must be positive.
+func ParseLevelWeights(s string) (LevelWeights, error) {
+	var out LevelWeights
+	seen := make(map[zapcore.Level]bool)
+	total := 0
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weight, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("level weight %q: want level=weight", part)
+		}
+		lvl, err := zapcore.ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("level weight %q: %w", part, err)
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("level weight %q: weight must be a non-negative integer", part)
+		}
+		if seen[lvl] {
+			return nil, fmt.Errorf("level weight %q: duplicate level", part)
+		}
+		seen[lvl] = true
+		total += w
+		out = append(out, LevelWeight{Level: lvl, Weight: w})
+	}
+
+	if total == 0 {
+		return nil, fmt.Errorf("level weights %q: at least one weight must be positive", s)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Level < out[j].Level })
+	return out, nil
+}
+
+// String implements flag.Value.
+func (w LevelWeights) String() string {
+	parts := make([]string, 0, len(w))
+	for _, lw := range w {
+		parts = append(parts, fmt.Sprintf("%s=%d", lw.Level, lw.Weight))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set implements flag.Value.
+func (w *LevelWeights) Set(s string) error {
+	parsed, err := ParseLevelWeights(s)
+	if err != nil {
+		return err
+	}
+	*w = parsed
+	return nil
+}
+
+// LevelMessages maps levels to text/template message templates, written as
+// "warn=slow tick {{.RandomNumber}};error=tick failed for {{.RandomString}}".
+// Templates can use .Count, .RandomNumber, .RandomString and .Level.
+// It implements flag.Value.
+type LevelMessages map[zapcore.Level]string
+
+// MessageData is the data level message templates are rendered with.
+type MessageData struct {
+	Count        uint64
+	RandomNumber int
+	RandomString string
+	Level        string
+}
+
+// ParseLevelMessages parses a semicolon separated list of level=template
+// pairs, checking that every template compiles and renders MessageData, so
+// a misspelt field is rejected here instead of on every record.
+func ParseLevelMessages(s string) (LevelMessages, error) {
+	out := make(LevelMessages)
+
+	for _, part := range strings.Split(s, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, tmpl, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("level message %q: want level=template", part)
+		}
+		lvl, err := zapcore.ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("level message %q: %w", part, err)
+		}
+		t, err := template.New(lvl.String()).Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("level message %q: %w", part, err)
+		}
+		if err := t.Execute(io.Discard, MessageData{}); err != nil {
+			return nil, fmt.Errorf("level message %q: %w", part, err)
+		}
+		out[lvl] = tmpl
+	}
+
+	return out, nil
+}
+
+// String implements flag.Value.
+func (m LevelMessages) String() string {
+	levels := make([]zapcore.Level, 0, len(m))
+	for lvl := range m {
+		levels = append(levels, lvl)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+
+	parts := make([]string, 0, len(levels))
+	for _, lvl := range levels {
+		parts = append(parts, lvl.String()+"="+m[lvl])
+	}
+	return strings.Join(parts, ";")
+}
+
+// Set implements flag.Value.
+func (m *LevelMessages) Set(s string) error {
+	parsed, err := ParseLevelMessages(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
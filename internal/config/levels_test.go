@@ -0,0 +1,100 @@
+package config
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseLevelWeights(t *testing.T) {
+	got, err := ParseLevelWeights("info=90, warn=7,error=2,debug=1,dpanic=0")
+	if err != nil {
+		t.Fatalf("ParseLevelWeights() error = %v", err)
+	}
+
+	want := LevelWeights{
+		{zapcore.DebugLevel, 1},
+		{zapcore.InfoLevel, 90},
+		{zapcore.WarnLevel, 7},
+		{zapcore.ErrorLevel, 2},
+		{zapcore.DPanicLevel, 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ParseLevelWeights() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("weight[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if s := got.String(); s != "debug=1,info=90,warn=7,error=2,dpanic=0" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestParseLevelWeights_Errors(t *testing.T) {
+	tests := []string{
+		"",
+		"info",
+		"loud=1",
+		"info=-1",
+		"info=x",
+		"info=1,info=2",
+		"info=0,warn=0",
+	}
+
+	for _, in := range tests {
+		t.Run(in, func(t *testing.T) {
+			if _, err := ParseLevelWeights(in); err == nil {
+				t.Errorf("ParseLevelWeights(%q) succeeded, want error", in)
+			}
+		})
+	}
+}
+
+func TestLevelWeights_Set(t *testing.T) {
+	var w LevelWeights
+	if err := w.Set("error=1"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if len(w) != 1 || w[0].Level != zapcore.ErrorLevel {
+		t.Errorf("Set() = %v, want error=1", w)
+	}
+	if err := w.Set("bogus"); err == nil {
+		t.Error("Set(bogus) succeeded, want error")
+	}
+}
+
+func TestParseLevelMessages(t *testing.T) {
+	got, err := ParseLevelMessages("error=tick failed for {{.RandomString}}; warn=slow tick")
+	if err != nil {
+		t.Fatalf("ParseLevelMessages() error = %v", err)
+	}
+	if got[zapcore.ErrorLevel] != "tick failed for {{.RandomString}}" {
+		t.Errorf("error template = %q", got[zapcore.ErrorLevel])
+	}
+	if got[zapcore.WarnLevel] != "slow tick" {
+		t.Errorf("warn template = %q", got[zapcore.WarnLevel])
+	}
+	if s := got.String(); s != "warn=slow tick;error=tick failed for {{.RandomString}}" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestParseLevelMessages_Errors(t *testing.T) {
+	tests := []string{
+		"error",
+		"loud=hi",
+		"error={{.Unclosed",
+		"warn=tick {{.Cnt}}",
+	}
+
+	for _, in := range tests {
+		t.Run(in, func(t *testing.T) {
+			if _, err := ParseLevelMessages(in); err == nil {
+				t.Errorf("ParseLevelMessages(%q) succeeded, want error", in)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import "fmt"
+
+// Mode selects what kind of records the loop generates. It implements
+// flag.Value.
+type Mode string
+
+// Generator modes.
+const (
+	// ModeRecords emits structured JSON records (count, random_number,
+	// random_string and any schema fields).
+	ModeRecords Mode = "records"
+
+	// ModeAccessLog emits web-server access log lines.
+	ModeAccessLog Mode = "access_log"
+
+	// ModeAdversarial cycles through a catalog of records with hostile
+	// content to test pipeline robustness.
+	ModeAdversarial Mode = "adversarial"
+)
+
+// String implements flag.Value.
+func (m Mode) String() string {
+	return string(m)
+}
+
+// Set implements flag.Value.
+func (m *Mode) Set(s string) error {
+	switch Mode(s) {
+	case ModeRecords, ModeAccessLog, ModeAdversarial:
+		*m = Mode(s)
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q: want %s, %s or %s", s, ModeRecords, ModeAccessLog, ModeAdversarial)
+}
+
+// AccessLogFormat is the line format of access_log mode. It implements
+// flag.Value.
+type AccessLogFormat string
+
+// Access log formats.
+const (
+	// AccessLogCommon is the NCSA Common Log Format.
+	AccessLogCommon AccessLogFormat = "common"
+
+	// AccessLogCombined adds referer and user agent, as nginx and Apache
+	// log by default.
+	AccessLogCombined AccessLogFormat = "combined"
+
+	// AccessLogJSON emits each request as a structured JSON record.
+	AccessLogJSON AccessLogFormat = "json"
+)
+
+// String implements flag.Value.
+func (f AccessLogFormat) String() string {
+	return string(f)
+}
+
+// Set implements flag.Value.
+func (f *AccessLogFormat) Set(s string) error {
+	switch AccessLogFormat(s) {
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON:
+		*f = AccessLogFormat(s)
+		return nil
+	}
+	return fmt.Errorf("unknown access log format %q: want %s, %s or %s", s, AccessLogCommon, AccessLogCombined, AccessLogJSON)
+}
@@ -0,0 +1,29 @@
+package config
+
+import "testing"
+
+func TestMode_Set(t *testing.T) {
+	var m Mode
+	if err := m.Set("access_log"); err != nil || m != ModeAccessLog {
+		t.Errorf("Set(access_log) = %v, mode %q", err, m)
+	}
+	if err := m.Set("chaos"); err == nil {
+		t.Error("Set(chaos) succeeded, want error")
+	}
+	if m != ModeAccessLog {
+		t.Errorf("failed Set changed mode to %q", m)
+	}
+}
+
+func TestAccessLogFormat_Set(t *testing.T) {
+	for _, s := range []string{"common", "combined", "json"} {
+		var f AccessLogFormat
+		if err := f.Set(s); err != nil || f.String() != s {
+			t.Errorf("Set(%q) = %v, format %q", s, err, f)
+		}
+	}
+	var f AccessLogFormat
+	if err := f.Set("w3c"); err == nil {
+		t.Error("Set(w3c) succeeded, want error")
+	}
+}
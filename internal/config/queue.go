@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Queue overflow policies: what happens to a record that arrives while a
+// sink's queue is full.
+const (
+	// OverflowBlock makes the loop wait for room, so a slow sink slows
+	// down generation.
+	OverflowBlock = "block"
+
+	// OverflowDropNewest drops the arriving record.
+	OverflowDropNewest = "drop_newest"
+
+	// OverflowDropOldest drops the record that has waited longest to make
+	// room for the arriving one.
+	OverflowDropOldest = "drop_oldest"
+
+	// OverflowSample admits only a sample of the records once the queue
+	// is half full, and drops the arriving record once it is full.
+	OverflowSample = "sample"
+)
+
+// Queue defaults.
+const (
+	DefaultQueueSize   = 1024
+	DefaultQueueSample = 0.1
+)
+
+// Queue bounds the records waiting for a sink between the loop and the
+// sink's own goroutine.
+type Queue struct {
+	// Size is the number of records the queue holds. Omitted means
+	// DefaultQueueSize.
+	Size int `json:"size,omitempty"`
+
+	// Overflow is one of the Overflow* policies. Omitted means
+	// drop_newest.
+	Overflow string `json:"overflow,omitempty"`
+
+	// Sample is the fraction of records admitted past half full with the
+	// sample policy, in (0, 1], spread evenly over the arriving records.
+	// Omitted means DefaultQueueSample.
+	Sample float64 `json:"sample,omitempty"`
+}
+
+func (q *Queue) validate() error {
+	if q.Size < 0 {
+		return errors.New("queue size must not be negative")
+	}
+	if q.Size == 0 {
+		q.Size = DefaultQueueSize
+	}
+	switch q.Overflow {
+	case "":
+		q.Overflow = OverflowDropNewest
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowSample:
+	default:
+		return fmt.Errorf("queue overflow must be %s, %s, %s or %s", OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowSample)
+	}
+	if q.Sample < 0 || q.Sample > 1 {
+		return errors.New("queue sample must be between 0 and 1")
+	}
+	if q.Sample != 0 && q.Overflow != OverflowSample {
+		return errors.New("queue sample needs overflow sample")
+	}
+	if q.Sample == 0 && q.Overflow == OverflowSample {
+		q.Sample = DefaultQueueSample
+	}
+	return nil
+}
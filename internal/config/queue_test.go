@@ -0,0 +1,60 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFile_Queues(t *testing.T) {
+	data := []byte(`{
+		"sinks": [
+			{"name": "default", "type": "stdout"},
+			{"name": "block", "type": "stdout", "queue": {"size": 10, "overflow": "block"}},
+			{"name": "oldest", "type": "stdout", "queue": {"overflow": "drop_oldest"}},
+			{"name": "sample", "type": "stdout", "queue": {"overflow": "sample"}},
+			{"name": "sample5", "type": "stdout", "queue": {"size": 100, "overflow": "sample", "sample": 0.05}}
+		]
+	}`)
+
+	f, err := ParseFile(data)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	want := []Queue{
+		{Size: DefaultQueueSize, Overflow: OverflowDropNewest},
+		{Size: 10, Overflow: OverflowBlock},
+		{Size: DefaultQueueSize, Overflow: OverflowDropOldest},
+		{Size: DefaultQueueSize, Overflow: OverflowSample, Sample: DefaultQueueSample},
+		{Size: 100, Overflow: OverflowSample, Sample: 0.05},
+	}
+	for i, w := range want {
+		if got := *f.Sinks[i].Queue; got != w {
+			t.Errorf("%s queue = %+v, want %+v", f.Sinks[i].Name, got, w)
+		}
+	}
+}
+
+func TestParseFile_QueueErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"negative size", `{"sinks": [{"name": "a", "type": "stdout", "queue": {"size": -1}}]}`, "must not be negative"},
+		{"bad overflow", `{"sinks": [{"name": "a", "type": "stdout", "queue": {"overflow": "spill"}}]}`, "block, drop_newest, drop_oldest or sample"},
+		{"bad sample", `{"sinks": [{"name": "a", "type": "stdout", "queue": {"overflow": "sample", "sample": 1.5}}]}`, "between 0 and 1"},
+		{"sample without policy", `{"sinks": [{"name": "a", "type": "stdout", "queue": {"sample": 0.5}}]}`, "needs overflow sample"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFile([]byte(tt.data))
+			if err == nil {
+				t.Fatal("ParseFile() succeeded, want error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseFile() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
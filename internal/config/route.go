@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Route selects the records a sink receives. Every condition that is set
+// must hold; a sink without a route receives every record.
+type Route struct {
+	// MinLevel passes records at or above this level, such as "error".
+	MinLevel *zapcore.Level `json:"min_level,omitempty"`
+
+	// Fields passes records whose named top-level fields hold one of the
+	// listed values, compared as text. Records without a named field do
+	// not pass.
+	Fields map[string][]string `json:"fields,omitempty"`
+
+	// Sample passes this fraction of the records that meet the other
+	// conditions, in (0, 1]. Records are picked by a hash of their count
+	// field, so every run and every sink picks the same ones. Omitted or
+	// zero means 1.
+	Sample float64 `json:"sample,omitempty"`
+}
+
+func (r *Route) validate() error {
+	if r.Sample < 0 || r.Sample > 1 {
+		return errors.New("route sample must be between 0 and 1")
+	}
+	if r.Sample == 0 {
+		r.Sample = 1
+	}
+	for key, values := range r.Fields {
+		if key == "" {
+			return errors.New("route fields need a name")
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("route field %q needs at least one value", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseFile_Routes(t *testing.T) {
+	data := []byte(`{
+		"sinks": [
+			{"name": "all", "type": "stdout"},
+			{"name": "errors", "type": "stdout", "route": {"min_level": "error"}},
+			{"name": "sample", "type": "stdout", "route": {"sample": 0.01, "fields": {"random_string": ["alpha", "beta"]}}},
+			{"name": "unsampled", "type": "stdout", "route": {"min_level": "debug"}}
+		]
+	}`)
+
+	f, err := ParseFile(data)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	if f.Sinks[0].Route != nil {
+		t.Errorf("route of a sink without one = %+v", f.Sinks[0].Route)
+	}
+	if r := f.Sinks[1].Route; r.MinLevel == nil || *r.MinLevel != zapcore.ErrorLevel || r.Sample != 1 {
+		t.Errorf("errors route = %+v", r)
+	}
+	if r := f.Sinks[2].Route; r.MinLevel != nil || r.Sample != 0.01 || len(r.Fields["random_string"]) != 2 {
+		t.Errorf("sample route = %+v", r)
+	}
+	if r := f.Sinks[3].Route; *r.MinLevel != zapcore.DebugLevel || r.Sample != 1 {
+		t.Errorf("debug route = %+v", r)
+	}
+}
+
+func TestParseFile_RouteErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"bad level", `{"sinks": [{"name": "a", "type": "stdout", "route": {"min_level": "loud"}}]}`, "unrecognized level"},
+		{"negative sample", `{"sinks": [{"name": "a", "type": "stdout", "route": {"sample": -0.5}}]}`, "between 0 and 1"},
+		{"large sample", `{"sinks": [{"name": "a", "type": "stdout", "route": {"sample": 2}}]}`, "between 0 and 1"},
+		{"empty field", `{"sinks": [{"name": "a", "type": "stdout", "route": {"fields": {"": ["x"]}}}]}`, "need a name"},
+		{"no values", `{"sinks": [{"name": "a", "type": "stdout", "route": {"fields": {"service": []}}}]}`, "at least one value"},
+		{"unknown setting", `{"sinks": [{"name": "a", "type": "stdout", "route": {"max_level": "info"}}]}`, "unknown field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFile([]byte(tt.data))
+			if err == nil {
+				t.Fatal("ParseFile() succeeded, want error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseFile() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Scenario types understood by the scenario engine.
+const (
+	// ScenarioErrorSpike logs a fraction of records at error level.
+	ScenarioErrorSpike = "error_spike"
+
+	// ScenarioLatencyOutlier replaces a fraction of random_number values
+	// with outliers above MaxNumber.
+	ScenarioLatencyOutlier = "latency_outlier"
+
+	// ScenarioDominantString makes one random_string value dominate.
+	ScenarioDominantString = "dominant_string"
+
+	// ScenarioSilence suppresses a fraction of records; at full intensity
+	// the generator goes completely quiet.
+	ScenarioSilence = "silence"
+)
+
+// DefaultOutlierMultiplier bounds latency outliers to
+// (MaxNumber, MaxNumber*DefaultOutlierMultiplier].
+const DefaultOutlierMultiplier = 10
+
+// Scenario is a scheduled anomaly declared in the config file.
+type Scenario struct {
+	// Name identifies the scenario in ground-truth output.
+	Name string `json:"name"`
+
+	// Type is one of the Scenario* constants.
+	Type string `json:"type"`
+
+	// Start is the offset from loop start at which the scenario begins.
+	Start Duration `json:"start"`
+
+	// Duration is how long the scenario lasts.
+	Duration Duration `json:"duration"`
+
+	// Intensity is the fraction of records affected, in (0, 1].
+	// Omitted or zero means 1.
+	Intensity float64 `json:"intensity,omitempty"`
+
+	// Value is the dominating string for dominant_string. Empty means the
+	// first configured string.
+	Value string `json:"value,omitempty"`
+
+	// Multiplier bounds latency_outlier values to MaxNumber*Multiplier.
+	// Omitted or zero means DefaultOutlierMultiplier.
+	Multiplier int `json:"multiplier,omitempty"`
+}
+
+func (s *Scenario) validate() error {
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+
+	switch s.Type {
+	case ScenarioErrorSpike, ScenarioLatencyOutlier, ScenarioDominantString, ScenarioSilence:
+	default:
+		return fmt.Errorf("%s: unknown type %q", s.Name, s.Type)
+	}
+
+	if s.Start < 0 {
+		return fmt.Errorf("%s: start must not be negative", s.Name)
+	}
+	if s.Duration <= 0 {
+		return fmt.Errorf("%s: duration must be positive", s.Name)
+	}
+	if s.Intensity < 0 || s.Intensity > 1 {
+		return fmt.Errorf("%s: intensity must be between 0 and 1", s.Name)
+	}
+	if s.Intensity == 0 {
+		s.Intensity = 1
+	}
+	if s.Multiplier < 0 {
+		return fmt.Errorf("%s: multiplier must not be negative", s.Name)
+	}
+	if s.Type == ScenarioLatencyOutlier && s.Multiplier == 0 {
+		s.Multiplier = DefaultOutlierMultiplier
+	}
+	if s.Type == ScenarioLatencyOutlier && s.Multiplier < 2 {
+		return fmt.Errorf("%s: multiplier must be at least 2", s.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/gen"
+)
+
+// Field types understood by the schema generator.
+const (
+	FieldInt       = "int"
+	FieldFloat     = "float"
+	FieldBool      = "bool"
+	FieldEnum      = "enum"
+	FieldUUID      = "uuid"
+	FieldTimestamp = "timestamp"
+	FieldObject    = "object"
+	FieldArray     = "array"
+	FieldTemplate  = "template"
+	FieldFake      = "fake"
+)
+
+// maxExactInt bounds int fields: JSON numbers are float64, which hold
+// every integer up to 2^53 exactly, and the span of such a range still fits
+// in an int64.
+const maxExactInt = 1 << 53
+
+// Timestamp formats besides Go layouts.
+const (
+	TimestampRFC3339     = "rfc3339"
+	TimestampRFC3339Nano = "rfc3339nano"
+	TimestampUnix        = "unix"
+	TimestampUnixMilli   = "unix_ms"
+)
+
+// Schema declares the fields of generated records.
+type Schema struct {
+	// Builtin keeps random_number and random_string ahead of the custom
+	// fields. count is always emitted. Omitted means true.
+	Builtin *bool `json:"builtin,omitempty"`
+
+	// Fields are generated in order, so templates can refer to any field
+	// declared before them.
+	Fields []FieldSpec `json:"fields"`
+}
+
+// BuiltinEnabled reports whether the built-in random fields are emitted.
+func (s *Schema) BuiltinEnabled() bool {
+	return s == nil || s.Builtin == nil || *s.Builtin
+}
+
+// FieldSpec declares one generated field.
+type FieldSpec struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// Min and Max bound int and float values (inclusive).
+	Min float64 `json:"min,omitempty"`
+	Max float64 `json:"max,omitempty"`
+
+	// Precision rounds float values to this many decimals; zero keeps
+	// full precision.
+	Precision int `json:"precision,omitempty"`
+
+	// Probability is the chance a bool is true. Omitted means 0.5.
+	Probability *float64 `json:"probability,omitempty"`
+
+	// Values and optional Weights define an enum.
+	Values  []any `json:"values,omitempty"`
+	Weights []int `json:"weights,omitempty"`
+
+	// Format is a timestamp format: rfc3339 (default), rfc3339nano, unix,
+	// unix_ms, or a Go time layout.
+	Format string `json:"format,omitempty"`
+
+	// Jitter shifts timestamps by a random amount in [-Jitter, +Jitter]
+	// around the record time.
+	Jitter Duration `json:"jitter,omitempty"`
+
+	// Fields are the members of an object.
+	Fields []FieldSpec `json:"fields,omitempty"`
+
+	// Items is the element type of an array, which has between MinItems
+	// and MaxItems elements.
+	Items    *FieldSpec `json:"items,omitempty"`
+	MinItems int        `json:"min_items,omitempty"`
+	MaxItems int        `json:"max_items,omitempty"`
+
+	// Generator names a realistic value generator from the gen package,
+	// such as "ipv4", "user_agent" or "ulid", for fake fields.
+	Generator string `json:"generator,omitempty"`
+
+	// CIDR keeps ipv4 and ipv6 fake values inside this prefix.
+	CIDR string `json:"cidr,omitempty"`
+
+	// Template is a text/template rendered with the fields generated so
+	// far, e.g. "{{.service}}-{{.count}}".
+	Template string `json:"template,omitempty"`
+}
+
+// fieldTypes lists every accepted field type.
+var fieldTypes = map[string]bool{
+	FieldInt: true, FieldFloat: true, FieldBool: true, FieldEnum: true,
+	FieldUUID: true, FieldTimestamp: true, FieldObject: true,
+	FieldArray: true, FieldTemplate: true, FieldFake: true,
+}
+
+func (s *Schema) validate() error {
+	if len(s.Fields) == 0 {
+		return errors.New("schema: at least one field is required")
+	}
+	names := map[string]bool{"count": true}
+	if s.BuiltinEnabled() {
+		names["random_number"] = true
+		names["random_string"] = true
+	}
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("schema: fields[%d]: %w", i, err)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("schema: fields[%d]: duplicate or reserved name %q", i, f.Name)
+		}
+		names[f.Name] = true
+	}
+	return nil
+}
+
+func (f *FieldSpec) validate() error {
+	if f.Name == "" {
+		return errors.New("name is required")
+	}
+	return f.validateValue()
+}
+
+// validateValue checks the type-specific settings. Array items have no
+// name, so they only go through this part.
+func (f *FieldSpec) validateValue() error {
+	label := f.Name
+	if label == "" {
+		label = "items"
+	}
+	if !fieldTypes[f.Type] {
+		return fmt.Errorf("%s: unknown type %q", label, f.Type)
+	}
+
+	switch f.Type {
+	case FieldInt, FieldFloat:
+		if f.Max < f.Min {
+			return fmt.Errorf("%s: max must be >= min", label)
+		}
+		if f.Type == FieldInt && (f.Min < -maxExactInt || f.Max > maxExactInt) {
+			return fmt.Errorf("%s: min and max must be within ±2^53", label)
+		}
+		if math.IsInf(f.Max-f.Min, 0) {
+			return fmt.Errorf("%s: range between min and max is too large", label)
+		}
+		if f.Precision < 0 {
+			return fmt.Errorf("%s: precision must not be negative", label)
+		}
+	case FieldBool:
+		if f.Probability != nil && (*f.Probability < 0 || *f.Probability > 1) {
+			return fmt.Errorf("%s: probability must be between 0 and 1", label)
+		}
+	case FieldEnum:
+		if len(f.Values) == 0 {
+			return fmt.Errorf("%s: values are required", label)
+		}
+		for _, v := range f.Values {
+			switch v.(type) {
+			case string, float64, bool:
+			default:
+				return fmt.Errorf("%s: values must be strings, numbers or booleans", label)
+			}
+		}
+		if len(f.Weights) > 0 {
+			if len(f.Weights) != len(f.Values) {
+				return fmt.Errorf("%s: weights must match values", label)
+			}
+			total := 0
+			for _, w := range f.Weights {
+				if w < 0 {
+					return fmt.Errorf("%s: weights must not be negative", label)
+				}
+				total += w
+			}
+			if total == 0 {
+				return fmt.Errorf("%s: at least one weight must be positive", label)
+			}
+		}
+	case FieldTimestamp:
+		if f.Jitter < 0 {
+			return fmt.Errorf("%s: jitter must not be negative", label)
+		}
+		if f.Format != "" && !isTimestampKeyword(f.Format) && time.Now().Format(f.Format) == f.Format {
+			return fmt.Errorf("%s: format %q is not a time layout", label, f.Format)
+		}
+	case FieldFake:
+		if _, ok := gen.Lookup(f.Generator); !ok {
+			return fmt.Errorf("%s: unknown generator %q (known: %s)", label, f.Generator, strings.Join(gen.Names(), ", "))
+		}
+		if f.CIDR != "" {
+			prefix, err := netip.ParsePrefix(f.CIDR)
+			if err != nil {
+				return fmt.Errorf("%s: %w", label, err)
+			}
+			if f.Generator != "ipv4" && f.Generator != "ipv6" {
+				return fmt.Errorf("%s: cidr only applies to ipv4 and ipv6", label)
+			}
+			if prefix.Addr().Is4() != (f.Generator == "ipv4") {
+				return fmt.Errorf("%s: cidr %s does not match generator %s", label, f.CIDR, f.Generator)
+			}
+		}
+	case FieldObject:
+		if len(f.Fields) == 0 {
+			return fmt.Errorf("%s: object needs fields", label)
+		}
+		names := make(map[string]bool)
+		for i := range f.Fields {
+			if err := f.Fields[i].validate(); err != nil {
+				return fmt.Errorf("%s.fields[%d]: %w", label, i, err)
+			}
+			if names[f.Fields[i].Name] {
+				return fmt.Errorf("%s: duplicate field %q", label, f.Fields[i].Name)
+			}
+			names[f.Fields[i].Name] = true
+		}
+	case FieldArray:
+		if f.Items == nil {
+			return fmt.Errorf("%s: array needs items", label)
+		}
+		if f.MinItems < 0 || f.MaxItems < f.MinItems {
+			return fmt.Errorf("%s: need 0 <= min_items <= max_items", label)
+		}
+		if err := f.Items.validateValue(); err != nil {
+			return fmt.Errorf("%s.items: %w", label, err)
+		}
+	case FieldTemplate:
+		if f.Template == "" {
+			return fmt.Errorf("%s: template is required", label)
+		}
+		if _, err := template.New(label).Parse(f.Template); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+	}
+
+	return nil
+}
+
+func isTimestampKeyword(s string) bool {
+	switch s {
+	case TimestampRFC3339, TimestampRFC3339Nano, TimestampUnix, TimestampUnixMilli:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,893 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+)
+
+// Sink types.
+const (
+	// SinkStdout writes records to stdout, like loggen does without sinks.
+	SinkStdout = "stdout"
+
+	// SinkCRI writes a container log file in the CRI format the kubelet
+	// and containerd use under /var/log/pods.
+	SinkCRI = "cri"
+
+	// SinkDocker writes a Docker json-file container log.
+	SinkDocker = "docker"
+
+	// SinkFile writes one record per line to a rotating file.
+	SinkFile = "file"
+
+	// SinkForward sends records to a Fluent Bit or Fluentd forward input
+	// over the Fluent Forward protocol.
+	SinkForward = "forward"
+
+	// SinkSyslog sends records to a syslog receiver over UDP, TCP or TLS.
+	SinkSyslog = "syslog"
+
+	// SinkLoki pushes records to Grafana Loki's push API.
+	SinkLoki = "loki"
+
+	// SinkElasticsearch indexes records through the Elasticsearch or
+	// OpenSearch _bulk API.
+	SinkElasticsearch = "elasticsearch"
+
+	// SinkSplunk sends records to a Splunk HTTP Event Collector.
+	SinkSplunk = "splunk"
+
+	// SinkKafka produces records to a Kafka topic.
+	SinkKafka = "kafka"
+
+	// SinkSocket writes newline-delimited records to a TCP, UDP or Unix
+	// domain socket.
+	SinkSocket = "socket"
+
+	// SinkJournald sends records to systemd-journald over its native
+	// protocol.
+	SinkJournald = "journald"
+)
+
+// Kafka acknowledgement levels: none, the partition leader, or every
+// in-sync replica.
+const (
+	KafkaAcksNone   = "0"
+	KafkaAcksLeader = "1"
+	KafkaAcksAll    = "all"
+)
+
+// Splunk HEC endpoints.
+const (
+	SplunkEvent = "event"
+	SplunkRaw   = "raw"
+)
+
+// Loki push encodings.
+const (
+	LokiProtobuf = "protobuf"
+	LokiJSON     = "json"
+)
+
+// Networks, syslog protocols and TCP framings.
+const (
+	NetworkUDP      = "udp"
+	NetworkTCP      = "tcp"
+	NetworkTLS      = "tls"
+	NetworkUnix     = "unix"
+	NetworkUnixgram = "unixgram"
+
+	SyslogRFC5424 = "rfc5424"
+	SyslogRFC3164 = "rfc3164"
+
+	// FramingOctetCounting prefixes each message with its length
+	// (RFC 6587 section 3.4.1).
+	FramingOctetCounting = "octet_counting"
+
+	// FramingNewline ends each message with a newline (RFC 6587 section
+	// 3.4.2).
+	FramingNewline = "newline"
+)
+
+// Compression of rotated files, Kafka record batches and forward messages.
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+
+	// CompressionLZ4 is recognised only to explain that it is not
+	// available: the Go standard library has no LZ4 encoder.
+	CompressionLZ4 = "lz4"
+)
+
+// Rotation modes of file sinks.
+const (
+	// RotateRename renames the live file and opens a new one.
+	RotateRename = "rename"
+
+	// RotateCopyTruncate copies the live file aside and truncates it in
+	// place, as logrotate's copytruncate does.
+	RotateCopyTruncate = "copytruncate"
+)
+
+// Container log defaults, matching the kubelet and containerd.
+const (
+	DefaultMaxLineSize = 16 << 10
+	DefaultMaxSize     = 10 << 20
+	DefaultMaxFiles    = 5
+)
+
+// Network and HTTP sink defaults.
+const (
+	DefaultForwardPort    = "24224"
+	DefaultSyslogPort     = "514"
+	DefaultSyslogTLSPort  = "6514"
+	DefaultKafkaPort      = "9092"
+	DefaultJournalSocket  = "/run/systemd/journal/socket"
+	DefaultSyslogFacility = "user"
+	DefaultTag            = "loggen"
+	DefaultTimeout        = 5 * time.Second
+	DefaultLokiPath       = "/loki/api/v1/push"
+	DefaultBatchSize      = 100
+	DefaultBatchWait      = time.Second
+	DefaultBulkPath       = "/_bulk"
+	DefaultIndex          = "loggen-%Y.%m.%d"
+	DefaultAckInterval    = time.Second
+	DefaultAckTimeout     = time.Minute
+)
+
+// Container log streams.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// ByteSize is a size in bytes that reads from the config file as a number
+// or as a string with a k or m suffix ("16k", "10m").
+type ByteSize int
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("size must be a number or a string such as \"10m\": %w", err)
+	}
+	n, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(n)
+	return nil
+}
+
+// Sink declares an output for generated records. When the config file
+// declares sinks, records go to every sink whose route selects them
+// instead of stdout; add a stdout sink to keep it.
+type Sink struct {
+	// Name identifies the sink in logs and metrics.
+	Name string `json:"name"`
+
+	// Type is one of the Sink* constants.
+	Type string `json:"type"`
+
+	// Format encodes records, as -output-format does for stdout. Omitted
+	// means json.
+	Format string `json:"format,omitempty"`
+
+	// Path is the log file written by file sinks.
+	Path string `json:"path,omitempty"`
+
+	// Stream is the container stream, stdout or stderr. Omitted means
+	// stdout.
+	Stream string `json:"stream,omitempty"`
+
+	// MaxLineSize splits longer container log lines into partial chunks.
+	// Omitted means DefaultMaxLineSize.
+	MaxLineSize ByteSize `json:"max_line_size,omitempty"`
+
+	// MaxSize rotates the file once it reaches this size. Zero disables
+	// size rotation for file sinks that rotate by time; otherwise omitted
+	// means DefaultMaxSize.
+	MaxSize ByteSize `json:"max_size,omitempty"`
+
+	// RotateEvery rotates file sinks when the record time enters a new
+	// interval, such as every hour on the hour.
+	RotateEvery Duration `json:"rotate_every,omitempty"`
+
+	// RotateMode is rename or copytruncate for file sinks. Omitted means
+	// rename.
+	RotateMode string `json:"rotate_mode,omitempty"`
+
+	// MaxFiles is the number of files kept, including the live one.
+	// Omitted means DefaultMaxFiles.
+	MaxFiles int `json:"max_files,omitempty"`
+
+	// Compression of rotated files other than the newest: none, gzip or
+	// zstd.
+	// Omitted means gzip for cri, like the kubelet, and none otherwise.
+	// For Kafka it compresses record batches with none, gzip or snappy,
+	// and for forward sinks each message with none or gzip; omitted means
+	// none.
+	Compression string `json:"compression,omitempty"`
+
+	// Address is the host:port of network sinks. An omitted port
+	// defaults to the protocol's usual one. Socket sinks on unix and
+	// unixgram networks take a socket path instead, as do journald sinks,
+	// where omitted means DefaultJournalSocket.
+	Address string `json:"address,omitempty"`
+
+	// Tag is the Fluent tag of forwarded records, the syslog APP-NAME and
+	// journal SYSLOG_IDENTIFIER, or the Kafka client.id. Omitted means
+	// DefaultTag.
+	Tag string `json:"tag,omitempty"`
+
+	// RequireAck asks the forward receiver to acknowledge each message
+	// and resends it on a new connection when no ack arrives. For Splunk
+	// it polls for indexer acknowledgements.
+	RequireAck bool `json:"require_ack,omitempty"`
+
+	// SharedKey enables the forward protocol handshake, matching the
+	// receiver's Shared_Key.
+	SharedKey string `json:"shared_key,omitempty"`
+
+	// Username and Password authenticate the forward handshake when the
+	// receiver requires users, and HTTP sinks with basic auth.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Timeout bounds connecting, the handshake and waiting for acks.
+	// Omitted means DefaultTimeout.
+	Timeout Duration `json:"timeout,omitempty"`
+
+	// Network is the transport of network sinks: udp, tcp or tls for
+	// syslog, where omitted means udp; tcp or tls for Kafka, where
+	// omitted means tcp; tcp, udp, unix or unixgram for socket sinks,
+	// where omitted means tcp.
+	Network string `json:"network,omitempty"`
+
+	// Protocol is the syslog message format, rfc5424 or rfc3164. Omitted
+	// means rfc5424.
+	Protocol string `json:"protocol,omitempty"`
+
+	// Framing separates syslog messages on tcp and tls: octet_counting or
+	// newline. Omitted means octet_counting.
+	Framing string `json:"framing,omitempty"`
+
+	// Facility is the syslog facility name, such as user or local0.
+	// Omitted means DefaultSyslogFacility.
+	Facility string `json:"facility,omitempty"`
+
+	// TLSCA is a PEM CA bundle that verifies the server certificate
+	// instead of the system roots.
+	TLSCA string `json:"tls_ca,omitempty"`
+
+	// TLSCert and TLSKey are a PEM client certificate and key for mutual
+	// TLS.
+	TLSCert string `json:"tls_cert,omitempty"`
+	TLSKey  string `json:"tls_key,omitempty"`
+
+	// TLSServerName overrides the name checked in the server certificate.
+	TLSServerName string `json:"tls_server_name,omitempty"`
+
+	// TLSSkipVerify disables server certificate verification, for tests.
+	TLSSkipVerify bool `json:"tls_skip_verify,omitempty"`
+
+	// URL is the endpoint of HTTP sinks. A Loki URL without a path gets
+	// DefaultLokiPath.
+	URL string `json:"url,omitempty"`
+
+	// Labels are static Loki stream labels.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// LabelFields adds a stream label per named top-level field, or the
+	// record level for "level". Records without the field omit the label.
+	LabelFields []string `json:"label_fields,omitempty"`
+
+	// Encoding is the Loki push body, protobuf (snappy-compressed) or
+	// json. Omitted means protobuf.
+	Encoding string `json:"encoding,omitempty"`
+
+	// TenantID is sent as X-Scope-OrgID to multi-tenant Loki.
+	TenantID string `json:"tenant_id,omitempty"`
+
+	// BatchSize is the number of records sent per request. Omitted means
+	// DefaultBatchSize.
+	BatchSize int `json:"batch_size,omitempty"`
+
+	// BatchWait sends a partial batch once its first record is this old.
+	// Omitted means DefaultBatchWait.
+	BatchWait Duration `json:"batch_wait,omitempty"`
+
+	// Index names the Elasticsearch index of each record. %Y, %m, %d and
+	// %H are replaced with the record's UTC date and hour. Omitted means
+	// DefaultIndex. For Splunk it is the target index, and omitted means
+	// the token's default index.
+	Index string `json:"index,omitempty"`
+
+	// Token is the Elasticsearch API key, sent as "ApiKey <token>", or the
+	// Splunk HEC token.
+	Token string `json:"token,omitempty"`
+
+	// Endpoint is the Splunk HEC endpoint, event or raw. Omitted means
+	// event.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Source and SourceType are the Splunk source and sourcetype of the
+	// records.
+	Source     string `json:"source,omitempty"`
+	SourceType string `json:"sourcetype,omitempty"`
+
+	// Channel is the Splunk request channel GUID. Omitted means a random
+	// one per run.
+	Channel string `json:"channel,omitempty"`
+
+	// AckInterval is how often the Splunk sink polls for indexer
+	// acknowledgements. Omitted means DefaultAckInterval.
+	AckInterval Duration `json:"ack_interval,omitempty"`
+
+	// AckTimeout is how long a Splunk batch may stay unacknowledged before
+	// it is counted as lost. Omitted means DefaultAckTimeout.
+	AckTimeout Duration `json:"ack_timeout,omitempty"`
+
+	// Brokers are the host:port bootstrap addresses of a Kafka cluster.
+	// An omitted port defaults to DefaultKafkaPort.
+	Brokers []string `json:"brokers,omitempty"`
+
+	// Topic is the Kafka topic records are produced to.
+	Topic string `json:"topic,omitempty"`
+
+	// KeyField names the top-level field whose value is the Kafka message
+	// key, so records with the same value share a partition. "level" is
+	// the record level and "host" is loggen's host name, which is the pod
+	// name in Kubernetes. Records without the field, or every record when
+	// omitted, are spread over the partitions batch by batch.
+	KeyField string `json:"key_field,omitempty"`
+
+	// Acks is the acknowledgement a Kafka produce request waits for: "0",
+	// "1" or "all". Omitted means all.
+	Acks string `json:"acks,omitempty"`
+
+	// Idempotent makes the Kafka producer number its batches so a resent
+	// batch is written once. It needs acks all.
+	Idempotent bool `json:"idempotent,omitempty"`
+
+	// Route selects the records the sink receives. Omitted means every
+	// record.
+	Route *Route `json:"route,omitempty"`
+
+	// Queue bounds the records waiting for the sink and decides what
+	// happens when it is full. Omitted means the Queue defaults.
+	Queue *Queue `json:"queue,omitempty"`
+}
+
+func (s *Sink) validate() error {
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+	if s.Format == "" {
+		s.Format = encode.FormatJSON
+	}
+	if !encode.Valid(s.Format) {
+		return fmt.Errorf("%s: unknown format %q", s.Name, s.Format)
+	}
+	if s.Route != nil {
+		if err := s.Route.validate(); err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+	if s.Queue == nil {
+		s.Queue = &Queue{}
+	}
+	if err := s.Queue.validate(); err != nil {
+		return fmt.Errorf("%s: %w", s.Name, err)
+	}
+
+	switch s.Type {
+	case SinkStdout:
+		return nil
+	case SinkCRI, SinkDocker, SinkFile:
+		return s.validateFile()
+	case SinkForward:
+		return s.validateForward()
+	case SinkSyslog:
+		return s.validateSyslog()
+	case SinkLoki:
+		return s.validateLoki()
+	case SinkElasticsearch:
+		return s.validateElasticsearch()
+	case SinkSplunk:
+		return s.validateSplunk()
+	case SinkKafka:
+		return s.validateKafka()
+	case SinkSocket:
+		return s.validateSocket()
+	case SinkJournald:
+		return s.validateJournald()
+	default:
+		return fmt.Errorf("%s: unknown type %q", s.Name, s.Type)
+	}
+}
+
+// validateFile checks the settings of sinks that write rotating files and
+// fills in their defaults.
+func (s *Sink) validateFile() error {
+	if s.Path == "" {
+		return fmt.Errorf("%s: path is required", s.Name)
+	}
+	if s.MaxLineSize < 0 || s.MaxSize < 0 || s.MaxFiles < 0 || s.RotateEvery < 0 {
+		return fmt.Errorf("%s: max_line_size, max_size, max_files and rotate_every must not be negative", s.Name)
+	}
+
+	if s.Type == SinkFile {
+		if s.Stream != "" || s.MaxLineSize != 0 {
+			return fmt.Errorf("%s: stream and max_line_size only apply to cri and docker sinks", s.Name)
+		}
+		switch s.RotateMode {
+		case "":
+			s.RotateMode = RotateRename
+		case RotateRename, RotateCopyTruncate:
+		default:
+			return fmt.Errorf("%s: rotate_mode must be rename or copytruncate", s.Name)
+		}
+		if s.MaxSize == 0 && s.RotateEvery == 0 {
+			s.MaxSize = DefaultMaxSize
+		}
+	} else {
+		if s.RotateEvery != 0 || s.RotateMode != "" {
+			return fmt.Errorf("%s: rotate_every and rotate_mode only apply to file sinks", s.Name)
+		}
+		switch s.Stream {
+		case "":
+			s.Stream = StreamStdout
+		case StreamStdout, StreamStderr:
+		default:
+			return fmt.Errorf("%s: stream must be stdout or stderr", s.Name)
+		}
+		if s.MaxLineSize == 0 {
+			s.MaxLineSize = DefaultMaxLineSize
+		}
+		if s.MaxSize == 0 {
+			s.MaxSize = DefaultMaxSize
+		}
+	}
+
+	switch s.Compression {
+	case "":
+		s.Compression = CompressionNone
+		if s.Type == SinkCRI {
+			s.Compression = CompressionGzip
+		}
+	case CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		return fmt.Errorf("%s: compression must be none, gzip or zstd", s.Name)
+	}
+
+	if s.MaxFiles == 0 {
+		s.MaxFiles = DefaultMaxFiles
+	}
+	if s.MaxFiles < 2 {
+		return fmt.Errorf("%s: max_files must be at least 2", s.Name)
+	}
+	return nil
+}
+
+// validateForward checks the settings of forward sinks and fills in their
+// defaults.
+func (s *Sink) validateForward() error {
+	if s.Address == "" {
+		return fmt.Errorf("%s: address is required", s.Name)
+	}
+	s.Address = withPort(s.Address, DefaultForwardPort)
+	if (s.Username != "" || s.Password != "") && s.SharedKey == "" {
+		return fmt.Errorf("%s: username and password need shared_key", s.Name)
+	}
+	switch s.Compression {
+	case "":
+		s.Compression = CompressionNone
+	case CompressionNone, CompressionGzip:
+	default:
+		return fmt.Errorf("%s: compression must be none or gzip", s.Name)
+	}
+	if err := s.validateBatch(); err != nil {
+		return err
+	}
+	return s.validateNetwork()
+}
+
+// validateSyslog checks the settings of syslog sinks and fills in their
+// defaults.
+func (s *Sink) validateSyslog() error {
+	if s.Address == "" {
+		return fmt.Errorf("%s: address is required", s.Name)
+	}
+
+	switch s.Network {
+	case "":
+		s.Network = NetworkUDP
+	case NetworkUDP, NetworkTCP, NetworkTLS:
+	default:
+		return fmt.Errorf("%s: network must be udp, tcp or tls", s.Name)
+	}
+	if s.Network == NetworkTLS {
+		s.Address = withPort(s.Address, DefaultSyslogTLSPort)
+	} else {
+		s.Address = withPort(s.Address, DefaultSyslogPort)
+		if s.hasTLS() {
+			return fmt.Errorf("%s: tls settings need network tls", s.Name)
+		}
+	}
+	if (s.TLSCert == "") != (s.TLSKey == "") {
+		return fmt.Errorf("%s: tls_cert and tls_key must be set together", s.Name)
+	}
+
+	switch s.Protocol {
+	case "":
+		s.Protocol = SyslogRFC5424
+	case SyslogRFC5424, SyslogRFC3164:
+	default:
+		return fmt.Errorf("%s: protocol must be rfc5424 or rfc3164", s.Name)
+	}
+
+	switch {
+	case s.Network == NetworkUDP && s.Framing != "":
+		return fmt.Errorf("%s: framing only applies to tcp and tls", s.Name)
+	case s.Network == NetworkUDP:
+	case s.Framing == "":
+		s.Framing = FramingOctetCounting
+	case s.Framing != FramingOctetCounting && s.Framing != FramingNewline:
+		return fmt.Errorf("%s: framing must be octet_counting or newline", s.Name)
+	}
+
+	if s.Facility == "" {
+		s.Facility = DefaultSyslogFacility
+	}
+	if _, ok := encode.SyslogFacility(s.Facility); !ok {
+		return fmt.Errorf("%s: unknown facility %q", s.Name, s.Facility)
+	}
+	if len(s.Tag) > 48 || strings.ContainsFunc(s.Tag, func(r rune) bool { return r <= ' ' || r > '~' }) {
+		return fmt.Errorf("%s: tag must be at most 48 printable ASCII characters without spaces", s.Name)
+	}
+	return s.validateNetwork()
+}
+
+// validateLoki checks the settings of Loki sinks and fills in their
+// defaults.
+func (s *Sink) validateLoki() error {
+	if err := s.validateHTTP(DefaultLokiPath); err != nil {
+		return err
+	}
+	for name := range s.Labels {
+		if !validLabelName(name) {
+			return fmt.Errorf("%s: invalid label name %q", s.Name, name)
+		}
+	}
+	for _, name := range s.LabelFields {
+		if !validLabelName(name) {
+			return fmt.Errorf("%s: label_fields: invalid label name %q", s.Name, name)
+		}
+		if _, ok := s.Labels[name]; ok {
+			return fmt.Errorf("%s: label %q is both static and a label field", s.Name, name)
+		}
+	}
+	if len(s.Labels) == 0 && len(s.LabelFields) == 0 {
+		s.Labels = map[string]string{"job": DefaultTag}
+	}
+
+	switch s.Encoding {
+	case "":
+		s.Encoding = LokiProtobuf
+	case LokiProtobuf, LokiJSON:
+	default:
+		return fmt.Errorf("%s: encoding must be protobuf or json", s.Name)
+	}
+	return nil
+}
+
+// validateElasticsearch checks the settings of Elasticsearch sinks and
+// fills in their defaults.
+func (s *Sink) validateElasticsearch() error {
+	if err := s.validateHTTP(DefaultBulkPath); err != nil {
+		return err
+	}
+	if s.Token != "" && s.Username != "" {
+		return fmt.Errorf("%s: use token or username, not both", s.Name)
+	}
+	if s.Index == "" {
+		s.Index = DefaultIndex
+	}
+	// The date directives expand to digits, so the pattern itself must
+	// follow the index name rules.
+	name := strings.NewReplacer("%Y", "", "%m", "", "%d", "", "%H", "").Replace(s.Index)
+	if name == "" || strings.ContainsAny(name, "%\\/*?\"<>|, #:") || name != strings.ToLower(name) ||
+		strings.HasPrefix(s.Index, "-") || strings.HasPrefix(s.Index, "_") || strings.HasPrefix(s.Index, "+") {
+		return fmt.Errorf("%s: index %q is not a valid lowercase index name", s.Name, s.Index)
+	}
+	return nil
+}
+
+// validateSplunk checks the settings of Splunk sinks and fills in their
+// defaults.
+func (s *Sink) validateSplunk() error {
+	switch s.Endpoint {
+	case "":
+		s.Endpoint = SplunkEvent
+	case SplunkEvent, SplunkRaw:
+	default:
+		return fmt.Errorf("%s: endpoint must be event or raw", s.Name)
+	}
+	if err := s.validateHTTP("/services/collector/" + s.Endpoint); err != nil {
+		return err
+	}
+	if s.Token == "" {
+		return fmt.Errorf("%s: token is required", s.Name)
+	}
+	if s.Username != "" {
+		return fmt.Errorf("%s: splunk sinks authenticate with token, not username", s.Name)
+	}
+	if s.Channel != "" && !validGUID(s.Channel) {
+		return fmt.Errorf("%s: channel must be a GUID such as 0aeeac95-ac74-4aa9-b30d-6c4c0ac581ba", s.Name)
+	}
+
+	if s.AckInterval < 0 || s.AckTimeout < 0 {
+		return fmt.Errorf("%s: ack_interval and ack_timeout must not be negative", s.Name)
+	}
+	if !s.RequireAck && (s.AckInterval != 0 || s.AckTimeout != 0) {
+		return fmt.Errorf("%s: ack_interval and ack_timeout need require_ack", s.Name)
+	}
+	if s.RequireAck {
+		if s.AckInterval == 0 {
+			s.AckInterval = Duration(DefaultAckInterval)
+		}
+		if s.AckTimeout == 0 {
+			s.AckTimeout = Duration(DefaultAckTimeout)
+		}
+	}
+	return nil
+}
+
+// validateSocket checks the settings of socket sinks and fills in their
+// defaults.
+func (s *Sink) validateSocket() error {
+	if s.Address == "" {
+		return fmt.Errorf("%s: address is required", s.Name)
+	}
+	switch s.Network {
+	case "":
+		s.Network = NetworkTCP
+	case NetworkTCP, NetworkUDP, NetworkUnix, NetworkUnixgram:
+	default:
+		return fmt.Errorf("%s: network must be tcp, udp, unix or unixgram", s.Name)
+	}
+	if s.Network == NetworkTCP || s.Network == NetworkUDP {
+		if _, port, err := net.SplitHostPort(s.Address); err != nil || port == "" {
+			return fmt.Errorf("%s: address must be host:port on %s", s.Name, s.Network)
+		}
+	}
+	if s.hasTLS() {
+		return fmt.Errorf("%s: socket sinks do not support tls", s.Name)
+	}
+	return s.validateTimeout()
+}
+
+// validateJournald checks the settings of journald sinks and fills in
+// their defaults.
+func (s *Sink) validateJournald() error {
+	if s.Address == "" {
+		s.Address = DefaultJournalSocket
+	}
+	if s.Network != "" {
+		return fmt.Errorf("%s: journald sinks always use a unix datagram socket, omit network", s.Name)
+	}
+	if s.hasTLS() {
+		return fmt.Errorf("%s: journald sinks do not support tls", s.Name)
+	}
+	return s.validateNetwork()
+}
+
+// validateKafka checks the settings of Kafka sinks and fills in their
+// defaults.
+func (s *Sink) validateKafka() error {
+	if len(s.Brokers) == 0 {
+		return fmt.Errorf("%s: brokers is required", s.Name)
+	}
+	for i, b := range s.Brokers {
+		if b == "" {
+			return fmt.Errorf("%s: brokers[%d] is empty", s.Name, i)
+		}
+		s.Brokers[i] = withPort(b, DefaultKafkaPort)
+	}
+	if !validTopic(s.Topic) {
+		return fmt.Errorf("%s: topic must be 1 to 249 characters of a-z, A-Z, 0-9, '.', '_' and '-'", s.Name)
+	}
+
+	switch s.Network {
+	case "":
+		s.Network = NetworkTCP
+	case NetworkTCP, NetworkTLS:
+	default:
+		return fmt.Errorf("%s: network must be tcp or tls", s.Name)
+	}
+	if s.Network != NetworkTLS && s.hasTLS() {
+		return fmt.Errorf("%s: tls settings need network tls", s.Name)
+	}
+	if (s.TLSCert == "") != (s.TLSKey == "") {
+		return fmt.Errorf("%s: tls_cert and tls_key must be set together", s.Name)
+	}
+	if s.Username != "" || s.Password != "" {
+		return fmt.Errorf("%s: kafka sinks do not support SASL, use tls_cert for mutual TLS", s.Name)
+	}
+
+	switch s.Acks {
+	case "":
+		s.Acks = KafkaAcksAll
+	case KafkaAcksNone, KafkaAcksLeader, KafkaAcksAll:
+	default:
+		return fmt.Errorf("%s: acks must be \"0\", \"1\" or \"all\"", s.Name)
+	}
+	if s.Idempotent && s.Acks != KafkaAcksAll {
+		return fmt.Errorf("%s: idempotent needs acks all", s.Name)
+	}
+
+	switch s.Compression {
+	case "":
+		s.Compression = CompressionNone
+	case CompressionNone, CompressionGzip, CompressionSnappy:
+	case CompressionZstd:
+		return fmt.Errorf("%s: zstd record batches are not supported, use gzip or snappy", s.Name)
+	case CompressionLZ4:
+		return fmt.Errorf("%s: lz4 compression is not available (the Go standard library has no lz4 encoder), use gzip or snappy", s.Name)
+	default:
+		return fmt.Errorf("%s: compression must be none, gzip or snappy", s.Name)
+	}
+
+	if err := s.validateBatch(); err != nil {
+		return err
+	}
+	return s.validateNetwork()
+}
+
+// validTopic reports whether name is a legal Kafka topic name.
+func validTopic(name string) bool {
+	if name == "" || len(name) > 249 || name == "." || name == ".." {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r == '.', r == '_', r == '-', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validGUID reports whether s has the 8-4-4-4-12 hex digit form of a GUID.
+func validGUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, r := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validateHTTP checks the URL, batching and TLS settings shared by HTTP
+// sinks. A URL without a path gets defaultPath.
+func (s *Sink) validateHTTP(defaultPath string) error {
+	if s.URL == "" {
+		return fmt.Errorf("%s: url is required", s.Name)
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.Name, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s: url must be http:// or https:// with a host", s.Name)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultPath
+		s.URL = u.String()
+	}
+	if u.Scheme != "https" && s.hasTLS() {
+		return fmt.Errorf("%s: tls settings need an https url", s.Name)
+	}
+	if (s.TLSCert == "") != (s.TLSKey == "") {
+		return fmt.Errorf("%s: tls_cert and tls_key must be set together", s.Name)
+	}
+	if s.Password != "" && s.Username == "" {
+		return fmt.Errorf("%s: password needs username", s.Name)
+	}
+	if err := s.validateBatch(); err != nil {
+		return err
+	}
+	return s.validateTimeout()
+}
+
+// validateBatch fills in the batching settings of HTTP, Kafka and forward
+// sinks.
+func (s *Sink) validateBatch() error {
+	if s.BatchSize < 0 || s.BatchWait < 0 {
+		return fmt.Errorf("%s: batch_size and batch_wait must not be negative", s.Name)
+	}
+	if s.BatchSize == 0 {
+		s.BatchSize = DefaultBatchSize
+	}
+	if s.BatchWait == 0 {
+		s.BatchWait = Duration(DefaultBatchWait)
+	}
+	return nil
+}
+
+// hasTLS reports whether any TLS setting is set.
+func (s *Sink) hasTLS() bool {
+	return s.TLSCA != "" || s.TLSCert != "" || s.TLSKey != "" || s.TLSServerName != "" || s.TLSSkipVerify
+}
+
+// validLabelName reports whether name matches [a-zA-Z_][a-zA-Z0-9_]*, the
+// Prometheus label name syntax Loki uses.
+func validLabelName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validateNetwork fills in the tag and timeout shared by network sinks.
+func (s *Sink) validateNetwork() error {
+	if s.Tag == "" {
+		s.Tag = DefaultTag
+	}
+	return s.validateTimeout()
+}
+
+// validateTimeout fills in the timeout of network and HTTP sinks.
+func (s *Sink) validateTimeout() error {
+	if s.Timeout < 0 {
+		return fmt.Errorf("%s: timeout must not be negative", s.Name)
+	}
+	if s.Timeout == 0 {
+		s.Timeout = Duration(DefaultTimeout)
+	}
+	return nil
+}
+
+// withPort adds port to address unless it already has one. A bracketed
+// IPv6 address without a port, such as [::1], is accepted too.
+func withPort(address, port string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFile_Sinks(t *testing.T) {
+	data := []byte(`{
+		"sinks": [
+			{"name": "console", "type": "stdout", "format": "logfmt"},
+			{"name": "pod", "type": "cri", "path": "/var/log/pods/demo/loggen/0.log", "max_line_size": "1k"},
+			{"name": "docker", "type": "docker", "path": "/tmp/c-json.log", "stream": "stderr", "max_size": 4096, "max_files": 3},
+			{"name": "hourly", "type": "file", "path": "/tmp/loggen.log", "rotate_every": "1h", "rotate_mode": "copytruncate", "compression": "gzip"},
+			{"name": "sized", "type": "file", "path": "/tmp/sized.log"},
+			{"name": "fluent", "type": "forward", "address": "fluent-bit"},
+			{"name": "secure", "type": "forward", "address": "fb:24240", "tag": "kube.loggen", "require_ack": true, "shared_key": "s3cret", "timeout": "1s"},
+			{"name": "udp", "type": "syslog", "address": "syslog.local"},
+			{"name": "tls", "type": "syslog", "address": "[::1]", "network": "tls", "protocol": "rfc3164", "framing": "newline", "facility": "local3", "tls_ca": "/etc/ca.pem"},
+			{"name": "loki", "type": "loki", "url": "http://loki:3100"},
+			{"name": "tenant", "type": "loki", "url": "https://loki.example/custom/push", "encoding": "json", "labels": {"env": "test"}, "label_fields": ["level", "random_string"], "batch_size": 10, "batch_wait": "250ms", "tenant_id": "team-a"},
+			{"name": "es", "type": "elasticsearch", "url": "https://es:9200", "token": "key"},
+			{"name": "os", "type": "elasticsearch", "url": "http://opensearch:9200/", "index": "bench-%Y-%m-%d-%H", "username": "admin", "password": "admin"},
+			{"name": "hec", "type": "splunk", "url": "https://splunk:8088", "token": "t"},
+			{"name": "hec-raw", "type": "splunk", "url": "https://splunk:8088", "token": "t", "endpoint": "raw", "require_ack": true, "channel": "0aeeac95-ac74-4aa9-b30d-6c4c0ac581ba", "index": "main"},
+			{"name": "kafka", "type": "kafka", "brokers": ["kafka-0", "kafka-1:9093"], "topic": "otel.logs"},
+			{"name": "kafka-tls", "type": "kafka", "brokers": ["kafka:9094"], "topic": "logs", "network": "tls", "tls_ca": "/etc/ca.pem", "key_field": "random_string", "acks": "1", "compression": "snappy", "batch_size": 500, "tag": "bench"},
+			{"name": "tcp", "type": "socket", "address": "localhost:5170"},
+			{"name": "dgram", "type": "socket", "network": "unixgram", "address": "/run/receiver.sock", "format": "logfmt"},
+			{"name": "journal", "type": "journald"}
+		]
+	}`)
+
+	f, err := ParseFile(data)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	if len(f.Sinks) != 20 {
+		t.Fatalf("got %d sinks, want 20", len(f.Sinks))
+	}
+
+	console, pod, docker := f.Sinks[0], f.Sinks[1], f.Sinks[2]
+	if console.Format != "logfmt" {
+		t.Errorf("console format = %q", console.Format)
+	}
+	if pod.Format != "json" || pod.Stream != StreamStdout || pod.MaxLineSize != 1024 ||
+		pod.MaxSize != DefaultMaxSize || pod.MaxFiles != DefaultMaxFiles || pod.Compression != CompressionGzip {
+		t.Errorf("cri defaults = %+v", pod)
+	}
+	if docker.Stream != StreamStderr || docker.MaxSize != 4096 || docker.MaxFiles != 3 ||
+		docker.MaxLineSize != DefaultMaxLineSize || docker.Compression != CompressionNone {
+		t.Errorf("docker sink = %+v", docker)
+	}
+
+	hourly, sized := f.Sinks[3], f.Sinks[4]
+	if hourly.MaxSize != 0 || time.Duration(hourly.RotateEvery) != time.Hour ||
+		hourly.RotateMode != RotateCopyTruncate || hourly.Compression != CompressionGzip {
+		t.Errorf("time-rotated file sink = %+v", hourly)
+	}
+	if sized.MaxSize != DefaultMaxSize || sized.RotateMode != RotateRename ||
+		sized.Compression != CompressionNone || sized.MaxFiles != DefaultMaxFiles {
+		t.Errorf("file sink defaults = %+v", sized)
+	}
+
+	fluent, secure := f.Sinks[5], f.Sinks[6]
+	if fluent.Address != "fluent-bit:24224" || fluent.Tag != DefaultTag ||
+		time.Duration(fluent.Timeout) != DefaultTimeout || fluent.RequireAck ||
+		fluent.Compression != CompressionNone || fluent.BatchSize != DefaultBatchSize {
+		t.Errorf("forward defaults = %+v", fluent)
+	}
+	if secure.Address != "fb:24240" || secure.Tag != "kube.loggen" || !secure.RequireAck ||
+		secure.SharedKey != "s3cret" || time.Duration(secure.Timeout) != time.Second {
+		t.Errorf("forward sink = %+v", secure)
+	}
+
+	udp, tls := f.Sinks[7], f.Sinks[8]
+	if udp.Address != "syslog.local:514" || udp.Network != NetworkUDP || udp.Protocol != SyslogRFC5424 ||
+		udp.Framing != "" || udp.Facility != DefaultSyslogFacility || udp.Tag != DefaultTag {
+		t.Errorf("syslog defaults = %+v", udp)
+	}
+	if tls.Address != "[::1]:6514" || tls.Protocol != SyslogRFC3164 || tls.Framing != FramingNewline || tls.Facility != "local3" {
+		t.Errorf("tls syslog sink = %+v", tls)
+	}
+
+	loki, tenant := f.Sinks[9], f.Sinks[10]
+	if loki.URL != "http://loki:3100/loki/api/v1/push" || loki.Encoding != LokiProtobuf || loki.Labels["job"] != "loggen" ||
+		loki.BatchSize != DefaultBatchSize || time.Duration(loki.BatchWait) != DefaultBatchWait || time.Duration(loki.Timeout) != DefaultTimeout {
+		t.Errorf("loki defaults = %+v", loki)
+	}
+	if tenant.URL != "https://loki.example/custom/push" || tenant.Encoding != LokiJSON || len(tenant.Labels) != 1 ||
+		len(tenant.LabelFields) != 2 || tenant.BatchSize != 10 || time.Duration(tenant.BatchWait) != 250*time.Millisecond {
+		t.Errorf("loki sink = %+v", tenant)
+	}
+
+	es, os := f.Sinks[11], f.Sinks[12]
+	if es.URL != "https://es:9200/_bulk" || es.Index != DefaultIndex || es.BatchSize != DefaultBatchSize {
+		t.Errorf("elasticsearch defaults = %+v", es)
+	}
+	if os.URL != "http://opensearch:9200/_bulk" || os.Index != "bench-%Y-%m-%d-%H" {
+		t.Errorf("opensearch sink = %+v", os)
+	}
+
+	hec, hecRaw := f.Sinks[13], f.Sinks[14]
+	if hec.URL != "https://splunk:8088/services/collector/event" || hec.Endpoint != SplunkEvent || hec.AckInterval != 0 {
+		t.Errorf("splunk defaults = %+v", hec)
+	}
+	if hecRaw.URL != "https://splunk:8088/services/collector/raw" || time.Duration(hecRaw.AckInterval) != DefaultAckInterval ||
+		time.Duration(hecRaw.AckTimeout) != DefaultAckTimeout || hecRaw.Index != "main" {
+		t.Errorf("splunk raw sink = %+v", hecRaw)
+	}
+
+	kafka, kafkaTLS := f.Sinks[15], f.Sinks[16]
+	if kafka.Brokers[0] != "kafka-0:9092" || kafka.Brokers[1] != "kafka-1:9093" || kafka.Network != NetworkTCP ||
+		kafka.Acks != KafkaAcksAll || kafka.Compression != CompressionNone || kafka.Tag != DefaultTag ||
+		kafka.BatchSize != DefaultBatchSize || time.Duration(kafka.Timeout) != DefaultTimeout {
+		t.Errorf("kafka defaults = %+v", kafka)
+	}
+	if kafkaTLS.Network != NetworkTLS || kafkaTLS.Acks != KafkaAcksLeader || kafkaTLS.Compression != CompressionSnappy ||
+		kafkaTLS.KeyField != "random_string" || kafkaTLS.BatchSize != 500 || kafkaTLS.Tag != "bench" {
+		t.Errorf("kafka sink = %+v", kafkaTLS)
+	}
+
+	tcp, dgram := f.Sinks[17], f.Sinks[18]
+	if tcp.Network != NetworkTCP || tcp.Address != "localhost:5170" || time.Duration(tcp.Timeout) != DefaultTimeout {
+		t.Errorf("socket defaults = %+v", tcp)
+	}
+	if dgram.Network != NetworkUnixgram || dgram.Address != "/run/receiver.sock" {
+		t.Errorf("unixgram socket sink = %+v", dgram)
+	}
+
+	if journal := f.Sinks[19]; journal.Address != DefaultJournalSocket || journal.Tag != DefaultTag {
+		t.Errorf("journald defaults = %+v", journal)
+	}
+}
+
+func TestParseFile_SinkErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"missing name", `{"sinks": [{"type": "stdout"}]}`, "name is required"},
+		{"unknown type", `{"sinks": [{"name": "a", "type": "pigeon"}]}`, "unknown type"},
+		{"unknown format", `{"sinks": [{"name": "a", "type": "stdout", "format": "xml"}]}`, "unknown format"},
+		{"duplicate name", `{"sinks": [{"name": "a", "type": "stdout"}, {"name": "a", "type": "stdout"}]}`, "duplicate"},
+		{"missing path", `{"sinks": [{"name": "a", "type": "cri"}]}`, "path is required"},
+		{"bad stream", `{"sinks": [{"name": "a", "type": "cri", "path": "x", "stream": "stdin"}]}`, "stream"},
+		{"negative size", `{"sinks": [{"name": "a", "type": "docker", "path": "x", "max_size": -1}]}`, "negative"},
+		{"one file", `{"sinks": [{"name": "a", "type": "docker", "path": "x", "max_files": 1}]}`, "at least 2"},
+		{"bad size", `{"sinks": [{"name": "a", "type": "cri", "path": "x", "max_size": "lots"}]}`, "invalid size"},
+		{"size type", `{"sinks": [{"name": "a", "type": "cri", "path": "x", "max_size": true}]}`, "number or a string"},
+		{"bad compression", `{"sinks": [{"name": "a", "type": "file", "path": "x", "compression": "lz4"}]}`, "none, gzip or zstd"},
+		{"bad rotate mode", `{"sinks": [{"name": "a", "type": "file", "path": "x", "rotate_mode": "move"}]}`, "rotate_mode"},
+		{"stream on file", `{"sinks": [{"name": "a", "type": "file", "path": "x", "stream": "stdout"}]}`, "only apply to cri and docker"},
+		{"time rotation on cri", `{"sinks": [{"name": "a", "type": "cri", "path": "x", "rotate_every": "1h"}]}`, "only apply to file"},
+		{"forward address", `{"sinks": [{"name": "a", "type": "forward"}]}`, "address is required"},
+		{"forward user", `{"sinks": [{"name": "a", "type": "forward", "address": "x", "username": "u"}]}`, "need shared_key"},
+		{"forward timeout", `{"sinks": [{"name": "a", "type": "forward", "address": "x", "timeout": "-1s"}]}`, "timeout"},
+		{"forward compression", `{"sinks": [{"name": "a", "type": "forward", "address": "x", "compression": "snappy"}]}`, "none or gzip"},
+		{"forward batch", `{"sinks": [{"name": "a", "type": "forward", "address": "x", "batch_size": -1}]}`, "must not be negative"},
+		{"syslog address", `{"sinks": [{"name": "a", "type": "syslog"}]}`, "address is required"},
+		{"syslog network", `{"sinks": [{"name": "a", "type": "syslog", "address": "x", "network": "sctp"}]}`, "udp, tcp or tls"},
+		{"syslog protocol", `{"sinks": [{"name": "a", "type": "syslog", "address": "x", "protocol": "rfc9999"}]}`, "rfc5424 or rfc3164"},
+		{"udp framing", `{"sinks": [{"name": "a", "type": "syslog", "address": "x", "framing": "newline"}]}`, "only applies to tcp"},
+		{"bad framing", `{"sinks": [{"name": "a", "type": "syslog", "address": "x", "network": "tcp", "framing": "nul"}]}`, "octet_counting or newline"},
+		{"bad facility", `{"sinks": [{"name": "a", "type": "syslog", "address": "x", "facility": "local9"}]}`, "unknown facility"},
+		{"bad tag", `{"sinks": [{"name": "a", "type": "syslog", "address": "x", "tag": "my app"}]}`, "printable ASCII"},
+		{"tls on udp", `{"sinks": [{"name": "a", "type": "syslog", "address": "x", "tls_skip_verify": true}]}`, "need network tls"},
+		{"tls key", `{"sinks": [{"name": "a", "type": "syslog", "address": "x", "network": "tls", "tls_cert": "c.pem"}]}`, "set together"},
+		{"loki url", `{"sinks": [{"name": "a", "type": "loki"}]}`, "url is required"},
+		{"loki scheme", `{"sinks": [{"name": "a", "type": "loki", "url": "ftp://loki"}]}`, "http:// or https://"},
+		{"loki label", `{"sinks": [{"name": "a", "type": "loki", "url": "http://loki", "labels": {"1job": "x"}}]}`, "invalid label name"},
+		{"loki label field", `{"sinks": [{"name": "a", "type": "loki", "url": "http://loki", "label_fields": ["a-b"]}]}`, "invalid label name"},
+		{"loki label twice", `{"sinks": [{"name": "a", "type": "loki", "url": "http://loki", "labels": {"level": "x"}, "label_fields": ["level"]}]}`, "both static"},
+		{"loki encoding", `{"sinks": [{"name": "a", "type": "loki", "url": "http://loki", "encoding": "xml"}]}`, "protobuf or json"},
+		{"loki tls", `{"sinks": [{"name": "a", "type": "loki", "url": "http://loki", "tls_ca": "ca.pem"}]}`, "https url"},
+		{"loki batch", `{"sinks": [{"name": "a", "type": "loki", "url": "http://loki", "batch_size": -1}]}`, "negative"},
+		{"loki password", `{"sinks": [{"name": "a", "type": "loki", "url": "http://loki", "password": "p"}]}`, "needs username"},
+		{"es url", `{"sinks": [{"name": "a", "type": "elasticsearch"}]}`, "url is required"},
+		{"es index case", `{"sinks": [{"name": "a", "type": "elasticsearch", "url": "http://es", "index": "Logs-%Y"}]}`, "lowercase index"},
+		{"es index chars", `{"sinks": [{"name": "a", "type": "elasticsearch", "url": "http://es", "index": "logs %Y"}]}`, "lowercase index"},
+		{"es index directive", `{"sinks": [{"name": "a", "type": "elasticsearch", "url": "http://es", "index": "logs-%j"}]}`, "lowercase index"},
+		{"es index prefix", `{"sinks": [{"name": "a", "type": "elasticsearch", "url": "http://es", "index": "_logs"}]}`, "lowercase index"},
+		{"es auth", `{"sinks": [{"name": "a", "type": "elasticsearch", "url": "http://es", "token": "t", "username": "u"}]}`, "not both"},
+		{"splunk token", `{"sinks": [{"name": "a", "type": "splunk", "url": "http://splunk"}]}`, "token is required"},
+		{"splunk endpoint", `{"sinks": [{"name": "a", "type": "splunk", "url": "http://splunk", "token": "t", "endpoint": "metrics"}]}`, "event or raw"},
+		{"splunk channel", `{"sinks": [{"name": "a", "type": "splunk", "url": "http://splunk", "token": "t", "channel": "chan-1"}]}`, "GUID"},
+		{"splunk ack", `{"sinks": [{"name": "a", "type": "splunk", "url": "http://splunk", "token": "t", "ack_timeout": "5s"}]}`, "need require_ack"},
+		{"splunk user", `{"sinks": [{"name": "a", "type": "splunk", "url": "http://splunk", "token": "t", "username": "u"}]}`, "not username"},
+		{"kafka brokers", `{"sinks": [{"name": "a", "type": "kafka", "topic": "logs"}]}`, "brokers is required"},
+		{"kafka topic", `{"sinks": [{"name": "a", "type": "kafka", "brokers": ["k"], "topic": "otel logs"}]}`, "topic must be"},
+		{"kafka acks", `{"sinks": [{"name": "a", "type": "kafka", "brokers": ["k"], "topic": "logs", "acks": "2"}]}`, "acks must be"},
+		{"kafka idempotent", `{"sinks": [{"name": "a", "type": "kafka", "brokers": ["k"], "topic": "logs", "acks": "1", "idempotent": true}]}`, "needs acks all"},
+		{"kafka zstd", `{"sinks": [{"name": "a", "type": "kafka", "brokers": ["k"], "topic": "logs", "compression": "zstd"}]}`, "zstd record batches are not supported"},
+		{"kafka lz4", `{"sinks": [{"name": "a", "type": "kafka", "brokers": ["k"], "topic": "logs", "compression": "lz4"}]}`, "not available"},
+		{"kafka network", `{"sinks": [{"name": "a", "type": "kafka", "brokers": ["k"], "topic": "logs", "network": "udp"}]}`, "tcp or tls"},
+		{"kafka tls", `{"sinks": [{"name": "a", "type": "kafka", "brokers": ["k"], "topic": "logs", "tls_skip_verify": true}]}`, "need network tls"},
+		{"kafka sasl", `{"sinks": [{"name": "a", "type": "kafka", "brokers": ["k"], "topic": "logs", "username": "u"}]}`, "SASL"},
+		{"socket address", `{"sinks": [{"name": "a", "type": "socket"}]}`, "address is required"},
+		{"socket port", `{"sinks": [{"name": "a", "type": "socket", "network": "udp", "address": "localhost"}]}`, "host:port on udp"},
+		{"socket network", `{"sinks": [{"name": "a", "type": "socket", "network": "tls", "address": "localhost:1"}]}`, "tcp, udp, unix or unixgram"},
+		{"socket tls", `{"sinks": [{"name": "a", "type": "socket", "address": "localhost:1", "tls_ca": "ca.pem"}]}`, "do not support tls"},
+		{"journald network", `{"sinks": [{"name": "a", "type": "journald", "network": "udp"}]}`, "omit network"},
+		{"journald tls", `{"sinks": [{"name": "a", "type": "journald", "tls_skip_verify": true}]}`, "do not support tls"},
+		{"negative interval", `{"sinks": [{"name": "a", "type": "file", "path": "x", "rotate_every": "-1h"}]}`, "negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFile([]byte(tt.data))
+			if err == nil {
+				t.Fatal("ParseFile() succeeded, want error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseFile() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
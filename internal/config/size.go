@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Record size distributions.
+const (
+	// SizeFixed pads every record to the same size.
+	SizeFixed = "fixed"
+
+	// SizeUniform draws sizes uniformly from a range.
+	SizeUniform = "uniform"
+
+	// SizeLongTail draws sizes from a truncated Pareto distribution: most
+	// records are near the minimum, a few approach the maximum.
+	SizeLongTail = "longtail"
+)
+
+// RecordSize is a target size distribution for encoded records, written
+// as "fixed:1k", "uniform:512-4k" or "longtail:256-1m". Sizes accept k and
+// m suffixes (KiB, MiB). The zero value disables shaping. It implements
+// flag.Value.
+type RecordSize struct {
+	Shape string
+	Min   int
+	Max   int
+}
+
+// ParseRecordSize parses a record size distribution.
+func ParseRecordSize(s string) (RecordSize, error) {
+	shape, spec, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return RecordSize{}, fmt.Errorf("record size %q: want shape:size or shape:min-max", s)
+	}
+
+	var rs RecordSize
+	var err error
+	switch shape {
+	case SizeFixed:
+		rs.Min, err = parseSize(spec)
+		rs.Max = rs.Min
+	case SizeUniform, SizeLongTail:
+		lo, hi, ok := strings.Cut(spec, "-")
+		if !ok {
+			return RecordSize{}, fmt.Errorf("record size %q: %s needs min-max", s, shape)
+		}
+		if rs.Min, err = parseSize(lo); err == nil {
+			rs.Max, err = parseSize(hi)
+		}
+	default:
+		return RecordSize{}, fmt.Errorf("record size %q: unknown shape %q, want %s, %s or %s", s, shape, SizeFixed, SizeUniform, SizeLongTail)
+	}
+	if err != nil {
+		return RecordSize{}, fmt.Errorf("record size %q: %w", s, err)
+	}
+	if rs.Min <= 0 || rs.Max < rs.Min {
+		return RecordSize{}, fmt.Errorf("record size %q: need 0 < min <= max", s)
+	}
+	rs.Shape = shape
+	return rs, nil
+}
+
+func parseSize(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	mult := 1
+	switch {
+	case strings.HasSuffix(s, "k"):
+		mult, s = 1<<10, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		mult, s = 1<<20, strings.TrimSuffix(s, "m")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// Enabled reports whether a distribution is configured.
+func (r RecordSize) Enabled() bool {
+	return r.Shape != ""
+}
+
+// String implements flag.Value.
+func (r RecordSize) String() string {
+	switch r.Shape {
+	case "":
+		return ""
+	case SizeFixed:
+		return fmt.Sprintf("%s:%d", r.Shape, r.Min)
+	default:
+		return fmt.Sprintf("%s:%d-%d", r.Shape, r.Min, r.Max)
+	}
+}
+
+// Set implements flag.Value.
+func (r *RecordSize) Set(s string) error {
+	parsed, err := ParseRecordSize(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import "testing"
+
+func TestParseRecordSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    RecordSize
+		wantErr bool
+	}{
+		{"fixed:512", RecordSize{SizeFixed, 512, 512}, false},
+		{"fixed:1k", RecordSize{SizeFixed, 1024, 1024}, false},
+		{"uniform:256-4K", RecordSize{SizeUniform, 256, 4096}, false},
+		{"longtail:200-1m", RecordSize{SizeLongTail, 200, 1 << 20}, false},
+		{"512", RecordSize{}, true},
+		{"fixed:0", RecordSize{}, true},
+		{"uniform:512", RecordSize{}, true},
+		{"uniform:4k-1k", RecordSize{}, true},
+		{"longtail:1x-2", RecordSize{}, true},
+		{"gaussian:1-2", RecordSize{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRecordSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRecordSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRecordSize(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordSize_String(t *testing.T) {
+	for _, s := range []string{"fixed:512", "uniform:256-4096", "longtail:200-1048576"} {
+		var rs RecordSize
+		if err := rs.Set(s); err != nil {
+			t.Fatalf("Set(%q) error = %v", s, err)
+		}
+		if rs.String() != s || !rs.Enabled() {
+			t.Errorf("String() = %q, want %q", rs.String(), s)
+		}
+	}
+	if (RecordSize{}).Enabled() {
+		t.Error("zero RecordSize is enabled")
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/stacktrace"
+)
+
+// StackTraceLanguages is the set of stack trace languages to emit, written
+// as "java,python,go". It implements flag.Value.
+type StackTraceLanguages []string
+
+// ParseStackTraceLanguages parses a comma separated list of languages.
+func ParseStackTraceLanguages(s string) (StackTraceLanguages, error) {
+	var out StackTraceLanguages
+	for _, part := range strings.Split(s, ",") {
+		lang := strings.ToLower(strings.TrimSpace(part))
+		if lang == "" {
+			continue
+		}
+		if !stacktrace.Valid(lang) {
+			return nil, fmt.Errorf("unknown stack trace language %q: want %s", lang, strings.Join(stacktrace.Languages, ", "))
+		}
+		out = append(out, lang)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("stack trace languages %q: at least one is required", s)
+	}
+	return out, nil
+}
+
+// String implements flag.Value.
+func (l StackTraceLanguages) String() string {
+	return strings.Join(l, ",")
+}
+
+// Set implements flag.Value.
+func (l *StackTraceLanguages) Set(s string) error {
+	parsed, err := ParseStackTraceLanguages(s)
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import "testing"
+
+func TestParseStackTraceLanguages(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"java,python,go", "java,python,go", false},
+		{" Go , java ", "go,java", false},
+		{"java,,", "java", false},
+		{"", "", true},
+		{"cobol", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseStackTraceLanguages(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStackTraceLanguages(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseStackTraceLanguages(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package encode
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// CEF header values identifying loggen as the device.
+const (
+	cefVendor  = "randomizedcoder"
+	cefProduct = "loggen"
+)
+
+var (
+	// cefHeader escapes header fields: backslash and pipe are escaped, and
+	// line breaks, which the header cannot hold, become spaces.
+	cefHeader = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+
+	// cefExtension escapes extension values.
+	cefExtension = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+)
+
+// cefEncoder renders ArcSight Common Event Format lines, replacing invalid
+// UTF-8 with U+FFFD:
+//
+//	CEF:0|randomizedcoder|loggen|dev|info|hello|3|rt=1771416000000 count=1
+//
+// The signature ID is the level, the name is the message, and fields
+// become extension key=value pairs after rt, the record time in Unix
+// milliseconds.
+type cefEncoder struct {
+	version string
+}
+
+func (e cefEncoder) Encode(rec *record.Record) ([]byte, error) {
+	b := make([]byte, 0, 160)
+	b = append(b, "CEF:0|"...)
+	for _, h := range []string{cefVendor, cefProduct, e.version, rec.Level.String(), rec.Message} {
+		b = append(b, cefHeader.Replace(strings.ToValidUTF8(h, "\ufffd"))...)
+		b = append(b, '|')
+	}
+	b = strconv.AppendInt(b, int64(cefSeverity(rec)), 10)
+	b = append(b, "|rt="...)
+	b = strconv.AppendInt(b, rec.Time.UnixMilli(), 10)
+	for _, f := range rec.Fields {
+		b = append(b, ' ')
+		b = append(b, cefKey(f.Key)...)
+		b = append(b, '=')
+		b = append(b, cefExtension.Replace(strings.ToValidUTF8(text(f.Value), "\ufffd"))...)
+	}
+	return append(b, '\n'), nil
+}
+
+// cefSeverity maps the OpenTelemetry severity onto CEF's 0-10 scale.
+func cefSeverity(rec *record.Record) int {
+	switch num, _ := Severity(rec.Level); num {
+	case 5:
+		return 1
+	case 9:
+		return 3
+	case 13:
+		return 5
+	case 17:
+		return 7
+	default:
+		return 10
+	}
+}
+
+// cefKey keeps extension keys to letters, digits, '_' and '.', the
+// characters CEF parsers accept.
+func cefKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, k)
+}
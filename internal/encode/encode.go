@@ -0,0 +1,133 @@
+// Package encode renders generated records in the formats loggen can
+// write: zap JSON, logfmt, plain text, ArcSight CEF, GELF and OTLP JSON.
+// Every format is rendered from the same record.Record, so any sink can
+// pick any format.
+package encode
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/logging"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// Supported output formats.
+const (
+	// FormatJSON is zap production JSON, the format transform.lua parses.
+	FormatJSON = "json"
+
+	// FormatLogfmt is key=value pairs, like the operational logfmt logs.
+	FormatLogfmt = "logfmt"
+
+	// FormatPlain is free-form text: timestamp, level, message, fields.
+	FormatPlain = "plain"
+
+	// FormatCEF is ArcSight Common Event Format.
+	FormatCEF = "cef"
+
+	// FormatGELF is Graylog Extended Log Format 1.1 JSON.
+	FormatGELF = "gelf"
+
+	// FormatOTLP is one OTLP/JSON ExportLogsServiceRequest holding a
+	// single LogRecord per line, as read by the collector's otlpjsonfile
+	// receiver.
+	FormatOTLP = "otlp_json"
+)
+
+// Formats lists every supported format.
+var Formats = []string{FormatJSON, FormatLogfmt, FormatPlain, FormatCEF, FormatGELF, FormatOTLP}
+
+// Valid reports whether format is supported.
+func Valid(format string) bool {
+	return slices.Contains(Formats, format)
+}
+
+// Encoder renders one record as a complete line, including the trailing
+// newline. Records with Raw set are written verbatim by their sink and are
+// not passed to an encoder.
+type Encoder interface {
+	Encode(rec *record.Record) ([]byte, error)
+}
+
+// options holds settings shared by the formats that describe the source.
+type options struct {
+	host    string
+	version string
+}
+
+// Option customises an encoder.
+type Option func(*options)
+
+// WithHost sets the host reported by GELF and OTLP. It defaults to
+// os.Hostname.
+func WithHost(host string) Option {
+	return func(o *options) {
+		o.host = host
+	}
+}
+
+// WithVersion sets the product version reported by CEF and OTLP. It
+// defaults to "dev".
+func WithVersion(version string) Option {
+	return func(o *options) {
+		o.version = version
+	}
+}
+
+// New returns the encoder for format.
+func New(format string, opts ...Option) (Encoder, error) {
+	o := options{version: "dev"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.host == "" {
+		o.host, _ = os.Hostname()
+	}
+
+	switch format {
+	case FormatJSON:
+		return zapEncoder{zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())}, nil
+	case FormatLogfmt:
+		return zapEncoder{logging.NewLogfmtEncoder(zap.NewProductionEncoderConfig())}, nil
+	case FormatPlain:
+		return plainEncoder{}, nil
+	case FormatCEF:
+		return cefEncoder{version: o.version}, nil
+	case FormatGELF:
+		return gelfEncoder{host: o.host}, nil
+	case FormatOTLP:
+		return otlpEncoder{host: o.host, version: o.version}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// entry returns the zap entry describing rec.
+func entry(rec *record.Record) zapcore.Entry {
+	return zapcore.Entry{
+		Level:   rec.Level,
+		Time:    rec.Time,
+		Message: rec.Message,
+		Caller:  rec.Caller,
+	}
+}
+
+// zapEncoder renders records with a zap encoder, so the json format is
+// zap production JSON byte for byte.
+type zapEncoder struct {
+	enc zapcore.Encoder
+}
+
+func (e zapEncoder) Encode(rec *record.Record) ([]byte, error) {
+	buf, err := e.enc.EncodeEntry(entry(rec), rec.ZapFields())
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Free()
+	return append([]byte(nil), buf.Bytes()...), nil
+}
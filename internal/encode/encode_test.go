@@ -0,0 +1,235 @@
+package encode
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var t0 = time.Date(2026, 2, 18, 12, 0, 0, 123456789, time.UTC)
+
+// fixtures covers plain records, every value type, nesting, characters
+// that need escaping in some format, and the extreme levels.
+func fixtures() []*record.Record {
+	caller := zapcore.NewEntryCaller(0, "/src/internal/loop/loop.go", 341, true)
+	return []*record.Record{
+		{
+			Time: t0, Level: zapcore.InfoLevel, Message: "hello world", Caller: caller,
+			Fields: []record.Field{
+				{Key: "count", Value: uint64(1)},
+				{Key: "random_number", Value: int64(42)},
+				{Key: "random_string", Value: "alpha"},
+			},
+		},
+		{
+			Time: t0.Add(time.Second), Level: zapcore.ErrorLevel, Message: "tick failed\nretrying | a=b \\", Caller: caller,
+			Fields: []record.Field{
+				{Key: "id", Value: "abc"},
+				{Key: "status", Value: int64(503)},
+				{Key: "latency_ms", Value: 12.5},
+				{Key: "ok", Value: false},
+				{Key: "at", Value: t0},
+				{Key: "http", Value: []record.Field{{Key: "method", Value: "GET"}, {Key: "path", Value: "/a b"}}},
+				{Key: "tags", Value: []any{"x", int64(2)}},
+				{Key: "empty", Value: ""},
+				{Key: "quote", Value: `say "hi"`},
+				{Key: "ratio", Value: math.NaN()},
+			},
+		},
+		{
+			Time: t0.Add(2 * time.Second), Level: zapcore.DebugLevel,
+		},
+		{
+			Time: t0.Add(3 * time.Second), Level: zapcore.FatalLevel, Message: "bad\xffbyte", Caller: caller,
+			Fields: []record.Field{
+				{Key: "big", Value: uint64(math.MaxUint64)},
+				{Key: "key with space", Value: "tab\there"},
+			},
+		},
+	}
+}
+
+func encodeAll(t *testing.T, enc Encoder) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	for i, rec := range fixtures() {
+		line, err := enc.Encode(rec)
+		if err != nil {
+			t.Fatalf("Encode(fixture %d) error = %v", i, err)
+		}
+		if bytes.Count(line, []byte("\n")) != 1 || line[len(line)-1] != '\n' {
+			t.Fatalf("Encode(fixture %d) = %q, want one newline-terminated line", i, line)
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
+
+func TestEncoders_Golden(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			enc, err := New(format, WithHost("node-1"), WithVersion("1.2.3"))
+			if err != nil {
+				t.Fatalf("New(%q) error = %v", format, err)
+			}
+			got := encodeAll(t, enc)
+
+			path := filepath.Join("testdata", format+".golden")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s output differs from %s:\ngot:\n%s\nwant:\n%s", format, path, got, want)
+			}
+		})
+	}
+}
+
+func TestEncoders_ValidJSON(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatGELF, FormatOTLP} {
+		enc, err := New(format, WithHost("node-1"))
+		if err != nil {
+			t.Fatalf("New(%q) error = %v", format, err)
+		}
+		for i, line := range bytes.Split(bytes.TrimSuffix(encodeAll(t, enc), []byte("\n")), []byte("\n")) {
+			if !json.Valid(line) {
+				t.Errorf("%s fixture %d is not valid JSON: %s", format, i, line)
+			}
+		}
+	}
+}
+
+func TestGELF_Fields(t *testing.T) {
+	enc, _ := New(FormatGELF, WithHost("node-1"))
+	line, err := enc.Encode(fixtures()[1])
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(line, &m); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := map[string]any{
+		"version":       "1.1",
+		"host":          "node-1",
+		"level":         float64(3),
+		"timestamp":     1771416001.123,
+		"_id_":          "abc",
+		"_status":       float64(503),
+		"_ok":           "false",
+		"_http_method":  "GET",
+		"_tags":         `["x",2]`,
+		"_ratio":        "NaN",
+		"short_message": "tick failed\nretrying | a=b \\",
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, m[k], v)
+		}
+	}
+}
+
+func TestOTLP_Record(t *testing.T) {
+	enc, _ := New(FormatOTLP, WithHost("node-1"), WithVersion("1.2.3"))
+	line, err := enc.Encode(fixtures()[0])
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var req struct {
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				LogRecords []struct {
+					TimeUnixNano   string `json:"timeUnixNano"`
+					SeverityNumber int    `json:"severityNumber"`
+					SeverityText   string `json:"severityText"`
+					Body           struct {
+						StringValue string `json:"stringValue"`
+					} `json:"body"`
+					Attributes []struct {
+						Key   string         `json:"key"`
+						Value map[string]any `json:"value"`
+					} `json:"attributes"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	lr := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if lr.TimeUnixNano != "1771416000123456789" || lr.SeverityNumber != 9 || lr.SeverityText != "INFO" || lr.Body.StringValue != "hello world" {
+		t.Errorf("log record = %+v", lr)
+	}
+	if len(lr.Attributes) != 4 || lr.Attributes[0].Key != "caller" || lr.Attributes[2].Value["intValue"] != "42" {
+		t.Errorf("attributes = %+v", lr.Attributes)
+	}
+}
+
+func TestCEF_Escaping(t *testing.T) {
+	enc, _ := New(FormatCEF, WithVersion("1.2.3"))
+	line, err := enc.Encode(fixtures()[1])
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	s := string(line)
+	for _, want := range []string{
+		`CEF:0|randomizedcoder|loggen|1.2.3|error|tick failed retrying \| a=b \\|7|rt=1771416001123 `,
+		` quote=say "hi" `,
+		` http={"method":"GET","path":"/a b"} `,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("CEF line missing %q:\n%s", want, s)
+		}
+	}
+}
+
+func TestNew_Unknown(t *testing.T) {
+	if _, err := New("xml"); err == nil {
+		t.Error("New(xml) succeeded, want error")
+	}
+	if Valid("xml") || !Valid(FormatGELF) {
+		t.Error("Valid() disagrees with Formats")
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		level  zapcore.Level
+		num    int
+		text   string
+		syslog int
+	}{
+		{zapcore.DebugLevel, 5, "DEBUG", 7},
+		{zapcore.InfoLevel, 9, "INFO", 6},
+		{zapcore.WarnLevel, 13, "WARN", 4},
+		{zapcore.ErrorLevel, 17, "ERROR", 3},
+		{zapcore.DPanicLevel, 21, "FATAL", 2},
+		{zapcore.PanicLevel, 21, "FATAL", 2},
+		{zapcore.FatalLevel, 21, "FATAL", 2},
+	}
+	for _, tt := range tests {
+		num, text := Severity(tt.level)
+		if num != tt.num || text != tt.text || SyslogSeverity(tt.level) != tt.syslog {
+			t.Errorf("%s: got %d %s syslog %d, want %d %s syslog %d",
+				tt.level, num, text, SyslogSeverity(tt.level), tt.num, tt.text, tt.syslog)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package encode
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// gelfEncoder renders GELF 1.1 messages, one JSON object per line:
+//
+//	{"version":"1.1","host":"node-1","short_message":"hello","timestamp":1771416000.000,"level":6,"_count":1}
+//
+// Fields become additional fields prefixed with an underscore. GELF only
+// allows string and number values, so booleans and times are sent as
+// strings, nested objects are flattened with '_' and arrays are sent as
+// JSON text.
+type gelfEncoder struct {
+	host string
+}
+
+func (e gelfEncoder) Encode(rec *record.Record) ([]byte, error) {
+	b := make([]byte, 0, 192)
+	b = append(b, `{"version":"1.1","host":`...)
+	b = appendJSONString(b, e.host)
+	b = append(b, `,"short_message":`...)
+	b = appendJSONString(b, rec.Message)
+	b = append(b, `,"timestamp":`...)
+	b = appendGELFTime(b, rec.Time)
+	b = append(b, `,"level":`...)
+	b = strconv.AppendInt(b, int64(SyslogSeverity(rec.Level)), 10)
+	if rec.Caller.Defined {
+		b = append(b, `,"_caller":`...)
+		b = appendJSONString(b, rec.Caller.TrimmedPath())
+	}
+	for _, f := range rec.Fields {
+		b = appendGELFField(b, gelfKey(f.Key), f.Value)
+	}
+	return append(b, '}', '\n'), nil
+}
+
+func appendGELFField(b []byte, key string, v any) []byte {
+	if obj, ok := v.([]record.Field); ok {
+		for _, f := range obj {
+			b = appendGELFField(b, key+"_"+gelfName(f.Key), f.Value)
+		}
+		return b
+	}
+
+	b = append(b, ',', '"', '_')
+	b = append(b, key...)
+	b = append(b, '"', ':')
+	switch v := v.(type) {
+	case int64, int, uint64:
+		return appendJSONValue(b, v)
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			return appendJSONValue(b, v)
+		}
+	}
+	return appendJSONString(b, text(v))
+}
+
+// appendGELFTime writes t as Unix seconds with millisecond decimals.
+func appendGELFTime(b []byte, t time.Time) []byte {
+	ms := t.UnixMilli()
+	b = strconv.AppendInt(b, ms/1000, 10)
+	b = append(b, '.')
+	frac := ms % 1000
+	if frac < 0 {
+		frac = -frac
+	}
+	return append(b, byte('0'+frac/100), byte('0'+frac/10%10), byte('0'+frac%10))
+}
+
+// gelfKey returns the additional field name for a top-level key. "id" is
+// renamed because Graylog reserves "_id".
+func gelfKey(k string) string {
+	if k == "id" {
+		return "id_"
+	}
+	return gelfName(k)
+}
+
+// gelfName keeps field names to the characters GELF allows.
+func gelfName(k string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, k)
+}
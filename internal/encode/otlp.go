@@ -0,0 +1,125 @@
+package encode
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// otlpEncoder renders each record as an OTLP/JSON ExportLogsServiceRequest
+// with one LogRecord, the line format of the collector's otlpjsonfile
+// receiver. The resource carries service.name, service.version and
+// host.name; the body is the message and fields become attributes.
+type otlpEncoder struct {
+	host    string
+	version string
+}
+
+func (e otlpEncoder) Encode(rec *record.Record) ([]byte, error) {
+	b := make([]byte, 0, 512)
+	b = append(b, `{"resourceLogs":[{"resource":{"attributes":[`...)
+	b = appendOTLPAttr(b, "service.name", "loggen")
+	b = append(b, ',')
+	b = appendOTLPAttr(b, "service.version", e.version)
+	b = append(b, ',')
+	b = appendOTLPAttr(b, "host.name", e.host)
+	b = append(b, `]},"scopeLogs":[{"scope":{"name":"loggen"},"logRecords":[{"timeUnixNano":"`...)
+	ts := rec.Time.UnixNano()
+	b = strconv.AppendInt(b, ts, 10)
+	b = append(b, `","observedTimeUnixNano":"`...)
+	b = strconv.AppendInt(b, ts, 10)
+	num, sev := Severity(rec.Level)
+	b = append(b, `","severityNumber":`...)
+	b = strconv.AppendInt(b, int64(num), 10)
+	b = append(b, `,"severityText":`...)
+	b = appendJSONString(b, sev)
+	b = append(b, `,"body":{"stringValue":`...)
+	b = appendJSONString(b, rec.Message)
+	b = append(b, `},"attributes":[`...)
+	n := 0
+	if rec.Caller.Defined {
+		b = appendOTLPAttr(b, "caller", rec.Caller.TrimmedPath())
+		n++
+	}
+	for _, f := range rec.Fields {
+		if n > 0 {
+			b = append(b, ',')
+		}
+		b = appendOTLPAttr(b, f.Key, f.Value)
+		n++
+	}
+	return append(b, "]}]}]}]}\n"...), nil
+}
+
+// appendOTLPAttr appends a KeyValue.
+func appendOTLPAttr(b []byte, key string, v any) []byte {
+	b = append(b, `{"key":`...)
+	b = appendJSONString(b, key)
+	b = append(b, `,"value":`...)
+	b = appendAnyValue(b, v)
+	return append(b, '}')
+}
+
+// appendAnyValue appends an AnyValue. Following the proto3 JSON mapping,
+// 64-bit integers are strings; unsigned values beyond int64 are sent as
+// strings since AnyValue has no unsigned type.
+func appendAnyValue(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case int64:
+		return appendOTLPInt(b, v)
+	case int:
+		return appendOTLPInt(b, int64(v))
+	case uint64:
+		if v <= math.MaxInt64 {
+			return appendOTLPInt(b, int64(v))
+		}
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			b = append(b, `{"doubleValue":`...)
+			b = strconv.AppendFloat(b, v, 'f', -1, 64)
+			return append(b, '}')
+		}
+		// proto3 JSON spells non-finite doubles as strings.
+		s := "NaN"
+		if math.IsInf(v, 1) {
+			s = "Infinity"
+		} else if math.IsInf(v, -1) {
+			s = "-Infinity"
+		}
+		b = append(b, `{"doubleValue":`...)
+		b = appendJSONString(b, s)
+		return append(b, '}')
+	case bool:
+		b = append(b, `{"boolValue":`...)
+		b = strconv.AppendBool(b, v)
+		return append(b, '}')
+	case []record.Field:
+		b = append(b, `{"kvlistValue":{"values":[`...)
+		for i, f := range v {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendOTLPAttr(b, f.Key, f.Value)
+		}
+		return append(b, "]}}"...)
+	case []any:
+		b = append(b, `{"arrayValue":{"values":[`...)
+		for i, item := range v {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendAnyValue(b, item)
+		}
+		return append(b, "]}}"...)
+	}
+	b = append(b, `{"stringValue":`...)
+	b = appendJSONString(b, text(v))
+	return append(b, '}')
+}
+
+func appendOTLPInt(b []byte, v int64) []byte {
+	b = append(b, `{"intValue":"`...)
+	b = strconv.AppendInt(b, v, 10)
+	return append(b, '"', '}')
+}
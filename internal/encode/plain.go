@@ -0,0 +1,54 @@
+package encode
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// plainTime is the timestamp layout of plain text lines.
+const plainTime = "2006-01-02T15:04:05.000Z07:00"
+
+// plainEncoder renders free-form text lines such as
+//
+//	2026-02-18T12:00:00.000Z INFO hello world count=1 random_string=alpha
+//
+// The message is written as is unless it spans lines; field values are
+// quoted when they contain spaces, quotes, '=' or control characters.
+type plainEncoder struct{}
+
+func (plainEncoder) Encode(rec *record.Record) ([]byte, error) {
+	b := make([]byte, 0, 128)
+	b = rec.Time.UTC().AppendFormat(b, plainTime)
+	b = append(b, ' ')
+	b = append(b, strings.ToUpper(rec.Level.String())...)
+	if rec.Message != "" {
+		b = append(b, ' ')
+		if strings.ContainsAny(rec.Message, "\r\n") || !utf8.ValidString(rec.Message) {
+			b = strconv.AppendQuote(b, rec.Message)
+		} else {
+			b = append(b, rec.Message...)
+		}
+	}
+	for _, f := range rec.Fields {
+		b = append(b, ' ')
+		b = append(b, f.Key...)
+		b = append(b, '=')
+		b = appendPlainValue(b, text(f.Value))
+	}
+	return append(b, '\n'), nil
+}
+
+func appendPlainValue(b []byte, s string) []byte {
+	if s == "" || !utf8.ValidString(s) {
+		return strconv.AppendQuote(b, s)
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f {
+			return strconv.AppendQuote(b, s)
+		}
+	}
+	return append(b, s...)
+}
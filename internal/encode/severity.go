@@ -0,0 +1,56 @@
+package encode
+
+import "go.uber.org/zap/zapcore"
+
+// Severity returns the OpenTelemetry severity number and text for level,
+// matching the mapping in the FluentBit transform.lua: dpanic and panic
+// are reported as FATAL.
+func Severity(level zapcore.Level) (int, string) {
+	switch level {
+	case zapcore.DebugLevel:
+		return 5, "DEBUG"
+	case zapcore.InfoLevel:
+		return 9, "INFO"
+	case zapcore.WarnLevel:
+		return 13, "WARN"
+	case zapcore.ErrorLevel:
+		return 17, "ERROR"
+	case zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel:
+		return 21, "FATAL"
+	default:
+		return 9, "INFO"
+	}
+}
+
+// SyslogSeverity returns the RFC 5424 severity for level: 7 (debug)
+// through 2 (critical) for the levels transform.lua reports as FATAL.
+func SyslogSeverity(level zapcore.Level) int {
+	switch level {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel:
+		return 2
+	default:
+		return 6
+	}
+}
+
+// syslogFacilities are the RFC 5424 facility codes by their usual names.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"ntp": 12, "security": 13, "console": 14, "solaris-cron": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogFacility returns the code of the syslog facility name, such as
+// user or local0.
+func SyslogFacility(name string) (int, bool) {
+	code, ok := syslogFacilities[name]
+	return code, ok
+}
@@ -0,0 +1,104 @@
+// Package gen generates realistic values, such as IP addresses, user
+// agents, HTTP requests and identifiers, for generated records. Every
+// generator draws from the caller's *rand.Rand, so a seeded loop produces
+// the same values on every run.
+package gen
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Func generates one value at time now. Identifiers that embed a
+// timestamp, such as UUIDv7 and ULID, use now so they follow the loop's
+// clock.
+type Func func(rng *rand.Rand, now time.Time) any
+
+// registry maps generator names usable from schemas to their functions.
+var registry = map[string]Func{
+	"ipv4":        func(rng *rand.Rand, _ time.Time) any { return IPv4(rng).String() },
+	"ipv6":        func(rng *rand.Rand, _ time.Time) any { return IPv6(rng).String() },
+	"user_agent":  func(rng *rand.Rand, _ time.Time) any { return UserAgent(rng) },
+	"http_method": func(rng *rand.Rand, _ time.Time) any { return HTTPMethod(rng) },
+	"http_path":   func(rng *rand.Rand, _ time.Time) any { return HTTPPath(rng) },
+	"http_status": func(rng *rand.Rand, _ time.Time) any { return int64(HTTPStatus(rng)) },
+	"email":       func(rng *rand.Rand, _ time.Time) any { return Email(rng) },
+	"hostname":    func(rng *rand.Rand, _ time.Time) any { return Hostname(rng) },
+	"uuid_v4":     func(rng *rand.Rand, _ time.Time) any { return UUIDv4(rng) },
+	"uuid_v7":     func(rng *rand.Rand, now time.Time) any { return UUIDv7(rng, now) },
+	"ulid":        func(rng *rand.Rand, now time.Time) any { return ULID(rng, now) },
+	"country":     func(rng *rand.Rand, _ time.Time) any { return CountryCode(rng) },
+	"duration": func(rng *rand.Rand, _ time.Time) any {
+		return Duration(rng, DefaultMinDuration, DefaultMaxDuration).String()
+	},
+}
+
+// Lookup returns the generator registered under name.
+func Lookup(name string) (Func, bool) {
+	f, ok := registry[name]
+	return f, ok
+}
+
+// Names returns the registered generator names in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Default bounds of the "duration" generator.
+const (
+	DefaultMinDuration = time.Millisecond
+	DefaultMaxDuration = 5 * time.Second
+)
+
+// Duration returns a duration in [lo, hi] drawn log-uniformly, so short
+// durations are common and long ones form a tail, as request latencies do.
+// It is rounded to microseconds.
+func Duration(rng *rand.Rand, lo, hi time.Duration) time.Duration {
+	if lo <= 0 {
+		lo = time.Microsecond
+	}
+	if hi <= lo {
+		return lo
+	}
+	l, h := math.Log(float64(lo)), math.Log(float64(hi))
+	d := time.Duration(math.Exp(l + rng.Float64()*(h-l)))
+	return min(max(d.Round(time.Microsecond), lo), hi)
+}
+
+// weighted picks from a fixed distribution.
+type weighted[T any] struct {
+	values []T
+	cum    []int
+	total  int
+}
+
+func newWeighted[T any](values []T, weights []int) *weighted[T] {
+	w := &weighted[T]{values: values, cum: make([]int, len(weights))}
+	for i, n := range weights {
+		w.total += n
+		w.cum[i] = w.total
+	}
+	return w
+}
+
+func (w *weighted[T]) pick(rng *rand.Rand) T {
+	n := rng.IntN(w.total)
+	for i, c := range w.cum {
+		if n < c {
+			return w.values[i]
+		}
+	}
+	return w.values[len(w.values)-1]
+}
+
+// pick returns a uniformly chosen element of values.
+func pick[T any](rng *rand.Rand, values []T) T {
+	return values[rng.IntN(len(values))]
+}
@@ -0,0 +1,77 @@
+package gen
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+var methods = newWeighted(
+	[]string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"},
+	[]int{70, 15, 5, 4, 3, 2, 1},
+)
+
+// HTTPMethod returns a request method, mostly GET.
+func HTTPMethod(rng *rand.Rand) string {
+	return methods.pick(rng)
+}
+
+var statuses = newWeighted(
+	[]int{200, 201, 204, 301, 302, 304, 400, 401, 403, 404, 429, 500, 502, 503},
+	[]int{700, 30, 20, 10, 15, 60, 25, 15, 10, 60, 10, 20, 10, 15},
+)
+
+// HTTPStatus returns a response status code. About 75% are 2xx, with a
+// realistic share of redirects, client errors and server errors.
+func HTTPStatus(rng *rand.Rand) int {
+	return statuses.pick(rng)
+}
+
+var (
+	resources = []string{"users", "orders", "products", "carts", "sessions", "invoices"}
+	assets    = []string{"app", "vendor", "main", "runtime"}
+	words     = []string{"shoes", "laptop", "coffee", "headphones", "chair", "lamp"}
+)
+
+// pathTemplates generate paths in proportion to how often they occur.
+var pathTemplates = newWeighted([]func(rng *rand.Rand) string{
+	func(rng *rand.Rand) string {
+		return fmt.Sprintf("/api/v1/%s/%d", pick(rng, resources), 1+rng.IntN(99999))
+	},
+	func(rng *rand.Rand) string {
+		return fmt.Sprintf("/api/v1/%s", pick(rng, resources))
+	},
+	func(rng *rand.Rand) string {
+		return fmt.Sprintf("/api/v1/%s/%d/items", pick(rng, resources), 1+rng.IntN(99999))
+	},
+	func(rng *rand.Rand) string {
+		return fmt.Sprintf("/static/js/%s.%08x.js", pick(rng, assets), rng.Uint32())
+	},
+	func(rng *rand.Rand) string {
+		return fmt.Sprintf("/search?q=%s&page=%d", pick(rng, words), 1+rng.IntN(5))
+	},
+	func(*rand.Rand) string { return "/" },
+	func(*rand.Rand) string { return "/healthz" },
+}, []int{30, 20, 10, 15, 10, 10, 5})
+
+// HTTPPath returns a request path, including query strings for searches.
+func HTTPPath(rng *rand.Rand) string {
+	return pathTemplates.pick(rng)(rng)
+}
+
+var userAgents = newWeighted([]string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+	"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+	"curl/8.7.1",
+	"Go-http-client/1.1",
+	"python-requests/2.31.0",
+	"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+	"kube-probe/1.30",
+}, []int{30, 15, 10, 15, 10, 5, 4, 4, 4, 3})
+
+// UserAgent returns a browser, tool or crawler user agent.
+func UserAgent(rng *rand.Rand) string {
+	return userAgents.pick(rng)
+}
@@ -0,0 +1,43 @@
+package gen
+
+import (
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTTPPath_Valid(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	for i := 0; i < 1000; i++ {
+		p := HTTPPath(rng)
+		if !strings.HasPrefix(p, "/") {
+			t.Fatalf("HTTPPath() = %q, want a leading slash", p)
+		}
+		if _, err := url.ParseRequestURI(p); err != nil {
+			t.Fatalf("HTTPPath() = %q is not a request URI: %v", p, err)
+		}
+	}
+}
+
+func TestHTTPMethod_MostlyGET(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	get := 0
+	for i := 0; i < 10000; i++ {
+		if HTTPMethod(rng) == "GET" {
+			get++
+		}
+	}
+	if get < 6500 || get > 7500 {
+		t.Errorf("%d of 10000 methods are GET, want about 70%%", get)
+	}
+}
+
+func TestUserAgent_NoQuotes(t *testing.T) {
+	// Access logs quote the user agent, so it must not contain quotes.
+	for _, ua := range userAgents.values {
+		if strings.ContainsRune(ua, '"') {
+			t.Errorf("user agent %q contains a quote", ua)
+		}
+	}
+}
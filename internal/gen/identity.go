@@ -0,0 +1,32 @@
+package gen
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+var (
+	firstNames   = []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi", "ivan", "judy", "mallory", "oscar"}
+	lastNames    = []string{"smith", "jones", "garcia", "muller", "rossi", "tanaka", "kim", "nguyen", "silva", "novak"}
+	emailDomains = []string{"example.com", "example.org", "mail.example.net", "corp.example"}
+)
+
+// Email returns an address such as "grace.kim42@example.org".
+func Email(rng *rand.Rand) string {
+	local := pick(rng, firstNames) + "." + pick(rng, lastNames)
+	if rng.IntN(2) == 0 {
+		local += fmt.Sprint(rng.IntN(100))
+	}
+	return local + "@" + pick(rng, emailDomains)
+}
+
+var countries = newWeighted(
+	[]string{"US", "GB", "DE", "FR", "IN", "BR", "JP", "CN", "CA", "AU", "NL", "ES", "IT", "KR", "MX", "SE", "PL", "ZA", "NG", "SG"},
+	[]int{30, 8, 8, 6, 10, 6, 5, 5, 4, 3, 2, 3, 3, 2, 2, 1, 1, 1, 1, 1},
+)
+
+// CountryCode returns an ISO 3166-1 alpha-2 code, weighted roughly by
+// internet population.
+func CountryCode(rng *rand.Rand) string {
+	return countries.pick(rng)
+}
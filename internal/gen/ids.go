@@ -0,0 +1,52 @@
+package gen
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// UUIDv4 returns a random (version 4) UUID.
+func UUIDv4(rng *rand.Rand) string {
+	var b [16]byte
+	putUint64(b[:8], rng.Uint64())
+	putUint64(b[8:], rng.Uint64())
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// UUIDv7 returns a time-ordered (version 7) UUID for now.
+func UUIDv7(rng *rand.Rand, now time.Time) string {
+	var b [16]byte
+	putUint64(b[8:], rng.Uint64())
+	ms := uint64(now.UnixMilli())
+	putUint64(b[:8], ms<<16|rng.Uint64()&0xffff)
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// crockford is the ULID alphabet.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a 26-character ULID for now: a 48-bit millisecond
+// timestamp followed by 80 random bits, in Crockford base32.
+func ULID(rng *rand.Rand, now time.Time) string {
+	ms := uint64(now.UnixMilli()) & (1<<48 - 1)
+	hi := ms<<16 | rng.Uint64()&0xffff // 48-bit time, 16 random bits
+	lo := rng.Uint64()                 // 64 random bits
+
+	var out [26]byte
+	// 128 bits are written as 26 5-bit groups; the first holds only 3 bits.
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,63 @@
+package gen
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUUIDv4_Format(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	rng := rand.New(rand.NewPCG(1, 1))
+	for i := 0; i < 100; i++ {
+		if u := UUIDv4(rng); !re.MatchString(u) {
+			t.Fatalf("UUIDv4() = %q", u)
+		}
+	}
+}
+
+func TestUUIDv7_TimeOrdered(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	rng := rand.New(rand.NewPCG(1, 1))
+
+	prev := ""
+	for i := 0; i < 100; i++ {
+		u := UUIDv7(rng, testNow.Add(time.Duration(i)*time.Millisecond))
+		if !re.MatchString(u) {
+			t.Fatalf("UUIDv7() = %q", u)
+		}
+		if u[:13] < prev {
+			t.Fatalf("UUIDv7() timestamp prefix %q sorts before %q", u[:13], prev)
+		}
+		prev = u[:13]
+	}
+	if got := UUIDv7(rng, testNow)[:13]; got != "019c709f-1600" {
+		t.Errorf("UUIDv7() timestamp = %q, want 019c709f-1600 (%d ms)", got, testNow.UnixMilli())
+	}
+}
+
+func TestULID(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	u := ULID(rng, testNow)
+	if len(u) != 26 {
+		t.Fatalf("ULID() = %q, want 26 characters", u)
+	}
+	if strings.Trim(u, crockford) != "" {
+		t.Errorf("ULID() = %q contains characters outside the Crockford alphabet", u)
+	}
+
+	// The first 10 characters encode the millisecond timestamp.
+	var ms uint64
+	for _, c := range u[:10] {
+		ms = ms<<5 | uint64(strings.IndexRune(crockford, c))
+	}
+	if int64(ms) != testNow.UnixMilli() {
+		t.Errorf("ULID() timestamp = %d, want %d", ms, testNow.UnixMilli())
+	}
+
+	if later := ULID(rng, testNow.Add(time.Millisecond)); later <= u {
+		t.Errorf("ULID() %q for a later time sorts before %q", later, u)
+	}
+}
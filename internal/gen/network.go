@@ -0,0 +1,68 @@
+package gen
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+)
+
+// IPv4 returns a random unicast IPv4 address outside the private, loopback
+// and link-local ranges, like a client address seen by an edge proxy.
+func IPv4(rng *rand.Rand) netip.Addr {
+	for {
+		v := rng.Uint32()
+		a := netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+		if a.IsGlobalUnicast() && !a.IsPrivate() && v>>24 < 224 && v>>24 != 0 {
+			return a
+		}
+	}
+}
+
+// IPv6 returns a random address in the global unicast range 2000::/3.
+func IPv6(rng *rand.Rand) netip.Addr {
+	var b [16]byte
+	hi, lo := rng.Uint64(), rng.Uint64()
+	putUint64(b[:8], hi)
+	putUint64(b[8:], lo)
+	b[0] = 0x20 | b[0]&0x1f
+	return netip.AddrFrom16(b)
+}
+
+// IPInPrefix returns a random address inside prefix, for example to keep
+// clients within a customer's CIDR block.
+func IPInPrefix(rng *rand.Rand, prefix netip.Prefix) netip.Addr {
+	prefix = prefix.Masked()
+	base := prefix.Addr().AsSlice()
+	hostBits := len(base)*8 - prefix.Bits()
+
+	out := make([]byte, len(base))
+	copy(out, base)
+	for i := len(out) - 1; i >= 0 && hostBits > 0; i-- {
+		r := byte(rng.Uint32())
+		if hostBits < 8 {
+			r &= byte(1<<hostBits - 1)
+		}
+		out[i] |= r
+		hostBits -= 8
+	}
+
+	a, _ := netip.AddrFromSlice(out)
+	return a
+}
+
+var (
+	hostRoles   = []string{"web", "api", "db", "cache", "worker", "gateway", "auth", "search"}
+	hostRegions = []string{"us-east-1", "us-west-2", "eu-west-1", "eu-central-1", "ap-southeast-1"}
+)
+
+// Hostname returns a host name such as "api-07.eu-west-1.example.internal".
+func Hostname(rng *rand.Rand) string {
+	return fmt.Sprintf("%s-%02d.%s.example.internal",
+		pick(rng, hostRoles), 1+rng.IntN(32), pick(rng, hostRegions))
+}
+
+func putUint64(b []byte, v uint64) {
+	for i := range 8 {
+		b[i] = byte(v >> (56 - 8*i))
+	}
+}
@@ -0,0 +1,60 @@
+package gen
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"regexp"
+	"testing"
+)
+
+func TestIPv4_Public(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	for i := 0; i < 10000; i++ {
+		a := IPv4(rng)
+		if !a.Is4() || a.IsPrivate() || a.IsLoopback() || a.IsMulticast() || a.IsLinkLocalUnicast() {
+			t.Fatalf("IPv4() = %v, want a public unicast address", a)
+		}
+	}
+}
+
+func TestIPv6_GlobalUnicast(t *testing.T) {
+	prefix := netip.MustParsePrefix("2000::/3")
+	rng := rand.New(rand.NewPCG(1, 1))
+	for i := 0; i < 1000; i++ {
+		if a := IPv6(rng); !prefix.Contains(a) {
+			t.Fatalf("IPv6() = %v, want inside %v", a, prefix)
+		}
+	}
+}
+
+func TestIPInPrefix(t *testing.T) {
+	tests := []string{"10.20.0.0/16", "192.0.2.128/25", "198.51.100.7/32", "2001:db8:abcd::/48", "0.0.0.0/0"}
+
+	for _, p := range tests {
+		t.Run(p, func(t *testing.T) {
+			prefix := netip.MustParsePrefix(p)
+			rng := rand.New(rand.NewPCG(5, 6))
+			seen := make(map[netip.Addr]bool)
+			for i := 0; i < 200; i++ {
+				a := IPInPrefix(rng, prefix)
+				if !prefix.Contains(a) {
+					t.Fatalf("IPInPrefix() = %v, want inside %v", a, prefix)
+				}
+				seen[a] = true
+			}
+			if prefix.Bits() < 24 && len(seen) < 150 {
+				t.Errorf("only %d distinct addresses out of 200", len(seen))
+			}
+		})
+	}
+}
+
+func TestHostname(t *testing.T) {
+	re := regexp.MustCompile(`^[a-z]+-\d{2}\.[a-z0-9-]+\.example\.internal$`)
+	rng := rand.New(rand.NewPCG(1, 1))
+	for i := 0; i < 100; i++ {
+		if h := Hostname(rng); !re.MatchString(h) {
+			t.Fatalf("Hostname() = %q", h)
+		}
+	}
+}
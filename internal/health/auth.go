@@ -0,0 +1,286 @@
+package health
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Role is the access level granted to an authenticated principal.
+type Role int
+
+// Roles, ordered from least to most privileged.
+const (
+	RoleNone Role = iota
+	RoleReader
+	RoleAdmin
+)
+
+// String returns the role name used in credential files.
+func (r Role) String() string {
+	switch r {
+	case RoleReader:
+		return "read"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// ParseRole converts a role name from a credential file.
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "read", "reader", "readonly", "read-only":
+		return RoleReader, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return RoleNone, fmt.Errorf("unknown role %q", s)
+	}
+}
+
+// adminPrefix is the path prefix of endpoints that require credentials.
+// Everything else (/health, /ready, /metrics) stays open for probes and
+// scrapers.
+const adminPrefix = "/admin/"
+
+// AuthOptions points at the static credential files for admin endpoints.
+type AuthOptions struct {
+	// TokenFile holds bearer tokens, one "token,user,role" entry per line.
+	TokenFile string
+
+	// BasicFile holds basic auth users, one "user:<hash>:role" entry per
+	// line, where <hash> is a PBKDF2 hash written by HashPassword.
+	BasicFile string
+}
+
+// Enabled reports whether any credential source has been configured.
+func (o AuthOptions) Enabled() bool {
+	return o.TokenFile != "" || o.BasicFile != ""
+}
+
+// Principal identifies an authenticated caller.
+type Principal struct {
+	User   string
+	Role   Role
+	Method string // "bearer" or "basic"
+}
+
+type basicUser struct {
+	hash passwordHash
+	role Role
+}
+
+// Authenticator validates bearer tokens and basic auth credentials loaded
+// from static files.
+type Authenticator struct {
+	tokens map[string]Principal
+	users  map[string]basicUser
+
+	// decoy is checked for unknown users, so response timing does not
+	// reveal which user names exist.
+	decoy passwordHash
+}
+
+// LoadAuthenticator reads the configured credential files.
+func LoadAuthenticator(o AuthOptions) (*Authenticator, error) {
+	a := &Authenticator{
+		tokens: make(map[string]Principal),
+		users:  make(map[string]basicUser),
+	}
+
+	if o.TokenFile != "" {
+		if err := a.loadTokens(o.TokenFile); err != nil {
+			return nil, err
+		}
+	}
+	if o.BasicFile != "" {
+		if err := a.loadBasic(o.BasicFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+func (a *Authenticator) loadTokens(path string) error {
+	return readCredentialLines(path, func(lineNo int, line string) error {
+		parts := strings.Split(line, ",")
+		if len(parts) != 3 {
+			return fmt.Errorf("%s:%d: want token,user,role", path, lineNo)
+		}
+		token, user := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if token == "" || user == "" {
+			return fmt.Errorf("%s:%d: empty token or user", path, lineNo)
+		}
+		role, err := ParseRole(parts[2])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		a.tokens[token] = Principal{User: user, Role: role, Method: "bearer"}
+		return nil
+	})
+}
+
+func (a *Authenticator) loadBasic(path string) error {
+	return readCredentialLines(path, func(lineNo int, line string) error {
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return fmt.Errorf("%s:%d: want user:<hash>:role", path, lineNo)
+		}
+		hash, err := parsePasswordHash(parts[1])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		role, err := ParseRole(parts[2])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		a.users[parts[0]] = basicUser{hash: hash, role: role}
+		if a.decoy.key == nil {
+			a.decoy = passwordHash{iterations: hash.iterations, salt: hash.salt, key: make([]byte, len(hash.key))}
+		}
+		return nil
+	})
+}
+
+// readCredentialLines calls fn for every non-blank, non-comment line.
+func readCredentialLines(path string, fn func(lineNo int, line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := fn(lineNo, line); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Authenticate returns the principal for the request's credentials.
+// ok is false when no valid credentials were supplied.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, bool) {
+	if a == nil {
+		return Principal{}, false
+	}
+
+	// Authentication schemes are case-insensitive (RFC 7235 section 2.1).
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if strings.EqualFold(scheme, "Bearer") {
+		return a.lookupToken(strings.TrimSpace(token))
+	}
+
+	if user, pass, found := r.BasicAuth(); found {
+		u, known := a.users[user]
+		if !known {
+			if a.decoy.key != nil {
+				a.decoy.verify(pass)
+			}
+			return Principal{}, false
+		}
+		if !u.hash.verify(pass) {
+			return Principal{}, false
+		}
+		return Principal{User: user, Role: u.role, Method: "basic"}, true
+	}
+
+	return Principal{}, false
+}
+
+// lookupToken compares against every token in constant time so response
+// timing does not reveal how much of a guess matched.
+func (a *Authenticator) lookupToken(token string) (Principal, bool) {
+	var match Principal
+	found := false
+	for candidate, p := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			match, found = p, true
+		}
+	}
+	return match, found
+}
+
+// requiredRole returns the role needed for a request to an admin endpoint.
+// Reads only need RoleReader; anything that may change state needs RoleAdmin.
+func requiredRole(r *http.Request) Role {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return RoleReader
+	}
+	return RoleAdmin
+}
+
+// statusRecorder captures the response status for audit logging.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// requireAuth wraps an admin handler with authentication, role checks and
+// audit logging of every request, whether allowed or denied.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	audit := s.logger.Named("audit")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields := []zap.Field{
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.String("remote_addr", r.RemoteAddr),
+		}
+
+		if s.auth == nil {
+			audit.Warn("admin request denied", append(fields,
+				zap.String("reason", "admin authentication not configured"))...)
+			http.Error(w, "admin endpoints disabled", http.StatusForbidden)
+			return
+		}
+
+		p, ok := s.auth.Authenticate(r)
+		if !ok {
+			audit.Warn("admin request denied", append(fields,
+				zap.String("reason", "missing or invalid credentials"))...)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="loggen", Basic realm="loggen"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		fields = append(fields,
+			zap.String("user", p.User),
+			zap.Stringer("role", p.Role),
+			zap.String("auth_method", p.Method),
+		)
+
+		if need := requiredRole(r); p.Role < need {
+			audit.Warn("admin request denied", append(fields,
+				zap.String("reason", "insufficient role"),
+				zap.Stringer("required_role", need))...)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		audit.Info("admin request", append(fields, zap.Int("status", rec.status))...)
+	})
+}
@@ -0,0 +1,350 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// testHash hashes password with the minimum iteration count, to keep the
+// tests fast.
+func testHash(t *testing.T, password string) string {
+	t.Helper()
+	h, err := hashPassword(password, minPasswordIterations)
+	if err != nil {
+		t.Fatalf("hashPassword() error = %v", err)
+	}
+	return h
+}
+
+// newAuthServer returns a server with credentials loaded and an observer
+// capturing its logs.
+func newAuthServer(t *testing.T) (*Server, *observer.ObservedLogs) {
+	t.Helper()
+	dir := t.TempDir()
+
+	tokens := "# token,user,role\n" +
+		"admin-token,alice,admin\n" +
+		"reader-token,bob,read\n"
+	users := "carol:" + testHash(t, "s3cret") + ":admin\n" +
+		"dave:" + testHash(t, "hunter2") + ":read\n"
+
+	auth, err := LoadAuthenticator(AuthOptions{
+		TokenFile: writeFile(t, dir, "tokens.csv", []byte(tokens)),
+		BasicFile: writeFile(t, dir, "users", []byte(users)),
+	})
+	if err != nil {
+		t.Fatalf("LoadAuthenticator() error = %v", err)
+	}
+
+	core, logs := observer.New(zap.InfoLevel)
+	s := NewServer(0, zap.New(core))
+	s.auth = auth
+	return s, logs
+}
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Role
+		wantErr bool
+	}{
+		{"admin", RoleAdmin, false},
+		{"ADMIN", RoleAdmin, false},
+		{"read", RoleReader, false},
+		{"read-only", RoleReader, false},
+		{"root", RoleNone, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRole(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRole(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRole(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAuthenticator_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts func(dir string) AuthOptions
+	}{
+		{
+			name: "missing file",
+			opts: func(dir string) AuthOptions { return AuthOptions{TokenFile: dir + "/nope"} },
+		},
+		{
+			name: "token line without role",
+			opts: func(dir string) AuthOptions {
+				return AuthOptions{TokenFile: writeFile(t, dir, "t", []byte("tok,user\n"))}
+			},
+		},
+		{
+			name: "token with unknown role",
+			opts: func(dir string) AuthOptions {
+				return AuthOptions{TokenFile: writeFile(t, dir, "t", []byte("tok,user,root\n"))}
+			},
+		},
+		{
+			name: "basic with bad hash",
+			opts: func(dir string) AuthOptions {
+				return AuthOptions{BasicFile: writeFile(t, dir, "u", []byte("u:$pbkdf2-sha256$i=600000$c2FsdHNhbHQ$zz:admin\n"))}
+			},
+		},
+		{
+			name: "basic with unsalted sha256",
+			opts: func(dir string) AuthOptions {
+				return AuthOptions{BasicFile: writeFile(t, dir, "u", []byte("u:sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b:admin\n"))}
+			},
+		},
+		{
+			name: "basic with too few iterations",
+			opts: func(dir string) AuthOptions {
+				h, _ := hashPassword("pw", 1000)
+				return AuthOptions{BasicFile: writeFile(t, dir, "u", []byte("u:"+h+":admin\n"))}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadAuthenticator(tt.opts(t.TempDir())); err == nil {
+				t.Error("LoadAuthenticator() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestServer_AdminAuthorization(t *testing.T) {
+	s, _ := newAuthServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		setAuth    func(r *http.Request)
+		wantStatus int
+	}{
+		{
+			name:       "health stays open",
+			method:     http.MethodGet,
+			path:       "/health",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "ready stays open",
+			method:     http.MethodGet,
+			path:       "/ready",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "admin without credentials",
+			method:     http.MethodGet,
+			path:       "/admin/ready",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "admin with wrong token",
+			method:     http.MethodGet,
+			path:       "/admin/ready",
+			setAuth:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "bearer scheme is case-insensitive",
+			method:     http.MethodGet,
+			path:       "/admin/ready",
+			setAuth:    func(r *http.Request) { r.Header.Set("Authorization", "bearer reader-token") },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "reader token can read",
+			method:     http.MethodGet,
+			path:       "/admin/ready",
+			setAuth:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer reader-token") },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "reader token cannot write",
+			method:     http.MethodPut,
+			path:       "/admin/ready",
+			body:       `{"ready": true}`,
+			setAuth:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer reader-token") },
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "admin token can write",
+			method:     http.MethodPut,
+			path:       "/admin/ready",
+			body:       `{"ready": true}`,
+			setAuth:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer admin-token") },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "basic admin can write",
+			method:     http.MethodPut,
+			path:       "/admin/ready",
+			body:       `{"ready": true}`,
+			setAuth:    func(r *http.Request) { r.SetBasicAuth("carol", "s3cret") },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "basic reader cannot write",
+			method:     http.MethodPut,
+			path:       "/admin/ready",
+			body:       `{"ready": true}`,
+			setAuth:    func(r *http.Request) { r.SetBasicAuth("dave", "hunter2") },
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "basic wrong password",
+			method:     http.MethodGet,
+			path:       "/admin/ready",
+			setAuth:    func(r *http.Request) { r.SetBasicAuth("carol", "wrong") },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "basic unknown user",
+			method:     http.MethodGet,
+			path:       "/admin/ready",
+			setAuth:    func(r *http.Request) { r.SetBasicAuth("mallory", "s3cret") },
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.setAuth != nil {
+				tt.setAuth(req)
+			}
+			w := httptest.NewRecorder()
+
+			s.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServer_AdminDisabledWithoutAuth(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s := NewServer(0, zap.New(core))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/ready", nil)
+	req.Header.Set("Authorization", "Bearer anything")
+	w := httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if logs.FilterMessage("admin request denied").Len() != 1 {
+		t.Error("denied request was not audit-logged")
+	}
+}
+
+func TestServer_AdminReady(t *testing.T) {
+	s, _ := newAuthServer(t)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/admin/ready", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-token")
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := put(`{"ready": false}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if s.IsReady() {
+		t.Error("IsReady() = true after PUT ready=false")
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != `{"ready":false}` {
+		t.Errorf("PUT body = %s, want {\"ready\":false}", got)
+	}
+
+	if w := put(`{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT without ready status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := put(`not json`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT invalid JSON status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/ready", nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestServer_AdminAuditLog(t *testing.T) {
+	s, logs := newAuthServer(t)
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/ready", strings.NewReader(`{"ready": true}`))
+	req.Header.Set("Authorization", "Bearer admin-token")
+	s.mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/ready", strings.NewReader(`{"ready": true}`))
+	req.Header.Set("Authorization", "Bearer reader-token")
+	s.mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	allowed := logs.FilterMessage("admin request").AllUntimed()
+	if len(allowed) != 1 {
+		t.Fatalf("got %d allowed audit entries, want 1", len(allowed))
+	}
+	ctx := allowed[0].ContextMap()
+	if ctx["user"] != "alice" || ctx["role"] != "admin" || ctx["method"] != http.MethodPut {
+		t.Errorf("audit entry = %v, want user=alice role=admin method=PUT", ctx)
+	}
+	if allowed[0].LoggerName != "audit" {
+		t.Errorf("audit logger name = %q, want %q", allowed[0].LoggerName, "audit")
+	}
+
+	denied := logs.FilterMessage("admin request denied").AllUntimed()
+	if len(denied) != 1 {
+		t.Fatalf("got %d denied audit entries, want 1", len(denied))
+	}
+	if got := denied[0].ContextMap()["reason"]; got != "insufficient role" {
+		t.Errorf("denied reason = %v, want %q", got, "insufficient role")
+	}
+}
+
+func TestServer_HandleAdminPrefixesPath(t *testing.T) {
+	s, _ := newAuthServer(t)
+	s.HandleAdmin("/custom", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/custom", nil)
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/custom", nil)
+	req.Header.Set("Authorization", "Bearer reader-token")
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("authenticated status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
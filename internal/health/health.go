@@ -3,51 +3,160 @@ package health
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// unixPrefix marks an Options.Addr as a Unix domain socket path.
+const unixPrefix = "unix:"
+
+// Options configures where and how the server listens.
+type Options struct {
+	// Addr is "host:port", ":port", or "unix:/path/to.sock".
+	Addr string
+
+	// TLS enables HTTPS and optional client certificate verification.
+	TLS TLSOptions
+
+	// Auth configures credentials for /admin/ endpoints. Without it the
+	// admin endpoints reject every request.
+	Auth AuthOptions
+}
+
 // Server provides health check endpoints.
 type Server struct {
-	port   int
+	opts   Options
 	logger *zap.Logger
 	server *http.Server
+	mux    *http.ServeMux
+	auth   *Authenticator
 	ready  atomic.Bool
+
+	mu       sync.Mutex
+	listener net.Listener
 }
 
-// NewServer creates a new health check server.
+// NewServer creates a new health check server listening on all interfaces.
 func NewServer(port int, logger *zap.Logger) *Server {
+	return NewServerWithOptions(Options{Addr: fmt.Sprintf(":%d", port)}, logger)
+}
+
+// NewServerWithOptions creates a new health check server with a custom
+// bind address and TLS settings.
+func NewServerWithOptions(opts Options, logger *zap.Logger) *Server {
 	s := &Server{
-		port:   port,
+		opts:   opts,
 		logger: logger,
+		mux:    http.NewServeMux(),
 	}
 	s.ready.Store(true)
+
+	s.mux.HandleFunc("/health", s.handleHealth)
+	s.mux.HandleFunc("/ready", s.handleReady)
+	s.HandleAdmin("/admin/ready", http.HandlerFunc(s.handleAdminReady))
+
 	return s
 }
 
-// Start begins serving health endpoints. This method blocks until the server
-// is shut down or encounters an error.
-func (s *Server) Start(ctx context.Context) error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/health", s.handleHealth)
-	mux.HandleFunc("/ready", s.handleReady)
+// Handle registers an unauthenticated handler, such as /metrics.
+// It must be called before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleAdmin registers a handler under /admin/ that requires credentials.
+// GET and HEAD need the read role; every other method needs the admin role.
+// It must be called before Start.
+func (s *Server) HandleAdmin(pattern string, handler http.Handler) {
+	if !strings.HasPrefix(pattern, adminPrefix) {
+		pattern = adminPrefix + strings.TrimPrefix(pattern, "/")
+	}
+	s.mux.Handle(pattern, s.requireAuth(handler))
+}
 
-	s.server = &http.Server{
-		Addr:              fmt.Sprintf(":%d", s.port),
-		Handler:           mux,
+// Listen sets up TLS and admin authentication and opens the listener.
+// Errors in the settings are returned here, before anything is served, so
+// the caller can exit instead of running without a health server.
+func (s *Server) Listen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return nil
+	}
+
+	srv := &http.Server{
+		Handler:           s.mux,
 		ReadTimeout:       5 * time.Second,
 		ReadHeaderTimeout: 5 * time.Second,
 		WriteTimeout:      5 * time.Second,
 		IdleTimeout:       60 * time.Second,
 	}
 
-	s.logger.Info("health server starting", zap.Int("port", s.port))
+	if s.opts.TLS.Enabled() {
+		tlsCfg, err := buildTLSConfig(s.opts.TLS)
+		if err != nil {
+			return fmt.Errorf("health server TLS setup: %w", err)
+		}
+		srv.TLSConfig = tlsCfg
+	}
+
+	if s.opts.Auth.Enabled() {
+		auth, err := LoadAuthenticator(s.opts.Auth)
+		if err != nil {
+			return fmt.Errorf("health server auth setup: %w", err)
+		}
+		s.auth = auth
+	} else {
+		s.logger.Warn("admin authentication not configured, /admin/ endpoints are disabled")
+	}
+
+	ln, err := listen(s.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("health server listen on %s: %w", s.opts.Addr, err)
+	}
+
+	s.server = srv
+	s.listener = ln
+	return nil
+}
 
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+// Start begins serving health endpoints, calling Listen first unless it
+// has already been called. This method blocks until the server is shut
+// down or encounters an error.
+func (s *Server) Start(ctx context.Context) error {
+	if err := s.Listen(); err != nil {
+		s.logger.Error("health server setup failed", zap.Error(err))
+		return err
+	}
+
+	s.mu.Lock()
+	srv, ln := s.server, s.listener
+	s.mu.Unlock()
+
+	s.logger.Info("health server starting",
+		zap.String("addr", ln.Addr().String()),
+		zap.Bool("tls", srv.TLSConfig != nil),
+		zap.Bool("client_auth", s.opts.TLS.ClientCAFile != ""),
+		zap.Bool("admin_auth", s.auth != nil),
+	)
+
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ServeTLS(ln, "", "")
+	} else {
+		err = srv.Serve(ln)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		s.logger.Error("health server error", zap.Error(err))
 		return err
 	}
@@ -55,11 +164,48 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// listen opens a TCP or Unix domain socket listener for addr. A stale
+// socket left behind by a previous run is removed first; any other file at
+// the path is an error, so a mistyped path never deletes data.
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		fi, err := os.Lstat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		case fi.Mode()&os.ModeSocket == 0:
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		default:
+			if err := os.Remove(path); err != nil {
+				return nil, fmt.Errorf("remove stale socket: %w", err)
+			}
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Addr returns the address the server is listening on, or nil if it has
+// not started yet.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
 // Shutdown gracefully stops the server.
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.ready.Store(false)
 
-	if s.server == nil {
+	s.mu.Lock()
+	srv := s.server
+	s.mu.Unlock()
+
+	if srv == nil {
 		return nil
 	}
 
@@ -67,7 +213,7 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	defer cancel()
 
 	s.logger.Info("health server shutting down")
-	return s.server.Shutdown(shutdownCtx)
+	return srv.Shutdown(shutdownCtx)
 }
 
 // SetReady updates the readiness status.
@@ -113,3 +259,32 @@ func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// adminReadyRequest is the body accepted by PUT /admin/ready.
+type adminReadyRequest struct {
+	Ready *bool `json:"ready"`
+}
+
+// handleAdminReady reports or overrides readiness so an operator can drain
+// the pod from its Service without stopping generation.
+func (s *Server) handleAdminReady(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut, http.MethodPost:
+		var req adminReadyRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil || req.Ready == nil {
+			http.Error(w, `body must be {"ready": true|false}`, http.StatusBadRequest)
+			return
+		}
+		s.SetReady(*req.Ready)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_ = json.NewEncoder(w).Encode(map[string]bool{"ready": s.IsReady()})
+	}
+}
@@ -3,8 +3,12 @@ package health
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -212,3 +216,61 @@ func TestServer_ShutdownWithoutStart(t *testing.T) {
 		t.Errorf("Shutdown() without Start() error = %v", err)
 	}
 }
+
+func TestServer_UnixSocket(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	sock := filepath.Join(t.TempDir(), "health.sock")
+
+	// A stale socket from a previous run must not block startup.
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := NewServerWithOptions(Options{Addr: "unix:" + sock}, logger)
+	startServer(t, s)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}}
+
+	resp, err := client.Get("http://unix/ready")
+	if err != nil {
+		t.Fatalf("GET /ready over unix socket error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /ready status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestServer_UnixSocketRefusesRegularFile(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	path := filepath.Join(t.TempDir(), "health.sock")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	s := NewServerWithOptions(Options{Addr: "unix:" + path}, logger)
+	if err := s.Listen(); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("Listen() error = %v, want refusal to replace a regular file", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+		t.Errorf("regular file was changed: %q, %v", data, err)
+	}
+}
+
+func TestServer_ListenError(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	s := NewServerWithOptions(Options{Addr: "256.0.0.1:0"}, logger)
+
+	if err := s.Start(context.Background()); err == nil {
+		t.Error("Start() with invalid address succeeded, want error")
+	}
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Basic auth passwords are stored as salted PBKDF2-HMAC-SHA256 hashes in
+// the PHC string format, $pbkdf2-sha256$i=<iterations>$<salt>$<hash>, with
+// unpadded base64 salt and hash.
+const (
+	passwordScheme = "pbkdf2-sha256"
+
+	// DefaultPasswordIterations follows the OWASP recommendation for
+	// PBKDF2-HMAC-SHA256.
+	DefaultPasswordIterations = 600_000
+
+	// minPasswordIterations rejects hashes too cheap to slow down a
+	// brute-force search.
+	minPasswordIterations = 100_000
+
+	passwordSaltSize = 16
+)
+
+var passwordEncoding = base64.RawStdEncoding
+
+// passwordHash is a parsed PBKDF2 hash.
+type passwordHash struct {
+	iterations int
+	salt       []byte
+	key        []byte
+}
+
+// HashPassword returns a hash of password with a random salt, for basic
+// auth credential files.
+func HashPassword(password string) (string, error) {
+	return hashPassword(password, DefaultPasswordIterations)
+}
+
+func hashPassword(password string, iterations int) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$%s$i=%d$%s$%s", passwordScheme, iterations,
+		passwordEncoding.EncodeToString(salt), passwordEncoding.EncodeToString(key)), nil
+}
+
+// parsePasswordHash reads a hash written by HashPassword.
+func parsePasswordHash(s string) (passwordHash, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != passwordScheme {
+		return passwordHash{}, fmt.Errorf("want a $%s$i=<iterations>$<salt>$<hash> password hash", passwordScheme)
+	}
+	iter, ok := strings.CutPrefix(parts[2], "i=")
+	iterations, err := strconv.Atoi(iter)
+	if !ok || err != nil {
+		return passwordHash{}, fmt.Errorf("invalid iteration count %q", parts[2])
+	}
+	if iterations < minPasswordIterations {
+		return passwordHash{}, fmt.Errorf("iteration count %d is below the minimum of %d", iterations, minPasswordIterations)
+	}
+	salt, err := passwordEncoding.DecodeString(parts[3])
+	if err != nil || len(salt) < 8 {
+		return passwordHash{}, errors.New("invalid salt")
+	}
+	key, err := passwordEncoding.DecodeString(parts[4])
+	if err != nil || len(key) != sha256.Size {
+		return passwordHash{}, errors.New("invalid hash")
+	}
+	return passwordHash{iterations: iterations, salt: salt, key: key}, nil
+}
+
+// verify reports whether password matches the hash.
+func (h passwordHash) verify(password string) bool {
+	key, err := pbkdf2.Key(sha256.New, password, h.salt, h.iterations, len(h.key))
+	return err == nil && subtle.ConstantTimeCompare(key, h.key) == 1
+}
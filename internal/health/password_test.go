@@ -0,0 +1,54 @@
+package health
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	h, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if !strings.HasPrefix(h, "$pbkdf2-sha256$i=600000$") {
+		t.Errorf("HashPassword() = %q, want a PHC pbkdf2-sha256 string", h)
+	}
+
+	parsed, err := parsePasswordHash(h)
+	if err != nil {
+		t.Fatalf("parsePasswordHash() error = %v", err)
+	}
+	if !parsed.verify("s3cret") || parsed.verify("s3cret ") || parsed.verify("") {
+		t.Error("verify() does not match exactly the hashed password")
+	}
+
+	// Equal passwords get different salts and so different hashes.
+	again, _ := HashPassword("s3cret")
+	if again == h {
+		t.Error("two hashes of the same password are equal")
+	}
+}
+
+func TestParsePasswordHash_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		want string
+	}{
+		{"other scheme", "$2y$10$abcdefghijklmnopqrstuu", "want a $pbkdf2-sha256"},
+		{"missing fields", "$pbkdf2-sha256$i=600000$c2FsdHNhbHQ", "want a $pbkdf2-sha256"},
+		{"bad iterations", "$pbkdf2-sha256$rounds=600000$c2FsdHNhbHQ$AAAA", "invalid iteration count"},
+		{"cheap", "$pbkdf2-sha256$i=1000$c2FsdHNhbHQ$AAAA", "below the minimum"},
+		{"short salt", "$pbkdf2-sha256$i=600000$c2FsdA$AAAA", "invalid salt"},
+		{"short hash", "$pbkdf2-sha256$i=600000$c2FsdHNhbHQ$AAAA", "invalid hash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePasswordHash(tt.hash)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parsePasswordHash() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
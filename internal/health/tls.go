@@ -0,0 +1,159 @@
+package health
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSOptions configures HTTPS and client certificate verification.
+type TLSOptions struct {
+	// CertFile and KeyFile are PEM files for the server certificate.
+	// TLS is enabled when both are set.
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile, when set, requires clients to present a certificate
+	// signed by one of the CAs it contains (mTLS).
+	ClientCAFile string
+}
+
+// Enabled reports whether any TLS setting is present. The server then
+// serves HTTPS, or refuses to start if the settings do not validate.
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != "" || o.ClientCAFile != ""
+}
+
+// validate checks that the options form a usable combination.
+func (o TLSOptions) validate() error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return errors.New("tls: both certificate and key files must be set")
+	}
+	if o.ClientCAFile != "" && o.CertFile == "" {
+		return errors.New("tls: client CA requires a server certificate and key")
+	}
+	return nil
+}
+
+// buildTLSConfig returns a tls.Config that serves a reloadable certificate
+// and optionally verifies client certificates.
+func buildTLSConfig(o TLSOptions) (*tls.Config, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	reloader, err := newCertReloader(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if o.ClientCAFile != "" {
+		pem, err := os.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates found in %s", o.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// certReloader serves a certificate pair and reloads it from disk when
+// either file's modification time changes, so rotated certificates (for
+// example from cert-manager) are picked up without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certMod  time.Time
+	keyMod   time.Time
+	lastStat time.Time
+}
+
+// reloadCheckInterval limits how often the files are stat'ed.
+const reloadCheckInterval = time.Second
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastStat) >= reloadCheckInterval {
+		r.lastStat = time.Now()
+		if r.changed() {
+			// Keep serving the previous certificate if the new pair is
+			// half-written or otherwise invalid.
+			_ = r.reloadLocked()
+		}
+	}
+
+	return r.cert, nil
+}
+
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reloadLocked()
+}
+
+func (r *certReloader) reloadLocked() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load key pair: %w", err)
+	}
+
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	r.lastStat = time.Now()
+	return nil
+}
+
+func (r *certReloader) changed() bool {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return false
+	}
+	return !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+}
+
+func (r *certReloader) modTimes() (time.Time, time.Time, error) {
+	ci, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("tls: stat certificate: %w", err)
+	}
+	ki, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("tls: stat key: %w", err)
+	}
+	return ci.ModTime(), ki.ModTime(), nil
+}
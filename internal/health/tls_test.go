@@ -0,0 +1,333 @@
+package health
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// testCA is a self-signed CA that can issue server and client certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "loggen test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns PEM-encoded certificate and key signed by the CA.
+func (ca *testCA) issue(t *testing.T, cn string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile(%s) error = %v", name, err)
+	}
+	return path
+}
+
+// startServer runs s in the background and waits until it is listening.
+func startServer(t *testing.T, s *Server) {
+	t.Helper()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.Start(context.Background())
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Addr() == nil {
+		select {
+		case err := <-errChan:
+			t.Fatalf("Start() returned early: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start listening")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Cleanup(func() {
+		_ = s.Shutdown(context.Background())
+		<-errChan
+	})
+}
+
+func TestTLSOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    TLSOptions
+		wantErr bool
+	}{
+		{"disabled", TLSOptions{}, false},
+		{"cert and key", TLSOptions{CertFile: "c", KeyFile: "k"}, false},
+		{"cert only", TLSOptions{CertFile: "c"}, true},
+		{"key only", TLSOptions{KeyFile: "k"}, true},
+		{"client CA without cert", TLSOptions{ClientCAFile: "ca"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServer_TLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+
+	s := NewServerWithOptions(Options{
+		Addr: "127.0.0.1:0",
+		TLS: TLSOptions{
+			CertFile: writeFile(t, dir, "tls.crt", certPEM),
+			KeyFile:  writeFile(t, dir, "tls.key", keyPEM),
+		},
+	}, zaptest.NewLogger(t))
+	startServer(t, s)
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}}
+
+	resp, err := client.Get("https://" + s.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("GET /health over TLS error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /health status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// Plaintext requests must not be served.
+	resp, err = http.Get("http://" + s.Addr().String() + "/health")
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Error("plaintext GET /health succeeded on TLS server")
+		}
+	}
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+	clientCertPEM, clientKeyPEM := ca.issue(t, "probe", 3, x509.ExtKeyUsageClientAuth)
+
+	s := NewServerWithOptions(Options{
+		Addr: "127.0.0.1:0",
+		TLS: TLSOptions{
+			CertFile:     writeFile(t, dir, "tls.crt", certPEM),
+			KeyFile:      writeFile(t, dir, "tls.key", keyPEM),
+			ClientCAFile: writeFile(t, dir, "ca.crt", ca.pem),
+		},
+	}, zaptest.NewLogger(t))
+	startServer(t, s)
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	url := "https://" + s.Addr().String() + "/health"
+
+	t.Run("without client certificate", func(t *testing.T) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}}
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			t.Error("GET without client certificate succeeded, want handshake failure")
+		}
+	})
+
+	t.Run("with client certificate", func(t *testing.T) {
+		clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+		if err != nil {
+			t.Fatalf("X509KeyPair() error = %v", err)
+		}
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}},
+		}}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("GET with client certificate error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /health status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "first", 2, x509.ExtKeyUsageServerAuth)
+	certFile := writeFile(t, dir, "tls.crt", certPEM)
+	keyFile := writeFile(t, dir, "tls.key", keyPEM)
+
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+
+	cert, _ := r.GetCertificate(nil)
+	if got := cert.Leaf.Subject.CommonName; got != "first" {
+		t.Fatalf("initial CommonName = %q, want %q", got, "first")
+	}
+
+	// Replace the pair and push the modification time forward so the
+	// change is visible regardless of filesystem timestamp granularity.
+	certPEM, keyPEM = ca.issue(t, "second", 3, x509.ExtKeyUsageServerAuth)
+	writeFile(t, dir, "tls.crt", certPEM)
+	writeFile(t, dir, "tls.key", keyPEM)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+	_ = os.Chtimes(keyFile, future, future)
+
+	r.mu.Lock()
+	r.lastStat = time.Time{}
+	r.mu.Unlock()
+
+	cert, _ = r.GetCertificate(nil)
+	if got := cert.Leaf.Subject.CommonName; got != "second" {
+		t.Errorf("reloaded CommonName = %q, want %q", got, "second")
+	}
+}
+
+func TestCertReloader_KeepsCertOnInvalidReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "good", 2, x509.ExtKeyUsageServerAuth)
+	certFile := writeFile(t, dir, "tls.crt", certPEM)
+	writeFile(t, dir, "tls.key", keyPEM)
+
+	r, err := newCertReloader(certFile, filepath.Join(dir, "tls.key"))
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+
+	writeFile(t, dir, "tls.crt", []byte("not a certificate"))
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+
+	r.mu.Lock()
+	r.lastStat = time.Time{}
+	r.mu.Unlock()
+
+	cert, err := r.GetCertificate(nil)
+	if err != nil || cert == nil {
+		t.Fatalf("GetCertificate() = %v, %v; want previous certificate", cert, err)
+	}
+	if got := cert.Leaf.Subject.CommonName; got != "good" {
+		t.Errorf("CommonName = %q, want %q", got, "good")
+	}
+}
+
+func TestBuildTLSConfig_BadClientCA(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+
+	_, err := buildTLSConfig(TLSOptions{
+		CertFile:     writeFile(t, dir, "tls.crt", certPEM),
+		KeyFile:      writeFile(t, dir, "tls.key", keyPEM),
+		ClientCAFile: writeFile(t, dir, "ca.crt", []byte("garbage")),
+	})
+	if err == nil {
+		t.Error("buildTLSConfig() with invalid client CA succeeded, want error")
+	}
+}
+
+func TestServer_ClientCAWithoutCertificate(t *testing.T) {
+	s := NewServerWithOptions(Options{
+		Addr: "127.0.0.1:0",
+		TLS:  TLSOptions{ClientCAFile: "ca.pem"},
+	}, zaptest.NewLogger(t))
+
+	if err := s.Listen(); err == nil || !strings.Contains(err.Error(), "client CA requires") {
+		t.Errorf("Listen() error = %v, want the client CA to need a certificate", err)
+	}
+	if s.Addr() != nil {
+		t.Error("server listens despite invalid TLS settings")
+	}
+}
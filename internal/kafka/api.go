@@ -0,0 +1,480 @@
+package kafka
+
+import (
+	"fmt"
+	"slices"
+)
+
+// API keys.
+const (
+	apiProduce        = 0
+	apiMetadata       = 3
+	apiAPIVersions    = 18
+	apiInitProducerID = 22
+)
+
+var apiNames = map[int16]string{
+	apiProduce:        "Produce",
+	apiMetadata:       "Metadata",
+	apiAPIVersions:    "ApiVersions",
+	apiInitProducerID: "InitProducerId",
+}
+
+// supported are the versions spoken for each API key. Produce v3 is the
+// first version with v2 record batches, which idempotent producers need;
+// Metadata v1 and InitProducerId v0 date from the same release, Kafka 0.11.
+// The newest are the last before the flexible encoding. A connection uses
+// the newest version its broker supports too, so brokers that dropped the
+// versions older than Kafka 2.1 (KIP-896, Kafka 4.0) still get one they
+// accept. ApiVersions v0 is what every broker answers.
+var supported = map[int16]APIVersion{
+	apiProduce:        {APIKey: apiProduce, MinVersion: 3, MaxVersion: 8},
+	apiMetadata:       {APIKey: apiMetadata, MinVersion: 1, MaxVersion: 8},
+	apiAPIVersions:    {APIKey: apiAPIVersions, MinVersion: 0, MaxVersion: 0},
+	apiInitProducerID: {APIKey: apiInitProducerID, MinVersion: 0, MaxVersion: 1},
+}
+
+// SupportedVersions returns the versions spoken for each API key, ordered
+// by key, as a broker answers ApiVersions.
+func SupportedVersions() []APIVersion {
+	vs := make([]APIVersion, 0, len(supported))
+	for _, v := range supported {
+		vs = append(vs, v)
+	}
+	slices.SortFunc(vs, func(a, b APIVersion) int { return int(a.APIKey) - int(b.APIKey) })
+	return vs
+}
+
+// Message is a request or response body. It is encoded and decoded in the
+// version of the encoder or decoder.
+type Message interface {
+	encode(e *encoder)
+	decode(d *decoder)
+}
+
+// Request is a message a client sends.
+type Request interface {
+	Message
+	apiKey() int16
+}
+
+// RequestHeader precedes every request.
+type RequestHeader struct {
+	APIKey        int16
+	APIVersion    int16
+	CorrelationID int32
+	ClientID      string
+}
+
+// APIVersion is the range of versions a broker supports for an API key.
+type APIVersion struct {
+	APIKey     int16
+	MinVersion int16
+	MaxVersion int16
+}
+
+// APIVersionsRequest asks a broker for the versions it supports.
+type APIVersionsRequest struct{}
+
+func (*APIVersionsRequest) apiKey() int16 { return apiAPIVersions }
+
+func (*APIVersionsRequest) encode(*encoder) {}
+
+func (*APIVersionsRequest) decode(*decoder) {}
+
+// APIVersionsResponse lists the versions the broker supports.
+type APIVersionsResponse struct {
+	Err  Error
+	APIs []APIVersion
+}
+
+func (r *APIVersionsResponse) encode(e *encoder) {
+	e.int16(int16(r.Err))
+	e.arrayLen(len(r.APIs))
+	for _, a := range r.APIs {
+		e.int16(a.APIKey)
+		e.int16(a.MinVersion)
+		e.int16(a.MaxVersion)
+	}
+}
+
+func (r *APIVersionsResponse) decode(d *decoder) {
+	r.Err = Error(d.int16())
+	for range d.arrayLen() {
+		r.APIs = append(r.APIs, APIVersion{APIKey: d.int16(), MinVersion: d.int16(), MaxVersion: d.int16()})
+	}
+}
+
+// Broker is a node of the cluster.
+type Broker struct {
+	NodeID int32
+	Host   string
+	Port   int32
+}
+
+// Address returns the host:port of the broker.
+func (b Broker) Address() string {
+	return joinHostPort(b.Host, b.Port)
+}
+
+// MetadataRequest asks for the brokers and the partitions of Topics, or
+// of every topic when Topics is empty. From v4 it lets the broker create
+// missing topics, as v1 to v3 do implicitly.
+type MetadataRequest struct {
+	Topics []string
+}
+
+func (*MetadataRequest) apiKey() int16 { return apiMetadata }
+
+func (r *MetadataRequest) encode(e *encoder) {
+	if len(r.Topics) == 0 {
+		e.int32(-1)
+	} else {
+		e.arrayLen(len(r.Topics))
+		for _, t := range r.Topics {
+			e.string(t)
+		}
+	}
+	if e.version >= 4 {
+		e.bool(true) // allow_auto_topic_creation
+	}
+	if e.version >= 8 {
+		e.bool(false) // include_cluster_authorized_operations
+		e.bool(false) // include_topic_authorized_operations
+	}
+}
+
+func (r *MetadataRequest) decode(d *decoder) {
+	n := d.arrayLen()
+	r.Topics = make([]string, 0, n)
+	for range n {
+		r.Topics = append(r.Topics, d.string())
+	}
+	if d.version >= 4 {
+		d.bool()
+	}
+	if d.version >= 8 {
+		d.bool()
+		d.bool()
+	}
+}
+
+// MetadataResponse lists the brokers and the leader of each partition.
+type MetadataResponse struct {
+	Brokers      []Broker
+	ControllerID int32
+	Topics       []TopicMetadata
+}
+
+// TopicMetadata describes the partitions of a topic.
+type TopicMetadata struct {
+	Err        Error
+	Name       string
+	Internal   bool
+	Partitions []PartitionMetadata
+}
+
+// PartitionMetadata names the leader of a partition. Leader is -1 while an
+// election is running.
+type PartitionMetadata struct {
+	Err       Error
+	Partition int32
+	Leader    int32
+	Replicas  []int32
+	ISR       []int32
+}
+
+// The fields a producer does not use, such as racks, leader epochs and
+// authorized operations, are skipped when decoding and left empty when
+// encoding.
+func (r *MetadataResponse) encode(e *encoder) {
+	if e.version >= 3 {
+		e.int32(0) // throttle_time_ms
+	}
+	e.arrayLen(len(r.Brokers))
+	for _, b := range r.Brokers {
+		e.int32(b.NodeID)
+		e.string(b.Host)
+		e.int32(b.Port)
+		e.nullableString("") // rack
+	}
+	if e.version >= 2 {
+		e.nullableString("") // cluster_id
+	}
+	e.int32(r.ControllerID)
+	e.arrayLen(len(r.Topics))
+	for _, t := range r.Topics {
+		e.int16(int16(t.Err))
+		e.string(t.Name)
+		e.bool(t.Internal)
+		e.arrayLen(len(t.Partitions))
+		for _, p := range t.Partitions {
+			e.int16(int16(p.Err))
+			e.int32(p.Partition)
+			e.int32(p.Leader)
+			if e.version >= 7 {
+				e.int32(-1) // leader_epoch
+			}
+			encodeInt32s(e, p.Replicas)
+			encodeInt32s(e, p.ISR)
+			if e.version >= 5 {
+				e.arrayLen(0) // offline_replicas
+			}
+		}
+		if e.version >= 8 {
+			e.int32(0) // topic_authorized_operations
+		}
+	}
+	if e.version >= 8 {
+		e.int32(0) // cluster_authorized_operations
+	}
+}
+
+func (r *MetadataResponse) decode(d *decoder) {
+	if d.version >= 3 {
+		d.int32()
+	}
+	for range d.arrayLen() {
+		b := Broker{NodeID: d.int32(), Host: d.string(), Port: d.int32()}
+		d.string() // rack
+		r.Brokers = append(r.Brokers, b)
+	}
+	if d.version >= 2 {
+		d.string()
+	}
+	r.ControllerID = d.int32()
+	for range d.arrayLen() {
+		t := TopicMetadata{Err: Error(d.int16()), Name: d.string(), Internal: d.bool()}
+		for range d.arrayLen() {
+			p := PartitionMetadata{Err: Error(d.int16()), Partition: d.int32(), Leader: d.int32()}
+			if d.version >= 7 {
+				d.int32()
+			}
+			p.Replicas = decodeInt32s(d)
+			p.ISR = decodeInt32s(d)
+			if d.version >= 5 {
+				decodeInt32s(d)
+			}
+			t.Partitions = append(t.Partitions, p)
+		}
+		if d.version >= 8 {
+			d.int32()
+		}
+		r.Topics = append(r.Topics, t)
+	}
+	if d.version >= 8 {
+		d.int32()
+	}
+}
+
+// InitProducerIDRequest asks for a producer ID and epoch for idempotent
+// produce requests. Transactions are not supported, so the transactional
+// ID is always null. Versions 0 and 1 are encoded alike.
+type InitProducerIDRequest struct {
+	TransactionTimeoutMs int32
+}
+
+func (*InitProducerIDRequest) apiKey() int16 { return apiInitProducerID }
+
+func (r *InitProducerIDRequest) encode(e *encoder) {
+	e.nullableString("")
+	e.int32(r.TransactionTimeoutMs)
+}
+
+func (r *InitProducerIDRequest) decode(d *decoder) {
+	d.string()
+	r.TransactionTimeoutMs = d.int32()
+}
+
+// InitProducerIDResponse carries the producer ID and epoch.
+type InitProducerIDResponse struct {
+	ThrottleTimeMs int32
+	Err            Error
+	ProducerID     int64
+	ProducerEpoch  int16
+}
+
+func (r *InitProducerIDResponse) encode(e *encoder) {
+	e.int32(r.ThrottleTimeMs)
+	e.int16(int16(r.Err))
+	e.int64(r.ProducerID)
+	e.int16(r.ProducerEpoch)
+}
+
+func (r *InitProducerIDResponse) decode(d *decoder) {
+	r.ThrottleTimeMs = d.int32()
+	r.Err = Error(d.int16())
+	r.ProducerID = d.int64()
+	r.ProducerEpoch = d.int16()
+}
+
+// Required acknowledgements of a produce request.
+const (
+	AcksNone   int16 = 0
+	AcksLeader int16 = 1
+	AcksAll    int16 = -1
+)
+
+// ProduceRequest appends record batches to partitions. With AcksNone the
+// broker sends no response.
+type ProduceRequest struct {
+	Acks      int16
+	TimeoutMs int32
+	Topics    []ProduceTopic
+}
+
+// ProduceTopic holds the record batches of a topic, by partition.
+type ProduceTopic struct {
+	Name       string
+	Partitions []ProducePartition
+}
+
+// ProducePartition holds the encoded record batches of a partition, as
+// returned by AppendRecordBatch.
+type ProducePartition struct {
+	Partition int32
+	Records   []byte
+}
+
+// Versions 3 to 8 of the request are encoded alike.
+func (*ProduceRequest) apiKey() int16 { return apiProduce }
+
+func (r *ProduceRequest) encode(e *encoder) {
+	e.nullableString("") // transactional ID
+	e.int16(r.Acks)
+	e.int32(r.TimeoutMs)
+	e.arrayLen(len(r.Topics))
+	for _, t := range r.Topics {
+		e.string(t.Name)
+		e.arrayLen(len(t.Partitions))
+		for _, p := range t.Partitions {
+			e.int32(p.Partition)
+			e.bytes(p.Records)
+		}
+	}
+}
+
+func (r *ProduceRequest) decode(d *decoder) {
+	d.string()
+	r.Acks = d.int16()
+	r.TimeoutMs = d.int32()
+	for range d.arrayLen() {
+		t := ProduceTopic{Name: d.string()}
+		for range d.arrayLen() {
+			t.Partitions = append(t.Partitions, ProducePartition{Partition: d.int32(), Records: d.bytes()})
+		}
+		r.Topics = append(r.Topics, t)
+	}
+}
+
+// ProduceResponse reports the outcome of each partition of a produce
+// request.
+type ProduceResponse struct {
+	Topics         []ProduceTopicResponse
+	ThrottleTimeMs int32
+}
+
+// ProduceTopicResponse reports the partitions of a topic.
+type ProduceTopicResponse struct {
+	Name       string
+	Partitions []ProducePartitionResponse
+}
+
+// ProducePartitionResponse is the outcome for one partition. BaseOffset
+// is the offset of the first appended record. From v8 the broker may
+// explain Err in ErrorMessage; the per-record errors are skipped.
+type ProducePartitionResponse struct {
+	Partition       int32
+	Err             Error
+	BaseOffset      int64
+	LogAppendTimeMs int64
+	LogStartOffset  int64
+	ErrorMessage    string
+}
+
+func (r *ProduceResponse) encode(e *encoder) {
+	e.arrayLen(len(r.Topics))
+	for _, t := range r.Topics {
+		e.string(t.Name)
+		e.arrayLen(len(t.Partitions))
+		for _, p := range t.Partitions {
+			e.int32(p.Partition)
+			e.int16(int16(p.Err))
+			e.int64(p.BaseOffset)
+			e.int64(p.LogAppendTimeMs)
+			if e.version >= 5 {
+				e.int64(p.LogStartOffset)
+			}
+			if e.version >= 8 {
+				e.arrayLen(0) // record_errors
+				e.nullableString(p.ErrorMessage)
+			}
+		}
+	}
+	e.int32(r.ThrottleTimeMs)
+}
+
+func (r *ProduceResponse) decode(d *decoder) {
+	for range d.arrayLen() {
+		t := ProduceTopicResponse{Name: d.string()}
+		for range d.arrayLen() {
+			p := ProducePartitionResponse{
+				Partition:       d.int32(),
+				Err:             Error(d.int16()),
+				BaseOffset:      d.int64(),
+				LogAppendTimeMs: d.int64(),
+			}
+			if d.version >= 5 {
+				p.LogStartOffset = d.int64()
+			}
+			if d.version >= 8 {
+				for range d.arrayLen() {
+					d.int32()  // batch_index
+					d.string() // batch_index_error_message
+				}
+				p.ErrorMessage = d.string()
+			}
+			t.Partitions = append(t.Partitions, p)
+		}
+		r.Topics = append(r.Topics, t)
+	}
+	r.ThrottleTimeMs = d.int32()
+}
+
+// newRequest returns an empty request body for a header, for brokers
+// decoding requests.
+func newRequest(h RequestHeader) (Request, error) {
+	var r Request
+	switch h.APIKey {
+	case apiProduce:
+		r = &ProduceRequest{}
+	case apiMetadata:
+		r = &MetadataRequest{}
+	case apiAPIVersions:
+		r = &APIVersionsRequest{}
+	case apiInitProducerID:
+		r = &InitProducerIDRequest{}
+	default:
+		return nil, fmt.Errorf("kafka: unsupported api key %d", h.APIKey)
+	}
+	if v := supported[h.APIKey]; h.APIVersion < v.MinVersion || h.APIVersion > v.MaxVersion {
+		return nil, fmt.Errorf("kafka: unsupported version %d of %s", h.APIVersion, apiNames[h.APIKey])
+	}
+	return r, nil
+}
+
+func encodeInt32s(e *encoder, vs []int32) {
+	e.arrayLen(len(vs))
+	for _, v := range vs {
+		e.int32(v)
+	}
+}
+
+func decodeInt32s(d *decoder) []int32 {
+	n := d.arrayLen()
+	vs := make([]int32, 0, n)
+	for range n {
+		vs = append(vs, d.int32())
+	}
+	return vs
+}
@@ -0,0 +1,248 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/snappy"
+)
+
+// Compression is the codec of a record batch, stored in its attributes.
+type Compression int8
+
+// Record batch codecs. LZ4 and zstd are recognised when reading but cannot
+// be written: the Go standard library has neither.
+const (
+	CompressionNone   Compression = 0
+	CompressionGzip   Compression = 1
+	CompressionSnappy Compression = 2
+	CompressionLZ4    Compression = 3
+	CompressionZstd   Compression = 4
+)
+
+// ParseCompression returns the codec called name: none, gzip or snappy.
+func ParseCompression(name string) (Compression, bool) {
+	switch name {
+	case "none":
+		return CompressionNone, true
+	case "gzip":
+		return CompressionGzip, true
+	case "snappy":
+		return CompressionSnappy, true
+	}
+	return 0, false
+}
+
+const (
+	magic = 2
+
+	// batchOverhead is the size of the batch header up to and including
+	// the record count.
+	batchOverhead = 61
+
+	// crcOffset is where the CRC sits; it covers everything after it.
+	crcOffset = 17
+
+	compressionMask = 0x07
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Record is a message of a record batch. A nil Key is a null key.
+type Record struct {
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+// RecordBatch is a v2 record batch. Idempotent producers set ProducerID,
+// ProducerEpoch and the BaseSequence of the first record; others leave
+// ProducerID at -1.
+type RecordBatch struct {
+	BaseOffset    int64
+	ProducerID    int64
+	ProducerEpoch int16
+	BaseSequence  int32
+	Compression   Compression
+	Records       []Record
+}
+
+// NextSequence returns the sequence number n records after seq. Sequence
+// numbers wrap to 0 after math.MaxInt32, as brokers expect.
+func NextSequence(seq int32, n int) int32 {
+	return int32((int64(seq) + int64(n)) % (math.MaxInt32 + 1))
+}
+
+// AppendRecordBatch appends b in the v2 record batch format, as carried by
+// produce requests.
+func AppendRecordBatch(dst []byte, b *RecordBatch) ([]byte, error) {
+	if len(b.Records) == 0 {
+		return nil, errors.New("kafka: empty record batch")
+	}
+	first, last := b.Records[0].Time, b.Records[0].Time
+	for _, r := range b.Records[1:] {
+		if r.Time.Before(first) {
+			first = r.Time
+		}
+		if r.Time.After(last) {
+			last = r.Time
+		}
+	}
+
+	var recs encoder
+	for i, r := range b.Records {
+		var body encoder
+		body.int8(0) // attributes
+		body.varint(r.Time.UnixMilli() - first.UnixMilli())
+		body.varint(int64(i))
+		body.varBytes(r.Key)
+		body.varBytes(r.Value)
+		body.varint(0) // headers
+		recs.varint(int64(len(body.b)))
+		recs.b = append(recs.b, body.b...)
+	}
+	payload, err := compress(b.Compression, recs.b)
+	if err != nil {
+		return nil, err
+	}
+
+	start := len(dst)
+	e := encoder{b: dst}
+	e.int64(b.BaseOffset)
+	e.int32(int32(batchOverhead - 12 + len(payload))) // length after this field
+	e.int32(-1)                                       // partition leader epoch
+	e.int8(magic)
+	e.int32(0) // CRC, filled in below
+	e.int16(int16(b.Compression))
+	e.int32(int32(len(b.Records) - 1)) // last offset delta
+	e.int64(first.UnixMilli())
+	e.int64(last.UnixMilli())
+	e.int64(b.ProducerID)
+	e.int16(b.ProducerEpoch)
+	e.int32(b.BaseSequence)
+	e.int32(int32(len(b.Records)))
+	e.b = append(e.b, payload...)
+
+	crc := crc32.Checksum(e.b[start+crcOffset+4:], castagnoli)
+	binary.BigEndian.PutUint32(e.b[start+crcOffset:], crc)
+	return e.b, nil
+}
+
+// ReadRecordBatches decodes the record batches in data, checking their
+// CRCs, as a broker receiving a produce request does.
+func ReadRecordBatches(data []byte) ([]RecordBatch, error) {
+	var batches []RecordBatch
+	for len(data) > 0 {
+		if len(data) < batchOverhead {
+			return nil, ErrMalformed
+		}
+		size := int(int32(binary.BigEndian.Uint32(data[8:]))) + 12
+		if size < batchOverhead || size > len(data) {
+			return nil, ErrMalformed
+		}
+		b, err := readRecordBatch(data[:size])
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+		data = data[size:]
+	}
+	return batches, nil
+}
+
+func readRecordBatch(data []byte) (RecordBatch, error) {
+	d := decoder{b: data}
+	b := RecordBatch{BaseOffset: d.int64()}
+	d.int32() // length
+	d.int32() // partition leader epoch
+	if m := d.int8(); m != magic {
+		return b, fmt.Errorf("kafka: record batch magic %d, want %d", m, magic)
+	}
+	crc := uint32(d.int32())
+	if crc32.Checksum(d.b, castagnoli) != crc {
+		return b, errors.New("kafka: record batch CRC mismatch")
+	}
+	attrs := d.int16()
+	b.Compression = Compression(attrs & compressionMask)
+	d.int32() // last offset delta
+	base := d.int64()
+	d.int64() // max timestamp
+	b.ProducerID = d.int64()
+	b.ProducerEpoch = d.int16()
+	b.BaseSequence = d.int32()
+	count := d.int32()
+
+	recs, err := decompress(b.Compression, d.b)
+	if err != nil {
+		return b, err
+	}
+	rd := decoder{b: recs}
+	for range count {
+		if rd.err != nil {
+			break
+		}
+		size := rd.varint()
+		body := decoder{b: rd.take(int(size))}
+		body.int8() // attributes
+		r := Record{Time: time.UnixMilli(base + body.varint()).UTC()}
+		body.varint() // offset delta
+		r.Key = body.varBytes()
+		r.Value = body.varBytes()
+		headers := body.varint()
+		if headers < 0 || headers > int64(len(body.b)) {
+			return b, ErrMalformed
+		}
+		for range headers {
+			body.varBytes()
+			body.varBytes()
+		}
+		if err := body.finish(); err != nil {
+			return b, err
+		}
+		b.Records = append(b.Records, r)
+	}
+	return b, rd.finish()
+}
+
+func compress(c Compression, src []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return src, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(src); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, src), nil
+	}
+	return nil, fmt.Errorf("kafka: compression %d is not supported", c)
+}
+
+func decompress(c Compression, src []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return src, nil
+	case CompressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(zr)
+	case CompressionSnappy:
+		return snappy.Decode(src)
+	}
+	return nil, fmt.Errorf("kafka: compression %d is not supported", c)
+}
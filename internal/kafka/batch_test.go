@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordBatch_RoundTrip(t *testing.T) {
+	t0 := time.Date(2026, 2, 18, 12, 0, 0, 123000000, time.UTC)
+	records := []Record{
+		{Key: []byte("k1"), Value: []byte(`{"msg":"one"}`), Time: t0},
+		{Value: []byte(strings.Repeat("two ", 100)), Time: t0.Add(1500 * time.Millisecond)},
+		{Key: []byte{}, Value: nil, Time: t0.Add(-time.Second)},
+	}
+
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionSnappy} {
+		in := RecordBatch{ProducerID: 7, ProducerEpoch: 1, BaseSequence: 42, Compression: c, Records: records}
+		prefix := []byte("prefix")
+		data, err := AppendRecordBatch(prefix, &in)
+		if err != nil {
+			t.Fatalf("compression %d: AppendRecordBatch() error = %v", c, err)
+		}
+		if !bytes.HasPrefix(data, prefix) {
+			t.Fatalf("compression %d: AppendRecordBatch() did not append", c)
+		}
+
+		// Two batches back to back, as in a produce request.
+		data = append(data[len(prefix):], data[len(prefix):]...)
+		out, err := ReadRecordBatches(data)
+		if err != nil {
+			t.Fatalf("compression %d: ReadRecordBatches() error = %v", c, err)
+		}
+		if len(out) != 2 {
+			t.Fatalf("compression %d: got %d batches, want 2", c, len(out))
+		}
+		got := out[0]
+		if got.ProducerID != 7 || got.ProducerEpoch != 1 || got.BaseSequence != 42 || got.Compression != c {
+			t.Errorf("compression %d: header = %+v", c, got)
+		}
+		if len(got.Records) != len(records) {
+			t.Fatalf("compression %d: got %d records, want %d", c, len(got.Records), len(records))
+		}
+		for i, r := range got.Records {
+			want := records[i]
+			if !bytes.Equal(r.Key, want.Key) || (r.Key == nil) != (want.Key == nil) ||
+				!bytes.Equal(r.Value, want.Value) || (r.Value == nil) != (want.Value == nil) ||
+				!r.Time.Equal(want.Time.Truncate(time.Millisecond)) {
+				t.Errorf("compression %d: record %d = %+v, want %+v", c, i, r, want)
+			}
+		}
+	}
+}
+
+func TestRecordBatch_Errors(t *testing.T) {
+	if _, err := AppendRecordBatch(nil, &RecordBatch{}); err == nil {
+		t.Error("AppendRecordBatch(empty) succeeded, want an error")
+	}
+	if _, err := AppendRecordBatch(nil, &RecordBatch{Compression: CompressionZstd, Records: []Record{{Value: []byte("x")}}}); err == nil {
+		t.Error("AppendRecordBatch(zstd) succeeded, want an error")
+	}
+
+	data, err := AppendRecordBatch(nil, &RecordBatch{ProducerID: -1, Records: []Record{{Value: []byte("value")}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-3] ^= 0xff
+	if _, err := ReadRecordBatches(corrupt); err == nil || !strings.Contains(err.Error(), "CRC") {
+		t.Errorf("ReadRecordBatches(corrupt) error = %v, want a CRC mismatch", err)
+	}
+	if _, err := ReadRecordBatches(data[:len(data)-1]); !errors.Is(err, ErrMalformed) {
+		t.Errorf("ReadRecordBatches(truncated) error = %v, want ErrMalformed", err)
+	}
+}
+
+func TestNextSequence(t *testing.T) {
+	tests := []struct {
+		seq  int32
+		n    int
+		want int32
+	}{
+		{0, 5, 5},
+		{math.MaxInt32 - 5, 5, math.MaxInt32},
+		{math.MaxInt32 - 5, 6, 0},
+		{math.MaxInt32, 1, 0},
+		{math.MaxInt32 - 1, 100, 98},
+	}
+	for _, tt := range tests {
+		if got := NextSequence(tt.seq, tt.n); got != tt.want {
+			t.Errorf("NextSequence(%d, %d) = %d, want %d", tt.seq, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	for name, want := range map[string]Compression{"none": CompressionNone, "gzip": CompressionGzip, "snappy": CompressionSnappy} {
+		if got, ok := ParseCompression(name); !ok || got != want {
+			t.Errorf("ParseCompression(%q) = %d, %v", name, got, ok)
+		}
+	}
+	if _, ok := ParseCompression("lz4"); ok {
+		t.Error("ParseCompression(lz4) succeeded")
+	}
+}
@@ -0,0 +1,223 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// maxFrame bounds the size of a request or response read, as brokers do
+// with socket.request.max.bytes.
+const maxFrame = 100 << 20
+
+// Conn is a client connection to one broker. Requests are sent one at a
+// time and wait for their response. The first request is preceded by
+// ApiVersions, which picks the version of each request. It is not safe for
+// concurrent use.
+type Conn struct {
+	conn     net.Conn
+	clientID string
+	timeout  time.Duration
+	nextID   int32
+	buf      []byte
+
+	// versions maps each API key both sides support to the newest
+	// common version. It is nil until negotiated.
+	versions map[int16]int16
+}
+
+// NewConn wraps conn, identifying as clientID. Each request and its
+// response must complete within timeout.
+func NewConn(conn net.Conn, clientID string, timeout time.Duration) *Conn {
+	return &Conn{conn: conn, clientID: clientID, timeout: timeout}
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Metadata fetches the brokers and the partitions of topics.
+func (c *Conn) Metadata(topics ...string) (*MetadataResponse, error) {
+	resp := &MetadataResponse{}
+	if err := c.roundTrip(&MetadataRequest{Topics: topics}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// InitProducerID fetches a producer ID and epoch for idempotent produce
+// requests.
+func (c *Conn) InitProducerID(timeout time.Duration) (*InitProducerIDResponse, error) {
+	resp := &InitProducerIDResponse{}
+	if err := c.roundTrip(&InitProducerIDRequest{TransactionTimeoutMs: int32(timeout.Milliseconds())}, resp); err != nil {
+		return nil, err
+	}
+	if resp.Err != ErrNone {
+		return nil, resp.Err
+	}
+	return resp, nil
+}
+
+// Produce sends req. With AcksNone it returns once the request is written,
+// with a nil response.
+func (c *Conn) Produce(req *ProduceRequest) (*ProduceResponse, error) {
+	if req.Acks == AcksNone {
+		version, err := c.Version(apiProduce)
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.send(req, version)
+	}
+	resp := &ProduceResponse{}
+	if err := c.roundTrip(req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Version returns the version spoken for the API key, negotiating the
+// versions first if needed.
+func (c *Conn) Version(apiKey int16) (int16, error) {
+	if apiKey == apiAPIVersions {
+		return 0, nil
+	}
+	if c.versions == nil {
+		if err := c.negotiate(); err != nil {
+			return 0, err
+		}
+	}
+	v, ok := c.versions[apiKey]
+	if !ok {
+		ours := supported[apiKey]
+		return 0, fmt.Errorf("kafka: broker does not support %s v%d to v%d", apiNames[apiKey], ours.MinVersion, ours.MaxVersion)
+	}
+	return v, nil
+}
+
+// negotiate asks the broker for its versions and keeps the newest common
+// version of each API key.
+func (c *Conn) negotiate() error {
+	resp := &APIVersionsResponse{}
+	if err := c.roundTrip(&APIVersionsRequest{}, resp); err != nil {
+		return fmt.Errorf("kafka: api versions: %w", err)
+	}
+	if resp.Err != ErrNone {
+		return fmt.Errorf("kafka: api versions: %w", resp.Err)
+	}
+	c.versions = map[int16]int16{}
+	for _, theirs := range resp.APIs {
+		ours, ok := supported[theirs.APIKey]
+		if !ok {
+			continue
+		}
+		v := min(ours.MaxVersion, theirs.MaxVersion)
+		if v >= max(ours.MinVersion, theirs.MinVersion) {
+			c.versions[theirs.APIKey] = v
+		}
+	}
+	return nil
+}
+
+func (c *Conn) roundTrip(req Request, resp Message) error {
+	version, err := c.Version(req.apiKey())
+	if err != nil {
+		return err
+	}
+	if err := c.send(req, version); err != nil {
+		return err
+	}
+	frame, err := readFrame(c.conn, c.buf)
+	if err != nil {
+		return err
+	}
+	c.buf = frame[:0]
+
+	d := decoder{b: frame, version: version}
+	if id := d.int32(); d.err == nil && id != c.nextID-1 {
+		return fmt.Errorf("kafka: response correlation id %d, want %d", id, c.nextID-1)
+	}
+	resp.decode(&d)
+	return d.finish()
+}
+
+// send writes req in version with the next correlation ID.
+func (c *Conn) send(req Request, version int16) error {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	e := encoder{b: c.buf[:0], version: version}
+	e.int32(0) // size, filled in below
+	e.int16(req.apiKey())
+	e.int16(version)
+	e.int32(c.nextID)
+	e.nullableString(c.clientID)
+	req.encode(&e)
+	binary.BigEndian.PutUint32(e.b, uint32(len(e.b)-4))
+	c.nextID++
+	c.buf = e.b[:0]
+
+	_, err := c.conn.Write(e.b)
+	return err
+}
+
+// ReadRequest reads a request as a broker does. It returns the header and
+// the body: an *APIVersionsRequest, *MetadataRequest,
+// *InitProducerIDRequest or *ProduceRequest.
+func ReadRequest(r io.Reader) (RequestHeader, Request, error) {
+	frame, err := readFrame(r, nil)
+	if err != nil {
+		return RequestHeader{}, nil, err
+	}
+	d := decoder{b: frame}
+	h := RequestHeader{APIKey: d.int16(), APIVersion: d.int16(), CorrelationID: d.int32(), ClientID: d.string()}
+	if d.err != nil {
+		return h, nil, d.err
+	}
+	req, err := newRequest(h)
+	if err != nil {
+		return h, nil, err
+	}
+	d.version = h.APIVersion
+	req.decode(&d)
+	return h, req, d.finish()
+}
+
+// WriteResponse writes resp as the reply to the request with header h, in
+// the request's version.
+func WriteResponse(w io.Writer, h RequestHeader, resp Message) error {
+	e := encoder{version: h.APIVersion}
+	e.int32(0)
+	e.int32(h.CorrelationID)
+	resp.encode(&e)
+	binary.BigEndian.PutUint32(e.b, uint32(len(e.b)-4))
+	_, err := w.Write(e.b)
+	return err
+}
+
+// readFrame reads a size-prefixed frame into buf.
+func readFrame(r io.Reader, buf []byte) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := int(int32(binary.BigEndian.Uint32(size[:])))
+	if n < 0 || n > maxFrame {
+		return nil, fmt.Errorf("kafka: frame of %d bytes: %w", n, ErrMalformed)
+	}
+	if cap(buf) < n {
+		buf = make([]byte, n)
+	}
+	buf = buf[:n]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func joinHostPort(host string, port int32) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
@@ -0,0 +1,253 @@
+package kafka
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve answers ApiVersions with versions, and the other requests read
+// from conn with respond, until conn closes.
+func serve(t *testing.T, conn net.Conn, versions []APIVersion, respond func(RequestHeader, Request) Message) {
+	t.Helper()
+	go func() {
+		defer conn.Close()
+		for {
+			h, req, err := ReadRequest(conn)
+			if err != nil {
+				return
+			}
+			var resp Message
+			if _, ok := req.(*APIVersionsRequest); ok {
+				resp = &APIVersionsResponse{APIs: versions}
+			} else {
+				resp = respond(h, req)
+			}
+			if resp != nil {
+				if err := WriteResponse(conn, h, resp); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func TestConn_RoundTrip(t *testing.T) {
+	// The version ranges of brokers from Kafka 0.11 to one that dropped
+	// the versions older than Kafka 2.1, and the versions spoken to them.
+	tests := []struct {
+		name     string
+		versions []APIVersion
+		want     map[int16]int16
+	}{
+		{
+			"kafka 0.11",
+			[]APIVersion{{apiProduce, 0, 3}, {apiMetadata, 0, 4}, {apiAPIVersions, 0, 1}, {apiInitProducerID, 0, 0}},
+			map[int16]int16{apiProduce: 3, apiMetadata: 4, apiInitProducerID: 0},
+		},
+		{
+			"metadata v1",
+			[]APIVersion{{apiProduce, 0, 5}, {apiMetadata, 0, 1}, {apiAPIVersions, 0, 1}, {apiInitProducerID, 0, 0}},
+			map[int16]int16{apiProduce: 5, apiMetadata: 1, apiInitProducerID: 0},
+		},
+		{
+			"kafka 2.0",
+			[]APIVersion{{apiProduce, 0, 6}, {apiMetadata, 0, 6}, {apiAPIVersions, 0, 2}, {apiInitProducerID, 0, 1}},
+			map[int16]int16{apiProduce: 6, apiMetadata: 6, apiInitProducerID: 1},
+		},
+		{
+			"kip-896",
+			[]APIVersion{{apiProduce, 3, 12}, {apiMetadata, 0, 13}, {apiAPIVersions, 0, 4}, {apiInitProducerID, 0, 5}},
+			map[int16]int16{apiProduce: 8, apiMetadata: 8, apiInitProducerID: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			var headers []RequestHeader
+			var produced *ProduceRequest
+			serve(t, server, tt.versions, func(h RequestHeader, req Request) Message {
+				headers = append(headers, h)
+				switch req := req.(type) {
+				case *MetadataRequest:
+					return &MetadataResponse{
+						Brokers:      []Broker{{NodeID: 1, Host: "kafka-1", Port: 9092}},
+						ControllerID: 1,
+						Topics: []TopicMetadata{{Name: req.Topics[0], Partitions: []PartitionMetadata{
+							{Partition: 0, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}},
+							{Partition: 1, Leader: -1, Err: ErrLeaderNotAvailable, Replicas: []int32{}, ISR: []int32{}},
+						}}},
+					}
+				case *InitProducerIDRequest:
+					return &InitProducerIDResponse{ProducerID: 4000, ProducerEpoch: 2}
+				case *ProduceRequest:
+					produced = req
+					if req.Acks == AcksNone {
+						return nil
+					}
+					return &ProduceResponse{Topics: []ProduceTopicResponse{{Name: "logs", Partitions: []ProducePartitionResponse{
+						{Partition: 0, BaseOffset: 10},
+						{Partition: 1, Err: ErrNotLeaderOrFollower, BaseOffset: -1},
+					}}}}
+				}
+				return nil
+			})
+			c := NewConn(client, "loggen", time.Second)
+			defer c.Close()
+
+			md, err := c.Metadata("logs")
+			if err != nil {
+				t.Fatalf("Metadata() error = %v", err)
+			}
+			if md.Brokers[0].Address() != "kafka-1:9092" || len(md.Topics) != 1 || len(md.Topics[0].Partitions) != 2 ||
+				md.Topics[0].Partitions[1].Err != ErrLeaderNotAvailable || md.Topics[0].Partitions[1].Leader != -1 ||
+				!reflect.DeepEqual(md.Topics[0].Partitions[0].ISR, []int32{1}) {
+				t.Errorf("Metadata() = %+v", md)
+			}
+
+			pid, err := c.InitProducerID(time.Minute)
+			if err != nil || pid.ProducerID != 4000 || pid.ProducerEpoch != 2 {
+				t.Errorf("InitProducerID() = %+v, %v", pid, err)
+			}
+
+			records, _ := AppendRecordBatch(nil, &RecordBatch{ProducerID: -1, Records: []Record{{Value: []byte("v")}}})
+			req := &ProduceRequest{Acks: AcksAll, TimeoutMs: 5000, Topics: []ProduceTopic{{Name: "logs", Partitions: []ProducePartition{
+				{Partition: 0, Records: records},
+				{Partition: 1, Records: records},
+			}}}}
+			resp, err := c.Produce(req)
+			if err != nil {
+				t.Fatalf("Produce() error = %v", err)
+			}
+			if got := resp.Topics[0].Partitions; got[0].BaseOffset != 10 || got[1].Err != ErrNotLeaderOrFollower {
+				t.Errorf("Produce() = %+v", resp)
+			}
+			if !reflect.DeepEqual(produced, req) {
+				t.Errorf("broker decoded %+v, want %+v", produced, req)
+			}
+
+			req.Acks = AcksNone
+			if resp, err := c.Produce(req); resp != nil || err != nil {
+				t.Errorf("Produce(acks 0) = %v, %v, want no response", resp, err)
+			}
+			// The next request still matches its response.
+			if _, err := c.Metadata("logs"); err != nil {
+				t.Errorf("Metadata() after acks 0 error = %v", err)
+			}
+
+			// Request 0 is ApiVersions.
+			for i, h := range headers {
+				if h.CorrelationID != int32(i+1) || h.ClientID != "loggen" {
+					t.Errorf("request %d header = %+v", i, h)
+				}
+			}
+			want := []int16{apiMetadata, apiInitProducerID, apiProduce, apiProduce, apiMetadata}
+			for i, h := range headers {
+				if h.APIKey != want[i] || h.APIVersion != tt.want[h.APIKey] {
+					t.Errorf("request %d = api key %d v%d, want %d v%d", i, h.APIKey, h.APIVersion, want[i], tt.want[want[i]])
+				}
+			}
+		})
+	}
+}
+
+func TestConn_Negotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []APIVersion
+		err      Error
+		want     string
+	}{
+		{"too old", []APIVersion{{apiProduce, 0, 2}, {apiMetadata, 0, 1}, {apiAPIVersions, 0, 0}}, ErrNone, "does not support Produce v3 to v8"},
+		{"too new", []APIVersion{{apiProduce, 9, 12}, {apiMetadata, 0, 13}, {apiAPIVersions, 0, 4}}, ErrNone, "does not support Produce v3 to v8"},
+		{"missing", []APIVersion{{apiMetadata, 0, 13}, {apiAPIVersions, 0, 4}}, ErrNone, "does not support Produce"},
+		{"error", nil, ErrUnsupportedVersion, "api versions: kafka: UNSUPPORTED_VERSION"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			go func() {
+				defer server.Close()
+				for {
+					h, _, err := ReadRequest(server)
+					if err != nil {
+						return
+					}
+					if err := WriteResponse(server, h, &APIVersionsResponse{Err: tt.err, APIs: tt.versions}); err != nil {
+						return
+					}
+				}
+			}()
+			c := NewConn(client, "", time.Second)
+			defer c.Close()
+
+			_, err := c.Produce(&ProduceRequest{Acks: AcksAll})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Produce() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConn_Errors(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		for {
+			h, req, err := ReadRequest(server)
+			if err != nil {
+				return
+			}
+			var resp Message = &InitProducerIDResponse{Err: ErrClusterAuthorizationFailed}
+			switch req.(type) {
+			case *APIVersionsRequest:
+				resp = &APIVersionsResponse{APIs: SupportedVersions()}
+			case *MetadataRequest:
+				// A response to some other request.
+				resp, h.CorrelationID = &MetadataResponse{}, h.CorrelationID+1
+			}
+			if err := WriteResponse(server, h, resp); err != nil {
+				return
+			}
+		}
+	}()
+	c := NewConn(client, "", time.Second)
+	defer c.Close()
+
+	if _, err := c.InitProducerID(time.Minute); err != ErrClusterAuthorizationFailed {
+		t.Errorf("InitProducerID() error = %v, want %v", err, ErrClusterAuthorizationFailed)
+	}
+	if _, err := c.Metadata("logs"); err == nil || !strings.Contains(err.Error(), "correlation id") {
+		t.Errorf("Metadata() error = %v, want a correlation id mismatch", err)
+	}
+}
+
+func TestReadRequest_Unsupported(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		e := encoder{}
+		e.int32(10)
+		e.int16(apiProduce)
+		e.int16(9)
+		e.int32(1)
+		e.int16(-1)
+		_, _ = client.Write(e.b)
+	}()
+	if _, _, err := ReadRequest(server); err == nil || !strings.Contains(err.Error(), "unsupported version 9") {
+		t.Errorf("ReadRequest() error = %v, want an unsupported version", err)
+	}
+}
+
+func TestError(t *testing.T) {
+	if got := ErrNotLeaderOrFollower.Error(); got != "kafka: NOT_LEADER_OR_FOLLOWER (6)" {
+		t.Errorf("Error() = %q", got)
+	}
+	if got := Error(999).Error(); got != "kafka: error code 999" {
+		t.Errorf("Error() = %q", got)
+	}
+	if !ErrNotLeaderOrFollower.Retriable() || ErrMessageTooLarge.Retriable() {
+		t.Error("Retriable() misclassifies errors")
+	}
+}
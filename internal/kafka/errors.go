@@ -0,0 +1,77 @@
+package kafka
+
+import "fmt"
+
+// Error is a Kafka protocol error code. The zero value means no error.
+type Error int16
+
+// Error codes a producer acts on.
+const (
+	ErrNone                         Error = 0
+	ErrUnknownServerError           Error = -1
+	ErrCorruptMessage               Error = 2
+	ErrUnknownTopicOrPartition      Error = 3
+	ErrLeaderNotAvailable           Error = 5
+	ErrNotLeaderOrFollower          Error = 6
+	ErrRequestTimedOut              Error = 7
+	ErrMessageTooLarge              Error = 10
+	ErrNetworkException             Error = 13
+	ErrInvalidTopic                 Error = 17
+	ErrRecordListTooLarge           Error = 18
+	ErrNotEnoughReplicas            Error = 19
+	ErrNotEnoughReplicasAfterAppend Error = 20
+	ErrInvalidRequiredAcks          Error = 21
+	ErrTopicAuthorizationFailed     Error = 29
+	ErrClusterAuthorizationFailed   Error = 31
+	ErrUnsupportedVersion           Error = 35
+	ErrOutOfOrderSequenceNumber     Error = 45
+	ErrDuplicateSequenceNumber      Error = 46
+	ErrInvalidProducerEpoch         Error = 47
+	ErrKafkaStorageError            Error = 56
+	ErrUnknownProducerID            Error = 59
+	ErrUnsupportedCompressionType   Error = 76
+)
+
+var errorNames = map[Error]string{
+	ErrUnknownServerError:           "UNKNOWN_SERVER_ERROR",
+	ErrCorruptMessage:               "CORRUPT_MESSAGE",
+	ErrUnknownTopicOrPartition:      "UNKNOWN_TOPIC_OR_PARTITION",
+	ErrLeaderNotAvailable:           "LEADER_NOT_AVAILABLE",
+	ErrNotLeaderOrFollower:          "NOT_LEADER_OR_FOLLOWER",
+	ErrRequestTimedOut:              "REQUEST_TIMED_OUT",
+	ErrMessageTooLarge:              "MESSAGE_TOO_LARGE",
+	ErrNetworkException:             "NETWORK_EXCEPTION",
+	ErrInvalidTopic:                 "INVALID_TOPIC_EXCEPTION",
+	ErrRecordListTooLarge:           "RECORD_LIST_TOO_LARGE",
+	ErrNotEnoughReplicas:            "NOT_ENOUGH_REPLICAS",
+	ErrNotEnoughReplicasAfterAppend: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	ErrInvalidRequiredAcks:          "INVALID_REQUIRED_ACKS",
+	ErrTopicAuthorizationFailed:     "TOPIC_AUTHORIZATION_FAILED",
+	ErrClusterAuthorizationFailed:   "CLUSTER_AUTHORIZATION_FAILED",
+	ErrUnsupportedVersion:           "UNSUPPORTED_VERSION",
+	ErrOutOfOrderSequenceNumber:     "OUT_OF_ORDER_SEQUENCE_NUMBER",
+	ErrDuplicateSequenceNumber:      "DUPLICATE_SEQUENCE_NUMBER",
+	ErrInvalidProducerEpoch:         "INVALID_PRODUCER_EPOCH",
+	ErrUnknownProducerID:            "UNKNOWN_PRODUCER_ID",
+	ErrUnsupportedCompressionType:   "UNSUPPORTED_COMPRESSION_TYPE",
+	ErrKafkaStorageError:            "KAFKA_STORAGE_ERROR",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return fmt.Sprintf("kafka: %s (%d)", name, int16(e))
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// Retriable reports whether a request that failed with e may succeed when
+// sent again, possibly after refreshing metadata.
+func (e Error) Retriable() bool {
+	switch e {
+	case ErrCorruptMessage, ErrUnknownTopicOrPartition, ErrLeaderNotAvailable,
+		ErrNotLeaderOrFollower, ErrRequestTimedOut, ErrNetworkException,
+		ErrNotEnoughReplicas, ErrNotEnoughReplicasAfterAppend, ErrKafkaStorageError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,311 @@
+// Package kafkatest runs an in-process, single-node Kafka broker for
+// tests. It speaks the requests package kafka implements, keeps produced
+// records in memory and can fail produce requests on demand.
+package kafkatest
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/kafka"
+)
+
+// Broker is a fake broker that leads every partition of one topic.
+type Broker struct {
+	t          testing.TB
+	ln         net.Listener
+	topic      string
+	partitions int
+
+	mu        sync.Mutex
+	versions  []kafka.APIVersion
+	conns     map[net.Conn]bool
+	requests  []kafka.RequestHeader
+	batches   map[int32][]kafka.RecordBatch
+	sequences map[int32]int32
+	producers int64
+	failures  []kafka.Error
+	hangups   int
+	dups      int
+	wg        sync.WaitGroup
+}
+
+// NewBroker starts a broker for topic with the given number of partitions.
+// It is closed when the test ends.
+func NewBroker(t testing.TB, topic string, partitions int) *Broker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Broker{
+		t:          t,
+		ln:         ln,
+		topic:      topic,
+		partitions: partitions,
+		versions:   kafka.SupportedVersions(),
+		conns:      map[net.Conn]bool{},
+		batches:    map[int32][]kafka.RecordBatch{},
+		sequences:  map[int32]int32{},
+	}
+	b.wg.Add(1)
+	go b.serve()
+	t.Cleanup(b.Close)
+	return b
+}
+
+// Addr returns the host:port the broker listens on.
+func (b *Broker) Addr() string {
+	return b.ln.Addr().String()
+}
+
+// Close stops the broker and closes its connections.
+func (b *Broker) Close() {
+	_ = b.ln.Close()
+	b.mu.Lock()
+	for c := range b.conns {
+		_ = c.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// SetVersions makes the broker answer ApiVersions with apis instead of
+// every version package kafka speaks, as an older or newer broker would.
+// Requests in other versions fail the test.
+func (b *Broker) SetVersions(apis ...kafka.APIVersion) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.versions = apis
+}
+
+// FailNext makes the next produce requests fail, one per error, with that
+// error on every partition. Nothing is appended.
+func (b *Broker) FailNext(errs ...kafka.Error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = append(b.failures, errs...)
+}
+
+// HangUpNext makes the broker append the records of the next n produce
+// requests and then close the connection instead of responding, as if the
+// response was lost.
+func (b *Broker) HangUpNext(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hangups += n
+}
+
+// Batches returns the record batches appended to partition.
+func (b *Broker) Batches(partition int32) []kafka.RecordBatch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]kafka.RecordBatch(nil), b.batches[partition]...)
+}
+
+// Records returns the records appended to partition, in offset order.
+func (b *Broker) Records(partition int32) []kafka.Record {
+	var out []kafka.Record
+	for _, batch := range b.Batches(partition) {
+		out = append(out, batch.Records...)
+	}
+	return out
+}
+
+// Requests returns the headers of the requests received so far.
+func (b *Broker) Requests() []kafka.RequestHeader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]kafka.RequestHeader(nil), b.requests...)
+}
+
+// Duplicates returns the number of idempotent batches dropped because
+// their sequence numbers were already appended.
+func (b *Broker) Duplicates() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dups
+}
+
+func (b *Broker) serve() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conns[conn] = true
+		b.mu.Unlock()
+		b.wg.Add(1)
+		go b.handle(conn)
+	}
+}
+
+func (b *Broker) handle(conn net.Conn) {
+	defer b.wg.Done()
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, conn)
+		b.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	for {
+		h, req, err := kafka.ReadRequest(conn)
+		if err != nil {
+			if !disconnected(err) {
+				b.t.Errorf("kafkatest: reading request: %v", err)
+			}
+			return
+		}
+		b.mu.Lock()
+		b.requests = append(b.requests, h)
+		versions := b.versions
+		b.mu.Unlock()
+		if !advertised(versions, h) {
+			b.t.Errorf("kafkatest: request for api key %d in version %d, which the broker did not advertise", h.APIKey, h.APIVersion)
+			return
+		}
+
+		var resp kafka.Message
+		switch req := req.(type) {
+		case *kafka.APIVersionsRequest:
+			resp = &kafka.APIVersionsResponse{APIs: versions}
+		case *kafka.MetadataRequest:
+			resp = b.metadata(req)
+		case *kafka.InitProducerIDRequest:
+			resp = b.initProducerID()
+		case *kafka.ProduceRequest:
+			var hangUp bool
+			resp, hangUp = b.produce(req)
+			if hangUp {
+				return
+			}
+			if req.Acks == kafka.AcksNone {
+				continue
+			}
+		}
+		if err := kafka.WriteResponse(conn, h, resp); err != nil {
+			return
+		}
+	}
+}
+
+func (b *Broker) metadata(req *kafka.MetadataRequest) *kafka.MetadataResponse {
+	host, port, _ := net.SplitHostPort(b.Addr())
+	p, _ := strconv.Atoi(port)
+	resp := &kafka.MetadataResponse{
+		Brokers: []kafka.Broker{{NodeID: 0, Host: host, Port: int32(p)}},
+	}
+	for _, name := range req.Topics {
+		t := kafka.TopicMetadata{Name: name}
+		if name != b.topic {
+			t.Err = kafka.ErrUnknownTopicOrPartition
+		}
+		for i := range b.partitions {
+			if name == b.topic {
+				t.Partitions = append(t.Partitions, kafka.PartitionMetadata{
+					Partition: int32(i), Leader: 0, Replicas: []int32{0}, ISR: []int32{0},
+				})
+			}
+		}
+		resp.Topics = append(resp.Topics, t)
+	}
+	return resp
+}
+
+func (b *Broker) initProducerID() *kafka.InitProducerIDResponse {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.producers++
+	clear(b.sequences)
+	return &kafka.InitProducerIDResponse{ProducerID: 1000 + b.producers}
+}
+
+// produce appends the batches of req, checking the sequence numbers of
+// idempotent batches as a broker does. It reports whether to hang up.
+func (b *Broker) produce(req *kafka.ProduceRequest) (*kafka.ProduceResponse, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var fail kafka.Error
+	if len(b.failures) > 0 {
+		fail, b.failures = b.failures[0], b.failures[1:]
+	}
+	resp := &kafka.ProduceResponse{}
+	for _, t := range req.Topics {
+		tr := kafka.ProduceTopicResponse{Name: t.Name}
+		for _, p := range t.Partitions {
+			pr := kafka.ProducePartitionResponse{Partition: p.Partition, Err: fail}
+			if fail == kafka.ErrNone {
+				pr.Err, pr.BaseOffset = b.append(t.Name, p)
+			}
+			tr.Partitions = append(tr.Partitions, pr)
+		}
+		resp.Topics = append(resp.Topics, tr)
+	}
+
+	if b.hangups > 0 && fail == kafka.ErrNone {
+		b.hangups--
+		return resp, true
+	}
+	return resp, false
+}
+
+func (b *Broker) append(topic string, p kafka.ProducePartition) (kafka.Error, int64) {
+	if topic != b.topic || p.Partition < 0 || int(p.Partition) >= b.partitions {
+		return kafka.ErrUnknownTopicOrPartition, -1
+	}
+	batches, err := kafka.ReadRecordBatches(p.Records)
+	if err != nil {
+		return kafka.ErrCorruptMessage, -1
+	}
+	var offset int64
+	for _, batch := range b.batches[p.Partition] {
+		offset += int64(len(batch.Records))
+	}
+	base := offset
+	for _, batch := range batches {
+		if batch.ProducerID >= 0 {
+			want := b.sequences[p.Partition]
+			switch {
+			case batch.BaseSequence < want:
+				b.dups++
+				continue
+			case batch.BaseSequence > want:
+				return kafka.ErrOutOfOrderSequenceNumber, -1
+			}
+			b.sequences[p.Partition] = kafka.NextSequence(want, len(batch.Records))
+		}
+		batch.BaseOffset = offset
+		offset += int64(len(batch.Records))
+		b.batches[p.Partition] = append(b.batches[p.Partition], batch)
+	}
+	return kafka.ErrNone, base
+}
+
+// advertised reports whether the request header h is in a version of
+// versions. ApiVersions (API key 18) v0 is always answered.
+func advertised(versions []kafka.APIVersion, h kafka.RequestHeader) bool {
+	if h.APIKey == 18 && h.APIVersion == 0 {
+		return true
+	}
+	for _, v := range versions {
+		if v.APIKey == h.APIKey {
+			return h.APIVersion >= v.MinVersion && h.APIVersion <= v.MaxVersion
+		}
+	}
+	return false
+}
+
+// disconnected reports whether err only means the client went away.
+func disconnected(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET)
+}
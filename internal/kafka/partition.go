@@ -0,0 +1,46 @@
+package kafka
+
+import "encoding/binary"
+
+// Partition returns the partition of a keyed record among n, as the Java
+// client's default partitioner picks it: the positive murmur2 hash of the
+// key modulo n. Records with the same key therefore land on the same
+// partition whichever client produced them.
+func Partition(key []byte, n int) int32 {
+	return int32((Murmur2(key) & 0x7fffffff) % int32(n))
+}
+
+// Murmur2 is the 32-bit MurmurHash2 of data with the seed the Java client
+// uses.
+func Murmur2(data []byte) int32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	h := uint32(seed) ^ uint32(len(data))
+	n := len(data) &^ 3
+	for i := 0; i < n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	switch len(data) & 3 {
+	case 3:
+		h ^= uint32(data[n+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[n+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[n])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
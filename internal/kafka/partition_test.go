@@ -0,0 +1,47 @@
+package kafka
+
+import "testing"
+
+func TestMurmur2(t *testing.T) {
+	// Vectors from the Java client's UtilsTest.
+	tests := []struct {
+		in   string
+		want int32
+	}{
+		{"21", -973932308},
+		{"foobar", -790332482},
+		{"a-little-bit-long-string", -985981536},
+		{"a-little-bit-longer-string", -1486304829},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", -58897971},
+		{"abc", 479470107},
+	}
+	for _, tt := range tests {
+		if got := Murmur2([]byte(tt.in)); got != tt.want {
+			t.Errorf("Murmur2(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	for _, n := range []int{1, 3, 12} {
+		seen := map[int32]bool{}
+		for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			p := Partition([]byte(key), n)
+			if p < 0 || int(p) >= n {
+				t.Fatalf("Partition(%q, %d) = %d, out of range", key, n, p)
+			}
+			if p != Partition([]byte(key), n) {
+				t.Fatalf("Partition(%q, %d) is not stable", key, n)
+			}
+			seen[p] = true
+		}
+		if n > 1 && len(seen) < 2 {
+			t.Errorf("Partition over %d partitions used only %v", n, seen)
+		}
+	}
+	// The hash of "foobar" is negative; its sign bit is masked, not
+	// negated, as in the Java client.
+	if got, want := Partition([]byte("foobar"), 7), int32((-790332482&0x7fffffff)%7); got != want {
+		t.Errorf("Partition(foobar, 7) = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+// Package kafka implements the parts of the Apache Kafka wire protocol a
+// producer needs: cluster metadata, idempotent producer IDs and produce
+// requests carrying v2 record batches. Requests and responses encode and
+// decode in both directions, so tests can run an in-process broker.
+//
+// Each connection asks the broker for its API versions and speaks the
+// newest both sides support, from those of Kafka 0.11 to the last before
+// the flexible encoding. There is no SASL or transactions.
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrMalformed is returned for a message that ends early or has an
+// impossible length.
+var ErrMalformed = errors.New("kafka: malformed message")
+
+// encoder appends protocol primitives to b. version is the API version
+// of the message being encoded.
+type encoder struct {
+	b       []byte
+	version int16
+}
+
+func (e *encoder) int8(v int8)   { e.b = append(e.b, byte(v)) }
+func (e *encoder) int16(v int16) { e.b = binary.BigEndian.AppendUint16(e.b, uint16(v)) }
+func (e *encoder) int32(v int32) { e.b = binary.BigEndian.AppendUint32(e.b, uint32(v)) }
+func (e *encoder) int64(v int64) { e.b = binary.BigEndian.AppendUint64(e.b, uint64(v)) }
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.int8(1)
+	} else {
+		e.int8(0)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// nullableString encodes "" as null.
+func (e *encoder) nullableString(s string) {
+	if s == "" {
+		e.int16(-1)
+		return
+	}
+	e.string(s)
+}
+
+// bytes encodes nil as null.
+func (e *encoder) bytes(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) arrayLen(n int) { e.int32(int32(n)) }
+
+// varint and varlong are zigzag encoded, as in protocol buffers.
+func (e *encoder) varint(v int64) { e.b = binary.AppendVarint(e.b, v) }
+
+// varBytes encodes nil as a length of -1.
+func (e *encoder) varBytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// decoder reads protocol primitives from b. The first error sticks and
+// every later read returns zero values, so a message is decoded in one go
+// and checked once. version is the API version of the message being
+// decoded.
+type decoder struct {
+	b       []byte
+	err     error
+	version int16
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.b) {
+		d.err = ErrMalformed
+		d.b = nil
+		return nil
+	}
+	v := d.b[:n:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) bool() bool { return d.int8() != 0 }
+
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.take(int(n))
+}
+
+// arrayLen returns the length of an array, treating null as empty. A
+// length longer than the remaining bytes could hold is malformed.
+func (d *decoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 {
+		return 0
+	}
+	if int(n) > len(d.b) {
+		d.err = ErrMalformed
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = ErrMalformed
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varBytes() []byte {
+	n := d.varint()
+	if n < 0 {
+		return nil
+	}
+	if n > math.MaxInt32 {
+		d.err = ErrMalformed
+		return nil
+	}
+	return d.take(int(n))
+}
+
+// finish returns the first error, or ErrMalformed if bytes are left over.
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.b) > 0 {
+		return ErrMalformed
+	}
+	return d.err
+}
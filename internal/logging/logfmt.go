@@ -0,0 +1,274 @@
+package logging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder renders entries as logfmt key=value pairs. Arrays, objects
+// and reflected values are rendered as quoted JSON.
+type logfmtEncoder struct {
+	cfg       zapcore.EncoderConfig
+	buf       *buffer.Buffer
+	namespace string
+}
+
+// NewLogfmtEncoder returns a zapcore.Encoder producing logfmt lines such as
+//
+//	ts=2026-02-18T12:00:00.000Z level=info msg="loop started" interval=5s
+func NewLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{cfg: cfg, buf: logfmtPool.Get()}
+}
+
+// Clone implements zapcore.Encoder.
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	c := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get(), namespace: e.namespace}
+	_, _ = c.buf.Write(e.buf.Bytes())
+	return c
+}
+
+// EncodeEntry implements zapcore.Encoder.
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	line := logfmtPool.Get()
+	out := &logfmtEncoder{cfg: e.cfg, buf: line}
+
+	if e.cfg.TimeKey != "" && !ent.Time.IsZero() {
+		out.AddString(e.cfg.TimeKey, ent.Time.UTC().Format(time.RFC3339Nano))
+	}
+	if e.cfg.LevelKey != "" {
+		out.AddString(e.cfg.LevelKey, ent.Level.String())
+	}
+	if e.cfg.NameKey != "" && ent.LoggerName != "" {
+		out.AddString(e.cfg.NameKey, ent.LoggerName)
+	}
+	if e.cfg.CallerKey != "" && ent.Caller.Defined {
+		out.AddString(e.cfg.CallerKey, ent.Caller.TrimmedPath())
+	}
+	if e.cfg.MessageKey != "" {
+		out.AddString(e.cfg.MessageKey, ent.Message)
+	}
+
+	if e.buf.Len() > 0 {
+		out.sep()
+		_, _ = line.Write(e.buf.Bytes())
+	}
+
+	out.namespace = e.namespace
+	for _, f := range fields {
+		f.AddTo(out)
+	}
+
+	if e.cfg.StacktraceKey != "" && ent.Stack != "" {
+		out.namespace = ""
+		out.AddString(e.cfg.StacktraceKey, ent.Stack)
+	}
+
+	ending := e.cfg.LineEnding
+	if ending == "" {
+		ending = zapcore.DefaultLineEnding
+	}
+	line.AppendString(ending)
+	return line, nil
+}
+
+func (e *logfmtEncoder) sep() {
+	if e.buf.Len() > 0 {
+		e.buf.AppendByte(' ')
+	}
+}
+
+func (e *logfmtEncoder) key(k string) {
+	e.sep()
+	if e.namespace != "" {
+		e.buf.AppendString(e.namespace)
+		e.buf.AppendByte('.')
+	}
+	e.buf.AppendString(k)
+	e.buf.AppendByte('=')
+}
+
+// appendValue writes s, quoting it when it is empty or contains spaces,
+// quotes, '=' or non-printable characters.
+func (e *logfmtEncoder) appendValue(s string) {
+	if s != "" && !needsQuote(s) {
+		e.buf.AppendString(s)
+		return
+	}
+	e.buf.AppendString(strconv.Quote(s))
+}
+
+func needsQuote(s string) bool {
+	if !utf8.ValidString(s) {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// appendJSON renders complex values as a quoted JSON string.
+func (e *logfmtEncoder) appendJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.appendValue(string(b))
+	return nil
+}
+
+// AddArray implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddArray(k string, v zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := m.AddArray(k, v); err != nil {
+		return err
+	}
+	e.key(k)
+	return e.appendJSON(m.Fields[k])
+}
+
+// AddObject implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddObject(k string, v zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := v.MarshalLogObject(m); err != nil {
+		return err
+	}
+	e.key(k)
+	return e.appendJSON(m.Fields)
+}
+
+// AddReflected implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddReflected(k string, v any) error {
+	e.key(k)
+	return e.appendJSON(v)
+}
+
+// OpenNamespace implements zapcore.ObjectEncoder by prefixing later keys.
+func (e *logfmtEncoder) OpenNamespace(k string) {
+	if e.namespace == "" {
+		e.namespace = k
+		return
+	}
+	e.namespace += "." + k
+}
+
+// AddBinary implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddBinary(k string, v []byte) {
+	e.AddString(k, base64.StdEncoding.EncodeToString(v))
+}
+
+// AddByteString implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddByteString(k string, v []byte) {
+	e.AddString(k, string(v))
+}
+
+// AddBool implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddBool(k string, v bool) {
+	e.key(k)
+	e.buf.AppendBool(v)
+}
+
+// AddComplex128 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddComplex128(k string, v complex128) {
+	e.key(k)
+	e.appendValue(strconv.FormatComplex(v, 'g', -1, 128))
+}
+
+// AddComplex64 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddComplex64(k string, v complex64) {
+	e.key(k)
+	e.appendValue(strconv.FormatComplex(complex128(v), 'g', -1, 64))
+}
+
+// AddDuration implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddDuration(k string, v time.Duration) {
+	e.key(k)
+	e.buf.AppendString(v.String())
+}
+
+// AddFloat64 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddFloat64(k string, v float64) {
+	e.key(k)
+	e.appendFloat(v, 64)
+}
+
+// AddFloat32 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddFloat32(k string, v float32) {
+	e.key(k)
+	e.appendFloat(float64(v), 32)
+}
+
+func (e *logfmtEncoder) appendFloat(v float64, bits int) {
+	switch {
+	case math.IsNaN(v):
+		e.buf.AppendString("NaN")
+	case math.IsInf(v, 1):
+		e.buf.AppendString("+Inf")
+	case math.IsInf(v, -1):
+		e.buf.AppendString("-Inf")
+	default:
+		e.buf.AppendFloat(v, bits)
+	}
+}
+
+// AddInt implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddInt(k string, v int) { e.AddInt64(k, int64(v)) }
+
+// AddInt64 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddInt64(k string, v int64) {
+	e.key(k)
+	e.buf.AppendInt(v)
+}
+
+// AddInt32 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddInt32(k string, v int32) { e.AddInt64(k, int64(v)) }
+
+// AddInt16 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddInt16(k string, v int16) { e.AddInt64(k, int64(v)) }
+
+// AddInt8 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddInt8(k string, v int8) { e.AddInt64(k, int64(v)) }
+
+// AddString implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddString(k, v string) {
+	e.key(k)
+	e.appendValue(v)
+}
+
+// AddTime implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddTime(k string, v time.Time) {
+	e.key(k)
+	e.buf.AppendString(v.UTC().Format(time.RFC3339Nano))
+}
+
+// AddUint implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddUint(k string, v uint) { e.AddUint64(k, uint64(v)) }
+
+// AddUint64 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddUint64(k string, v uint64) {
+	e.key(k)
+	e.buf.AppendUint(v)
+}
+
+// AddUint32 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddUint32(k string, v uint32) { e.AddUint64(k, uint64(v)) }
+
+// AddUint16 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddUint16(k string, v uint16) { e.AddUint64(k, uint64(v)) }
+
+// AddUint8 implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddUint8(k string, v uint8) { e.AddUint64(k, uint64(v)) }
+
+// AddUintptr implements zapcore.ObjectEncoder.
+func (e *logfmtEncoder) AddUintptr(k string, v uintptr) { e.AddUint64(k, uint64(v)) }
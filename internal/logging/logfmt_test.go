@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func encodeLogfmt(t *testing.T, enc zapcore.Encoder, ent zapcore.Entry, fields ...zapcore.Field) string {
+	t.Helper()
+	buf, err := enc.EncodeEntry(ent, fields)
+	if err != nil {
+		t.Fatalf("EncodeEntry() error = %v", err)
+	}
+	defer buf.Free()
+	return buf.String()
+}
+
+func TestLogfmtEncoder_EncodeEntry(t *testing.T) {
+	enc := NewLogfmtEncoder(zap.NewProductionEncoderConfig())
+	ent := zapcore.Entry{
+		Level:      zapcore.InfoLevel,
+		Time:       time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC),
+		LoggerName: "loggen",
+		Message:    "loop started",
+	}
+
+	got := encodeLogfmt(t, enc, ent,
+		zap.Duration("interval", 5*time.Second),
+		zap.Int("max_number", 100),
+		zap.String("empty", ""),
+		zap.Bool("ok", true),
+	)
+	want := `ts=2026-02-18T12:00:00Z level=info logger=loggen msg="loop started" interval=5s max_number=100 empty="" ok=true` + "\n"
+	if got != want {
+		t.Errorf("EncodeEntry() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLogfmtEncoder_Quoting(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", "alpha", "k=alpha"},
+		{"space", "a b", `k="a b"`},
+		{"equals", "a=b", `k="a=b"`},
+		{"quote", `say "hi"`, `k="say \"hi\""`},
+		{"newline", "a\nb", `k="a\nb"`},
+		{"backslash", `c:\tmp`, `k="c:\\tmp"`},
+		{"invalid utf8", "\xff", `k="\xff"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := zapcore.EncoderConfig{}
+			got := encodeLogfmt(t, NewLogfmtEncoder(cfg), zapcore.Entry{}, zap.String("k", tt.value))
+			if got != tt.want+"\n" {
+				t.Errorf("got %q, want %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
+
+func TestLogfmtEncoder_FieldTypes(t *testing.T) {
+	cfg := zapcore.EncoderConfig{}
+	got := encodeLogfmt(t, NewLogfmtEncoder(cfg), zapcore.Entry{},
+		zap.Float64("f", 1.5),
+		zap.Float64("nan", math.NaN()),
+		zap.Uint64("u", 7),
+		zap.Ints("arr", []int{1, 2}),
+		zap.Error(errors.New("boom")),
+		zap.Any("obj", map[string]int{"a": 1}),
+	)
+	want := `f=1.5 nan=NaN u=7 arr=[1,2] error=boom obj="{\"a\":1}"` + "\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLogfmtEncoder_WithContextAndNamespace(t *testing.T) {
+	cfg := zapcore.EncoderConfig{MessageKey: "msg"}
+	enc := NewLogfmtEncoder(cfg)
+	zap.String("component", "loop").AddTo(enc)
+	ctxEnc := enc.Clone()
+	ctxEnc.OpenNamespace("req")
+
+	got := encodeLogfmt(t, ctxEnc, zapcore.Entry{Message: "hi"}, zap.Int("id", 3))
+	want := "msg=hi component=loop req.id=3\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+// Package logging builds loggen's operational zap logger for the
+// application's own messages. Generated records go through the sinks.
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Supported operational log encodings.
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+	EncodingLogfmt  = "logfmt"
+)
+
+// Well-known output destinations. Anything else is treated as a file path.
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// OperationalName is the logger name attached to operational messages so
+// they can be told apart from generated records downstream.
+const OperationalName = "loggen"
+
+// Options configures the operational logger.
+type Options struct {
+	// Level is the minimum enabled level (debug, info, warn, error, ...).
+	Level string
+
+	// Encoding is one of json, console or logfmt.
+	Encoding string
+
+	// Output is stdout, stderr, or a file path to append to.
+	Output string
+}
+
+// ValidEncoding reports whether enc is a supported encoding.
+func ValidEncoding(enc string) bool {
+	switch enc {
+	case EncodingJSON, EncodingConsole, EncodingLogfmt:
+		return true
+	}
+	return false
+}
+
+// New builds the operational logger. The returned AtomicLevel can be used
+// to change the level at runtime; it also implements http.Handler.
+func New(opts Options) (*zap.Logger, zap.AtomicLevel, error) {
+	level := zap.NewAtomicLevel()
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, level, fmt.Errorf("invalid log level %q: %w", opts.Level, err)
+		}
+	}
+
+	enc, err := newEncoder(opts.Encoding)
+	if err != nil {
+		return nil, level, err
+	}
+
+	ws, err := openOutput(opts.Output)
+	if err != nil {
+		return nil, level, err
+	}
+
+	core := zapcore.NewCore(enc, ws, level)
+	logger := zap.New(core,
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	).Named(OperationalName)
+
+	return logger, level, nil
+}
+
+func newEncoder(encoding string) (zapcore.Encoder, error) {
+	switch strings.ToLower(encoding) {
+	case "", EncodingJSON:
+		return zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), nil
+	case EncodingConsole:
+		cfg := zap.NewProductionEncoderConfig()
+		cfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		cfg.EncodeDuration = zapcore.StringDurationEncoder
+		return zapcore.NewConsoleEncoder(cfg), nil
+	case EncodingLogfmt:
+		return NewLogfmtEncoder(zap.NewProductionEncoderConfig()), nil
+	default:
+		return nil, fmt.Errorf("unsupported log encoding %q", encoding)
+	}
+}
+
+// openOutput resolves an output destination to a locked WriteSyncer.
+func openOutput(output string) (zapcore.WriteSyncer, error) {
+	switch output {
+	case "", OutputStderr:
+		return zapcore.Lock(os.Stderr), nil
+	case OutputStdout:
+		return zapcore.Lock(os.Stdout), nil
+	default:
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open log output: %w", err)
+		}
+		return zapcore.Lock(f), nil
+	}
+}
@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestValidEncoding(t *testing.T) {
+	for _, enc := range []string{EncodingJSON, EncodingConsole, EncodingLogfmt} {
+		if !ValidEncoding(enc) {
+			t.Errorf("ValidEncoding(%q) = false, want true", enc)
+		}
+	}
+	if ValidEncoding("xml") {
+		t.Error("ValidEncoding(\"xml\") = true, want false")
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"bad level", Options{Level: "loud"}},
+		{"bad encoding", Options{Encoding: "xml"}},
+		{"bad output", Options{Output: filepath.Join(t.TempDir(), "missing", "ops.log")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := New(tt.opts); err == nil {
+				t.Error("New() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestNew_FileOutputAndAtomicLevel(t *testing.T) {
+	for _, enc := range []string{EncodingJSON, EncodingConsole, EncodingLogfmt} {
+		t.Run(enc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ops.log")
+			logger, level, err := New(Options{Level: "warn", Encoding: enc, Output: path})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			logger.Info("hidden")
+			logger.Warn("shown")
+			level.SetLevel(zapcore.DebugLevel)
+			logger.Debug("now visible")
+			_ = logger.Sync()
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			out := string(data)
+			if strings.Contains(out, "hidden") {
+				t.Errorf("info message logged at warn level:\n%s", out)
+			}
+			if !strings.Contains(out, "shown") || !strings.Contains(out, "now visible") {
+				t.Errorf("expected messages missing:\n%s", out)
+			}
+			if !strings.Contains(out, OperationalName) {
+				t.Errorf("logger name %q missing:\n%s", OperationalName, out)
+			}
+		})
+	}
+}
+
+// TestFluentBitExcludesOperationalLoggers checks that the Fluent Bit grep
+// filters drop the operational logger and its named sub-loggers, and
+// nothing else.
+func TestFluentBitExcludesOperationalLoggers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ops.log")
+	logger, _, err := New(Options{Level: "info", Encoding: EncodingJSON, Output: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	logger.Info("starting")
+	logger.Named("scenario").Info("scenario started")
+	logger.Named("audit").Info("admin request")
+	_ = logger.Sync()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec struct {
+			Logger string `json:"logger"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		names = append(names, rec.Logger)
+	}
+	if len(names) != 3 || names[1] != OperationalName+".scenario" {
+		t.Fatalf("logger names = %q, want loggen and two sub-loggers", names)
+	}
+
+	exclude := regexp.MustCompile(`Exclude\s+logger\s+(\S+)`)
+	for _, file := range []string{"../../k8s/fluentbit/configmap.yaml", "../../nix/fluentbit.nix"} {
+		conf, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		m := exclude.FindSubmatch(conf)
+		if m == nil {
+			t.Fatalf("%s has no logger exclude filter", file)
+		}
+		re := regexp.MustCompile(string(m[1]))
+		for _, name := range names {
+			if !re.MatchString(name) {
+				t.Errorf("%s lets logger %q through", file, name)
+			}
+		}
+		for _, name := range []string{"loggenerator", "app.loggen"} {
+			if re.MatchString(name) {
+				t.Errorf("%s drops records of logger %q", file, name)
+			}
+		}
+	}
+}
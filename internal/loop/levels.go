@@ -0,0 +1,100 @@
+package loop
+
+import (
+	"math/rand/v2"
+	"strings"
+	"text/template"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+)
+
+// DefaultLevelMessages are the message templates used for levels that have
+// no override. Info keeps the historical "tick" message.
+var DefaultLevelMessages = map[zapcore.Level]string{
+	zapcore.DebugLevel:  "tick debug",
+	zapcore.InfoLevel:   "tick",
+	zapcore.WarnLevel:   "tick warning",
+	zapcore.ErrorLevel:  "tick error",
+	zapcore.DPanicLevel: "tick dpanic",
+	zapcore.PanicLevel:  "tick panic",
+	zapcore.FatalLevel:  "tick fatal",
+}
+
+// MessageData is the data available to message templates.
+type MessageData = config.MessageData
+
+// LevelPicker draws levels from a weighted distribution.
+type LevelPicker struct {
+	levels []zapcore.Level
+	cum    []int
+	total  int
+}
+
+// NewLevelPicker builds a picker from weights. Zero-weight levels are never
+// chosen; an empty or all-zero distribution always yields info.
+func NewLevelPicker(weights config.LevelWeights) *LevelPicker {
+	p := &LevelPicker{}
+	for _, w := range weights {
+		if w.Weight <= 0 {
+			continue
+		}
+		p.total += w.Weight
+		p.levels = append(p.levels, w.Level)
+		p.cum = append(p.cum, p.total)
+	}
+	return p
+}
+
+// Pick returns the next level.
+func (p *LevelPicker) Pick(rng *rand.Rand) zapcore.Level {
+	if p.total == 0 {
+		return zapcore.InfoLevel
+	}
+	if len(p.levels) == 1 {
+		return p.levels[0]
+	}
+	n := rng.IntN(p.total)
+	for i, c := range p.cum {
+		if n < c {
+			return p.levels[i]
+		}
+	}
+	return p.levels[len(p.levels)-1]
+}
+
+// messageRenderer renders per-level message templates.
+type messageRenderer struct {
+	templates map[zapcore.Level]*template.Template
+	buf       strings.Builder
+}
+
+func newMessageRenderer(overrides config.LevelMessages) *messageRenderer {
+	r := &messageRenderer{templates: make(map[zapcore.Level]*template.Template)}
+	for lvl, text := range DefaultLevelMessages {
+		r.templates[lvl] = template.Must(template.New(lvl.String()).Parse(text))
+	}
+	for lvl, text := range overrides {
+		// Overrides were validated when the configuration was parsed; fall
+		// back to the default if one still fails.
+		if t, err := template.New(lvl.String()).Parse(text); err == nil {
+			r.templates[lvl] = t
+		}
+	}
+	return r
+}
+
+// render returns the message for lvl. A level without a template gets
+// "tick", and a template that fails to execute "tick <level>".
+func (r *messageRenderer) render(lvl zapcore.Level, data MessageData) string {
+	t, ok := r.templates[lvl]
+	if !ok {
+		return "tick"
+	}
+	r.buf.Reset()
+	if err := t.Execute(&r.buf, data); err != nil {
+		return "tick " + lvl.String()
+	}
+	return r.buf.String()
+}
@@ -0,0 +1,116 @@
+package loop
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+)
+
+func TestLevelPicker_Default(t *testing.T) {
+	p := NewLevelPicker(nil)
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 100; i++ {
+		if got := p.Pick(rng); got != zapcore.InfoLevel {
+			t.Fatalf("Pick() = %v, want info", got)
+		}
+	}
+}
+
+func TestLevelPicker_Distribution(t *testing.T) {
+	weights, err := config.ParseLevelWeights("info=70,warn=20,error=10,fatal=0")
+	if err != nil {
+		t.Fatalf("ParseLevelWeights() error = %v", err)
+	}
+	p := NewLevelPicker(weights)
+	rng := rand.New(rand.NewPCG(12345, 67890))
+
+	counts := make(map[zapcore.Level]int)
+	iterations := 10000
+	for i := 0; i < iterations; i++ {
+		counts[p.Pick(rng)]++
+	}
+
+	if counts[zapcore.FatalLevel] != 0 {
+		t.Errorf("zero-weight fatal picked %d times", counts[zapcore.FatalLevel])
+	}
+
+	expect := map[zapcore.Level]float64{
+		zapcore.InfoLevel:  0.70,
+		zapcore.WarnLevel:  0.20,
+		zapcore.ErrorLevel: 0.10,
+	}
+	for lvl, frac := range expect {
+		got := float64(counts[lvl]) / float64(iterations)
+		if got < frac-0.03 || got > frac+0.03 {
+			t.Errorf("%v fraction = %.3f, want about %.2f", lvl, got, frac)
+		}
+	}
+}
+
+func TestMessageRenderer(t *testing.T) {
+	r := newMessageRenderer(config.LevelMessages{
+		zapcore.ErrorLevel: "tick {{.Count}} failed for {{.RandomString}}",
+		zapcore.WarnLevel:  "{{.Missing}}",
+	})
+	data := MessageData{Count: 7, RandomNumber: 42, RandomString: "beta", Level: "error"}
+
+	if got := r.render(zapcore.ErrorLevel, data); got != "tick 7 failed for beta" {
+		t.Errorf("render(error) = %q", got)
+	}
+	if got := r.render(zapcore.InfoLevel, data); got != "tick" {
+		t.Errorf("render(info) = %q, want default %q", got, "tick")
+	}
+	if got := r.render(zapcore.WarnLevel, data); got != "tick warn" {
+		t.Errorf("render(warn) with failing template = %q, want %q", got, "tick warn")
+	}
+}
+
+func TestLooper_LevelMixIncludesSevereLevels(t *testing.T) {
+	weights, err := config.ParseLevelWeights("dpanic=1,panic=1,fatal=1")
+	if err != nil {
+		t.Fatalf("ParseLevelWeights() error = %v", err)
+	}
+	cfg := &config.Config{MaxNumber: 100, NumStrings: 10, LevelWeights: weights}
+
+	// Records must be written at every level without panicking or
+	// exiting, even where the logger API would.
+	rec := &recorder{}
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)), WithSink(rec))
+
+	for i := 0; i < 60; i++ {
+		l.tick()
+	}
+
+	seen := make(map[zapcore.Level]int)
+	for _, r := range rec.recs {
+		seen[r.Level]++
+		if !r.Caller.Defined {
+			t.Error("generated record has no caller")
+		}
+	}
+	for _, lvl := range []zapcore.Level{zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel} {
+		if seen[lvl] == 0 {
+			t.Errorf("no %v records generated", lvl)
+		}
+	}
+	if len(rec.recs) != 60 {
+		t.Errorf("got %d records, want 60", len(rec.recs))
+	}
+}
+
+func TestLooper_DefaultLevelIsInfo(t *testing.T) {
+	cfg := &config.Config{MaxNumber: 100, NumStrings: 10}
+	rec := &recorder{}
+	l := New(cfg, zap.NewNop(), WithSink(rec))
+
+	l.tick()
+
+	if len(rec.recs) != 1 || rec.recs[0].Level != zapcore.InfoLevel || rec.recs[0].Message != "tick" {
+		t.Errorf("records = %v, want one info tick", rec.recs)
+	}
+}
@@ -4,11 +4,24 @@ package loop
 import (
 	"context"
 	"math/rand/v2"
+	"os"
+	"runtime"
 	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/access"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/adversarial"
 	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/scenario"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/schema"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/shape"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/sink"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/stacktrace"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/truth"
 )
 
 // DefaultStrings is the predefined set of random strings.
@@ -22,27 +35,104 @@ type Looper struct {
 	cfg    *config.Config
 	logger *zap.Logger
 	rng    *rand.Rand
+	seed   uint64
 	count  uint64
+
+	levels    *LevelPicker
+	messages  *messageRenderer
+	scenarios *scenario.Engine
+	schema    *schema.Generator
+	shaper    *shape.Shaper
+	sink      sink.Sink
+	traces    []string
+	truth     *truth.Writer
+	now       func() time.Time
 }
 
-// New creates a new Looper instance.
-func New(cfg *config.Config, logger *zap.Logger) *Looper {
-	return &Looper{
-		cfg:    cfg,
-		logger: logger,
-		rng:    rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), uint64(time.Now().UnixNano()>>32))),
-		count:  0,
+// Option customises a Looper.
+type Option func(*Looper)
+
+// WithClock replaces time.Now as the loop's clock. Scenario schedules and
+// record timestamps follow it, which lets tests step through time.
+func WithClock(now func() time.Time) Option {
+	return func(l *Looper) {
+		l.now = now
+	}
+}
+
+// WithGroundTruth records every generated record and scenario window in w.
+// The caller owns w and closes it after Run returns.
+func WithGroundTruth(w *truth.Writer) Option {
+	return func(l *Looper) {
+		l.truth = w
+	}
+}
+
+// WithSchema adds the fields generated by g to every record. Unless the
+// schema disables them, random_number and random_string are kept.
+func WithSchema(g *schema.Generator) Option {
+	return func(l *Looper) {
+		l.schema = g
 	}
 }
 
+// WithShaper pads every encoded record to a size drawn by s. Raw lines,
+// such as access logs and stack traces, are left as they are.
+func WithShaper(s *shape.Shaper) Option {
+	return func(l *Looper) {
+		l.shaper = s
+	}
+}
+
+// WithSink writes every record, raw lines included, to s. It defaults to
+// zap JSON on stdout. The caller owns s and closes it after Run returns.
+func WithSink(s sink.Sink) Option {
+	return func(l *Looper) {
+		l.sink = s
+	}
+}
+
+// New creates a new Looper instance seeded from cfg.Seed, or from the
+// current time when no seed is configured.
+func New(cfg *config.Config, logger *zap.Logger, opts ...Option) *Looper {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	rng := rand.New(rand.NewPCG(seed, seed>>32))
+	l := NewWithRng(cfg, logger, rng, opts...)
+	l.seed = seed
+	return l
+}
+
 // NewWithRng creates a new Looper with a custom random source (for testing).
-func NewWithRng(cfg *config.Config, logger *zap.Logger, rng *rand.Rand) *Looper {
-	return &Looper{
+func NewWithRng(cfg *config.Config, logger *zap.Logger, rng *rand.Rand, opts ...Option) *Looper {
+	l := &Looper{
 		cfg:    cfg,
 		logger: logger,
 		rng:    rng,
 		count:  0,
+
+		levels:    NewLevelPicker(cfg.LevelWeights),
+		messages:  newMessageRenderer(cfg.LevelMessages),
+		scenarios: scenario.New(cfg.Scenarios, logger),
+		traces:    cfg.StackTraceLanguages,
+		now:       time.Now,
+	}
+	if len(l.traces) == 0 {
+		l.traces = stacktrace.Languages
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.sink == nil {
+		enc, _ := encode.New(encode.FormatJSON)
+		l.sink = sink.NewWriter(os.Stdout, enc)
+	}
+	if l.truth != nil {
+		l.scenarios.AddObserver(l.truth)
+	}
+	return l
 }
 
 // Run starts the logging loop, blocking until context is cancelled.
@@ -51,14 +141,26 @@ func (l *Looper) Run(ctx context.Context) {
 	defer ticker.Stop()
 
 	l.logger.Info("loop started",
+		zap.Stringer("mode", l.cfg.Mode),
 		zap.Duration("interval", l.cfg.SleepDuration),
 		zap.Int("max_number", l.cfg.MaxNumber),
 		zap.Int("num_strings", l.cfg.NumStrings),
+		zap.Stringer("level_weights", l.cfg.LevelWeights),
+		zap.Int("scenarios", l.scenarios.Len()),
+		zap.Float64("stack_trace_rate", l.cfg.StackTraceRate),
+		zap.Uint64("seed", l.seed),
 	)
 
+	start := l.now()
+	if l.truth != nil {
+		l.truth.Begin(start, l.seed)
+	}
+	l.scenarios.Start(start)
+
 	for {
 		select {
 		case <-ctx.Done():
+			l.scenarios.Stop(l.now())
 			l.logger.Info("loop stopped", zap.Uint64("total_ticks", l.count))
 			return
 		case <-ticker.C:
@@ -69,16 +171,196 @@ func (l *Looper) Run(ctx context.Context) {
 
 // tick performs one iteration of the loop.
 func (l *Looper) tick() {
+	now := l.now()
+	eff := l.scenarios.Update(now)
+
+	// A silenced tick produces no record and does not advance count, so
+	// count stays a gap-free sequence of emitted records.
+	if eff.SilenceRate > 0 && l.rng.Float64() < eff.SilenceRate {
+		return
+	}
+
 	l.count++
 
+	switch l.cfg.Mode {
+	case config.ModeAccessLog:
+		l.tickAccess(now, eff)
+		return
+	case config.ModeAdversarial:
+		l.tickAdversarial(now)
+		return
+	}
+
+	if l.cfg.StackTraceRate > 0 && l.rng.Float64() < l.cfg.StackTraceRate {
+		l.tickTrace(now)
+		return
+	}
+
 	randomNum := l.RandomNumber()
 	randomStr := l.RandomString()
+	level := l.levels.Pick(l.rng)
 
-	l.logger.Info("tick",
-		zap.Uint64("count", l.count),
-		zap.Int("random_number", randomNum),
-		zap.String("random_string", randomStr),
-	)
+	randomNum, randomStr, level = l.applyEffects(eff, randomNum, randomStr, level)
+
+	msg := l.messages.render(level, MessageData{
+		Count:        l.count,
+		RandomNumber: randomNum,
+		RandomString: randomStr,
+		Level:        level.String(),
+	})
+
+	rec := record.Record{
+		Time:    now,
+		Level:   level,
+		Message: msg,
+		Fields:  []record.Field{{Key: "count", Value: l.count}},
+	}
+	builtin := l.schema == nil || l.schema.Builtin()
+	if builtin {
+		rec.Fields = append(rec.Fields,
+			record.Field{Key: "random_number", Value: int64(randomNum)},
+			record.Field{Key: "random_string", Value: randomStr},
+		)
+	}
+	if l.schema != nil {
+		rec.Fields = l.schema.Generate(l.rng, now, rec.Fields)
+	}
+
+	l.write(&rec)
+
+	if l.truth != nil {
+		if builtin {
+			l.truth.Record(now, l.count, level, randomNum, randomStr)
+		} else {
+			l.truth.RecordCustom(now, l.count, level)
+		}
+	}
+}
+
+// tickAccess emits one simulated access log line. Scenarios map onto
+// traffic: error spikes become 503s, latency outliers slow requests down
+// and a dominant string becomes a hot path.
+func (l *Looper) tickAccess(now time.Time, eff scenario.Effects) {
+	req := access.NewRequest(l.rng, now, l.cfg.AccessLogTrace)
+
+	if eff.OutlierRate > 0 && l.rng.Float64() < eff.OutlierRate {
+		req.Latency = access.MaxLatency * time.Duration(max(eff.OutlierMultiplier, 2))
+	}
+	if eff.DominantRate > 0 && l.rng.Float64() < eff.DominantRate {
+		req.Path = "/"
+		if eff.DominantValue != "" {
+			req.Path = eff.DominantValue
+		}
+	}
+	if eff.ErrorRate > 0 && l.rng.Float64() < eff.ErrorRate && req.Status < 500 {
+		req.FailWith(503)
+	}
+
+	rec := record.Record{
+		Time:    now,
+		Level:   req.Level(),
+		Message: req.Message(),
+		Fields:  append([]record.Field{{Key: "count", Value: l.count}}, req.Fields()...),
+	}
+	switch l.cfg.AccessLogFormat {
+	case config.AccessLogCommon:
+		rec.Raw = req.Common()
+	case config.AccessLogJSON:
+	default:
+		rec.Raw = req.Combined()
+	}
+
+	l.write(&rec)
+
+	if l.truth != nil {
+		l.truth.RecordAccess(now, l.count, rec.Level, req.Status, req.Latency)
+	}
+}
+
+// tickAdversarial emits the next case of the adversarial catalog. Cases
+// cycle in catalog order, so count identifies the case of every record.
+func (l *Looper) tickAdversarial(now time.Time) {
+	c := adversarial.For(l.count)
+	rec := c.Record(now, l.count)
+	l.write(&rec)
+
+	if l.truth != nil {
+		l.truth.RecordCase(now, l.count, rec.Level, c.Name)
+	}
+}
+
+// tickTrace emits a multi-line stack trace in place of a record. Its lines
+// are written together, as a crashing service prints them, and the ground
+// truth notes which lines belong to it.
+func (l *Looper) tickTrace(now time.Time) {
+	lang := l.traces[0]
+	if len(l.traces) > 1 {
+		lang = l.traces[l.rng.IntN(len(l.traces))]
+	}
+	tr := stacktrace.Generate(l.rng, lang, l.count)
+
+	rec := record.Record{
+		Time:    now,
+		Level:   zapcore.ErrorLevel,
+		Message: tr.Lines[0],
+		Fields: []record.Field{
+			{Key: "count", Value: l.count},
+			{Key: "language", Value: tr.Language},
+			{Key: "lines", Value: int64(len(tr.Lines))},
+		},
+		Raw: tr.Text(),
+	}
+	l.write(&rec)
+
+	if l.truth != nil {
+		l.truth.RecordTrace(now, rec.Level, truth.Trace{
+			Seq:      l.count,
+			Language: tr.Language,
+			Lines:    len(tr.Lines),
+			First:    tr.Lines[0],
+			SHA256:   tr.SHA256(),
+		})
+	}
+}
+
+// write sends a generated record at any level to the sink, after padding
+// it when a shaper is set. Records never pass through a zap logger, so
+// DPanic, Panic and Fatal records are written like any other record
+// instead of panicking or exiting the process.
+func (l *Looper) write(rec *record.Record) {
+	if rec.Raw == "" {
+		rec.Caller = zapcore.NewEntryCaller(runtime.Caller(1))
+		if l.shaper != nil {
+			l.shaper.Pad(l.rng, rec)
+		}
+	}
+
+	if err := l.sink.Write(rec); err != nil {
+		l.logger.Warn("failed to write generated record", zap.Error(err))
+	}
+}
+
+// applyEffects distorts a record according to the active scenarios.
+// The random source is only consulted for effects that are active, so a
+// run without scenarios produces the same sequence as before.
+func (l *Looper) applyEffects(eff scenario.Effects, num int, str string, level zapcore.Level) (int, string, zapcore.Level) {
+	if eff.OutlierRate > 0 && l.rng.Float64() < eff.OutlierRate {
+		num = OutlierNumber(l.rng, l.cfg.MaxNumber, eff.OutlierMultiplier)
+	}
+
+	if eff.DominantRate > 0 && l.rng.Float64() < eff.DominantRate {
+		if eff.DominantValue != "" {
+			str = eff.DominantValue
+		} else if strs := l.getStrings(); len(strs) > 0 {
+			str = strs[0]
+		}
+	}
+
+	if eff.ErrorRate > 0 && l.rng.Float64() < eff.ErrorRate && level < zapcore.ErrorLevel {
+		level = zapcore.ErrorLevel
+	}
+
+	return num, str, level
 }
 
 // RandomNumber returns a random integer in [0, MaxNumber].
@@ -109,6 +391,12 @@ func (l *Looper) getStrings() []string {
 	return DefaultStrings[:l.cfg.NumStrings]
 }
 
+// Seed returns the seed of the random source, or zero if it was supplied
+// by the caller.
+func (l *Looper) Seed() uint64 {
+	return l.seed
+}
+
 // Count returns the current tick count.
 func (l *Looper) Count() uint64 {
 	return l.count
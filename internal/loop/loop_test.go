@@ -1,14 +1,29 @@
 package loop
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"math/rand/v2"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/adversarial"
 	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/schema"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/shape"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/sink"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/truth"
 )
 
 func TestLooper_RandomNumber(t *testing.T) {
@@ -127,7 +142,7 @@ func TestLooper_Run_Cancellation(t *testing.T) {
 		SleepDuration: 10 * time.Millisecond,
 	}
 	logger := zaptest.NewLogger(t)
-	l := New(cfg, logger)
+	l := New(cfg, logger, WithSink(&recorder{}))
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -183,3 +198,510 @@ func TestLooper_Deterministic(t *testing.T) {
 		}
 	}
 }
+
+func TestLooper_SeparateStreams(t *testing.T) {
+	cfg := &config.Config{MaxNumber: 100, NumStrings: 10}
+	opsCore, opsLogs := observer.New(zap.InfoLevel)
+	rec := &recorder{}
+
+	l := New(cfg, zap.New(opsCore), WithSink(rec))
+	l.tick()
+
+	if len(rec.recs) != 1 || rec.recs[0].Message != "tick" {
+		t.Errorf("sink got %v, want one tick record", rec.recs)
+	}
+	if opsLogs.FilterMessage("tick").Len() != 0 {
+		t.Error("tick record leaked into the operational logger")
+	}
+}
+
+// fakeClock is a manually advanced clock for scenario tests.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestLooper_Scenarios(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)}
+	cfg := &config.Config{
+		MaxNumber:  100,
+		NumStrings: 10,
+		Scenarios: []config.Scenario{
+			{Name: "errors", Type: config.ScenarioErrorSpike, Start: config.Duration(10 * time.Second), Duration: config.Duration(10 * time.Second), Intensity: 1},
+			{Name: "slow", Type: config.ScenarioLatencyOutlier, Start: config.Duration(20 * time.Second), Duration: config.Duration(10 * time.Second), Intensity: 1, Multiplier: 10},
+			{Name: "hot", Type: config.ScenarioDominantString, Start: config.Duration(30 * time.Second), Duration: config.Duration(10 * time.Second), Intensity: 1, Value: "kappa"},
+			{Name: "outage", Type: config.ScenarioSilence, Start: config.Duration(40 * time.Second), Duration: config.Duration(10 * time.Second), Intensity: 1},
+		},
+	}
+
+	rec := &recorder{}
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)),
+		WithSink(rec), WithClock(clock.Now))
+
+	// One tick per second for a minute.
+	for i := 0; i < 60; i++ {
+		l.tick()
+		clock.Advance(time.Second)
+	}
+
+	entries := rec.recs
+	if len(entries) != 50 {
+		t.Fatalf("got %d records, want 50 (10 silenced)", len(entries))
+	}
+	if l.Count() != 50 {
+		t.Errorf("Count() = %d, want 50", l.Count())
+	}
+
+	for i, e := range entries {
+		ctx := e.Map()
+		num := ctx["random_number"].(int64)
+		str := ctx["random_string"].(string)
+		sec := e.Time.Sub(time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)) / time.Second
+
+		switch {
+		case sec >= 10 && sec < 20:
+			if e.Level != zap.ErrorLevel {
+				t.Errorf("record %d at %ds level = %v, want error", i, sec, e.Level)
+			}
+		case sec >= 20 && sec < 30:
+			if num <= 100 || num > 1000 {
+				t.Errorf("record %d at %ds random_number = %d, want outlier in (100, 1000]", i, sec, num)
+			}
+		case sec >= 30 && sec < 40:
+			if str != "kappa" {
+				t.Errorf("record %d at %ds random_string = %q, want kappa", i, sec, str)
+			}
+		case sec >= 40 && sec < 50:
+			t.Errorf("record %d emitted at %ds during silence", i, sec)
+		default:
+			if e.Level != zap.InfoLevel || num > 100 {
+				t.Errorf("record %d at %ds not back to normal: level=%v num=%d", i, sec, e.Level, num)
+			}
+		}
+	}
+}
+
+func TestOutlierNumber(t *testing.T) {
+	rng := rand.New(rand.NewPCG(42, 42))
+	for i := 0; i < 1000; i++ {
+		n := OutlierNumber(rng, 100, 5)
+		if n <= 100 || n > 500 {
+			t.Fatalf("OutlierNumber(100, 5) = %d, want (100, 500]", n)
+		}
+	}
+	if n := OutlierNumber(rng, 0, 0); n != 2 {
+		t.Errorf("OutlierNumber(0, 0) = %d, want 2", n)
+	}
+}
+
+func TestLooper_SeedReproducible(t *testing.T) {
+	cfg := &config.Config{MaxNumber: 100, NumStrings: 10, Seed: 1234}
+
+	l1 := New(cfg, zap.NewNop())
+	l2 := New(cfg, zap.NewNop())
+	if l1.Seed() != 1234 {
+		t.Errorf("Seed() = %d, want 1234", l1.Seed())
+	}
+	for i := 0; i < 50; i++ {
+		if a, b := l1.RandomNumber(), l2.RandomNumber(); a != b {
+			t.Fatalf("iteration %d: %d != %d with the same seed", i, a, b)
+		}
+	}
+
+	if New(&config.Config{}, zap.NewNop()).Seed() == 0 {
+		t.Error("Seed() = 0 for a time-seeded looper, want the chosen seed")
+	}
+}
+
+func TestLooper_GroundTruth(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)}
+	cfg := &config.Config{
+		MaxNumber:  100,
+		NumStrings: 10,
+		Scenarios: []config.Scenario{
+			{Name: "outage", Type: config.ScenarioSilence, Start: config.Duration(30 * time.Second), Duration: config.Duration(60 * time.Second), Intensity: 1},
+		},
+	}
+
+	var buf bytes.Buffer
+	tw := truth.NewWriter(&buf, time.Minute)
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)),
+		WithSink(&recorder{}), WithClock(clock.Now), WithGroundTruth(tw))
+
+	tw.Begin(clock.Now(), 0)
+	for i := 0; i < 180; i++ {
+		l.tick()
+		clock.Advance(time.Second)
+	}
+	l.scenarios.Stop(clock.Now())
+	if err := tw.Close(clock.Now()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	var records float64
+	var anomalies int
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		switch line["kind"] {
+		case truth.KindWindow:
+			records += line["records"].(float64)
+		case truth.KindAnomaly:
+			anomalies++
+		}
+	}
+
+	if uint64(records) != l.Count() {
+		t.Errorf("manifest counts %v records, looper emitted %d", records, l.Count())
+	}
+	if l.Count() != 120 {
+		t.Errorf("Count() = %d, want 120 (60 silenced)", l.Count())
+	}
+	if anomalies != 1 {
+		t.Errorf("got %d anomaly lines, want 1", anomalies)
+	}
+}
+
+func TestLooper_Schema(t *testing.T) {
+	builtin := false
+	gen, err := schema.New(&config.Schema{
+		Builtin: &builtin,
+		Fields: []config.FieldSpec{
+			{Name: "service", Type: config.FieldEnum, Values: []any{"checkout"}},
+			{Name: "latency_ms", Type: config.FieldInt, Min: 5, Max: 5},
+			{Name: "summary", Type: config.FieldTemplate, Template: "{{.service}} #{{.count}}"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+
+	rec := &recorder{}
+	cfg := &config.Config{MaxNumber: 100, NumStrings: 10}
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)),
+		WithSink(rec), WithSchema(gen))
+	l.tick()
+	l.tick()
+
+	if len(rec.recs) != 2 {
+		t.Fatalf("got %d records, want 2", len(rec.recs))
+	}
+	ctx := rec.recs[1].Map()
+	want := map[string]any{
+		"count":      uint64(2),
+		"service":    "checkout",
+		"latency_ms": int64(5),
+		"summary":    "checkout #2",
+	}
+	for k, v := range want {
+		if ctx[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, ctx[k], v)
+		}
+	}
+	if _, ok := ctx["random_number"]; ok {
+		t.Error("random_number emitted although builtin fields are disabled")
+	}
+}
+
+func TestLooper_SchemaGroundTruth(t *testing.T) {
+	builtin := false
+	gen, err := schema.New(&config.Schema{
+		Builtin: &builtin,
+		Fields:  []config.FieldSpec{{Name: "service", Type: config.FieldEnum, Values: []any{"checkout"}}},
+	})
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+
+	clock := &fakeClock{t: time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)}
+	var buf bytes.Buffer
+	tw := truth.NewWriter(&buf, time.Minute)
+	cfg := &config.Config{MaxNumber: 100, NumStrings: 10}
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)),
+		WithSink(&recorder{}), WithSchema(gen), WithClock(clock.Now), WithGroundTruth(tw))
+
+	tw.Begin(clock.Now(), 0)
+	for range 5 {
+		l.tick()
+		clock.Advance(time.Second)
+	}
+	if err := tw.Close(clock.Now()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	var win truth.Window
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line truth.Window
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		if line.Kind == truth.KindWindow {
+			win = line
+		}
+	}
+	if win.Records != 5 {
+		t.Errorf("manifest counts %d records, want 5", win.Records)
+	}
+	if len(win.RandomString) != 0 || win.RandomNumber != nil {
+		t.Errorf("manifest counts random_string %v and random_number %v, which the schema does not emit", win.RandomString, win.RandomNumber)
+	}
+}
+
+func TestLooper_RecordSize(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := &config.Config{MaxNumber: 100, NumStrings: 10}
+	size := config.RecordSize{Shape: config.SizeFixed, Min: 1024, Max: 1024}
+	enc := newEncoder(t, encode.FormatJSON)
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)),
+		WithSink(sink.NewWriter(&buf, enc)), WithShaper(shape.New(size, enc)))
+	for range 5 {
+		l.tick()
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d records, want 5", len(lines))
+	}
+	for i, line := range lines {
+		if len(line)+1 != 1024 {
+			t.Errorf("record %d is %d bytes, want 1024", i, len(line)+1)
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("record %d is not JSON: %v", i, err)
+		}
+		if _, ok := m[shape.PaddingKey]; !ok {
+			t.Errorf("record %d has no padding field", i)
+		}
+	}
+}
+
+func TestLooper_Logfmt(t *testing.T) {
+	var buf bytes.Buffer
+	clock := &fakeClock{t: time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)}
+	cfg := &config.Config{MaxNumber: 100, NumStrings: 10}
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)),
+		WithSink(sink.NewWriter(&buf, newEncoder(t, encode.FormatLogfmt))), WithClock(clock.Now))
+	l.tick()
+	l.tick()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	want := regexp.MustCompile(`^ts=2026-02-18T12:00:00Z level=info caller=loop/loop.go:\d+ msg=tick count=2 random_number=\d+ random_string=\S+$`)
+	if !want.MatchString(lines[1]) {
+		t.Errorf("line = %q, want logfmt record", lines[1])
+	}
+}
+
+// recorder is a sink that keeps every record.
+type recorder struct{ recs []*record.Record }
+
+func (r *recorder) Write(rec *record.Record) error { r.recs = append(r.recs, rec); return nil }
+func (r *recorder) Close() error                   { return nil }
+
+// encoded returns the records that are not raw lines.
+func (r *recorder) encoded() []*record.Record {
+	var recs []*record.Record
+	for _, rec := range r.recs {
+		if rec.Raw == "" {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+// raw returns the raw lines as a sink writes them.
+func (r *recorder) raw() string {
+	var b strings.Builder
+	for _, rec := range r.recs {
+		if rec.Raw != "" {
+			b.WriteString(rec.Raw + "\n")
+		}
+	}
+	return b.String()
+}
+
+func newEncoder(t *testing.T, format string) encode.Encoder {
+	t.Helper()
+	enc, err := encode.New(format)
+	if err != nil {
+		t.Fatalf("encode.New(%q) error = %v", format, err)
+	}
+	return enc
+}
+
+func TestLooper_Sink(t *testing.T) {
+	rec := &recorder{}
+	cfg := &config.Config{MaxNumber: 100, NumStrings: 10, Mode: config.ModeAccessLog, AccessLogFormat: config.AccessLogCommon}
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)), WithSink(rec))
+	l.tick()
+	cfg.Mode = config.ModeRecords
+	l.tick()
+
+	if len(rec.recs) != 2 {
+		t.Fatalf("sink got %d records, want 2", len(rec.recs))
+	}
+	if rec.recs[0].Raw == "" || rec.recs[0].Caller.Defined {
+		t.Errorf("access record = %+v, want a raw line without caller", rec.recs[0])
+	}
+	if rec.recs[1].Raw != "" || !rec.recs[1].Caller.Defined {
+		t.Errorf("record = %+v, want an encoded record with caller", rec.recs[1])
+	}
+}
+
+func TestLooper_AccessLog(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)}
+	clf := regexp.MustCompile(`^\S+ - \S+ \[18/Feb/2026:12:00:\d\d \+0000\] "[A-Z]+ \S+ HTTP/\d\.\d" (\d{3}) (\d+|-) "[^"]*" "[^"]*"$`)
+
+	t.Run("combined", func(t *testing.T) {
+		cfg := &config.Config{
+			Mode:            config.ModeAccessLog,
+			AccessLogFormat: config.AccessLogCombined,
+			Scenarios: []config.Scenario{
+				{Name: "errors", Type: config.ScenarioErrorSpike, Start: config.Duration(5 * time.Second), Duration: config.Duration(5 * time.Second), Intensity: 1},
+			},
+		}
+		var buf bytes.Buffer
+		l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)),
+			WithSink(sink.NewWriter(&buf, newEncoder(t, encode.FormatJSON))), WithClock(clock.Now))
+		for i := 0; i < 10; i++ {
+			l.tick()
+			clock.Advance(time.Second)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if len(lines) != 10 {
+			t.Fatalf("got %d lines, want 10", len(lines))
+		}
+		for i, line := range lines {
+			m := clf.FindStringSubmatch(line)
+			if m == nil {
+				t.Fatalf("line %d = %q does not match the combined format", i, line)
+			}
+			if i >= 5 && m[1][0] != '5' {
+				t.Errorf("line %d during error spike has status %s, want 5xx", i, m[1])
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		cfg := &config.Config{Mode: config.ModeAccessLog, AccessLogFormat: config.AccessLogJSON, AccessLogTrace: true}
+		rec := &recorder{}
+		l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)),
+			WithSink(rec), WithClock(clock.Now))
+		l.tick()
+
+		if raw := rec.raw(); raw != "" {
+			t.Errorf("json mode wrote raw output %q", raw)
+		}
+		if len(rec.recs) != 1 {
+			t.Fatalf("got %d records, want 1", len(rec.recs))
+		}
+		ctx := rec.recs[0].Map()
+		for _, key := range []string{"count", "remote_addr", "method", "path", "status", "request_time_ms", "trace_id", "span_id", "request_id"} {
+			if _, ok := ctx[key]; !ok {
+				t.Errorf("JSON access record lacks %s: %v", key, ctx)
+			}
+		}
+	})
+}
+
+func TestLooper_StackTraces(t *testing.T) {
+	cfg := &config.Config{
+		MaxNumber:           100,
+		NumStrings:          10,
+		StackTraceRate:      0.3,
+		StackTraceLanguages: config.StackTraceLanguages{"java", "go"},
+	}
+	var manifest bytes.Buffer
+	rec := &recorder{}
+	clock := &fakeClock{t: time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)}
+	tw := truth.NewWriter(&manifest, time.Hour)
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)),
+		WithSink(rec), WithClock(clock.Now), WithGroundTruth(tw))
+
+	tw.Begin(clock.Now(), 0)
+	for i := 0; i < 100; i++ {
+		l.tick()
+		clock.Advance(time.Second)
+	}
+	if err := tw.Close(clock.Now()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	var traces []truth.Trace
+	for _, line := range strings.Split(strings.TrimSpace(manifest.String()), "\n") {
+		var tr truth.Trace
+		if err := json.Unmarshal([]byte(line), &tr); err != nil {
+			t.Fatalf("invalid manifest line %q: %v", line, err)
+		}
+		if tr.Kind == truth.KindTrace {
+			traces = append(traces, tr)
+		}
+	}
+	if len(traces) < 15 || len(traces) > 45 {
+		t.Fatalf("got %d traces in 100 ticks, want about 30", len(traces))
+	}
+	if records := len(rec.encoded()); records+len(traces) != 100 {
+		t.Errorf("got %d records and %d traces, want 100 in total", records, len(traces))
+	}
+
+	// Every trace appears in the raw output as consecutive lines starting
+	// with its recorded first line.
+	out := strings.Split(rec.raw(), "\n")
+	pos := 0
+	for _, tr := range traces {
+		if tr.Language != "java" && tr.Language != "go" {
+			t.Errorf("trace %d language = %q", tr.Seq, tr.Language)
+		}
+		for pos < len(out) && out[pos] != tr.First {
+			pos++
+		}
+		if pos+tr.Lines > len(out) {
+			t.Fatalf("trace %d (%q) not found in raw output", tr.Seq, tr.First)
+		}
+		sum := sha256.Sum256([]byte(strings.Join(out[pos:pos+tr.Lines], "\n")))
+		if hex.EncodeToString(sum[:]) != tr.SHA256 {
+			t.Errorf("trace %d lines do not match the recorded sha256", tr.Seq)
+		}
+		pos += tr.Lines
+	}
+}
+
+func TestLooper_Adversarial(t *testing.T) {
+	cfg := &config.Config{Mode: config.ModeAdversarial}
+	rec := &recorder{}
+	l := NewWithRng(cfg, zap.NewNop(), rand.New(rand.NewPCG(1, 2)), WithSink(rec))
+
+	n := len(adversarial.Cases)
+	for i := 0; i < n+1; i++ {
+		l.tick()
+	}
+
+	raw := rec.raw()
+	records := rec.encoded()
+	rawLines := strings.Count(raw, "\n")
+	if len(records)+rawLines != n+1 {
+		t.Fatalf("got %d records and %d raw lines, want %d", len(records), rawLines, n+1)
+	}
+	first := records[0].Map()
+	if first["adversarial_case"] != adversarial.Cases[0].Name {
+		t.Errorf("first case = %v, want %s", first["adversarial_case"], adversarial.Cases[0].Name)
+	}
+	last := records[len(records)-1].Map()
+	if last["count"] != uint64(n+1) || last["adversarial_case"] != adversarial.Cases[0].Name {
+		t.Errorf("record %d = %v, want the catalog to start over", n+1, last)
+	}
+	if !strings.Contains(raw, `"adversarial_case":"duplicate_keys"`) {
+		t.Errorf("raw output lacks the duplicate_keys case:\n%s", raw)
+	}
+}
@@ -21,3 +21,15 @@ func RandomStringFromSlice(rng *rand.Rand, strings []string) string {
 	}
 	return strings[rng.IntN(len(strings))]
 }
+
+// OutlierNumber returns an integer in (max, max*multiplier], well outside
+// the normal [0, max] range. A non-positive max is treated as 1.
+func OutlierNumber(rng *rand.Rand, max, multiplier int) int {
+	if max <= 0 {
+		max = 1
+	}
+	if multiplier < 2 {
+		multiplier = 2
+	}
+	return max + 1 + rng.IntN(max*(multiplier-1))
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry_Text(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("loggen_records_total", "Records written.", "sink", "stdout").Add(3)
+	r.Counter("loggen_records_total", "Records written.", "sink", `we"ird`).Inc()
+	r.Gauge("loggen_queue_depth", "Queued records.").Set(-2.5)
+	h := r.Histogram("loggen_record_size_bytes", "Record sizes.", []float64{100, 10})
+	h.Observe(5)
+	h.Observe(10)
+	h.Observe(50)
+	h.Observe(500)
+
+	want := `# HELP loggen_queue_depth Queued records.
+# TYPE loggen_queue_depth gauge
+loggen_queue_depth -2.5
+# HELP loggen_record_size_bytes Record sizes.
+# TYPE loggen_record_size_bytes histogram
+loggen_record_size_bytes_bucket{le="10"} 2
+loggen_record_size_bytes_bucket{le="100"} 3
+loggen_record_size_bytes_bucket{le="+Inf"} 4
+loggen_record_size_bytes_sum 565
+loggen_record_size_bytes_count 4
+# HELP loggen_records_total Records written.
+# TYPE loggen_records_total counter
+loggen_records_total{sink="stdout"} 3
+loggen_records_total{sink="we\"ird"} 1
+`
+	if got := r.Text(); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegistry_SameSeries(t *testing.T) {
+	r := NewRegistry()
+	a := r.Counter("c", "help", "k", "v")
+	b := r.Counter("c", "help", "k", "v")
+	if a != b {
+		t.Error("Counter() returned a new series for the same labels")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering c as a gauge did not panic")
+		}
+	}()
+	r.Gauge("c", "help")
+}
+
+func TestCounter_Concurrent(t *testing.T) {
+	c := NewRegistry().Counter("c", "help")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	if c.Value() != 8000 {
+		t.Errorf("Value() = %v, want 8000", c.Value())
+	}
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("up_total", "help").Inc()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "up_total 1\n") {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	got := ExponentialBuckets(64, 4, 3)
+	if len(got) != 3 || got[0] != 64 || got[1] != 256 || got[2] != 1024 {
+		t.Errorf("ExponentialBuckets(64, 4, 3) = %v", got)
+	}
+}
@@ -0,0 +1,190 @@
+package msgpack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxLength bounds string, binary and container lengths so a corrupt
+// stream cannot make the decoder allocate gigabytes.
+const maxLength = 64 << 20
+
+// Ext is a decoded extension value.
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// Decoder reads MessagePack values from a stream.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder creates a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next value. Integers decode as int64, or uint64 when
+// they exceed int64; floats as float64; strings as string; binary as
+// []byte; arrays as []any; maps as map[string]any, with binary keys
+// converted to strings; extensions as Ext.
+func (d *Decoder) Decode() (any, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return d.mapping(int(c & 0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if v > math.MaxInt64 {
+			return v, nil
+		}
+		return int64(v), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, nil
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.bytes(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapping(int(n))
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unknown type byte 0x%02x", c)
+}
+
+func (d *Decoder) uint(size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (d *Decoder) bytes(n int) ([]byte, error) {
+	if n > maxLength {
+		return nil, fmt.Errorf("msgpack: length %d exceeds limit", n)
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(d.r, buf)
+	return buf, err
+}
+
+func (d *Decoder) str(n int) (string, error) {
+	b, err := d.bytes(n)
+	return string(b), err
+}
+
+func (d *Decoder) array(n int) ([]any, error) {
+	if n > maxLength {
+		return nil, fmt.Errorf("msgpack: length %d exceeds limit", n)
+	}
+	out := make([]any, 0, min(n, 1024))
+	for range n {
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (d *Decoder) mapping(n int) (map[string]any, error) {
+	if n > maxLength {
+		return nil, fmt.Errorf("msgpack: length %d exceeds limit", n)
+	}
+	out := make(map[string]any, min(n, 1024))
+	for range n {
+		k, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		switch k := k.(type) {
+		case string:
+			out[k] = v
+		case []byte:
+			out[string(k)] = v
+		default:
+			out[fmt.Sprint(k)] = v
+		}
+	}
+	return out, nil
+}
+
+func (d *Decoder) ext(n int) (Ext, error) {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return Ext{}, err
+	}
+	data, err := d.bytes(n)
+	return Ext{Type: int8(typ), Data: data}, err
+}
@@ -0,0 +1,95 @@
+package msgpack
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func([]byte) []byte
+		want   any
+		size   int
+	}{
+		{"nil", AppendNil, nil, 1},
+		{"true", func(b []byte) []byte { return AppendBool(b, true) }, true, 1},
+		{"false", func(b []byte) []byte { return AppendBool(b, false) }, false, 1},
+		{"positive fixint", func(b []byte) []byte { return AppendInt(b, 127) }, int64(127), 1},
+		{"negative fixint", func(b []byte) []byte { return AppendInt(b, -32) }, int64(-32), 1},
+		{"int8", func(b []byte) []byte { return AppendInt(b, -100) }, int64(-100), 2},
+		{"int16", func(b []byte) []byte { return AppendInt(b, -1000) }, int64(-1000), 3},
+		{"int32", func(b []byte) []byte { return AppendInt(b, -100000) }, int64(-100000), 5},
+		{"int64", func(b []byte) []byte { return AppendInt(b, math.MinInt64) }, int64(math.MinInt64), 9},
+		{"uint8", func(b []byte) []byte { return AppendUint(b, 200) }, int64(200), 2},
+		{"uint16", func(b []byte) []byte { return AppendUint(b, 60000) }, int64(60000), 3},
+		{"uint32", func(b []byte) []byte { return AppendUint(b, 4000000000) }, int64(4000000000), 5},
+		{"uint64", func(b []byte) []byte { return AppendUint(b, math.MaxUint64) }, uint64(math.MaxUint64), 9},
+		{"float", func(b []byte) []byte { return AppendFloat(b, 1.5) }, 1.5, 9},
+		{"fixstr", func(b []byte) []byte { return AppendString(b, "tick") }, "tick", 5},
+		{"str8", func(b []byte) []byte { return AppendString(b, strings.Repeat("a", 32)) }, strings.Repeat("a", 32), 34},
+		{"str16", func(b []byte) []byte { return AppendString(b, strings.Repeat("a", 300)) }, strings.Repeat("a", 300), 303},
+		{"str32", func(b []byte) []byte { return AppendString(b, strings.Repeat("a", 70000)) }, strings.Repeat("a", 70000), 70005},
+		{"bin8", func(b []byte) []byte { return AppendBinary(b, []byte{1, 2}) }, []byte{1, 2}, 4},
+		{"ext8", func(b []byte) []byte { return AppendExt(b, 0, make([]byte, 8)) }, Ext{Type: 0, Data: make([]byte, 8)}, 10},
+		{"ext3", func(b []byte) []byte { return AppendExt(b, -1, []byte{1, 2, 3}) }, Ext{Type: -1, Data: []byte{1, 2, 3}}, 6},
+		{"array", func(b []byte) []byte {
+			b = AppendArrayHeader(b, 2)
+			b = AppendInt(b, 1)
+			return AppendString(b, "x")
+		}, []any{int64(1), "x"}, 4},
+		{"array16", func(b []byte) []byte {
+			b = AppendArrayHeader(b, 16)
+			for range 16 {
+				b = AppendNil(b)
+			}
+			return b
+		}, make([]any, 16), 19},
+		{"map", func(b []byte) []byte {
+			b = AppendMapHeader(b, 2)
+			b = AppendString(b, "ack")
+			b = AppendString(b, "id")
+			b = AppendBinary(b, []byte("nonce"))
+			return AppendBool(b, true)
+		}, map[string]any{"ack": "id", "nonce": true}, 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.encode(nil)
+			if len(b) != tt.size {
+				t.Errorf("encoded size = %d, want %d", len(b), tt.size)
+			}
+			got, err := NewDecoder(bytes.NewReader(b)).Decode()
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"unknown type", []byte{0xc1}},
+		{"truncated string", []byte{0xa5, 'a'}},
+		{"truncated int", []byte{0xcd, 0x01}},
+		{"huge string", []byte{0xdb, 0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDecoder(bytes.NewReader(tt.data)).Decode(); err == nil {
+				t.Error("Decode() succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Package record defines the generated log record that flows from the loop
+// to its outputs.
+package record
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Record is one generated log record.
+type Record struct {
+	Time    time.Time
+	Level   zapcore.Level
+	Message string
+
+	// Fields are the structured attributes in emission order.
+	Fields []Field
+
+	// Caller is the code location reported in the caller key. The loop
+	// fills it in just before the record is written.
+	Caller zapcore.EntryCaller
+
+	// Raw, when set, is a preformatted line, such as an access log line,
+	// that is written verbatim instead of being encoded. Fields still
+	// describe it for filtering and ground truth.
+	Raw string
+}
+
+// Field is a named value. Value is one of int64, uint64, float64, bool,
+// string, time.Time, []any (array) or []Field (nested object).
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Get returns the value of the top-level field key.
+func (r *Record) Get(key string) (any, bool) {
+	for _, f := range r.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Map returns the top-level fields as a map, for templates and predicates.
+func (r *Record) Map() map[string]any {
+	m := make(map[string]any, len(r.Fields))
+	for _, f := range r.Fields {
+		m[f.Key] = f.Value
+	}
+	return m
+}
+
+// ZapFields converts the record's fields to zap fields, preserving order.
+func (r *Record) ZapFields() []zap.Field {
+	out := make([]zap.Field, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		out = append(out, zapField(f.Key, f.Value))
+	}
+	return out
+}
+
+func zapField(key string, v any) zap.Field {
+	switch v := v.(type) {
+	case int64:
+		return zap.Int64(key, v)
+	case int:
+		return zap.Int(key, v)
+	case uint64:
+		return zap.Uint64(key, v)
+	case float64:
+		return zap.Float64(key, v)
+	case bool:
+		return zap.Bool(key, v)
+	case string:
+		return zap.String(key, v)
+	case time.Time:
+		return zap.Time(key, v)
+	case []Field:
+		return zap.Object(key, object(v))
+	case []any:
+		return zap.Array(key, array(v))
+	default:
+		return zap.Any(key, v)
+	}
+}
+
+// object marshals nested fields as a JSON object.
+type object []Field
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (o object) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, f := range o {
+		zapField(f.Key, f.Value).AddTo(enc)
+	}
+	return nil
+}
+
+// array marshals a slice of values as a JSON array.
+type array []any
+
+// MarshalLogArray implements zapcore.ArrayMarshaler.
+func (a array) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, v := range a {
+		switch v := v.(type) {
+		case int64:
+			enc.AppendInt64(v)
+		case int:
+			enc.AppendInt(v)
+		case uint64:
+			enc.AppendUint64(v)
+		case float64:
+			enc.AppendFloat64(v)
+		case bool:
+			enc.AppendBool(v)
+		case string:
+			enc.AppendString(v)
+		case time.Time:
+			enc.AppendTime(v)
+		case []Field:
+			if err := enc.AppendObject(object(v)); err != nil {
+				return err
+			}
+		case []any:
+			if err := enc.AppendArray(array(v)); err != nil {
+				return err
+			}
+		default:
+			if err := enc.AppendReflected(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package record
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRecord_GetAndMap(t *testing.T) {
+	r := Record{Fields: []Field{{Key: "a", Value: int64(1)}, {Key: "b", Value: "x"}}}
+
+	if v, ok := r.Get("b"); !ok || v != "x" {
+		t.Errorf("Get(b) = %v, %v, want x, true", v, ok)
+	}
+	if _, ok := r.Get("c"); ok {
+		t.Error("Get(c) found a missing field")
+	}
+	if m := r.Map(); len(m) != 2 || m["a"] != int64(1) {
+		t.Errorf("Map() = %v", m)
+	}
+}
+
+func TestRecord_ZapFields(t *testing.T) {
+	ts := time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)
+	r := Record{Fields: []Field{
+		{Key: "i", Value: int64(-3)},
+		{Key: "u", Value: uint64(3)},
+		{Key: "f", Value: 1.5},
+		{Key: "b", Value: true},
+		{Key: "s", Value: "str"},
+		{Key: "t", Value: ts},
+		{Key: "arr", Value: []any{int64(1), "two", []Field{{Key: "k", Value: "v"}}}},
+		{Key: "obj", Value: []Field{{Key: "n", Value: []any{true}}}},
+	}}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range r.ZapFields() {
+		f.AddTo(enc)
+	}
+
+	if enc.Fields["i"] != int64(-3) || enc.Fields["u"] != uint64(3) || enc.Fields["s"] != "str" {
+		t.Errorf("scalar fields = %v", enc.Fields)
+	}
+	if enc.Fields["t"] != ts {
+		t.Errorf("t = %v, want %v", enc.Fields["t"], ts)
+	}
+	arr, ok := enc.Fields["arr"].([]any)
+	if !ok || len(arr) != 3 || arr[1] != "two" {
+		t.Fatalf("arr = %#v", enc.Fields["arr"])
+	}
+	if nested, ok := arr[2].(map[string]any); !ok || nested["k"] != "v" {
+		t.Errorf("arr[2] = %#v, want object", arr[2])
+	}
+	obj, ok := enc.Fields["obj"].(map[string]any)
+	if !ok {
+		t.Fatalf("obj = %#v", enc.Fields["obj"])
+	}
+	if n, ok := obj["n"].([]any); !ok || n[0] != true {
+		t.Errorf("obj.n = %#v", obj["n"])
+	}
+}
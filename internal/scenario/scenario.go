@@ -0,0 +1,202 @@
+// Package scenario schedules incident scenarios, such as error spikes or
+// complete silence, that distort the generated records for a while and then
+// recover. Start and end of every scenario are logged as ground truth so
+// detection queries can be scored.
+package scenario
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+)
+
+// Effects is the combined distortion of all active scenarios at one point
+// in time. Rates are probabilities in [0, 1]; zero means unaffected.
+type Effects struct {
+	// SilenceRate is the probability a record is suppressed.
+	SilenceRate float64
+
+	// ErrorRate is the probability a record is forced to error level.
+	ErrorRate float64
+
+	// OutlierRate is the probability random_number is replaced by an
+	// outlier up to MaxNumber*OutlierMultiplier.
+	OutlierRate       float64
+	OutlierMultiplier int
+
+	// DominantRate is the probability random_string is replaced by
+	// DominantValue. An empty DominantValue means the first string.
+	DominantRate  float64
+	DominantValue string
+
+	// Active lists the names of the scenarios that produced these effects.
+	Active []string
+}
+
+// Window is the ground truth for one scenario run.
+type Window struct {
+	Name      string
+	Type      string
+	Intensity float64
+	Start     time.Time
+	End       time.Time
+
+	// Truncated is set when the loop stopped before the scenario ended.
+	Truncated bool
+}
+
+// Observer is notified when scenarios start and end, for example to
+// export ground truth.
+type Observer interface {
+	ScenarioStarted(w Window)
+	ScenarioEnded(w Window)
+}
+
+type phase int
+
+const (
+	pending phase = iota
+	active
+	done
+)
+
+// Engine evaluates scheduled scenarios against the loop's clock.
+type Engine struct {
+	specs     []config.Scenario
+	phases    []phase
+	origin    time.Time
+	started   bool
+	logger    *zap.Logger
+	observers []Observer
+}
+
+// New creates an engine for specs. Transitions are logged to logger, which
+// should be the operational logger so ground truth is not mixed into the
+// generated records.
+func New(specs []config.Scenario, logger *zap.Logger) *Engine {
+	return &Engine{
+		specs:  specs,
+		phases: make([]phase, len(specs)),
+		logger: logger.Named("scenario"),
+	}
+}
+
+// AddObserver registers o for scenario transitions.
+func (e *Engine) AddObserver(o Observer) {
+	e.observers = append(e.observers, o)
+}
+
+// Len returns the number of configured scenarios.
+func (e *Engine) Len() int {
+	return len(e.specs)
+}
+
+// Start sets the time scenario offsets are measured from. Calling it more
+// than once has no effect.
+func (e *Engine) Start(origin time.Time) {
+	if e.started {
+		return
+	}
+	e.origin = origin
+	e.started = true
+}
+
+// window returns the scheduled window of spec i.
+func (e *Engine) window(i int) Window {
+	s := e.specs[i]
+	start := e.origin.Add(time.Duration(s.Start))
+	return Window{
+		Name:      s.Name,
+		Type:      s.Type,
+		Intensity: s.Intensity,
+		Start:     start,
+		End:       start.Add(time.Duration(s.Duration)),
+	}
+}
+
+// Update advances scenarios to now, reporting any transitions, and returns
+// the effects that apply to a record generated at now.
+func (e *Engine) Update(now time.Time) Effects {
+	var eff Effects
+	if len(e.specs) == 0 {
+		return eff
+	}
+	e.Start(now)
+
+	for i, s := range e.specs {
+		w := e.window(i)
+
+		if e.phases[i] == pending && !now.Before(w.Start) {
+			e.phases[i] = active
+			e.begin(w)
+		}
+		if e.phases[i] == active && !now.Before(w.End) {
+			e.phases[i] = done
+			e.finish(w)
+		}
+		if e.phases[i] != active {
+			continue
+		}
+
+		eff.Active = append(eff.Active, s.Name)
+		switch s.Type {
+		case config.ScenarioSilence:
+			eff.SilenceRate = max(eff.SilenceRate, s.Intensity)
+		case config.ScenarioErrorSpike:
+			eff.ErrorRate = max(eff.ErrorRate, s.Intensity)
+		case config.ScenarioLatencyOutlier:
+			eff.OutlierRate = max(eff.OutlierRate, s.Intensity)
+			eff.OutlierMultiplier = max(eff.OutlierMultiplier, s.Multiplier)
+		case config.ScenarioDominantString:
+			if s.Intensity >= eff.DominantRate {
+				eff.DominantRate = s.Intensity
+				eff.DominantValue = s.Value
+			}
+		}
+	}
+
+	return eff
+}
+
+// Stop ends any active scenario at now, marking it truncated.
+func (e *Engine) Stop(now time.Time) {
+	for i := range e.specs {
+		if e.phases[i] != active {
+			continue
+		}
+		e.phases[i] = done
+		w := e.window(i)
+		w.End = now
+		w.Truncated = true
+		e.finish(w)
+	}
+}
+
+func (e *Engine) begin(w Window) {
+	e.logger.Info("scenario started",
+		zap.String("scenario", w.Name),
+		zap.String("scenario_type", w.Type),
+		zap.Float64("intensity", w.Intensity),
+		zap.Time("start", w.Start),
+		zap.Time("planned_end", w.End),
+	)
+	for _, o := range e.observers {
+		o.ScenarioStarted(w)
+	}
+}
+
+func (e *Engine) finish(w Window) {
+	e.logger.Info("scenario ended",
+		zap.String("scenario", w.Name),
+		zap.String("scenario_type", w.Type),
+		zap.Float64("intensity", w.Intensity),
+		zap.Time("start", w.Start),
+		zap.Time("end", w.End),
+		zap.Bool("truncated", w.Truncated),
+	)
+	for _, o := range e.observers {
+		o.ScenarioEnded(w)
+	}
+}
@@ -0,0 +1,148 @@
+package scenario
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+)
+
+var origin = time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)
+
+func spec(name, typ string, start, dur time.Duration, intensity float64) config.Scenario {
+	return config.Scenario{
+		Name:      name,
+		Type:      typ,
+		Start:     config.Duration(start),
+		Duration:  config.Duration(dur),
+		Intensity: intensity,
+	}
+}
+
+func TestEngine_NoScenarios(t *testing.T) {
+	e := New(nil, zap.NewNop())
+	eff := e.Update(origin)
+	if eff.SilenceRate != 0 || eff.ErrorRate != 0 || eff.OutlierRate != 0 || eff.DominantRate != 0 || len(eff.Active) != 0 {
+		t.Errorf("Update() = %+v, want no effects", eff)
+	}
+}
+
+func TestEngine_Schedule(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	e := New([]config.Scenario{
+		spec("errors", config.ScenarioErrorSpike, time.Minute, 2*time.Minute, 0.5),
+	}, zap.New(core))
+	e.Start(origin)
+
+	tests := []struct {
+		at       time.Duration
+		wantRate float64
+	}{
+		{0, 0},
+		{59 * time.Second, 0},
+		{time.Minute, 0.5},
+		{2 * time.Minute, 0.5},
+		{3 * time.Minute, 0},
+		{10 * time.Minute, 0},
+	}
+	for _, tt := range tests {
+		eff := e.Update(origin.Add(tt.at))
+		if eff.ErrorRate != tt.wantRate {
+			t.Errorf("at %v: ErrorRate = %v, want %v", tt.at, eff.ErrorRate, tt.wantRate)
+		}
+	}
+
+	started := logs.FilterMessage("scenario started").AllUntimed()
+	ended := logs.FilterMessage("scenario ended").AllUntimed()
+	if len(started) != 1 || len(ended) != 1 {
+		t.Fatalf("got %d started / %d ended entries, want 1 / 1", len(started), len(ended))
+	}
+
+	ctx := ended[0].ContextMap()
+	if ctx["scenario"] != "errors" || ctx["scenario_type"] != config.ScenarioErrorSpike {
+		t.Errorf("ended entry = %v", ctx)
+	}
+	if got := ctx["start"].(time.Time); !got.Equal(origin.Add(time.Minute)) {
+		t.Errorf("ground truth start = %v, want %v", got, origin.Add(time.Minute))
+	}
+	if got := ctx["end"].(time.Time); !got.Equal(origin.Add(3 * time.Minute)) {
+		t.Errorf("ground truth end = %v, want %v", got, origin.Add(3*time.Minute))
+	}
+	if ended[0].LoggerName != "scenario" {
+		t.Errorf("logger name = %q, want %q", ended[0].LoggerName, "scenario")
+	}
+}
+
+func TestEngine_StartsOnFirstUpdate(t *testing.T) {
+	e := New([]config.Scenario{
+		spec("outage", config.ScenarioSilence, 0, time.Minute, 1),
+	}, zap.NewNop())
+
+	if eff := e.Update(origin); eff.SilenceRate != 1 {
+		t.Errorf("SilenceRate = %v, want 1", eff.SilenceRate)
+	}
+	if eff := e.Update(origin.Add(time.Minute)); eff.SilenceRate != 0 {
+		t.Errorf("SilenceRate after end = %v, want 0", eff.SilenceRate)
+	}
+}
+
+func TestEngine_CombinedEffects(t *testing.T) {
+	slow := spec("slow", config.ScenarioLatencyOutlier, 0, time.Hour, 0.2)
+	slow.Multiplier = 50
+	hot := spec("hot", config.ScenarioDominantString, 0, time.Hour, 0.9)
+	hot.Value = "alpha"
+
+	e := New([]config.Scenario{
+		spec("errors-low", config.ScenarioErrorSpike, 0, time.Hour, 0.1),
+		spec("errors-high", config.ScenarioErrorSpike, 0, time.Hour, 0.4),
+		slow,
+		hot,
+	}, zap.NewNop())
+
+	eff := e.Update(origin)
+	if eff.ErrorRate != 0.4 {
+		t.Errorf("ErrorRate = %v, want the highest active rate 0.4", eff.ErrorRate)
+	}
+	if eff.OutlierRate != 0.2 || eff.OutlierMultiplier != 50 {
+		t.Errorf("outliers = %v x%d, want 0.2 x50", eff.OutlierRate, eff.OutlierMultiplier)
+	}
+	if eff.DominantRate != 0.9 || eff.DominantValue != "alpha" {
+		t.Errorf("dominant = %v %q, want 0.9 alpha", eff.DominantRate, eff.DominantValue)
+	}
+	if len(eff.Active) != 4 {
+		t.Errorf("Active = %v, want 4 scenarios", eff.Active)
+	}
+}
+
+func TestEngine_StopTruncates(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	e := New([]config.Scenario{
+		spec("outage", config.ScenarioSilence, 0, time.Hour, 1),
+		spec("later", config.ScenarioSilence, 2*time.Hour, time.Hour, 1),
+	}, zap.New(core))
+
+	e.Update(origin)
+	stop := origin.Add(10 * time.Minute)
+	e.Stop(stop)
+
+	ended := logs.FilterMessage("scenario ended").AllUntimed()
+	if len(ended) != 1 {
+		t.Fatalf("got %d ended entries, want 1 (only the active scenario)", len(ended))
+	}
+	ctx := ended[0].ContextMap()
+	if ctx["truncated"] != true {
+		t.Errorf("truncated = %v, want true", ctx["truncated"])
+	}
+	if got := ctx["end"].(time.Time); !got.Equal(stop) {
+		t.Errorf("end = %v, want %v", got, stop)
+	}
+
+	// Stopped scenarios do not come back.
+	if eff := e.Update(origin.Add(20 * time.Minute)); eff.SilenceRate != 0 {
+		t.Errorf("SilenceRate after Stop = %v, want 0", eff.SilenceRate)
+	}
+}
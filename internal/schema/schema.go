@@ -0,0 +1,310 @@
+// Package schema generates record fields from the schema declared in the
+// config file, so generated records can model real service logs instead of
+// the fixed count, random_number and random_string fields.
+package schema
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/netip"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/gen"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// valueFunc generates one value. fields holds the top-level fields
+// generated so far in the current record, for templates.
+type valueFunc func(rng *rand.Rand, now time.Time, fields []record.Field) any
+
+// Generator produces the custom fields of a record.
+type Generator struct {
+	builtin bool
+	names   []string
+	gens    []valueFunc
+}
+
+// New compiles spec into a generator. spec is expected to have been
+// validated by the config package; New still reports errors it finds.
+func New(spec *config.Schema) (*Generator, error) {
+	g := &Generator{builtin: spec.BuiltinEnabled()}
+	for _, f := range spec.Fields {
+		fn, err := compile(f)
+		if err != nil {
+			return nil, fmt.Errorf("schema: %s: %w", f.Name, err)
+		}
+		g.names = append(g.names, f.Name)
+		g.gens = append(g.gens, fn)
+	}
+	return g, nil
+}
+
+// Builtin reports whether random_number and random_string are emitted
+// alongside the custom fields.
+func (g *Generator) Builtin() bool {
+	return g.builtin
+}
+
+// Generate appends the schema fields to base, which holds the fields
+// already set on the record, and returns the extended slice. Fields are
+// generated in declaration order from rng, so a seeded run is
+// reproducible.
+func (g *Generator) Generate(rng *rand.Rand, now time.Time, base []record.Field) []record.Field {
+	out := base
+	for i, fn := range g.gens {
+		out = append(out, record.Field{Key: g.names[i], Value: fn(rng, now, out)})
+	}
+	return out
+}
+
+func compile(f config.FieldSpec) (valueFunc, error) {
+	switch f.Type {
+	case config.FieldInt:
+		lo, hi := int64(math.Ceil(f.Min)), int64(math.Floor(f.Max))
+		if hi < lo {
+			return nil, fmt.Errorf("no integer between %v and %v", f.Min, f.Max)
+		}
+		return func(rng *rand.Rand, _ time.Time, _ []record.Field) any {
+			return lo + rng.Int64N(hi-lo+1)
+		}, nil
+
+	case config.FieldFloat:
+		lo, hi, prec := f.Min, f.Max, f.Precision
+		return func(rng *rand.Rand, _ time.Time, _ []record.Field) any {
+			v := lo + rng.Float64()*(hi-lo)
+			if prec > 0 {
+				p := math.Pow(10, float64(prec))
+				v = math.Round(v*p) / p
+			}
+			return v
+		}, nil
+
+	case config.FieldBool:
+		p := 0.5
+		if f.Probability != nil {
+			p = *f.Probability
+		}
+		return func(rng *rand.Rand, _ time.Time, _ []record.Field) any {
+			return rng.Float64() < p
+		}, nil
+
+	case config.FieldEnum:
+		return compileEnum(f)
+
+	case config.FieldUUID:
+		return func(rng *rand.Rand, _ time.Time, _ []record.Field) any {
+			return gen.UUIDv4(rng)
+		}, nil
+
+	case config.FieldTimestamp:
+		return compileTimestamp(f), nil
+
+	case config.FieldFake:
+		return compileFake(f)
+
+	case config.FieldObject:
+		names := make([]string, len(f.Fields))
+		gens := make([]valueFunc, len(f.Fields))
+		for i, sub := range f.Fields {
+			fn, err := compile(sub)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", sub.Name, err)
+			}
+			names[i] = sub.Name
+			gens[i] = fn
+		}
+		return func(rng *rand.Rand, now time.Time, fields []record.Field) any {
+			obj := make([]record.Field, len(gens))
+			for i, fn := range gens {
+				obj[i] = record.Field{Key: names[i], Value: fn(rng, now, fields)}
+			}
+			return obj
+		}, nil
+
+	case config.FieldArray:
+		item, err := compile(*f.Items)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		lo, hi := f.MinItems, f.MaxItems
+		return func(rng *rand.Rand, now time.Time, fields []record.Field) any {
+			n := lo
+			if hi > lo {
+				n += rng.IntN(hi - lo + 1)
+			}
+			arr := make([]any, n)
+			for i := range arr {
+				arr[i] = item(rng, now, fields)
+			}
+			return arr
+		}, nil
+
+	case config.FieldTemplate:
+		tmpl, err := template.New(f.Name).Parse(f.Template)
+		if err != nil {
+			return nil, err
+		}
+		var keys []string
+		templateKeys(tmpl.Root, &keys)
+		return func(_ *rand.Rand, _ time.Time, fields []record.Field) any {
+			// Fields the template names but the record lacks render
+			// empty rather than as "<no value>".
+			data := make(map[string]any, len(keys)+len(fields))
+			for _, k := range keys {
+				data[k] = ""
+			}
+			for _, fld := range fields {
+				data[fld.Key] = fld.Value
+			}
+			var b strings.Builder
+			if err := tmpl.Execute(&b, data); err != nil {
+				return ""
+			}
+			return b.String()
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown type %q", f.Type)
+}
+
+// templateKeys appends the first identifier of every field reference in
+// node, such as service for {{.service}} or {{$.service.name}}.
+func templateKeys(node parse.Node, keys *[]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			templateKeys(c, keys)
+		}
+	case *parse.ActionNode:
+		templateKeys(n.Pipe, keys)
+	case *parse.IfNode:
+		templateKeys(&n.BranchNode, keys)
+	case *parse.RangeNode:
+		templateKeys(&n.BranchNode, keys)
+	case *parse.WithNode:
+		templateKeys(&n.BranchNode, keys)
+	case *parse.BranchNode:
+		templateKeys(n.Pipe, keys)
+		templateKeys(n.List, keys)
+		templateKeys(n.ElseList, keys)
+	case *parse.TemplateNode:
+		templateKeys(n.Pipe, keys)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			templateKeys(c, keys)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			templateKeys(a, keys)
+		}
+	case *parse.ChainNode:
+		templateKeys(n.Node, keys)
+	case *parse.FieldNode:
+		*keys = append(*keys, n.Ident[0])
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			*keys = append(*keys, n.Ident[1])
+		}
+	}
+}
+
+func compileEnum(f config.FieldSpec) (valueFunc, error) {
+	values := make([]any, len(f.Values))
+	for i, v := range f.Values {
+		values[i] = normalize(v)
+	}
+
+	if len(f.Weights) == 0 {
+		return func(rng *rand.Rand, _ time.Time, _ []record.Field) any {
+			return values[rng.IntN(len(values))]
+		}, nil
+	}
+
+	cumulative := make([]int, len(f.Weights))
+	total := 0
+	for i, w := range f.Weights {
+		total += w
+		cumulative[i] = total
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("weights must sum to a positive number")
+	}
+	return func(rng *rand.Rand, _ time.Time, _ []record.Field) any {
+		n := rng.IntN(total)
+		for i, c := range cumulative {
+			if n < c {
+				return values[i]
+			}
+		}
+		return values[len(values)-1]
+	}, nil
+}
+
+func compileFake(f config.FieldSpec) (valueFunc, error) {
+	if f.CIDR != "" {
+		prefix, err := netip.ParsePrefix(f.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		return func(rng *rand.Rand, _ time.Time, _ []record.Field) any {
+			return gen.IPInPrefix(rng, prefix).String()
+		}, nil
+	}
+
+	fn, ok := gen.Lookup(f.Generator)
+	if !ok {
+		return nil, fmt.Errorf("unknown generator %q", f.Generator)
+	}
+	return func(rng *rand.Rand, now time.Time, _ []record.Field) any {
+		return fn(rng, now)
+	}, nil
+}
+
+func compileTimestamp(f config.FieldSpec) valueFunc {
+	jitter := int64(f.Jitter)
+	format := f.Format
+	return func(rng *rand.Rand, now time.Time, _ []record.Field) any {
+		t := now
+		if jitter > 0 {
+			t = t.Add(time.Duration(rng.Int64N(2*jitter+1) - jitter))
+		}
+		t = t.UTC()
+		switch format {
+		case "", config.TimestampRFC3339:
+			return t.Format(time.RFC3339)
+		case config.TimestampRFC3339Nano:
+			return t.Format(time.RFC3339Nano)
+		case config.TimestampUnix:
+			return t.Unix()
+		case config.TimestampUnixMilli:
+			return t.UnixMilli()
+		default:
+			return t.Format(format)
+		}
+	}
+}
+
+// normalize turns JSON numbers that are whole into int64, so enum values
+// such as 200 or 404 are emitted as integers.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
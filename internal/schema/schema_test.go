@@ -0,0 +1,188 @@
+package schema
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+var testNow = time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)
+
+func generate(t *testing.T, fields ...config.FieldSpec) []record.Field {
+	t.Helper()
+	g, err := New(&config.Schema{Fields: fields})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return g.Generate(rand.New(rand.NewPCG(1, 2)), testNow, nil)
+}
+
+func TestGenerate_Types(t *testing.T) {
+	half := 1.0
+	uuidRe := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	tests := []struct {
+		name  string
+		spec  config.FieldSpec
+		check func(v any) bool
+	}{
+		{"int", config.FieldSpec{Type: config.FieldInt, Min: 10, Max: 20}, func(v any) bool {
+			n, ok := v.(int64)
+			return ok && n >= 10 && n <= 20
+		}},
+		{"float precision", config.FieldSpec{Type: config.FieldFloat, Min: 0, Max: 1, Precision: 2}, func(v any) bool {
+			f, ok := v.(float64)
+			return ok && f >= 0 && f <= 1 && f*100 == float64(int(f*100+0.5))
+		}},
+		{"bool always", config.FieldSpec{Type: config.FieldBool, Probability: &half}, func(v any) bool {
+			return v == true
+		}},
+		{"enum integer", config.FieldSpec{Type: config.FieldEnum, Values: []any{float64(404)}}, func(v any) bool {
+			return v == int64(404)
+		}},
+		{"enum weighted", config.FieldSpec{Type: config.FieldEnum, Values: []any{"a", "b"}, Weights: []int{0, 1}}, func(v any) bool {
+			return v == "b"
+		}},
+		{"uuid", config.FieldSpec{Type: config.FieldUUID}, func(v any) bool {
+			s, ok := v.(string)
+			return ok && uuidRe.MatchString(s)
+		}},
+		{"timestamp default", config.FieldSpec{Type: config.FieldTimestamp}, func(v any) bool {
+			return v == "2026-02-18T12:00:00Z"
+		}},
+		{"timestamp unix_ms", config.FieldSpec{Type: config.FieldTimestamp, Format: config.TimestampUnixMilli}, func(v any) bool {
+			return v == testNow.UnixMilli()
+		}},
+		{"timestamp layout", config.FieldSpec{Type: config.FieldTimestamp, Format: "2006-01-02"}, func(v any) bool {
+			return v == "2026-02-18"
+		}},
+		{"timestamp jitter", config.FieldSpec{Type: config.FieldTimestamp, Format: config.TimestampUnix, Jitter: config.Duration(5 * time.Second)}, func(v any) bool {
+			n, ok := v.(int64)
+			return ok && n >= testNow.Unix()-5 && n <= testNow.Unix()+5
+		}},
+		{"array", config.FieldSpec{Type: config.FieldArray, MinItems: 3, MaxItems: 3, Items: &config.FieldSpec{Type: config.FieldInt, Max: 1}}, func(v any) bool {
+			a, ok := v.([]any)
+			return ok && len(a) == 3
+		}},
+		{"fake", config.FieldSpec{Type: config.FieldFake, Generator: "http_status"}, func(v any) bool {
+			n, ok := v.(int64)
+			return ok && n >= 200 && n < 600
+		}},
+		{"fake cidr", config.FieldSpec{Type: config.FieldFake, Generator: "ipv4", CIDR: "10.1.2.0/24"}, func(v any) bool {
+			s, ok := v.(string)
+			return ok && strings.HasPrefix(s, "10.1.2.")
+		}},
+		{"object", config.FieldSpec{Type: config.FieldObject, Fields: []config.FieldSpec{{Name: "id", Type: config.FieldInt, Min: 7, Max: 7}}}, func(v any) bool {
+			o, ok := v.([]record.Field)
+			return ok && len(o) == 1 && o[0].Key == "id" && o[0].Value == int64(7)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.spec.Name = "f"
+			fields := generate(t, tt.spec)
+			if len(fields) != 1 || fields[0].Key != "f" {
+				t.Fatalf("Generate() = %+v, want one field f", fields)
+			}
+			if !tt.check(fields[0].Value) {
+				t.Errorf("Generate() value = %#v (%T)", fields[0].Value, fields[0].Value)
+			}
+		})
+	}
+}
+
+func TestGenerate_Template(t *testing.T) {
+	fields := generate(t,
+		config.FieldSpec{Name: "service", Type: config.FieldEnum, Values: []any{"api"}},
+		config.FieldSpec{Name: "path", Type: config.FieldTemplate, Template: "/{{.service}}/{{.missing}}x"},
+	)
+	if got := fields[1].Value; got != "/api/x" {
+		t.Errorf("template = %q, want /api/x", got)
+	}
+}
+
+func TestGenerate_TemplateMissingFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"field", "[{{.missing}}]", "[]"},
+		{"root variable", "[{{$.missing}}]", "[]"},
+		{"condition", "{{if .missing}}set{{else}}unset{{end}}", "unset"},
+		{"pipeline", `{{.missing | printf "%q"}}`, `""`},
+		{"literal text kept", "<no value>", "<no value>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := generate(t, config.FieldSpec{Name: "f", Type: config.FieldTemplate, Template: tt.template})
+			if got := fields[0].Value; got != tt.want {
+				t.Errorf("template = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate_IntExtremes(t *testing.T) {
+	const limit = 1 << 53
+	tests := []struct {
+		name     string
+		min, max float64
+	}{
+		{"full range", -limit, limit},
+		{"single value at max", limit, limit},
+		{"single value at min", -limit, -limit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(&config.Schema{Fields: []config.FieldSpec{{Name: "f", Type: config.FieldInt, Min: tt.min, Max: tt.max}}})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			rng := rand.New(rand.NewPCG(1, 2))
+			for range 100 {
+				n := g.Generate(rng, testNow, nil)[0].Value.(int64)
+				if n < int64(tt.min) || n > int64(tt.max) {
+					t.Fatalf("value %d outside [%v, %v]", n, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerate_Reproducible(t *testing.T) {
+	specs := []config.FieldSpec{
+		{Name: "id", Type: config.FieldUUID},
+		{Name: "n", Type: config.FieldFloat, Max: 100},
+	}
+	a := generate(t, specs...)
+	b := generate(t, specs...)
+	for i := range a {
+		if a[i].Value != b[i].Value {
+			t.Errorf("field %s differs with the same seed: %v != %v", a[i].Key, a[i].Value, b[i].Value)
+		}
+	}
+}
+
+func TestGenerator_Builtin(t *testing.T) {
+	off := false
+	g, err := New(&config.Schema{Builtin: &off, Fields: []config.FieldSpec{{Name: "a", Type: config.FieldBool}}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if g.Builtin() {
+		t.Error("Builtin() = true, want false")
+	}
+}
+
+func TestNew_NoIntegerInRange(t *testing.T) {
+	if _, err := New(&config.Schema{Fields: []config.FieldSpec{{Name: "a", Type: config.FieldInt, Min: 0.2, Max: 0.8}}}); err == nil {
+		t.Error("New() succeeded for an int range without integers")
+	}
+}
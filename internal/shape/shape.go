@@ -0,0 +1,120 @@
+// Package shape pads generated records to a target size distribution and
+// measures the bytes written, so pipelines can be tested with realistic
+// record sizes instead of the ~200 byte default.
+package shape
+
+import (
+	"math"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// PaddingKey is the field that carries the padding.
+const PaddingKey = "padding"
+
+// paretoAlpha shapes the long tail; 1.16 is the classic 80/20 split.
+const paretoAlpha = 1.16
+
+// words make up the padding, so it compresses like text rather than like a
+// run of one repeated byte.
+var words = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing",
+	"elit", "sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore",
+	"et", "dolore", "magna", "aliqua", "enim", "ad", "minim", "veniam",
+	"quis", "nostrud", "exercitation", "ullamco", "laboris", "nisi",
+	"aliquip", "ex", "ea", "commodo", "consequat",
+}
+
+// Shaper pads records to sizes drawn from a distribution. It measures each
+// record with the encoder of the output, so the target includes the
+// timestamp, caller and trailing newline of that format.
+type Shaper struct {
+	size config.RecordSize
+	enc  encode.Encoder
+}
+
+// New creates a Shaper for size, which must be enabled, measuring records
+// with enc.
+func New(size config.RecordSize, enc encode.Encoder) *Shaper {
+	return &Shaper{size: size, enc: enc}
+}
+
+// Target draws the next record size from the distribution.
+func (s *Shaper) Target(rng *rand.Rand) int {
+	lo, hi := s.size.Min, s.size.Max
+	switch s.size.Shape {
+	case config.SizeUniform:
+		return lo + rng.IntN(hi-lo+1)
+	case config.SizeLongTail:
+		// Inverse CDF of a Pareto distribution truncated to [lo, hi].
+		u := rng.Float64()
+		ratio := math.Pow(float64(lo)/float64(hi), paretoAlpha)
+		x := float64(lo) / math.Pow(1-u*(1-ratio), 1/paretoAlpha)
+		return min(hi, max(lo, int(x)))
+	default:
+		return lo
+	}
+}
+
+// Pad appends a padding field to rec so that it encodes to the next target
+// size. Records already at or above the target are left unchanged. Sizes
+// are exact for the JSON formats and CEF; logfmt and plain quote padding
+// that contains a space, so a padding of a single word may land two bytes
+// short.
+func (s *Shaper) Pad(rng *rand.Rand, rec *record.Record) {
+	target := s.Target(rng)
+
+	// Measure with an empty padding field so the key and separators are
+	// counted; padding words need no escaping in any format.
+	rec.Fields = append(rec.Fields, record.Field{Key: PaddingKey, Value: ""})
+	last := len(rec.Fields) - 1
+	line, err := s.enc.Encode(rec)
+	if err != nil || len(line) >= target {
+		rec.Fields = rec.Fields[:last]
+		return
+	}
+	rec.Fields[last].Value = Padding(rng, target-len(line))
+}
+
+// Padding returns n bytes of space-separated words.
+func Padding(rng *rand.Rand, n int) string {
+	var b strings.Builder
+	b.Grow(n)
+	for b.Len() < n {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(words[rng.IntN(len(words))])
+	}
+	return b.String()[:n]
+}
+
+// Meter counts the records and bytes written to one output.
+type Meter struct {
+	records *metrics.Counter
+	bytes   *metrics.Counter
+	sizes   *metrics.Histogram
+}
+
+// NewMeter registers the output metrics in reg with the given label pairs,
+// such as "sink", "stdout".
+func NewMeter(reg *metrics.Registry, labels ...string) *Meter {
+	return &Meter{
+		records: reg.Counter("loggen_output_records_total", "Records written to each output.", labels...),
+		bytes:   reg.Counter("loggen_output_bytes_total", "Bytes written to each output.", labels...),
+		sizes: reg.Histogram("loggen_record_size_bytes", "Size of written records in bytes.",
+			metrics.ExponentialBuckets(64, 2, 15), labels...),
+	}
+}
+
+// Observe counts one record of n bytes.
+func (m *Meter) Observe(n int) {
+	m.records.Inc()
+	m.bytes.Add(float64(n))
+	m.sizes.Observe(float64(n))
+}
@@ -0,0 +1,141 @@
+package shape
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+func newRecord() *record.Record {
+	return &record.Record{
+		Level:   zapcore.InfoLevel,
+		Time:    time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC),
+		Message: "hello",
+		Caller:  zapcore.NewEntryCaller(0, "loop/loop.go", 341, true),
+		Fields:  []record.Field{{Key: "count", Value: uint64(7)}},
+	}
+}
+
+func newShaper(t *testing.T, size config.RecordSize, format string) (*Shaper, encode.Encoder) {
+	t.Helper()
+	enc, err := encode.New(format, encode.WithHost("node-1"))
+	if err != nil {
+		t.Fatalf("encode.New() error = %v", err)
+	}
+	return New(size, enc), enc
+}
+
+func TestShaper_PadFixed(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, format := range []string{encode.FormatJSON, encode.FormatGELF, encode.FormatOTLP, encode.FormatCEF} {
+		for _, size := range []int{600, 4096, 1 << 20} {
+			s, enc := newShaper(t, config.RecordSize{Shape: config.SizeFixed, Min: size, Max: size}, format)
+			rec := newRecord()
+			s.Pad(rng, rec)
+			out, err := enc.Encode(rec)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if len(out) != size {
+				t.Errorf("%s fixed:%d encoded to %d bytes", format, size, len(out))
+			}
+			if format != encode.FormatCEF && !json.Valid(out) {
+				t.Errorf("%s padded record is not JSON: %s", format, out)
+			}
+			if v, _ := rec.Get("count"); v != uint64(7) {
+				t.Errorf("count = %v, want 7", v)
+			}
+		}
+	}
+}
+
+func TestShaper_PadSmallTarget(t *testing.T) {
+	s, _ := newShaper(t, config.RecordSize{Shape: config.SizeFixed, Min: 10, Max: 10}, encode.FormatJSON)
+	rec := newRecord()
+	s.Pad(rand.New(rand.NewPCG(1, 2)), rec)
+	if len(rec.Fields) != 1 {
+		t.Errorf("record above target was padded: %v", rec.Fields)
+	}
+}
+
+func TestShaper_Target(t *testing.T) {
+	tests := []struct {
+		size config.RecordSize
+		// wantBelowMin and wantBelowMax bound the share of targets below
+		// the midpoint; wantTop is the least acceptable largest target.
+		wantBelowMin, wantBelowMax float64
+		wantTop                    int
+	}{
+		{config.RecordSize{Shape: config.SizeUniform, Min: 1000, Max: 3000}, 0.45, 0.55, 2900},
+		{config.RecordSize{Shape: config.SizeLongTail, Min: 256, Max: 1 << 20}, 0.99, 1, 16 * 256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.size.String(), func(t *testing.T) {
+			s, _ := newShaper(t, tt.size, encode.FormatJSON)
+			rng := rand.New(rand.NewPCG(1, 2))
+			mid := (tt.size.Min + tt.size.Max) / 2
+			const n = 10000
+			below, top := 0, 0
+			for range n {
+				v := s.Target(rng)
+				if v < tt.size.Min || v > tt.size.Max {
+					t.Fatalf("Target() = %d, outside %d..%d", v, tt.size.Min, tt.size.Max)
+				}
+				if v < mid {
+					below++
+				}
+				top = max(top, v)
+			}
+			share := float64(below) / n
+			if share < tt.wantBelowMin || share > tt.wantBelowMax {
+				t.Errorf("%.3f of targets below %d, want %.2f..%.2f", share, mid, tt.wantBelowMin, tt.wantBelowMax)
+			}
+			if top < tt.wantTop {
+				t.Errorf("largest target %d, want at least %d", top, tt.wantTop)
+			}
+		})
+	}
+}
+
+func TestPadding(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, n := range []int{1, 5, 64, 1000} {
+		p := Padding(rng, n)
+		if len(p) != n {
+			t.Errorf("Padding(%d) has %d bytes", n, len(p))
+		}
+		if strings.ContainsAny(p, "\"\\\n") {
+			t.Errorf("Padding(%d) = %q needs escaping", n, p)
+		}
+	}
+}
+
+func TestMeter(t *testing.T) {
+	reg := metrics.NewRegistry()
+	m := NewMeter(reg)
+	for _, line := range []string{"one\n", "three\n", "seventeen\n"} {
+		m.Observe(len(line))
+	}
+
+	text := reg.Text()
+	for _, want := range []string{
+		"loggen_output_records_total 3\n",
+		"loggen_output_bytes_total 20\n",
+		"loggen_record_size_bytes_count 3\n",
+		`loggen_record_size_bytes_bucket{le="64"} 3`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics missing %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package sink
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+)
+
+// batcher collects items and hands them to flush in batches of size, or
+// once the oldest item is wait old. Flushes run under its lock, so a slow
+// destination slows down add.
+type batcher[T any] struct {
+	size  int
+	wait  time.Duration
+	flush func([]T) error
+
+	mu    sync.Mutex
+	items []T
+	timer *time.Timer
+
+	// err is the error of the last timed flush, returned by the next add
+	// or close since no caller was waiting for it.
+	err error
+
+	batches *metrics.Counter
+	sent    *metrics.Counter
+	failed  *metrics.Counter
+}
+
+// newBatcher creates a batcher whose deliveries are counted in reg under
+// the sink name.
+func newBatcher[T any](name string, size int, wait time.Duration, flush func([]T) error, reg *metrics.Registry) *batcher[T] {
+	return &batcher[T]{
+		size:    size,
+		wait:    wait,
+		flush:   flush,
+		items:   make([]T, 0, size),
+		batches: reg.Counter("loggen_sink_batches_total", "Batches sent by the sink.", "sink", name),
+		sent:    reg.Counter("loggen_sink_records_sent_total", "Records the destination accepted.", "sink", name),
+		failed:  reg.Counter("loggen_sink_records_failed_total", "Records the sink failed to deliver.", "sink", name),
+	}
+}
+
+// add queues item, flushing when the batch is full. Items are delivered
+// once the batch is full or wait after the first one arrived, so the error
+// add returns may belong to earlier items, such as a failed timed flush.
+func (b *batcher[T]) add(item T) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.err
+	b.err = nil
+	b.items = append(b.items, item)
+	if len(b.items) >= b.size {
+		return errors.Join(err, b.flushLocked())
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.wait, b.flushTimed)
+	}
+	return err
+}
+
+// close flushes the items still queued.
+func (b *batcher[T]) close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.err
+	b.err = nil
+	return errors.Join(err, b.flushLocked())
+}
+
+func (b *batcher[T]) flushTimed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timer = nil
+	if err := b.flushLocked(); err != nil {
+		b.err = err
+	}
+}
+
+func (b *batcher[T]) flushLocked() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.items) == 0 {
+		return nil
+	}
+	items := b.items
+	b.items = make([]T, 0, b.size)
+
+	b.batches.Inc()
+	err := b.flush(items)
+	failed := len(items)
+	var pe *partialError
+	switch {
+	case err == nil:
+		failed = 0
+	case errors.As(err, &pe):
+		failed = pe.failed
+	}
+	b.failed.Add(float64(failed))
+	b.sent.Add(float64(len(items) - failed))
+	return err
+}
+
+// partialError reports a batch the destination accepted only in part.
+type partialError struct {
+	failed int
+	err    error
+}
+
+func (e *partialError) Error() string { return e.err.Error() }
+
+func (e *partialError) Unwrap() error { return e.err }
@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+)
+
+func TestBatcher_TimedFlushError(t *testing.T) {
+	errDown := errors.New("receiver down")
+	var (
+		mu      sync.Mutex
+		flushed [][]int
+	)
+	flushedCh := make(chan struct{}, 1)
+	flush := func(items []int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		flushed = append(flushed, items)
+		flushedCh <- struct{}{}
+		if len(flushed) == 1 {
+			return errDown
+		}
+		return nil
+	}
+	reg := metrics.NewRegistry()
+	b := newBatcher("test", 10, 10*time.Millisecond, flush, reg)
+
+	if err := b.add(1); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	select {
+	case <-flushedCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("batch was not flushed after wait")
+	}
+
+	// The timed flush failed with nobody waiting, so the next add reports
+	// it.
+	if err := b.add(2); !errors.Is(err, errDown) {
+		t.Errorf("add() after failed flush error = %v, want %v", err, errDown)
+	}
+	if err := b.close(); err != nil {
+		t.Errorf("close() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(flushed) != 2 || len(flushed[1]) != 1 || flushed[1][0] != 2 {
+		t.Errorf("flushed = %v, want [[1] [2]]", flushed)
+	}
+	if got := sinkCounter(reg, "loggen_sink_records_failed_total", "test"); got != 1 {
+		t.Errorf("records failed metric = %v, want 1", got)
+	}
+}
@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/zstd"
+)
+
+// Suffixes of compressed rotated files.
+const (
+	gzipSuffix = ".gz"
+	zstdSuffix = ".zst"
+)
+
+// rotatedSuffixes are the suffixes a rotated file can have, so files
+// compressed before a change of codec are still found.
+var rotatedSuffixes = []string{"", gzipSuffix, zstdSuffix}
+
+// compressFile compresses path with compression, gzip or zstd, to path
+// plus the codec's suffix through a temporary file, then removes path, so
+// readers never see a partial archive.
+func compressFile(path, compression string) error {
+	suffix := gzipSuffix
+	newWriter := func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	if compression == config.CompressionZstd {
+		suffix = zstdSuffix
+		newWriter = func(w io.Writer) io.WriteCloser { return zstd.NewWriter(w) }
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + suffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	zw := newWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+suffix); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// compressed reports whether path is a compressed rotated file.
+func compressed(path string) bool {
+	return strings.HasSuffix(path, gzipSuffix) || strings.HasSuffix(path, zstdSuffix)
+}
+
+// trimCompressed returns path without a compression suffix.
+func trimCompressed(path string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(path, gzipSuffix), zstdSuffix)
+}
+
+// existsRotated reports whether path exists, plain or compressed.
+func existsRotated(path string) bool {
+	return slices.ContainsFunc(rotatedSuffixes, func(suffix string) bool {
+		return exists(path + suffix)
+	})
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
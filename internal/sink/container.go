@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// Container log tags marking partial and full CRI lines.
+const (
+	tagPartial = "P"
+	tagFull    = "F"
+)
+
+// Container writes a container log file the way a node's container runtime
+// does, in CRI format:
+//
+//	2026-02-18T12:00:00.123456789Z stdout F {"level":"info",...}
+//
+// or in Docker's json-file format:
+//
+//	{"log":"{\"level\":\"info\",...}\n","stream":"stdout","time":"2026-02-18T12:00:00.123456789Z"}
+//
+// Like a container printing to stdout, every line of a record is its own
+// entry, so multi-line stack traces arrive as several entries. Lines
+// longer than the maximum line size are split into partial entries: CRI
+// tags them P and the last chunk F; Docker leaves the newline off all but
+// the last chunk.
+//
+// Rotation follows the kubelet for CRI: once the file reaches max_size it
+// is renamed with a timestamp suffix, rotated files other than the newest
+// are gzipped, and the oldest are removed to keep max_files in total.
+// Docker files rotate to .1, .2 and so on, as json-file does with
+// max-size and max-file.
+type Container struct {
+	docker  bool
+	stream  string
+	enc     encode.Encoder
+	maxLine int
+	file    *rotatingFile
+}
+
+// NewContainer opens the container log file of spec, creating its
+// directory, and appends to it. Rotations are counted in reg.
+func NewContainer(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*Container, error) {
+	c := &Container{
+		docker:  spec.Type == config.SinkDocker,
+		stream:  spec.Stream,
+		enc:     enc,
+		maxLine: int(spec.MaxLineSize),
+	}
+	if c.stream == "" {
+		c.stream = config.StreamStdout
+	}
+	if c.maxLine <= 0 {
+		c.maxLine = config.DefaultMaxLineSize
+	}
+	if spec.MaxSize <= 0 {
+		spec.MaxSize = config.DefaultMaxSize
+	}
+
+	file, err := openRotating(spec, reg)
+	if err != nil {
+		return nil, err
+	}
+	c.file = file
+	return c, nil
+}
+
+// Write implements Sink.
+func (c *Container) Write(rec *record.Record) error {
+	line, err := Line(c.enc, rec)
+	if err != nil {
+		return err
+	}
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	var buf []byte
+	for _, l := range bytes.Split(line, []byte("\n")) {
+		for len(l) > c.maxLine {
+			buf = c.appendEntry(buf, ts, l[:c.maxLine], false)
+			l = l[c.maxLine:]
+		}
+		buf = c.appendEntry(buf, ts, l, true)
+	}
+	return c.file.write(buf, ts)
+}
+
+// appendEntry appends one log entry holding chunk. full marks the last
+// chunk of a line.
+func (c *Container) appendEntry(buf []byte, ts time.Time, chunk []byte, full bool) []byte {
+	ts = ts.UTC()
+	if c.docker {
+		log := string(chunk)
+		if full {
+			log += "\n"
+		}
+		// encoding/json escapes HTML characters and replaces invalid
+		// UTF-8, as Docker's json-file driver does.
+		entry, _ := json.Marshal(struct {
+			Log    string `json:"log"`
+			Stream string `json:"stream"`
+			Time   string `json:"time"`
+		}{log, c.stream, ts.Format(time.RFC3339Nano)})
+		buf = append(buf, entry...)
+		return append(buf, '\n')
+	}
+
+	tag := tagPartial
+	if full {
+		tag = tagFull
+	}
+	buf = ts.AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, ' ')
+	buf = append(buf, c.stream...)
+	buf = append(buf, ' ')
+	buf = append(buf, tag...)
+	buf = append(buf, ' ')
+	buf = append(buf, chunk...)
+	return append(buf, '\n')
+}
+
+// Close implements Sink.
+func (c *Container) Close() error {
+	return c.file.close()
+}
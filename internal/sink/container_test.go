@@ -0,0 +1,227 @@
+package sink
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+func newContainer(t *testing.T, spec config.Sink) *Container {
+	t.Helper()
+	if spec.Format == "" {
+		spec.Format = encode.FormatJSON
+	}
+	c, err := NewContainer(spec, mustEncoder(t, spec.Format), nil)
+	if err != nil {
+		t.Fatalf("NewContainer() error = %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestContainer_CRI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo_loggen_1234", "loggen", "0.log")
+	c := newContainer(t, config.Sink{Type: config.SinkCRI, Path: path, Format: encode.FormatPlain, MaxLineSize: 10})
+
+	recs := []*record.Record{
+		{Time: t0, Raw: "short"},
+		{Time: t0, Raw: "exactly10!"},
+		{Time: t0, Raw: "0123456789abcdefghijXYZ"},
+		{Time: t0, Raw: "Traceback:\n  boom"},
+	}
+	for _, rec := range recs {
+		if err := c.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	ts := "2026-02-18T12:00:00.123456789Z"
+	want := []string{
+		ts + " stdout F short",
+		ts + " stdout F exactly10!",
+		ts + " stdout P 0123456789",
+		ts + " stdout P abcdefghij",
+		ts + " stdout F XYZ",
+		ts + " stdout F Traceback:",
+		ts + " stdout F   boom",
+	}
+	if got := readLines(t, path); !slices.Equal(got, want) {
+		t.Errorf("CRI lines =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestContainer_Docker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "abc", "abc-json.log")
+	c := newContainer(t, config.Sink{Type: config.SinkDocker, Path: path, Stream: config.StreamStderr, MaxLineSize: 8})
+
+	if err := c.Write(&record.Record{Time: t0, Raw: "<a&b> and more"}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := c.Write(newRecord(3)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	lines := readLines(t, path)
+	if lines[0] != `{"log":"\u003ca\u0026b\u003e an","stream":"stderr","time":"2026-02-18T12:00:00.123456789Z"}` {
+		t.Errorf("first chunk = %s", lines[0])
+	}
+
+	// Reassembling the chunks like a log collector gives back the lines.
+	var got []string
+	var partial strings.Builder
+	for _, l := range lines {
+		var entry struct{ Log, Stream, Time string }
+		if err := json.Unmarshal([]byte(l), &entry); err != nil {
+			t.Fatalf("invalid json-file entry %q: %v", l, err)
+		}
+		if entry.Stream != "stderr" {
+			t.Errorf("stream = %q", entry.Stream)
+		}
+		partial.WriteString(entry.Log)
+		if strings.HasSuffix(entry.Log, "\n") {
+			got = append(got, strings.TrimSuffix(partial.String(), "\n"))
+			partial.Reset()
+		}
+	}
+	if len(got) != 2 || got[0] != "<a&b> and more" || !strings.Contains(got[1], `"msg":"tick","count":3}`) {
+		t.Errorf("reassembled = %q", got)
+	}
+}
+
+func TestContainer_RotateCRI(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "0.log")
+	c := newContainer(t, config.Sink{Type: config.SinkCRI, Path: path, MaxSize: 100, MaxFiles: 3, MaxLineSize: 1024, Compression: config.CompressionGzip})
+
+	// Each record is about 100 bytes, so every write rotates.
+	for i := range 5 {
+		rec := newRecord(uint64(i))
+		rec.Time = t0.Add(time.Duration(i) * 10 * time.Second)
+		if err := c.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	names := dirNames(t, dir)
+	want := []string{"0.log", "0.log.20260218-120030.gz", "0.log.20260218-120040"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	if info, _ := os.Stat(path); info.Size() != 0 {
+		t.Errorf("live file has %d bytes after rotation, want 0", info.Size())
+	}
+
+	// The newest rotated file stays plain; older ones are gzipped.
+	if !strings.Contains(readLines(t, filepath.Join(dir, want[2]))[0], `"count":4`) {
+		t.Error("newest rotated file does not hold the last record")
+	}
+	f, err := os.Open(filepath.Join(dir, want[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	data, _ := io.ReadAll(zr)
+	if !strings.Contains(string(data), `"count":3`) {
+		t.Errorf("compressed file = %q, want record 3", data)
+	}
+}
+
+func TestContainer_RotateCRISameSecond(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "0.log")
+	c := newContainer(t, config.Sink{Type: config.SinkCRI, Path: path, MaxSize: 10, MaxFiles: 4, Compression: config.CompressionGzip})
+	for i := range 3 {
+		if err := c.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	want := []string{"0.log", "0.log.20260218-120000.gz", "0.log.20260218-120001.gz", "0.log.20260218-120002"}
+	if names := dirNames(t, dir); !slices.Equal(names, want) {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+}
+
+func TestContainer_RotateDocker(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "abc-json.log")
+		spec := config.Sink{Type: config.SinkDocker, Path: path, MaxSize: 10, MaxFiles: 3}
+		if compress {
+			spec.Compression = config.CompressionGzip
+		}
+		c := newContainer(t, spec)
+		for i := range 4 {
+			if err := c.Write(newRecord(uint64(i))); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+		}
+
+		want := []string{"abc-json.log", "abc-json.log.1", "abc-json.log.2"}
+		if compress {
+			want[2] += ".gz"
+		}
+		if names := dirNames(t, dir); !slices.Equal(names, want) {
+			t.Errorf("compress=%v: files = %v, want %v", compress, names, want)
+		}
+		if !strings.Contains(readLines(t, path+".1")[0], `count\":3`) {
+			t.Errorf("compress=%v: .1 does not hold the newest record", compress)
+		}
+	}
+}
+
+func TestContainer_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "0.log")
+	for range 2 {
+		c := newContainer(t, config.Sink{Type: config.SinkCRI, Path: path})
+		if err := c.Write(newRecord(1)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+	if lines := readLines(t, path); len(lines) != 2 {
+		t.Errorf("got %d lines after reopening, want 2", len(lines))
+	}
+	c := newContainer(t, config.Sink{Type: config.SinkCRI, Path: path})
+	_ = c.Close()
+	if err := c.Write(newRecord(1)); err == nil {
+		t.Error("Write() after Close() succeeded, want error")
+	}
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
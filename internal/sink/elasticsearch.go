@@ -0,0 +1,200 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// Elasticsearch indexes batches of records through the _bulk API of
+// Elasticsearch or OpenSearch. Each record is created in the index named
+// by the index pattern for its time, so date-based indices and data
+// streams both work. Documents rejected with 429 are sent once more; other
+// rejected documents are counted as failed and reported.
+type Elasticsearch struct {
+	spec   config.Sink
+	enc    encode.Encoder
+	client *http.Client
+	batch  *batcher[bulkDoc]
+}
+
+// bulkDoc is a document waiting to be indexed.
+type bulkDoc struct {
+	index  string
+	source []byte
+}
+
+// bulkResponse is the part of a _bulk response the sink reads.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// NewElasticsearch creates an Elasticsearch sink for spec.
+func NewElasticsearch(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*Elasticsearch, error) {
+	client, err := newHTTPClient(spec)
+	if err != nil {
+		return nil, err
+	}
+	s := &Elasticsearch{spec: spec, enc: enc, client: client}
+	s.batch = newBatcher(spec.Name, spec.BatchSize, time.Duration(spec.BatchWait), s.bulk, reg)
+	return s, nil
+}
+
+// Write implements Sink. It batches rec as a document of the _bulk
+// request, in the index for its time.
+func (s *Elasticsearch) Write(rec *record.Record) error {
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return err
+	}
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return s.batch.add(bulkDoc{index: indexName(s.spec.Index, ts), source: document(line, rec.Raw != "", ts)})
+}
+
+// Close implements Sink. It sends the records still batched.
+func (s *Elasticsearch) Close() error {
+	return s.batch.close()
+}
+
+// indexName expands the %Y, %m, %d and %H directives of pattern with the
+// UTC date and hour of t.
+func indexName(pattern string, t time.Time) string {
+	if !strings.Contains(pattern, "%") {
+		return pattern
+	}
+	t = t.UTC()
+	return strings.NewReplacer(
+		"%Y", t.Format("2006"),
+		"%m", t.Format("01"),
+		"%d", t.Format("02"),
+		"%H", t.Format("15"),
+	).Replace(pattern)
+}
+
+// document returns the source of a record: a JSON object line with an
+// @timestamp key added in front, or any other line in a message key.
+func document(line []byte, raw bool, ts time.Time) []byte {
+	doc := make([]byte, 0, len(line)+64)
+	doc = append(doc, `{"@timestamp":"`...)
+	doc = ts.UTC().AppendFormat(doc, time.RFC3339Nano)
+	doc = append(doc, '"')
+
+	if !raw && len(line) >= 2 && line[0] == '{' && json.Valid(line) {
+		if line[1] != '}' {
+			doc = append(doc, ',')
+		}
+		return append(doc, line[1:]...)
+	}
+	msg, _ := json.Marshal(string(line))
+	doc = append(doc, `,"message":`...)
+	doc = append(doc, msg...)
+	return append(doc, '}')
+}
+
+// bulk indexes docs, sending the ones rejected with 429 once more.
+func (s *Elasticsearch) bulk(docs []bulkDoc) error {
+	failed, retry, firstErr, err := s.send(docs)
+	if err != nil {
+		return err
+	}
+	if len(retry) > 0 {
+		retryFailed, again, retryErr, err := s.send(retry)
+		switch {
+		case err != nil:
+			failed += len(retry)
+			retryErr = err.Error()
+		case len(again) > 0:
+			failed += retryFailed + len(again)
+			if retryErr == "" {
+				retryErr = "429 Too Many Requests"
+			}
+		default:
+			failed += retryFailed
+		}
+		if firstErr == "" {
+			firstErr = retryErr
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return &partialError{
+		failed: failed,
+		err:    fmt.Errorf("%d of %d documents failed, first: %s", failed, len(docs), firstErr),
+	}
+}
+
+// send makes one _bulk request. It returns the number of documents that
+// failed for good, the ones rejected with 429, and the first error
+// description.
+func (s *Elasticsearch) send(docs []bulkDoc) (failed int, retry []bulkDoc, firstErr string, err error) {
+	var body []byte
+	for _, d := range docs {
+		body = append(body, `{"create":{"_index":`...)
+		body = strconv.AppendQuote(body, d.index)
+		body = append(body, "}}\n"...)
+		body = append(body, d.source...)
+		body = append(body, '\n')
+	}
+
+	post := newPost(s.spec, s.spec.URL, "application/x-ndjson", body)
+	data, err := do(s.client, func() (*http.Request, error) {
+		req, err := post()
+		if err == nil && s.spec.Token != "" {
+			req.Header.Set("Authorization", "ApiKey "+s.spec.Token)
+		}
+		return req, err
+	})
+	if err != nil {
+		return 0, nil, "", err
+	}
+
+	var resp bulkResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return 0, nil, "", fmt.Errorf("decoding bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return 0, nil, "", nil
+	}
+	if len(resp.Items) != len(docs) {
+		return 0, nil, "", fmt.Errorf("bulk response has %d items for %d documents", len(resp.Items), len(docs))
+	}
+
+	for i, item := range resp.Items {
+		for _, result := range item {
+			if result.Status < 300 {
+				continue
+			}
+			if result.Status == http.StatusTooManyRequests {
+				retry = append(retry, docs[i])
+				continue
+			}
+			failed++
+			if firstErr == "" {
+				firstErr = strconv.Itoa(result.Status)
+				if result.Error != nil {
+					firstErr = result.Error.Type + ": " + result.Error.Reason
+				}
+			}
+		}
+	}
+	return failed, retry, firstErr, nil
+}
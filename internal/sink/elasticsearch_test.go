@@ -0,0 +1,277 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// fakeBulk is a _bulk endpoint. status decides the result of each
+// document from its count field and how often it was sent before.
+type fakeBulk struct {
+	fakeServer
+	status func(count float64, attempt int) int
+
+	requests int
+	auth     string
+	attempts map[float64]int
+	indexed  map[string][]map[string]any
+}
+
+func newFakeBulk(t *testing.T, status func(count float64, attempt int) int) *fakeBulk {
+	return &fakeBulk{fakeServer: fakeServer{t: t}, status: status, attempts: map[float64]int{}, indexed: map[string][]map[string]any{}}
+}
+
+func (f *fakeBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	f.auth = r.Header.Get("Authorization")
+	if r.URL.Path != config.DefaultBulkPath || r.Header.Get("Content-Type") != "application/x-ndjson" {
+		f.t.Errorf("request = %s %s, want %s with x-ndjson", r.Method, r.URL.Path, config.DefaultBulkPath)
+	}
+
+	type result struct {
+		Status int               `json:"status"`
+		Error  map[string]string `json:"error,omitempty"`
+	}
+	var items []map[string]result
+	hasErrors := false
+	sc := bufio.NewScanner(r.Body)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var action map[string]map[string]string
+		if err := json.Unmarshal(sc.Bytes(), &action); err != nil {
+			f.t.Errorf("action line %q: %v", sc.Text(), err)
+			return
+		}
+		if !sc.Scan() {
+			f.t.Error("action without document")
+			return
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &doc); err != nil {
+			f.t.Errorf("document %q: %v", sc.Text(), err)
+			return
+		}
+
+		count, _ := doc["count"].(float64)
+		status := f.status(count, f.attempts[count])
+		f.attempts[count]++
+		res := result{Status: status}
+		switch status {
+		case http.StatusCreated:
+			index := action["create"]["_index"]
+			f.indexed[index] = append(f.indexed[index], doc)
+		case http.StatusTooManyRequests:
+			res.Error = map[string]string{"type": "es_rejected_execution_exception", "reason": "queue full"}
+			hasErrors = true
+		default:
+			res.Error = map[string]string{"type": "mapper_parsing_exception", "reason": fmt.Sprintf("failed to parse count %v", count)}
+			hasErrors = true
+		}
+		items = append(items, map[string]result{"create": res})
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"took": 1, "errors": hasErrors, "items": items})
+}
+
+func created(float64, int) int { return http.StatusCreated }
+
+func newElasticsearch(t *testing.T, f http.Handler, configure func(*config.Sink)) (*Elasticsearch, *metrics.Registry) {
+	t.Helper()
+	spec := testSpec("es", config.SinkElasticsearch, encode.FormatJSON)
+	spec.Index = config.DefaultIndex
+	return newHTTPSink(t, f, config.DefaultBulkPath, spec, NewElasticsearch, configure)
+}
+
+func TestIndexName(t *testing.T) {
+	ts := time.Date(2026, 2, 18, 23, 59, 59, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"loggen-%Y.%m.%d", "loggen-2026.02.18"},
+		{"loggen-%Y-%m-%d-%H", "loggen-2026-02-18-22"},
+		{"loggen", "loggen"},
+	}
+
+	for _, tt := range tests {
+		if got := indexName(tt.pattern, ts); got != tt.want {
+			t.Errorf("indexName(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		raw  bool
+		want string
+	}{
+		{"object", `{"level":"info","msg":"tick"}`, false, `{"@timestamp":"2026-02-18T12:00:00.123456789Z","level":"info","msg":"tick"}`},
+		{"empty object", `{}`, false, `{"@timestamp":"2026-02-18T12:00:00.123456789Z"}`},
+		{"logfmt", `level=info msg=tick`, false, `{"@timestamp":"2026-02-18T12:00:00.123456789Z","message":"level=info msg=tick"}`},
+		{"raw json-like", `{"not": parsed}`, true, `{"@timestamp":"2026-02-18T12:00:00.123456789Z","message":"{\"not\": parsed}"}`},
+		{"raw object", `{"a":1}`, true, `{"@timestamp":"2026-02-18T12:00:00.123456789Z","message":"{\"a\":1}"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(document([]byte(tt.line), tt.raw, t0))
+			if got != tt.want {
+				t.Errorf("document() = %s, want %s", got, tt.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("document() = %s is not valid JSON", got)
+			}
+		})
+	}
+}
+
+func TestElasticsearch_Bulk(t *testing.T) {
+	f := newFakeBulk(t, created)
+	s, reg := newElasticsearch(t, f, func(spec *config.Sink) { spec.Token = "c2VjcmV0" })
+
+	late := newRecord(1)
+	late.Time = time.Date(2026, 2, 18, 23, 59, 59, 0, time.UTC)
+	early := newRecord(2)
+	early.Time = late.Time.Add(2 * time.Second)
+	raw := newRecord(3)
+	raw.Raw = `10.0.0.1 - - "GET / HTTP/1.1" 200`
+	for _, rec := range []*record.Record{late, early, raw} {
+		if err := s.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if f.requests != 1 || f.auth != "ApiKey c2VjcmV0" {
+		t.Errorf("requests = %d with Authorization %q, want 1 with the API key", f.requests, f.auth)
+	}
+	day1, day2 := f.indexed["loggen-2026.02.18"], f.indexed["loggen-2026.02.19"]
+	if len(day1) != 2 || len(day2) != 1 {
+		t.Fatalf("indexed = %v, want two records on the 18th and one on the 19th", f.indexed)
+	}
+	if day1[0]["@timestamp"] != "2026-02-18T23:59:59Z" || day1[0]["msg"] != "tick" || day1[0]["count"] != float64(1) {
+		t.Errorf("document = %v", day1[0])
+	}
+	if day1[1]["message"] != raw.Raw || day1[1]["@timestamp"] != "2026-02-18T12:00:00.123456789Z" {
+		t.Errorf("raw document = %v, want the line in message", day1[1])
+	}
+	if day2[0]["count"] != float64(2) {
+		t.Errorf("document after midnight = %v, want count 2", day2[0])
+	}
+	if got := sinkCounter(reg, "loggen_sink_records_sent_total", "es"); got != 3 {
+		t.Errorf("records sent metric = %v, want 3", got)
+	}
+}
+
+func TestElasticsearch_PartialFailure(t *testing.T) {
+	// count 2 is rejected by the mapping, count 3 hits a full queue once
+	// and count 4 every time.
+	f := newFakeBulk(t, func(count float64, attempt int) int {
+		switch {
+		case count == 2:
+			return http.StatusBadRequest
+		case count == 3 && attempt == 0, count == 4:
+			return http.StatusTooManyRequests
+		default:
+			return http.StatusCreated
+		}
+	})
+	s, reg := newElasticsearch(t, f, nil)
+
+	for i := range 6 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	err := s.Close()
+	if err == nil || !strings.Contains(err.Error(), "2 of 6 documents failed, first: mapper_parsing_exception: failed to parse count 2") {
+		t.Errorf("Close() error = %v, want a partial failure", err)
+	}
+
+	if f.requests != 2 {
+		t.Errorf("requests = %d, want the batch and one retry of the 429s", f.requests)
+	}
+	if f.attempts[3] != 2 || f.attempts[4] != 2 || f.attempts[2] != 1 {
+		t.Errorf("attempts = %v, want 429s sent twice and the mapping error once", f.attempts)
+	}
+	if got := len(f.indexed["loggen-2026.02.18"]); got != 4 {
+		t.Errorf("indexed %d documents, want 4", got)
+	}
+	sent := sinkCounter(reg, "loggen_sink_records_sent_total", "es")
+	failed := sinkCounter(reg, "loggen_sink_records_failed_total", "es")
+	if sent != 4 || failed != 2 {
+		t.Errorf("sent, failed metrics = %v, %v, want 4, 2", sent, failed)
+	}
+}
+
+func TestElasticsearch_RequestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr string
+	}{
+		{"unauthorized", func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, `{"error":"security_exception"}`, http.StatusUnauthorized)
+		}, "401 Unauthorized"},
+		{"not json", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("<html>"))
+		}, "decoding bulk response"},
+		{"item count", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"errors":true,"items":[]}`))
+		}, "0 items for 1 documents"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, reg := newElasticsearch(t, tt.handler, nil)
+			if err := s.Write(newRecord(1)); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			err := s.Close()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Close() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if got := sinkCounter(reg, "loggen_sink_records_failed_total", "es"); got != 1 {
+				t.Errorf("records failed metric = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestElasticsearch_RetriesServerError(t *testing.T) {
+	var calls int
+	bulk := newFakeBulk(t, created)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		bulk.ServeHTTP(w, r)
+	})
+	s, _ := newElasticsearch(t, handler, nil)
+	if err := s.Write(newRecord(1)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if calls != 2 || len(bulk.indexed["loggen-2026.02.18"]) != 1 {
+		t.Errorf("calls = %d, indexed = %v, want the batch indexed on the second call", calls, bulk.indexed)
+	}
+}
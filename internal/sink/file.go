@@ -0,0 +1,49 @@
+package sink
+
+import (
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// File writes one record per line to a rotating file. It rotates when the
+// file reaches max_size, when the record time enters a new rotate_every
+// interval, or both. In rename mode the live file is renamed and a new one
+// created, so a collector must follow the rename; in copytruncate mode it
+// is copied aside and truncated in place, so a collector keeps its file
+// but may miss lines written between its last read and the truncation.
+type File struct {
+	enc  encode.Encoder
+	file *rotatingFile
+}
+
+// NewFile opens the file of spec, creating its directory, and appends to
+// it. Rotations are counted in reg.
+func NewFile(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*File, error) {
+	file, err := openRotating(spec, reg)
+	if err != nil {
+		return nil, err
+	}
+	return &File{enc: enc, file: file}, nil
+}
+
+// Write implements Sink.
+func (s *File) Write(rec *record.Record) error {
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return err
+	}
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return s.file.write(append(line, '\n'), ts)
+}
+
+// Close implements Sink.
+func (s *File) Close() error {
+	return s.file.close()
+}
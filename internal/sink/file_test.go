@@ -0,0 +1,173 @@
+package sink
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+)
+
+func newFile(t *testing.T, spec config.Sink, reg *metrics.Registry) *File {
+	t.Helper()
+	spec.Name = "test"
+	f, err := NewFile(spec, mustEncoder(t, encode.FormatPlain), reg)
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+// writeAt writes record count at t0 plus offset.
+func writeAt(t *testing.T, s Sink, count uint64, offset time.Duration) {
+	t.Helper()
+	rec := newRecord(count)
+	rec.Time = t0.Add(offset)
+	if err := s.Write(rec); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+}
+
+func TestFile_SizeRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "loggen.log")
+	// An unrelated file sharing the prefix must survive retention.
+	if err := os.WriteFile(path+".conf", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reg := metrics.NewRegistry()
+	s := newFile(t, config.Sink{Path: path, MaxSize: 80, MaxFiles: 3, Compression: config.CompressionGzip}, reg)
+
+	// Each line is 43 bytes, so every second write rotates.
+	for i := range 8 {
+		writeAt(t, s, uint64(i), time.Duration(i)*time.Second)
+	}
+
+	want := []string{"loggen.log", "loggen.log.20260218-120005.gz", "loggen.log.20260218-120007", "loggen.log.conf"}
+	if names := dirNames(t, dir); !slices.Equal(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	if lines := readLines(t, path+".20260218-120007"); len(lines) != 2 || !strings.HasSuffix(lines[1], "count=7") {
+		t.Errorf("newest rotated file = %q", lines)
+	}
+
+	text := reg.Text()
+	for _, m := range []string{
+		`loggen_sink_rotations_total{sink="test",reason="size"} 4`,
+		`loggen_sink_rotations_total{sink="test",reason="time"} 0`,
+		`loggen_sink_files_removed_total{sink="test"} 2`,
+		`loggen_sink_files_compressed_total{sink="test"} 3`,
+		`loggen_sink_file_bytes{sink="test"} 0`,
+	} {
+		if !strings.Contains(text, m) {
+			t.Errorf("metrics missing %q:\n%s", m, text)
+		}
+	}
+}
+
+func TestFile_ZstdRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "loggen.log")
+	s := newFile(t, config.Sink{Path: path, MaxSize: 80, MaxFiles: 3, Compression: config.CompressionZstd}, nil)
+
+	for i := range 8 {
+		writeAt(t, s, uint64(i), time.Duration(i)*time.Second)
+	}
+
+	want := []string{"loggen.log", "loggen.log.20260218-120005.zst", "loggen.log.20260218-120007"}
+	if names := dirNames(t, dir); !slices.Equal(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, want[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		t.Errorf("compressed file starts with % x, want the zstd magic", data[:min(len(data), 4)])
+	}
+}
+
+func TestFile_TimeRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "loggen.log")
+	reg := metrics.NewRegistry()
+	s := newFile(t, config.Sink{Path: path, RotateEvery: config.Duration(time.Minute), MaxFiles: 5}, reg)
+
+	writeAt(t, s, 1, 10*time.Second)
+	writeAt(t, s, 2, 50*time.Second)
+	writeAt(t, s, 3, 70*time.Second)  // 12:01, rotates
+	writeAt(t, s, 4, 200*time.Second) // 12:03, rotates
+
+	want := []string{"loggen.log", "loggen.log.20260218-120110", "loggen.log.20260218-120320"}
+	if names := dirNames(t, dir); !slices.Equal(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	if lines := readLines(t, filepath.Join(dir, want[1])); len(lines) != 2 {
+		t.Errorf("first minute holds %d lines, want 2", len(lines))
+	}
+	if lines := readLines(t, path); len(lines) != 1 || !strings.HasSuffix(lines[0], "count=4") {
+		t.Errorf("live file = %q", lines)
+	}
+	if !strings.Contains(reg.Text(), `loggen_sink_rotations_total{sink="test",reason="time"} 2`) {
+		t.Errorf("time rotations not counted:\n%s", reg.Text())
+	}
+}
+
+func TestFile_CopyTruncate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "loggen.log")
+	s := newFile(t, config.Sink{Path: path, MaxSize: 80, RotateMode: config.RotateCopyTruncate}, nil)
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		writeAt(t, s, uint64(i), 0)
+	}
+
+	// The live file keeps its inode and restarts from the beginning.
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("copytruncate replaced the live file")
+	}
+	if lines := readLines(t, path); len(lines) != 1 || !strings.HasSuffix(lines[0], "count=2") {
+		t.Errorf("live file = %q, want only the record after truncation", lines)
+	}
+	if lines := readLines(t, path+".20260218-120000"); len(lines) != 2 {
+		t.Errorf("copy holds %d lines, want 2", len(lines))
+	}
+}
+
+func TestFile_RenameReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "loggen.log")
+	s := newFile(t, config.Sink{Path: path, MaxSize: 40}, nil)
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeAt(t, s, 1, 0)
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(before, after) {
+		t.Error("rename rotation kept the live file")
+	}
+	rotated, err := os.Stat(path + ".20260218-120000")
+	if err != nil || !os.SameFile(before, rotated) {
+		t.Errorf("rotated file is not the original: %v", err)
+	}
+}
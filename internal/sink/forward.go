@@ -0,0 +1,412 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/msgpack"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// eventTimeExt is the MessagePack extension type of Fluent EventTime.
+const eventTimeExt = 0
+
+// Forward sends records to a Fluent Bit or Fluentd forward input in
+// batches, one PackedForward message per batch, or CompressedPackedForward
+// with gzip compression. With the json format each record is a map of the
+// keys zap writes (level, ts, caller, msg and the fields), which
+// transform.lua reads directly; other formats and raw records are sent in a
+// log key, as the tail input would.
+//
+// The connection is opened on the first batch and reopened after an error,
+// when the batch is sent once more. Without require_ack a batch written to
+// a connection the receiver has already dropped can be lost; with it, each
+// batch carries a chunk ID and is resent unless the receiver acknowledges
+// it within the timeout. Delivery is then at least once: a receiver that
+// wrote the batch but whose ack timed out gets it twice.
+type Forward struct {
+	spec     config.Sink
+	enc      encode.Encoder
+	hostname string
+	timeout  time.Duration
+	batch    *batcher[[]byte]
+
+	mu   sync.Mutex
+	conn net.Conn
+	dec  *msgpack.Decoder
+	buf  []byte
+
+	connects *metrics.Counter
+	retries  *metrics.Counter
+	acks     *metrics.Counter
+}
+
+// NewForward creates a forward sink for spec. It does not connect until the
+// first batch is sent, so loggen can start before its receiver.
+func NewForward(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*Forward, error) {
+	connects, retries := connMetrics(reg, spec.Name)
+	s := &Forward{
+		spec:     spec,
+		enc:      enc,
+		hostname: hostname(),
+		timeout:  time.Duration(spec.Timeout),
+		connects: connects,
+		retries:  retries,
+		acks:     reg.Counter("loggen_sink_forward_acks_total", "Forward messages acknowledged by the receiver.", "sink", spec.Name),
+	}
+	s.batch = newBatcher(spec.Name, spec.BatchSize, time.Duration(spec.BatchWait), s.flush, reg)
+	return s, nil
+}
+
+// Write implements Sink. It batches rec as a forward entry of the
+// PackedForward message.
+func (s *Forward) Write(rec *record.Record) error {
+	entry, err := s.entry(rec)
+	if err != nil {
+		return err
+	}
+	return s.batch.add(entry)
+}
+
+// Close implements Sink. It sends the records still batched and closes the
+// connection.
+func (s *Forward) Close() error {
+	err := s.batch.close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return err
+	}
+	err = errors.Join(err, s.conn.Close())
+	s.conn = nil
+	return err
+}
+
+// flush sends entries as one message, once more on a new connection if
+// the first attempt fails.
+func (s *Forward) flush(entries [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var chunk string
+	if s.spec.RequireAck {
+		chunk = randomID(16, base64.StdEncoding.EncodeToString)
+	}
+	msg, err := s.message(s.buf[:0], entries, chunk)
+	if err != nil {
+		return err
+	}
+	s.buf = msg
+
+	if err := s.send(msg, chunk); err == nil {
+		return nil
+	}
+	s.disconnect()
+	s.retries.Inc()
+	if err := s.send(msg, chunk); err != nil {
+		s.disconnect()
+		return err
+	}
+	return nil
+}
+
+// send writes msg, connecting first if needed, and waits for the ack of
+// chunk when it is set.
+func (s *Forward) send(msg []byte, chunk string) error {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	if err := s.conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+	if _, err := s.conn.Write(msg); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+
+	v, err := s.dec.Decode()
+	if err != nil {
+		return fmt.Errorf("waiting for ack: %w", err)
+	}
+	resp, _ := v.(map[string]any)
+	if ack := text(resp["ack"]); ack != chunk {
+		return fmt.Errorf("ack %q does not match chunk %q", ack, chunk)
+	}
+	s.acks.Inc()
+	return nil
+}
+
+func (s *Forward) connect() error {
+	conn, err := net.DialTimeout("tcp", s.spec.Address, s.timeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.dec = msgpack.NewDecoder(conn)
+	if s.spec.SharedKey != "" {
+		if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+			s.disconnect()
+			return err
+		}
+		if err := s.handshake(); err != nil {
+			s.disconnect()
+			return fmt.Errorf("forward handshake: %w", err)
+		}
+	}
+	s.connects.Inc()
+	return nil
+}
+
+func (s *Forward) disconnect() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// handshake answers the receiver's HELO with a PING proving the shared
+// key, and checks that the PONG proves the receiver knows it too.
+func (s *Forward) handshake() error {
+	v, err := s.dec.Decode()
+	if err != nil {
+		return fmt.Errorf("reading HELO: %w", err)
+	}
+	helo, _ := v.([]any)
+	if len(helo) < 2 || text(helo[0]) != "HELO" {
+		return errors.New("receiver did not send HELO")
+	}
+	opts, _ := helo[1].(map[string]any)
+	nonce := text(opts["nonce"])
+	auth := text(opts["auth"])
+
+	salt := randomID(16, hex.EncodeToString)
+	var password string
+	if auth != "" {
+		password = digest(auth, s.spec.Username, s.spec.Password)
+	}
+	b := msgpack.AppendArrayHeader(nil, 6)
+	b = msgpack.AppendString(b, "PING")
+	b = msgpack.AppendString(b, s.hostname)
+	b = msgpack.AppendString(b, salt)
+	b = msgpack.AppendString(b, digest(salt, s.hostname, nonce, s.spec.SharedKey))
+	b = msgpack.AppendString(b, s.spec.Username)
+	b = msgpack.AppendString(b, password)
+	if _, err := s.conn.Write(b); err != nil {
+		return err
+	}
+
+	v, err = s.dec.Decode()
+	if err != nil {
+		return fmt.Errorf("reading PONG: %w", err)
+	}
+	pong, _ := v.([]any)
+	if len(pong) < 5 || text(pong[0]) != "PONG" {
+		return errors.New("receiver did not send PONG")
+	}
+	if ok, _ := pong[1].(bool); !ok {
+		return fmt.Errorf("rejected: %s", text(pong[2]))
+	}
+	if text(pong[4]) != digest(salt, text(pong[3]), nonce, s.spec.SharedKey) {
+		return errors.New("receiver does not know the shared key")
+	}
+	return nil
+}
+
+// message appends the PackedForward message [tag, entries, option] of
+// entries to b. The entries are a MessagePack stream in a bin, gzipped for
+// CompressedPackedForward.
+func (s *Forward) message(b []byte, entries [][]byte, chunk string) ([]byte, error) {
+	var stream bytes.Buffer
+	if s.spec.Compression == config.CompressionGzip {
+		zw := gzip.NewWriter(&stream)
+		for _, e := range entries {
+			if _, err := zw.Write(e); err != nil {
+				return nil, err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, e := range entries {
+			stream.Write(e)
+		}
+	}
+
+	b = msgpack.AppendArrayHeader(b, 3)
+	b = msgpack.AppendString(b, s.spec.Tag)
+	b = msgpack.AppendBinary(b, stream.Bytes())
+
+	n := 1
+	if chunk != "" {
+		n++
+	}
+	if s.spec.Compression == config.CompressionGzip {
+		n++
+	}
+	b = msgpack.AppendMapHeader(b, n)
+	b = msgpack.AppendString(b, "size")
+	b = msgpack.AppendInt(b, int64(len(entries)))
+	if chunk != "" {
+		b = msgpack.AppendString(b, "chunk")
+		b = msgpack.AppendString(b, chunk)
+	}
+	if s.spec.Compression == config.CompressionGzip {
+		b = msgpack.AppendString(b, "compressed")
+		b = msgpack.AppendString(b, "gzip")
+	}
+	return b, nil
+}
+
+// entry returns the entry [time, record] of rec.
+func (s *Forward) entry(rec *record.Record) ([]byte, error) {
+	b := msgpack.AppendArrayHeader(nil, 2)
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	b = appendEventTime(b, ts)
+
+	if rec.Raw == "" && s.spec.Format == encode.FormatJSON {
+		start := len(b)
+		b = appendRecordMap(b, rec, ts)
+		observe(s.enc, len(b)-start)
+		return b, nil
+	}
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return nil, err
+	}
+	b = msgpack.AppendMapHeader(b, 1)
+	b = msgpack.AppendString(b, "log")
+	b = msgpack.AppendString(b, string(line))
+	return b, nil
+}
+
+// appendEventTime appends t as a Fluent EventTime: seconds and nanoseconds
+// as big-endian 32-bit integers in extension type 0.
+func appendEventTime(b []byte, t time.Time) []byte {
+	var data [8]byte
+	binary.BigEndian.PutUint32(data[:4], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(data[4:], uint32(t.Nanosecond()))
+	return msgpack.AppendExt(b, eventTimeExt, data[:])
+}
+
+// appendRecordMap appends rec as a map with the keys of zap's production
+// JSON encoder.
+func appendRecordMap(b []byte, rec *record.Record, ts time.Time) []byte {
+	n := 3 + len(rec.Fields)
+	if rec.Caller.Defined {
+		n++
+	}
+	b = msgpack.AppendMapHeader(b, n)
+	b = msgpack.AppendString(b, "level")
+	b = msgpack.AppendString(b, rec.Level.String())
+	b = msgpack.AppendString(b, "ts")
+	b = msgpack.AppendFloat(b, float64(ts.UnixNano())/float64(time.Second))
+	if rec.Caller.Defined {
+		b = msgpack.AppendString(b, "caller")
+		b = msgpack.AppendString(b, rec.Caller.TrimmedPath())
+	}
+	b = msgpack.AppendString(b, "msg")
+	b = msgpack.AppendString(b, rec.Message)
+	for _, f := range rec.Fields {
+		b = msgpack.AppendString(b, f.Key)
+		b = appendValue(b, f.Value)
+	}
+	return b
+}
+
+// appendValue appends a record field value. Times are RFC 3339 strings.
+func appendValue(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case nil:
+		return msgpack.AppendNil(b)
+	case string:
+		return msgpack.AppendString(b, v)
+	case int64:
+		return msgpack.AppendInt(b, v)
+	case int:
+		return msgpack.AppendInt(b, int64(v))
+	case uint64:
+		return msgpack.AppendUint(b, v)
+	case float64:
+		return msgpack.AppendFloat(b, v)
+	case bool:
+		return msgpack.AppendBool(b, v)
+	case time.Time:
+		return msgpack.AppendString(b, v.Format(time.RFC3339Nano))
+	case []record.Field:
+		b = msgpack.AppendMapHeader(b, len(v))
+		for _, f := range v {
+			b = msgpack.AppendString(b, f.Key)
+			b = appendValue(b, f.Value)
+		}
+		return b
+	case []any:
+		b = msgpack.AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			b = appendValue(b, item)
+		}
+		return b
+	default:
+		return msgpack.AppendString(b, fmt.Sprint(v))
+	}
+}
+
+// hostname returns the host name network sinks report, falling back to
+// loggen.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "loggen"
+	}
+	return name
+}
+
+// digest is the hex SHA-512 of the concatenated parts, as the forward
+// handshake uses.
+func digest(parts ...string) string {
+	h := sha512.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// randomID returns n random bytes rendered by render.
+func randomID(n int, render func([]byte) string) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return render(b)
+}
+
+// text returns a decoded MessagePack string or binary value as a string.
+func text(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,495 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/msgpack"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// event is one record received by the forward receiver.
+type event struct {
+	tag    string
+	time   time.Time
+	record map[string]any
+	chunk  string
+}
+
+// receiver is an in-process forward input. It checks the handshake and the
+// message structure, and acknowledges chunks unless told otherwise.
+type receiver struct {
+	t         *testing.T
+	ln        net.Listener
+	sharedKey string
+	noAck     bool
+
+	// forgePong accepts every PING and answers without knowing the key.
+	forgePong bool
+
+	// dropFirst closes the first connection after reading one message,
+	// without acknowledging it.
+	dropFirst bool
+
+	mu     sync.Mutex
+	events []event
+	acked  []string
+	conns  int
+}
+
+func newReceiver(t *testing.T, configure func(*receiver)) *receiver {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	r := &receiver{t: t, ln: ln}
+	if configure != nil {
+		configure(r)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go r.serve()
+	return r
+}
+
+func (r *receiver) serve() {
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		r.conns++
+		first := r.conns == 1
+		r.mu.Unlock()
+		go r.handle(conn, first)
+	}
+}
+
+func (r *receiver) handle(conn net.Conn, first bool) {
+	defer conn.Close()
+	dec := msgpack.NewDecoder(conn)
+
+	if r.sharedKey != "" && !r.handshake(conn, dec) {
+		return
+	}
+
+	for {
+		v, err := dec.Decode()
+		if err != nil {
+			return
+		}
+		msg, ok := v.([]any)
+		if !ok || len(msg) < 2 {
+			r.t.Errorf("message = %#v, want [tag, entries, option]", v)
+			return
+		}
+		var option map[string]any
+		var chunk string
+		if len(msg) == 3 {
+			option, _ = msg[2].(map[string]any)
+			chunk = text(option["chunk"])
+		}
+		entries, err := r.entries(msg[1], option)
+		if err != nil {
+			r.t.Errorf("entries: %v", err)
+			return
+		}
+		for _, e := range entries {
+			entry, _ := e.([]any)
+			if len(entry) != 2 {
+				r.t.Errorf("entry = %#v, want [time, record]", e)
+				return
+			}
+			ext, ok := entry[0].(msgpack.Ext)
+			if !ok || ext.Type != 0 || len(ext.Data) != 8 {
+				r.t.Errorf("entry time = %#v, want EventTime", entry[0])
+				return
+			}
+			ts := time.Unix(int64(binary.BigEndian.Uint32(ext.Data[:4])), int64(binary.BigEndian.Uint32(ext.Data[4:]))).UTC()
+			rec, _ := entry[1].(map[string]any)
+			r.mu.Lock()
+			r.events = append(r.events, event{tag: text(msg[0]), time: ts, record: rec, chunk: chunk})
+			r.mu.Unlock()
+		}
+
+		if first && r.dropFirst {
+			return
+		}
+		if chunk == "" || r.noAck {
+			continue
+		}
+		b := msgpack.AppendMapHeader(nil, 1)
+		b = msgpack.AppendString(b, "ack")
+		b = msgpack.AppendString(b, chunk)
+		if _, err := conn.Write(b); err != nil {
+			return
+		}
+		r.mu.Lock()
+		r.acked = append(r.acked, chunk)
+		r.mu.Unlock()
+	}
+}
+
+// entries decodes the entries of a Forward, PackedForward or
+// CompressedPackedForward message.
+func (r *receiver) entries(v any, option map[string]any) ([]any, error) {
+	if entries, ok := v.([]any); ok {
+		return entries, nil
+	}
+	stream := []byte(text(v))
+	if text(option["compressed"]) == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(stream))
+		if err != nil {
+			return nil, err
+		}
+		if stream, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	var entries []any
+	dec := msgpack.NewDecoder(bytes.NewReader(stream))
+	for {
+		e, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if size, ok := option["size"]; ok && size != int64(len(entries)) {
+		return nil, fmt.Errorf("size = %v, but the message has %d entries", size, len(entries))
+	}
+	return entries, nil
+}
+
+// handshake sends HELO, verifies the client's PING digest and answers
+// with PONG. It reports whether the client was accepted.
+func (r *receiver) handshake(conn net.Conn, dec *msgpack.Decoder) bool {
+	const nonce, hostname = "nonce-1234", "receiver"
+	b := msgpack.AppendArrayHeader(nil, 2)
+	b = msgpack.AppendString(b, "HELO")
+	b = msgpack.AppendMapHeader(b, 3)
+	b = msgpack.AppendString(b, "nonce")
+	b = msgpack.AppendBinary(b, []byte(nonce))
+	b = msgpack.AppendString(b, "auth")
+	b = msgpack.AppendBinary(b, nil)
+	b = msgpack.AppendString(b, "keepalive")
+	b = msgpack.AppendBool(b, true)
+	if _, err := conn.Write(b); err != nil {
+		return false
+	}
+
+	v, err := dec.Decode()
+	if err != nil {
+		return false
+	}
+	ping, _ := v.([]any)
+	if len(ping) != 6 || text(ping[0]) != "PING" {
+		r.t.Errorf("PING = %#v", v)
+		return false
+	}
+	salt := text(ping[2])
+	ok := text(ping[3]) == digest(salt, text(ping[1]), nonce, r.sharedKey)
+	key := r.sharedKey
+	if r.forgePong {
+		ok, key = true, "unknown"
+	}
+
+	b = msgpack.AppendArrayHeader(nil, 5)
+	b = msgpack.AppendString(b, "PONG")
+	b = msgpack.AppendBool(b, ok)
+	if ok {
+		b = msgpack.AppendString(b, "")
+	} else {
+		b = msgpack.AppendString(b, "shared_key mismatch")
+	}
+	b = msgpack.AppendString(b, hostname)
+	b = msgpack.AppendString(b, digest(salt, hostname, nonce, key))
+	_, _ = conn.Write(b)
+	return ok
+}
+
+func (r *receiver) received() ([]event, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]event(nil), r.events...), append([]string(nil), r.acked...)
+}
+
+// waitFor waits until the receiver has n events, for sinks that do not
+// wait for acks.
+func (r *receiver) waitFor(n int) []event {
+	r.t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		events, _ := r.received()
+		if len(events) >= n || time.Now().After(deadline) {
+			return events
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newForward(t *testing.T, r *receiver, format string, configure func(*config.Sink)) (*Forward, *metrics.Registry) {
+	t.Helper()
+	spec := testSpec("fluent", config.SinkForward, format)
+	spec.Address = r.ln.Addr().String()
+	spec.Tag = "loggen.test"
+	// One record per message, so each Write sends.
+	spec.BatchSize = 1
+	if configure != nil {
+		configure(&spec)
+	}
+	s, reg := newSink(t, spec, NewForward)
+	t.Cleanup(func() { _ = s.Close() })
+	return s, reg
+}
+
+func TestForward_Message(t *testing.T) {
+	r := newReceiver(t, nil)
+	s, _ := newForward(t, r, encode.FormatJSON, nil)
+
+	rec := newRecord(7)
+	rec.Fields = append(rec.Fields,
+		record.Field{Key: "random_string", Value: "alpha"},
+		record.Field{Key: "request", Value: []record.Field{{Key: "status", Value: int64(503)}}},
+		record.Field{Key: "tags", Value: []any{"a", true}},
+	)
+	if err := s.Write(rec); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	events := r.waitFor(1)
+	if len(events) != 1 {
+		t.Fatalf("received %d events, want 1", len(events))
+	}
+	e := events[0]
+	if e.tag != "loggen.test" || !e.time.Equal(t0) || e.chunk != "" {
+		t.Errorf("event = %q at %v chunk %q, want loggen.test at %v without chunk", e.tag, e.time, e.chunk, t0)
+	}
+	got := e.record
+	if got["level"] != "info" || got["msg"] != "tick" || got["count"] != int64(7) || got["random_string"] != "alpha" {
+		t.Errorf("record = %#v", got)
+	}
+	if ts, _ := got["ts"].(float64); time.Duration(ts*1e9-float64(t0.UnixNano())).Abs() > time.Microsecond {
+		t.Errorf("ts = %v, want %v", got["ts"], float64(t0.UnixNano())/1e9)
+	}
+	if req, _ := got["request"].(map[string]any); req["status"] != int64(503) {
+		t.Errorf("request = %#v, want status 503", got["request"])
+	}
+	if tags, _ := got["tags"].([]any); len(tags) != 2 || tags[0] != "a" || tags[1] != true {
+		t.Errorf("tags = %#v", got["tags"])
+	}
+}
+
+func TestForward_LogKey(t *testing.T) {
+	r := newReceiver(t, nil)
+	s, _ := newForward(t, r, encode.FormatLogfmt, nil)
+
+	raw := newRecord(2)
+	raw.Raw = `127.0.0.1 - - "GET / HTTP/1.1" 200`
+	for _, rec := range []*record.Record{newRecord(1), raw} {
+		if err := s.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	events := r.waitFor(2)
+	if len(events) != 2 {
+		t.Fatalf("received %d events, want 2", len(events))
+	}
+	if log := text(events[0].record["log"]); !strings.Contains(log, "msg=tick") || !strings.Contains(log, "count=1") {
+		t.Errorf("logfmt log = %q", log)
+	}
+	if log := text(events[1].record["log"]); log != raw.Raw {
+		t.Errorf("raw log = %q, want %q", log, raw.Raw)
+	}
+}
+
+func TestForward_Ack(t *testing.T) {
+	r := newReceiver(t, nil)
+	s, reg := newForward(t, r, encode.FormatJSON, func(spec *config.Sink) {
+		spec.RequireAck = true
+		spec.BatchSize = 2
+	})
+
+	for i := range 3 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write(%d) error = %v", i, err)
+		}
+	}
+	if events, _ := r.received(); len(events) != 2 {
+		t.Fatalf("received %d events before Close, want the full batch of 2", len(events))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	events, acked := r.received()
+	if len(events) != 3 || len(acked) != 2 {
+		t.Fatalf("received %d events and sent %d acks, want 3 events in 2 acknowledged messages", len(events), len(acked))
+	}
+	if events[0].chunk != acked[0] || events[1].chunk != acked[0] || events[2].chunk != acked[1] || acked[0] == acked[1] {
+		t.Errorf("event chunks = %q %q %q, acks = %v, want one chunk per message", events[0].chunk, events[1].chunk, events[2].chunk, acked)
+	}
+	for i, e := range events {
+		if e.record["count"] != int64(i) {
+			t.Errorf("event %d count = %v, want %d", i, e.record["count"], i)
+		}
+	}
+	if got := sinkCounter(reg, "loggen_sink_forward_acks_total", "fluent"); got != 2 {
+		t.Errorf("acks metric = %v, want 2", got)
+	}
+	if got := sinkCounter(reg, "loggen_sink_records_sent_total", "fluent"); got != 3 {
+		t.Errorf("sent metric = %v, want 3", got)
+	}
+}
+
+func TestForward_Compressed(t *testing.T) {
+	r := newReceiver(t, nil)
+	s, _ := newForward(t, r, encode.FormatJSON, func(spec *config.Sink) {
+		spec.Compression = config.CompressionGzip
+		spec.BatchSize = 5
+		spec.BatchWait = config.Duration(10 * time.Millisecond)
+	})
+
+	for i := range 3 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write(%d) error = %v", i, err)
+		}
+	}
+	// The partial batch goes out once batch_wait passes.
+	events := r.waitFor(3)
+	if len(events) != 3 {
+		t.Fatalf("received %d events, want 3", len(events))
+	}
+	for i, e := range events {
+		if e.record["count"] != int64(i) || e.record["msg"] != "tick" {
+			t.Errorf("event %d = %#v", i, e.record)
+		}
+	}
+}
+
+func TestForward_AckTimeout(t *testing.T) {
+	r := newReceiver(t, func(r *receiver) { r.noAck = true })
+	s, reg := newForward(t, r, encode.FormatJSON, func(spec *config.Sink) {
+		spec.RequireAck = true
+		spec.Timeout = config.Duration(50 * time.Millisecond)
+	})
+
+	err := s.Write(newRecord(1))
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("Write() error = %v, want a timeout", err)
+	}
+	if events, _ := r.received(); len(events) != 2 {
+		t.Errorf("received %d events, want the message and one resend", len(events))
+	}
+	if got := sinkCounter(reg, "loggen_sink_retries_total", "fluent"); got != 1 {
+		t.Errorf("retries metric = %v, want 1", got)
+	}
+}
+
+func TestForward_Reconnect(t *testing.T) {
+	r := newReceiver(t, func(r *receiver) { r.dropFirst = true })
+	s, reg := newForward(t, r, encode.FormatJSON, func(spec *config.Sink) { spec.RequireAck = true })
+
+	if err := s.Write(newRecord(1)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	events, acked := r.received()
+	if len(events) != 2 || len(acked) != 1 || events[0].chunk != events[1].chunk || acked[0] != events[0].chunk {
+		t.Errorf("events = %+v, acks = %v, want the chunk resent and acknowledged once", events, acked)
+	}
+	if got := sinkCounter(reg, "loggen_sink_connects_total", "fluent"); got != 2 {
+		t.Errorf("connects metric = %v, want 2", got)
+	}
+}
+
+func TestForward_SharedKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr string
+	}{
+		{"accepted", "s3cret", ""},
+		{"rejected", "guess", "shared_key mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReceiver(t, func(r *receiver) { r.sharedKey = "s3cret" })
+			s, _ := newForward(t, r, encode.FormatJSON, func(spec *config.Sink) {
+				spec.SharedKey = tt.key
+				spec.RequireAck = true
+			})
+
+			err := s.Write(newRecord(1))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				if events, acked := r.received(); len(events) != 1 || len(acked) != 1 {
+					t.Errorf("received %d events and %d acks, want 1 each", len(events), len(acked))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Write() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForward_ForgedPong(t *testing.T) {
+	// A receiver that accepts any PING but does not know the key cannot
+	// produce the PONG digest, so the sink refuses to send to it.
+	r := newReceiver(t, func(r *receiver) {
+		r.sharedKey = "s3cret"
+		r.forgePong = true
+	})
+	s, _ := newForward(t, r, encode.FormatJSON, func(spec *config.Sink) { spec.SharedKey = "s3cret" })
+
+	err := s.Write(newRecord(1))
+	if err == nil || !strings.Contains(err.Error(), "does not know the shared key") {
+		t.Errorf("Write() error = %v, want a shared key error", err)
+	}
+	if events, _ := r.received(); len(events) != 0 {
+		t.Errorf("received %d events, want none", len(events))
+	}
+}
+
+func TestForward_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	spec := testSpec("fluent", config.SinkForward, encode.FormatJSON)
+	spec.Address = addr
+	spec.Tag = "loggen"
+	spec.BatchSize = 1
+	s, _ := newSink(t, spec, NewForward)
+	if err := s.Write(newRecord(1)); err == nil {
+		t.Error("Write() to a closed port succeeded, want error")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
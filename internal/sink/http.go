@@ -0,0 +1,93 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+)
+
+// maxErrorBody bounds how much of an error response is quoted.
+const maxErrorBody = 512
+
+// newHTTPClient creates the client of an HTTP sink, with the TLS settings
+// of spec for https URLs.
+func newHTTPClient(spec config.Sink) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if strings.HasPrefix(spec.URL, "https://") {
+		tlsConfig, err := tlsClientConfig(spec)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: transport, Timeout: time.Duration(spec.Timeout)}, nil
+}
+
+// statusError is a non-2xx response.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("%d %s", e.code, http.StatusText(e.code))
+	}
+	return fmt.Sprintf("%d %s: %s", e.code, http.StatusText(e.code), e.body)
+}
+
+// retryable reports whether a request that failed with err may succeed
+// when sent again: transport errors, 429 and 5xx.
+func retryable(err error) bool {
+	se, ok := err.(*statusError)
+	return !ok || se.code == http.StatusTooManyRequests || se.code >= 500
+}
+
+// do sends the request built by newRequest, and sends it once more after
+// a retryable failure. It returns the body of a 2xx response.
+func do(client *http.Client, newRequest func() (*http.Request, error)) ([]byte, error) {
+	body, err := doOnce(client, newRequest)
+	if err != nil && retryable(err) {
+		body, err = doOnce(client, newRequest)
+	}
+	return body, err
+}
+
+func doOnce(client *http.Client, newRequest func() (*http.Request, error)) ([]byte, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// newPost returns a request builder for a POST of body to url with the
+// given content type and basic auth from spec.
+func newPost(spec config.Sink, url, contentType string, body []byte) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		if spec.Username != "" {
+			req.SetBasicAuth(spec.Username, spec.Password)
+		}
+		return req, nil
+	}
+}
@@ -0,0 +1,171 @@
+package sink
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// journalPrefix is prepended to the journal name of a record field that
+// would be invalid, or would replace a field the sink sets itself.
+const journalPrefix = "LOGGEN_"
+
+// maxJournalName is the longest field name journald accepts.
+const maxJournalName = 64
+
+// journalReserved are the fields the journald sink sets itself.
+var journalReserved = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+}
+
+// Journald sends each record to systemd-journald as one datagram in its
+// native protocol. MESSAGE is the record in the sink's format, PRIORITY
+// its syslog severity (as for the syslog sink), SYSLOG_IDENTIFIER the tag,
+// and CODE_FILE, CODE_LINE and CODE_FUNC its caller. Every field becomes
+// a journal field named in upper case: nested objects join their keys
+// with underscores and arrays become repeated fields. A record too large
+// for a datagram is passed to journald as an unlinked temporary file, as
+// sd_journal_send does.
+type Journald struct {
+	spec config.Sink
+	enc  encode.Encoder
+
+	mu   sync.Mutex
+	conn *netConn
+	buf  []byte
+
+	files *metrics.Counter
+}
+
+// NewJournald creates a journald sink for spec. It connects on the first
+// write and reconnects when journald restarts.
+func NewJournald(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*Journald, error) {
+	return &Journald{
+		spec:  spec,
+		enc:   enc,
+		conn:  newNetConn(spec.Name, config.NetworkUnixgram, spec.Address, nil, time.Duration(spec.Timeout), reg),
+		files: reg.Counter("loggen_sink_journald_files_total", "Records too large for a datagram, passed to journald as files.", "sink", spec.Name),
+	}, nil
+}
+
+// Write implements Sink.
+func (s *Journald) Write(rec *record.Record) error {
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = s.message(s.buf[:0], rec, line)
+	err = s.conn.write(s.buf)
+	if errors.Is(err, syscall.EMSGSIZE) {
+		s.files.Inc()
+		return sendJournalFile(s.conn.conn, s.buf)
+	}
+	return err
+}
+
+// Close implements Sink.
+func (s *Journald) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.close()
+}
+
+// message appends the native protocol entry for rec to b.
+func (s *Journald) message(b []byte, rec *record.Record, line []byte) []byte {
+	b = appendJournalField(b, "MESSAGE", string(line))
+	b = appendJournalField(b, "PRIORITY", strconv.Itoa(encode.SyslogSeverity(rec.Level)))
+	b = appendJournalField(b, "SYSLOG_IDENTIFIER", s.spec.Tag)
+	if rec.Caller.Defined {
+		b = appendJournalField(b, "CODE_FILE", rec.Caller.File)
+		b = appendJournalField(b, "CODE_LINE", strconv.Itoa(rec.Caller.Line))
+		if rec.Caller.Function != "" {
+			b = appendJournalField(b, "CODE_FUNC", rec.Caller.Function)
+		}
+	}
+	for _, f := range rec.Fields {
+		b = appendJournalValue(b, f.Key, f.Value)
+	}
+	return b
+}
+
+// appendJournalValue appends the journal fields of the record field key:
+// one for a scalar, one per element of an array and one per leaf of an
+// object.
+func appendJournalValue(b []byte, key string, v any) []byte {
+	switch v := v.(type) {
+	case []record.Field:
+		for _, f := range v {
+			b = appendJournalValue(b, key+"_"+f.Key, f.Value)
+		}
+	case []any:
+		for _, e := range v {
+			b = appendJournalValue(b, key, e)
+		}
+	default:
+		text, ok := scalarText(v)
+		if !ok {
+			return b
+		}
+		if name := journalName(key); name != "" {
+			b = appendJournalField(b, name, text)
+		}
+	}
+	return b
+}
+
+// journalName makes key a valid journal field name: upper case letters,
+// digits and underscores, starting with a letter, at most 64 characters.
+// Other characters become underscores.
+func journalName(key string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToUpper(key) {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	name := sb.String()
+	if name == "" {
+		return ""
+	}
+	if name[0] < 'A' || name[0] > 'Z' || journalReserved[name] {
+		name = journalPrefix + name
+	}
+	if len(name) > maxJournalName {
+		name = name[:maxJournalName]
+	}
+	return name
+}
+
+// appendJournalField appends one field: NAME=value and a newline, or, for
+// a value containing a newline, the name, a newline, the value's length
+// as a little-endian 64-bit integer, the value and a newline.
+func appendJournalField(b []byte, name, value string) []byte {
+	b = append(b, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		b = append(b, '=')
+		b = append(b, value...)
+		return append(b, '\n')
+	}
+	b = append(b, '\n')
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(value)))
+	b = append(b, value...)
+	return append(b, '\n')
+}
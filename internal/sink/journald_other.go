@@ -0,0 +1,14 @@
+//go:build !unix
+
+package sink
+
+import (
+	"errors"
+	"net"
+)
+
+// sendJournalFile fails: passing a file to journald needs Unix descriptor
+// passing.
+func sendJournalFile(net.Conn, []byte) error {
+	return errors.New("record too large for a journal datagram")
+}
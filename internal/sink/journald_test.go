@@ -0,0 +1,189 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// newJournald returns a journald sink writing to a temporary socket, and
+// the socket.
+func newJournald(t *testing.T, format string) (*Journald, *net.UnixConn, *metrics.Registry) {
+	t.Helper()
+	path := socketPath(t)
+	conn, err := net.ListenUnixgram(config.NetworkUnixgram, &net.UnixAddr{Name: path, Net: config.NetworkUnixgram})
+	if err != nil {
+		t.Fatalf("ListenUnixgram() error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	spec := testSpec("journal", config.SinkJournald, format)
+	spec.Address = path
+	spec.Tag = "loggen"
+	s, reg := newSink(t, spec, NewJournald)
+	t.Cleanup(func() { _ = s.Close() })
+	return s, conn, reg
+}
+
+// parseJournal decodes a native protocol entry into its fields, keeping
+// repeated fields in order.
+func parseJournal(t *testing.T, data []byte) map[string][]string {
+	t.Helper()
+	fields := map[string][]string{}
+	for len(data) > 0 {
+		nl := bytes.IndexByte(data, '\n')
+		if nl < 0 {
+			t.Fatalf("entry ends without a newline: %q", data)
+		}
+		line := data[:nl]
+		if eq := bytes.IndexByte(line, '='); eq >= 0 {
+			fields[string(line[:eq])] = append(fields[string(line[:eq])], string(line[eq+1:]))
+			data = data[nl+1:]
+			continue
+		}
+		rest := data[nl+1:]
+		if len(rest) < 8 {
+			t.Fatalf("binary field %s has no length", line)
+		}
+		n := int(binary.LittleEndian.Uint64(rest))
+		if len(rest) < 8+n+1 || rest[8+n] != '\n' {
+			t.Fatalf("binary field %s is malformed", line)
+		}
+		fields[string(line)] = append(fields[string(line)], string(rest[8:8+n]))
+		data = rest[8+n+1:]
+	}
+	return fields
+}
+
+func readJournal(t *testing.T, conn *net.UnixConn) map[string][]string {
+	t.Helper()
+	buf := make([]byte, 64<<10)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	return parseJournal(t, buf[:n])
+}
+
+func TestJournald_Fields(t *testing.T) {
+	s, conn, _ := newJournald(t, encode.FormatLogfmt)
+
+	rec := newRecord(7)
+	rec.Caller = zapcore.NewEntryCaller(0, "/src/loop/loop.go", 256, true)
+	rec.Caller.Function = "loop.(*Loop).tick"
+	rec.Fields = append(rec.Fields,
+		record.Field{Key: "random_string", Value: "alpha"},
+		record.Field{Key: "request", Value: []record.Field{{Key: "status", Value: int64(200)}, {Key: "user-agent", Value: "curl/8"}}},
+		record.Field{Key: "tags", Value: []any{"a", "b"}},
+		record.Field{Key: "message", Value: "shadowed"},
+		record.Field{Key: "_id", Value: "x"},
+		record.Field{Key: "9lives", Value: true},
+		record.Field{Key: "note", Value: "line one\nline two"},
+		record.Field{Key: strings.Repeat("k", 80), Value: 1},
+	)
+	if err := s.Write(rec); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	got := readJournal(t, conn)
+	want := map[string][]string{
+		"PRIORITY":              {"6"},
+		"SYSLOG_IDENTIFIER":     {"loggen"},
+		"CODE_FILE":             {"/src/loop/loop.go"},
+		"CODE_LINE":             {"256"},
+		"CODE_FUNC":             {"loop.(*Loop).tick"},
+		"COUNT":                 {"7"},
+		"RANDOM_STRING":         {"alpha"},
+		"REQUEST_STATUS":        {"200"},
+		"REQUEST_USER_AGENT":    {"curl/8"},
+		"TAGS":                  {"a", "b"},
+		"LOGGEN_MESSAGE":        {"shadowed"},
+		"LOGGEN__ID":            {"x"},
+		"LOGGEN_9LIVES":         {"true"},
+		"NOTE":                  {"line one\nline two"},
+		strings.Repeat("K", 64): {"1"},
+	}
+	for name, values := range want {
+		if strings.Join(got[name], "|") != strings.Join(values, "|") {
+			t.Errorf("%s = %q, want %q", name, got[name], values)
+		}
+	}
+	if len(got) != len(want)+1 {
+		t.Errorf("got fields %v, want %d fields", got, len(want)+1)
+	}
+	if msg := got["MESSAGE"]; len(msg) != 1 || !strings.Contains(msg[0], "msg=tick count=7 random_string=alpha") {
+		t.Errorf("MESSAGE = %q, want the logfmt record", msg)
+	}
+}
+
+func TestJournald_Priority(t *testing.T) {
+	s, conn, _ := newJournald(t, encode.FormatJSON)
+
+	tests := []struct {
+		level zapcore.Level
+		want  string
+	}{
+		{zapcore.DebugLevel, "7"},
+		{zapcore.InfoLevel, "6"},
+		{zapcore.WarnLevel, "4"},
+		{zapcore.ErrorLevel, "3"},
+		{zapcore.DPanicLevel, "2"},
+		{zapcore.FatalLevel, "2"},
+	}
+	for _, tt := range tests {
+		rec := newRecord(1)
+		rec.Level = tt.level
+		if err := s.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if got := readJournal(t, conn)["PRIORITY"]; len(got) != 1 || got[0] != tt.want {
+			t.Errorf("level %s: PRIORITY = %q, want %s", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestJournald_RawMultiline(t *testing.T) {
+	s, conn, _ := newJournald(t, encode.FormatJSON)
+
+	rec := newRecord(1)
+	rec.Raw = "panic: boom\n\ngoroutine 1 [running]:\nmain.main()"
+	if err := s.Write(rec); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got := readJournal(t, conn)
+	if msg := got["MESSAGE"]; len(msg) != 1 || msg[0] != rec.Raw {
+		t.Errorf("MESSAGE = %q, want the raw line intact", msg)
+	}
+	if _, ok := got["CODE_FILE"]; ok {
+		t.Error("CODE_FILE set for a record without a caller")
+	}
+}
+
+func TestJournalName(t *testing.T) {
+	tests := map[string]string{
+		"count":         "COUNT",
+		"http.method":   "HTTP_METHOD",
+		"user-agent":    "USER_AGENT",
+		"PRIORITY":      "LOGGEN_PRIORITY",
+		"_SYSTEMD_UNIT": "LOGGEN__SYSTEMD_UNIT",
+		"2xx":           "LOGGEN_2XX",
+		"ünï":           "LOGGEN__N_",
+		"":              "",
+	}
+	for key, want := range tests {
+		if got := journalName(key); got != want {
+			t.Errorf("journalName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//go:build unix
+
+package sink
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// sendJournalFile passes msg to journald in an unlinked file under
+// /dev/shm, sent as a descriptor over conn. journald accepts such files
+// from clients that cannot use a sealed memfd.
+func sendJournalFile(conn net.Conn, msg []byte) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("journal connection is not a unix socket")
+	}
+	dir := "/dev/shm"
+	if _, err := os.Stat(dir); err != nil {
+		dir = os.TempDir()
+	}
+	f, err := os.CreateTemp(dir, "loggen-journal-")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		return err
+	}
+	if _, err := f.Write(msg); err != nil {
+		return err
+	}
+	// WriteMsgUnix refuses connected datagram sockets, so send on the raw
+	// descriptor.
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	var sendErr error
+	err = raw.Write(func(fd uintptr) bool {
+		sendErr = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return sendErr != syscall.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	return sendErr
+}
@@ -0,0 +1,63 @@
+//go:build unix
+
+package sink
+
+import (
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+)
+
+func TestJournald_LargeRecord(t *testing.T) {
+	s, conn, reg := newJournald(t, encode.FormatJSON)
+
+	rec := newRecord(1)
+	rec.Raw = strings.Repeat("x", 4<<20)
+	if err := s.Write(rec); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	buf := make([]byte, 1024)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("ReadMsgUnix() error = %v", err)
+	}
+	if n != 0 {
+		t.Errorf("datagram carries %d bytes, want only a descriptor", n)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("control messages = %v, %v", msgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("descriptors = %v, %v", fds, err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Nlink != 0 {
+		t.Errorf("file has %d links, want it unlinked", st.Nlink)
+	}
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, info.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := parseJournal(t, data)["MESSAGE"]; len(msg) != 1 || msg[0] != rec.Raw {
+		t.Errorf("MESSAGE has %d values, want the 4 MiB record", len(msg))
+	}
+	if got := sinkCounter(reg, "loggen_sink_journald_files_total", "journal"); got != 1 {
+		t.Errorf("files metric = %v, want 1", got)
+	}
+}
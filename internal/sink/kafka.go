@@ -0,0 +1,465 @@
+package sink
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/kafka"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// Kafka produces batches of records to a Kafka topic. Each message value
+// is the record in the sink's format and its key is the key field, so
+// records with the same key land on the partition the Java client would
+// pick. Unkeyed records go to one partition per batch, moving on with each
+// batch. The partition leaders are looked up through the bootstrap
+// brokers on first use and again after a partition error, and each batch
+// is sent to the leader of every partition it touches.
+type Kafka struct {
+	spec        config.Sink
+	enc         encode.Encoder
+	host        string
+	tls         *tls.Config
+	acks        int16
+	compression kafka.Compression
+	batch       *batcher[kafka.Record]
+
+	// The fields below belong to produce, which the batcher runs one at a
+	// time.
+	brokers    map[int32]string
+	conns      map[int32]*kafka.Conn
+	leaders    []int32
+	producerID int64
+	epoch      int16
+	sequences  []int32
+	next       int
+
+	connects *metrics.Counter
+	retries  *metrics.Counter
+}
+
+// kafkaBatch is the encoded record batch of one partition.
+type kafkaBatch struct {
+	partition int32
+	records   int
+	data      []byte
+}
+
+// NewKafka creates a Kafka sink for spec. It connects on the first batch.
+func NewKafka(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*Kafka, error) {
+	s := &Kafka{
+		spec:       spec,
+		enc:        enc,
+		host:       hostname(),
+		conns:      map[int32]*kafka.Conn{},
+		producerID: -1,
+		epoch:      -1,
+	}
+	if spec.Network == config.NetworkTLS {
+		cfg, err := tlsClientConfig(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.tls = cfg
+	}
+	switch spec.Acks {
+	case config.KafkaAcksNone:
+		s.acks = kafka.AcksNone
+	case config.KafkaAcksLeader:
+		s.acks = kafka.AcksLeader
+	default:
+		s.acks = kafka.AcksAll
+	}
+	compression, ok := kafka.ParseCompression(spec.Compression)
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression %q", spec.Compression)
+	}
+	s.compression = compression
+	s.connects, s.retries = connMetrics(reg, spec.Name)
+	s.batch = newBatcher(spec.Name, spec.BatchSize, time.Duration(spec.BatchWait), s.produce, reg)
+	return s, nil
+}
+
+// Write implements Sink. It batches rec as a Kafka record keyed by
+// key_field.
+func (s *Kafka) Write(rec *record.Record) error {
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return err
+	}
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return s.batch.add(kafka.Record{Key: s.key(rec), Value: line, Time: ts})
+}
+
+// Close implements Sink. It produces the records still batched and closes
+// the broker connections.
+func (s *Kafka) Close() error {
+	err := s.batch.close()
+	s.disconnect()
+	return err
+}
+
+// key returns the message key of rec, or nil for an unkeyed record.
+func (s *Kafka) key(rec *record.Record) []byte {
+	switch s.spec.KeyField {
+	case "":
+		return nil
+	case "level":
+		return []byte(rec.Level.String())
+	case "host":
+		return []byte(s.host)
+	}
+	v, ok := rec.Get(s.spec.KeyField)
+	if !ok {
+		return nil
+	}
+	text, ok := scalarText(v)
+	if !ok {
+		return nil
+	}
+	return []byte(text)
+}
+
+// produce sends recs, one record batch per partition. Partitions that
+// fail with a retriable error, or whose leader cannot be reached, are
+// sent once more after refreshing the metadata. An idempotent producer
+// resends the same sequence numbers, so the broker drops a batch it had
+// already written.
+func (s *Kafka) produce(recs []kafka.Record) error {
+	if err := s.prepare(); err != nil {
+		return err
+	}
+	batches, err := s.encode(recs)
+	if err != nil {
+		return err
+	}
+
+	retry, failed, err := s.send(batches)
+	if len(retry) > 0 {
+		s.retries.Inc()
+		s.disconnect()
+		s.leaders = nil
+		if perr := s.prepare(); perr != nil {
+			err = perr
+		} else {
+			var failedAgain []kafkaBatch
+			var errAgain error
+			retry, failedAgain, errAgain = s.send(retry)
+			failed = append(failed, failedAgain...)
+			if errAgain != nil {
+				err = errAgain
+			}
+		}
+		failed = append(failed, retry...)
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	// A batch that was not written leaves a gap in the sequence numbers
+	// of its partition, which the broker would reject from now on.
+	if s.spec.Idempotent {
+		s.producerID = -1
+	}
+	n := 0
+	for _, b := range failed {
+		n += b.records
+	}
+	return &partialError{failed: n, err: fmt.Errorf("%d of %d records failed: %w", n, len(recs), err)}
+}
+
+// prepare fetches the partition leaders and, for an idempotent producer,
+// a producer ID when they are missing.
+func (s *Kafka) prepare() error {
+	if s.leaders == nil {
+		if err := s.refresh(); err != nil {
+			return err
+		}
+	}
+	if !s.spec.Idempotent || s.producerID >= 0 {
+		return nil
+	}
+	ids := make([]int32, 0, len(s.brokers))
+	for id := range s.brokers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var errs []error
+	for _, id := range ids {
+		conn, err := s.conn(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resp, err := conn.InitProducerID(time.Duration(s.spec.Timeout))
+		if err != nil {
+			s.drop(id)
+			errs = append(errs, err)
+			continue
+		}
+		s.producerID, s.epoch = resp.ProducerID, resp.ProducerEpoch
+		s.sequences = make([]int32, len(s.leaders))
+		return nil
+	}
+	return fmt.Errorf("getting a producer id: %w", errors.Join(errs...))
+}
+
+// refresh looks up the brokers and partition leaders of the topic through
+// the first bootstrap broker that answers.
+func (s *Kafka) refresh() error {
+	var errs []error
+	for _, address := range s.spec.Brokers {
+		md, err := s.metadata(address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", address, err))
+			continue
+		}
+		if len(md.Topics) != 1 || md.Topics[0].Name != s.spec.Topic {
+			return fmt.Errorf("metadata for topic %s is missing", s.spec.Topic)
+		}
+		t := md.Topics[0]
+		if t.Err != kafka.ErrNone {
+			return fmt.Errorf("topic %s: %w", s.spec.Topic, t.Err)
+		}
+		if len(t.Partitions) == 0 {
+			return fmt.Errorf("topic %s has no partitions", s.spec.Topic)
+		}
+
+		s.brokers = make(map[int32]string, len(md.Brokers))
+		for _, b := range md.Brokers {
+			s.brokers[b.NodeID] = b.Address()
+		}
+		s.leaders = make([]int32, len(t.Partitions))
+		for i := range s.leaders {
+			s.leaders[i] = -1
+		}
+		for _, p := range t.Partitions {
+			if p.Partition >= 0 && int(p.Partition) < len(s.leaders) && p.Err == kafka.ErrNone {
+				s.leaders[p.Partition] = p.Leader
+			}
+		}
+		for len(s.sequences) < len(s.leaders) {
+			s.sequences = append(s.sequences, 0)
+		}
+		return nil
+	}
+	return fmt.Errorf("no bootstrap broker answered: %w", errors.Join(errs...))
+}
+
+func (s *Kafka) metadata(address string) (*kafka.MetadataResponse, error) {
+	conn, err := s.dial(address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.Metadata(s.spec.Topic)
+}
+
+// encode assigns recs to partitions and encodes one record batch per
+// partition, in partition order.
+func (s *Kafka) encode(recs []kafka.Record) ([]kafkaBatch, error) {
+	n := len(s.leaders)
+	unkeyed := int32(-1)
+	byPartition := map[int32][]kafka.Record{}
+	for _, r := range recs {
+		var p int32
+		if r.Key != nil {
+			p = kafka.Partition(r.Key, n)
+		} else {
+			if unkeyed < 0 {
+				unkeyed = s.stickyPartition()
+			}
+			p = unkeyed
+		}
+		byPartition[p] = append(byPartition[p], r)
+	}
+
+	partitions := make([]int32, 0, len(byPartition))
+	for p := range byPartition {
+		partitions = append(partitions, p)
+	}
+	slices.Sort(partitions)
+
+	batches := make([]kafkaBatch, 0, len(partitions))
+	for _, p := range partitions {
+		rb := kafka.RecordBatch{
+			ProducerID:    s.producerID,
+			ProducerEpoch: s.epoch,
+			BaseSequence:  -1,
+			Compression:   s.compression,
+			Records:       byPartition[p],
+		}
+		if s.spec.Idempotent {
+			rb.BaseSequence = s.sequences[p]
+		}
+		data, err := kafka.AppendRecordBatch(nil, &rb)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, kafkaBatch{partition: p, records: len(rb.Records), data: data})
+	}
+	return batches, nil
+}
+
+// stickyPartition returns the partition of this batch's unkeyed records:
+// the next one in turn that has a leader.
+func (s *Kafka) stickyPartition() int32 {
+	n := len(s.leaders)
+	for i := range n {
+		p := (s.next + i) % n
+		if s.leaders[p] >= 0 {
+			s.next = p + 1
+			return int32(p)
+		}
+	}
+	p := s.next % n
+	s.next = p + 1
+	return int32(p)
+}
+
+// send produces batches to their leaders. It returns the batches worth
+// retrying, the batches that failed for good and the first error.
+func (s *Kafka) send(batches []kafkaBatch) (retry, failed []kafkaBatch, err error) {
+	note := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+
+	byLeader := map[int32][]kafkaBatch{}
+	for _, b := range batches {
+		leader := int32(-1)
+		if int(b.partition) < len(s.leaders) {
+			leader = s.leaders[b.partition]
+		}
+		if leader < 0 {
+			retry = append(retry, b)
+			note(fmt.Errorf("partition %d: %w", b.partition, kafka.ErrLeaderNotAvailable))
+			continue
+		}
+		byLeader[leader] = append(byLeader[leader], b)
+	}
+
+	for leader, group := range byLeader {
+		conn, cerr := s.conn(leader)
+		if cerr != nil {
+			retry = append(retry, group...)
+			note(cerr)
+			continue
+		}
+		req := &kafka.ProduceRequest{
+			Acks:      s.acks,
+			TimeoutMs: int32(time.Duration(s.spec.Timeout).Milliseconds()),
+			Topics:    []kafka.ProduceTopic{{Name: s.spec.Topic}},
+		}
+		for _, b := range group {
+			req.Topics[0].Partitions = append(req.Topics[0].Partitions, kafka.ProducePartition{Partition: b.partition, Records: b.data})
+		}
+		resp, perr := conn.Produce(req)
+		if perr != nil {
+			s.drop(leader)
+			retry = append(retry, group...)
+			note(perr)
+			continue
+		}
+
+		results := map[int32]kafka.ProducePartitionResponse{}
+		if resp != nil {
+			for _, t := range resp.Topics {
+				for _, p := range t.Partitions {
+					results[p.Partition] = p
+				}
+			}
+		}
+		for _, b := range group {
+			result, ok := results[b.partition]
+			perr := result.Err
+			switch {
+			case resp == nil, ok && (perr == kafka.ErrNone || perr == kafka.ErrDuplicateSequenceNumber):
+				if s.spec.Idempotent {
+					s.sequences[b.partition] = kafka.NextSequence(s.sequences[b.partition], b.records)
+				}
+			case !ok:
+				failed = append(failed, b)
+				note(fmt.Errorf("partition %d: missing from the produce response", b.partition))
+			case perr.Retriable():
+				retry = append(retry, b)
+				note(partitionError(result))
+			default:
+				failed = append(failed, b)
+				note(partitionError(result))
+			}
+		}
+	}
+	return retry, failed, err
+}
+
+// partitionError describes the error of a partition, with the broker's
+// explanation if it gave one.
+func partitionError(p kafka.ProducePartitionResponse) error {
+	if p.ErrorMessage != "" {
+		return fmt.Errorf("partition %d: %w: %s", p.Partition, p.Err, p.ErrorMessage)
+	}
+	return fmt.Errorf("partition %d: %w", p.Partition, p.Err)
+}
+
+// conn returns the connection to broker id, dialling it if needed.
+func (s *Kafka) conn(id int32) (*kafka.Conn, error) {
+	if c, ok := s.conns[id]; ok {
+		return c, nil
+	}
+	address, ok := s.brokers[id]
+	if !ok {
+		return nil, fmt.Errorf("broker %d is not in the metadata", id)
+	}
+	c, err := s.dial(address)
+	if err != nil {
+		return nil, err
+	}
+	s.conns[id] = c
+	return c, nil
+}
+
+func (s *Kafka) dial(address string) (*kafka.Conn, error) {
+	timeout := time.Duration(s.spec.Timeout)
+	dialer := &net.Dialer{Timeout: timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, s.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.connects.Inc()
+	// Leave the broker time to wait for its replicas before answering.
+	return kafka.NewConn(conn, s.spec.Tag, 2*timeout), nil
+}
+
+// drop closes the connection to broker id after an error.
+func (s *Kafka) drop(id int32) {
+	if c, ok := s.conns[id]; ok {
+		_ = c.Close()
+		delete(s.conns, id)
+	}
+}
+
+func (s *Kafka) disconnect() {
+	for id := range s.conns {
+		s.drop(id)
+	}
+}
@@ -0,0 +1,325 @@
+package sink
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/kafka"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/kafka/kafkatest"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+func newKafka(t *testing.T, broker *kafkatest.Broker, configure func(*config.Sink)) (*Kafka, *metrics.Registry) {
+	t.Helper()
+	spec := testSpec("kafka", config.SinkKafka, encode.FormatJSON)
+	spec.Brokers = []string{"127.0.0.1:1", broker.Addr()}
+	spec.Topic = "otel_logs"
+	spec.Network = config.NetworkTCP
+	spec.Acks = config.KafkaAcksAll
+	spec.Compression = config.CompressionNone
+	spec.Tag = "loggen"
+	spec.BatchSize = 4
+	if configure != nil {
+		configure(&spec)
+	}
+	return newSink(t, spec, NewKafka)
+}
+
+// keyedRecord returns a record whose random_string field is key.
+func keyedRecord(count uint64, key string) *record.Record {
+	rec := newRecord(count)
+	rec.Fields = append(rec.Fields, record.Field{Key: "random_string", Value: key})
+	return rec
+}
+
+func TestKafka_Keyed(t *testing.T) {
+	broker := kafkatest.NewBroker(t, "otel_logs", 3)
+	s, reg := newKafka(t, broker, func(spec *config.Sink) { spec.KeyField = "random_string" })
+
+	keys := []string{"alpha", "bravo", "charlie", "delta"}
+	for i := range 8 {
+		rec := keyedRecord(uint64(i), keys[i%len(keys)])
+		if i == 7 {
+			rec.Fields = rec.Fields[:1] // no key
+		}
+		if err := s.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	total := 0
+	for p := range int32(3) {
+		for _, r := range broker.Records(p) {
+			total++
+			if r.Key == nil {
+				continue
+			}
+			if want := kafka.Partition(r.Key, 3); want != p {
+				t.Errorf("key %s on partition %d, want %d", r.Key, p, want)
+			}
+			if !strings.Contains(string(r.Value), `"random_string":"`+string(r.Key)+`"`) {
+				t.Errorf("value %s does not match key %s", r.Value, r.Key)
+			}
+			if !r.Time.Equal(t0.Truncate(time.Millisecond)) {
+				t.Errorf("timestamp = %v, want the record time", r.Time)
+			}
+		}
+	}
+	if total != 8 {
+		t.Errorf("broker has %d records, want 8", total)
+	}
+	if got := sinkCounter(reg, "loggen_sink_records_sent_total", "kafka"); got != 8 {
+		t.Errorf("records sent metric = %v, want 8", got)
+	}
+	for _, h := range broker.Requests() {
+		if h.ClientID != "loggen" {
+			t.Errorf("client id = %q, want the tag", h.ClientID)
+		}
+	}
+}
+
+func TestKafka_KeyFields(t *testing.T) {
+	broker := kafkatest.NewBroker(t, "otel_logs", 1)
+	tests := []struct {
+		field string
+		rec   *record.Record
+		want  string
+	}{
+		{"level", newRecord(1), "info"},
+		{"host", newRecord(1), "pod-7"},
+		{"count", newRecord(42), "42"},
+		{"random_string", newRecord(1), ""},
+		{"", keyedRecord(1, "k"), ""},
+	}
+	for _, tt := range tests {
+		s, _ := newKafka(t, broker, func(spec *config.Sink) { spec.KeyField = tt.field })
+		s.host = "pod-7"
+		got := s.key(tt.rec)
+		if string(got) != tt.want || (tt.want == "") != (got == nil) {
+			t.Errorf("key field %q: key = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestKafka_Unkeyed(t *testing.T) {
+	broker := kafkatest.NewBroker(t, "otel_logs", 3)
+	s, _ := newKafka(t, broker, func(spec *config.Sink) { spec.BatchSize = 2 })
+
+	for i := range 8 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Four batches of two, one partition per batch in turn.
+	for p, want := range []int{4, 2, 2} {
+		if got := len(broker.Records(int32(p))); got != want {
+			t.Errorf("partition %d has %d records, want %d", p, got, want)
+		}
+	}
+	if got := broker.Records(1); len(got) > 0 && !strings.Contains(string(got[0].Value), `"count":2`) {
+		t.Errorf("partition 1 starts with %s, want the second batch", got[0].Value)
+	}
+}
+
+func TestKafka_Compression(t *testing.T) {
+	for _, name := range []string{config.CompressionGzip, config.CompressionSnappy} {
+		broker := kafkatest.NewBroker(t, "otel_logs", 1)
+		s, _ := newKafka(t, broker, func(spec *config.Sink) { spec.Compression = name })
+		for i := range 3 {
+			_ = s.Write(newRecord(uint64(i)))
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("%s: Close() error = %v", name, err)
+		}
+		batches := broker.Batches(0)
+		want, _ := kafka.ParseCompression(name)
+		if len(batches) != 1 || batches[0].Compression != want || len(batches[0].Records) != 3 {
+			t.Errorf("%s: batches = %+v", name, batches)
+		}
+	}
+}
+
+func TestKafka_Idempotent(t *testing.T) {
+	broker := kafkatest.NewBroker(t, "otel_logs", 1)
+	s, reg := newKafka(t, broker, func(spec *config.Sink) {
+		spec.Idempotent = true
+		spec.BatchSize = 2
+	})
+
+	// The broker writes the first batch but the response is lost; the
+	// resent batch carries the same sequence numbers and is dropped.
+	broker.HangUpNext(1)
+	for i := range 4 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	batches := broker.Batches(0)
+	if len(batches) != 2 || broker.Duplicates() != 1 {
+		t.Fatalf("got %d batches and %d duplicates, want 2 and 1", len(batches), broker.Duplicates())
+	}
+	if batches[0].ProducerID != 1001 || batches[0].BaseSequence != 0 || batches[1].BaseSequence != 2 {
+		t.Errorf("batches = %+v, want producer 1001 with sequences 0 and 2", batches)
+	}
+	if got := sinkCounter(reg, "loggen_sink_retries_total", "kafka"); got != 1 {
+		t.Errorf("retries metric = %v, want 1", got)
+	}
+	if got := sinkCounter(reg, "loggen_sink_records_sent_total", "kafka"); got != 4 {
+		t.Errorf("records sent metric = %v, want 4", got)
+	}
+}
+
+func TestKafka_RetriableError(t *testing.T) {
+	broker := kafkatest.NewBroker(t, "otel_logs", 2)
+	s, reg := newKafka(t, broker, nil)
+
+	broker.FailNext(kafka.ErrNotLeaderOrFollower)
+	for i := range 4 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	_ = s.Close()
+
+	if got := len(broker.Records(0)); got != 4 {
+		t.Errorf("partition 0 has %d records, want 4 after the retry", got)
+	}
+	metadata := 0
+	for _, h := range broker.Requests() {
+		if h.APIKey == 3 {
+			metadata++
+		}
+	}
+	if metadata != 2 {
+		t.Errorf("got %d metadata requests, want a refresh before the retry", metadata)
+	}
+	if got := sinkCounter(reg, "loggen_sink_records_failed_total", "kafka"); got != 0 {
+		t.Errorf("records failed metric = %v, want 0", got)
+	}
+}
+
+func TestKafka_Versions(t *testing.T) {
+	// API keys 0, 3, 18 and 22 are Produce, Metadata, ApiVersions and
+	// InitProducerId.
+	tests := []struct {
+		name     string
+		versions []kafka.APIVersion
+		produce  int16
+		metadata int16
+		wantErr  string
+	}{
+		{"kafka 0.11", []kafka.APIVersion{
+			{APIKey: 0, MaxVersion: 3}, {APIKey: 3, MaxVersion: 4}, {APIKey: 18, MaxVersion: 1}, {APIKey: 22, MaxVersion: 0},
+		}, 3, 4, ""},
+		{"kip-896", []kafka.APIVersion{
+			{APIKey: 0, MinVersion: 3, MaxVersion: 12}, {APIKey: 3, MaxVersion: 13}, {APIKey: 18, MaxVersion: 4}, {APIKey: 22, MaxVersion: 5},
+		}, 8, 8, ""},
+		{"kafka 0.10", []kafka.APIVersion{
+			{APIKey: 0, MaxVersion: 2}, {APIKey: 3, MaxVersion: 2}, {APIKey: 18, MaxVersion: 0},
+		}, 0, 2, "does not support Produce v3 to v8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := kafkatest.NewBroker(t, "otel_logs", 1)
+			broker.SetVersions(tt.versions...)
+			s, _ := newKafka(t, broker, func(spec *config.Sink) {
+				spec.Idempotent = tt.wantErr == ""
+				spec.Brokers = spec.Brokers[1:]
+			})
+
+			var err error
+			for i := range 4 {
+				err = errors.Join(err, s.Write(newRecord(uint64(i))))
+			}
+			err = errors.Join(err, s.Close())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Write() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if got := len(broker.Records(0)); got != 4 {
+				t.Errorf("broker has %d records, want 4", got)
+			}
+			for _, h := range broker.Requests() {
+				if (h.APIKey == 0 && h.APIVersion != tt.produce) || (h.APIKey == 3 && h.APIVersion != tt.metadata) {
+					t.Errorf("api key %d sent in v%d, want Produce v%d and Metadata v%d", h.APIKey, h.APIVersion, tt.produce, tt.metadata)
+				}
+			}
+		})
+	}
+}
+
+func TestKafka_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*config.Sink)
+		fail      []kafka.Error
+		want      string
+	}{
+		{"rejected", nil, []kafka.Error{kafka.ErrMessageTooLarge}, "4 of 4 records failed: partition 0: kafka: MESSAGE_TOO_LARGE (10)"},
+		{"retries exhausted", nil, []kafka.Error{kafka.ErrNotEnoughReplicas, kafka.ErrNotEnoughReplicas}, "NOT_ENOUGH_REPLICAS"},
+		{"unknown topic", func(spec *config.Sink) { spec.Topic = "missing" }, nil, "topic missing: kafka: UNKNOWN_TOPIC_OR_PARTITION"},
+		{"unreachable", func(spec *config.Sink) { spec.Brokers = spec.Brokers[:1] }, nil, "no bootstrap broker answered"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := kafkatest.NewBroker(t, "otel_logs", 1)
+			s, reg := newKafka(t, broker, tt.configure)
+			broker.FailNext(tt.fail...)
+
+			var err error
+			for i := range 4 {
+				err = errors.Join(err, s.Write(newRecord(uint64(i))))
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Write() error = %v, want %q", err, tt.want)
+			}
+			if got := sinkCounter(reg, "loggen_sink_records_failed_total", "kafka"); got != 4 {
+				t.Errorf("records failed metric = %v, want 4", got)
+			}
+			_ = s.Close()
+		})
+	}
+}
+
+func TestKafka_AcksNone(t *testing.T) {
+	broker := kafkatest.NewBroker(t, "otel_logs", 1)
+	s, reg := newKafka(t, broker, func(spec *config.Sink) { spec.Acks = config.KafkaAcksNone })
+
+	for i := range 4 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if got := sinkCounter(reg, "loggen_sink_records_sent_total", "kafka"); got != 4 {
+		t.Errorf("records sent metric = %v, want 4", got)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(broker.Records(0)) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := len(broker.Records(0)); got != 4 {
+		t.Errorf("broker has %d records, want 4", got)
+	}
+	_ = s.Close()
+}
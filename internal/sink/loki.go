@@ -0,0 +1,237 @@
+package sink
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/snappy"
+)
+
+// Loki pushes batches of records to Loki's push API, as snappy-compressed
+// protobuf like Promtail or as JSON. Each record becomes an entry in the
+// stream of its labels: the static labels plus one per label field the
+// record has. The entry line is the record in the sink's format.
+type Loki struct {
+	spec   config.Sink
+	enc    encode.Encoder
+	client *http.Client
+	batch  *batcher[lokiEntry]
+}
+
+// lokiEntry is a record waiting to be pushed.
+type lokiEntry struct {
+	labels string
+	time   time.Time
+	line   string
+}
+
+// lokiStream is the entries of one label set, in arrival order.
+type lokiStream struct {
+	labels  string
+	entries []lokiEntry
+}
+
+// NewLoki creates a Loki sink for spec.
+func NewLoki(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*Loki, error) {
+	client, err := newHTTPClient(spec)
+	if err != nil {
+		return nil, err
+	}
+	s := &Loki{spec: spec, enc: enc, client: client}
+	s.batch = newBatcher(spec.Name, spec.BatchSize, time.Duration(spec.BatchWait), s.push, reg)
+	return s, nil
+}
+
+// Write implements Sink. It batches rec as an entry of the stream its
+// labels select.
+func (s *Loki) Write(rec *record.Record) error {
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return err
+	}
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return s.batch.add(lokiEntry{labels: s.labels(rec), time: ts, line: string(line)})
+}
+
+// Close implements Sink. It pushes the records still batched.
+func (s *Loki) Close() error {
+	return s.batch.close()
+}
+
+// labels returns the stream selector of rec, such as
+// {job="loggen", level="info"}, with label names sorted.
+func (s *Loki) labels(rec *record.Record) string {
+	pairs := make([][2]string, 0, len(s.spec.Labels)+len(s.spec.LabelFields))
+	for name, value := range s.spec.Labels {
+		pairs = append(pairs, [2]string{name, value})
+	}
+	for _, name := range s.spec.LabelFields {
+		if name == "level" {
+			pairs = append(pairs, [2]string{name, rec.Level.String()})
+			continue
+		}
+		v, ok := rec.Get(name)
+		if !ok {
+			continue
+		}
+		if text, ok := scalarText(v); ok {
+			pairs = append(pairs, [2]string{name, text})
+		}
+	}
+	slices.SortFunc(pairs, func(a, b [2]string) int { return strings.Compare(a[0], b[0]) })
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, p := range pairs {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(p[0])
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(strings.ToValidUTF8(p[1], "\ufffd")))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// push sends entries in one request.
+func (s *Loki) push(entries []lokiEntry) error {
+	streams := groupStreams(entries)
+
+	var body []byte
+	var contentType string
+	if s.spec.Encoding == config.LokiJSON {
+		var err error
+		if body, err = lokiJSON(streams); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	} else {
+		body = snappy.Encode(nil, lokiProtobuf(streams))
+		contentType = "application/x-protobuf"
+	}
+
+	post := newPost(s.spec, s.spec.URL, contentType, body)
+	_, err := do(s.client, func() (*http.Request, error) {
+		req, err := post()
+		if err == nil && s.spec.TenantID != "" {
+			req.Header.Set("X-Scope-OrgID", s.spec.TenantID)
+		}
+		return req, err
+	})
+	return err
+}
+
+// groupStreams groups entries by label set, keeping the order in which
+// each label set first appears.
+func groupStreams(entries []lokiEntry) []*lokiStream {
+	var streams []*lokiStream
+	index := map[string]*lokiStream{}
+	for _, e := range entries {
+		st, ok := index[e.labels]
+		if !ok {
+			st = &lokiStream{labels: e.labels}
+			index[e.labels] = st
+			streams = append(streams, st)
+		}
+		st.entries = append(st.entries, e)
+	}
+	return streams
+}
+
+// lokiJSON renders the JSON push body. The JSON form takes labels as an
+// object rather than a selector string, so the selector is parsed back.
+func lokiJSON(streams []*lokiStream) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	body := struct {
+		Streams []stream `json:"streams"`
+	}{Streams: make([]stream, 0, len(streams))}
+
+	for _, st := range streams {
+		values := make([][2]string, 0, len(st.entries))
+		for _, e := range st.entries {
+			values = append(values, [2]string{strconv.FormatInt(e.time.UnixNano(), 10), e.line})
+		}
+		body.Streams = append(body.Streams, stream{Stream: parseSelector(st.labels), Values: values})
+	}
+	return json.Marshal(body)
+}
+
+// parseSelector parses a selector written by Loki.labels.
+func parseSelector(sel string) map[string]string {
+	labels := map[string]string{}
+	rest := strings.TrimSuffix(strings.TrimPrefix(sel, "{"), "}")
+	for rest != "" {
+		name, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		quoted, err := strconv.QuotedPrefix(after)
+		if err != nil {
+			break
+		}
+		labels[name], _ = strconv.Unquote(quoted)
+		rest = strings.TrimPrefix(after[len(quoted):], ", ")
+	}
+	return labels
+}
+
+// lokiProtobuf renders a logproto.PushRequest:
+//
+//	PushRequest { repeated Stream streams = 1; }
+//	Stream { string labels = 1; repeated Entry entries = 2; }
+//	Entry { Timestamp timestamp = 1; string line = 2; }
+//	Timestamp { int64 seconds = 1; int32 nanos = 2; }
+func lokiProtobuf(streams []*lokiStream) []byte {
+	var req, stream, entry, ts []byte
+	for _, st := range streams {
+		stream = appendProtoString(stream[:0], 1, st.labels)
+		for _, e := range st.entries {
+			ts = appendProtoVarint(ts[:0], 1, uint64(e.time.Unix()))
+			ts = appendProtoVarint(ts, 2, uint64(e.time.Nanosecond()))
+			entry = appendProtoBytes(entry[:0], 1, ts)
+			entry = appendProtoString(entry, 2, e.line)
+			stream = appendProtoBytes(stream, 2, entry)
+		}
+		req = appendProtoBytes(req, 1, stream)
+	}
+	return req
+}
+
+// Protobuf wire types.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendProtoString(b []byte, field int, v string) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,327 @@
+package sink
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/snappy"
+)
+
+// lokiPush is one request received by the fake Loki, decoded.
+type lokiPush struct {
+	header  http.Header
+	streams map[string][]lokiEntry
+	order   []string
+}
+
+// fakeLoki decodes pushes in either encoding. fail holds the status codes
+// of the first responses; later requests get 204.
+type fakeLoki struct {
+	fakeServer
+	fail []int
+	reqs []lokiPush
+}
+
+func newFakeLoki(t *testing.T, fail ...int) *fakeLoki {
+	return &fakeLoki{fakeServer: fakeServer{t: t}, fail: fail}
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != config.DefaultLokiPath {
+		f.t.Errorf("request = %s %s, want POST %s", r.Method, r.URL.Path, config.DefaultLokiPath)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.fail) > 0 {
+		code := f.fail[0]
+		f.fail = f.fail[1:]
+		http.Error(w, "entry out of order", code)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		f.t.Errorf("reading body: %v", err)
+		return
+	}
+	push := lokiPush{header: r.Header.Clone(), streams: map[string][]lokiEntry{}}
+	switch r.Header.Get("Content-Type") {
+	case "application/x-protobuf":
+		raw, err := snappy.Decode(body)
+		if err != nil {
+			f.t.Errorf("snappy.Decode() error = %v", err)
+			return
+		}
+		f.decodeProtobuf(&push, raw)
+	case "application/json":
+		f.decodeJSON(&push, body)
+	default:
+		f.t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+	}
+	f.reqs = append(f.reqs, push)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeLoki) decodeJSON(push *lokiPush, body []byte) {
+	var req struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		f.t.Errorf("json.Unmarshal() error = %v", err)
+		return
+	}
+	for _, st := range req.Streams {
+		key := selectorOf(st.Stream)
+		push.order = append(push.order, key)
+		for _, v := range st.Values {
+			ns, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				f.t.Errorf("timestamp %q: %v", v[0], err)
+			}
+			push.streams[key] = append(push.streams[key], lokiEntry{time: time.Unix(0, ns).UTC(), line: v[1]})
+		}
+	}
+}
+
+func (f *fakeLoki) decodeProtobuf(push *lokiPush, raw []byte) {
+	for _, stream := range protoFields(f.t, raw)[1] {
+		st := protoFields(f.t, stream.([]byte))
+		labels := string(st[1][0].([]byte))
+		push.order = append(push.order, labels)
+		for _, e := range st[2] {
+			entry := protoFields(f.t, e.([]byte))
+			ts := protoFields(f.t, entry[1][0].([]byte))
+			var sec, nsec uint64
+			if len(ts[1]) > 0 {
+				sec = ts[1][0].(uint64)
+			}
+			if len(ts[2]) > 0 {
+				nsec = ts[2][0].(uint64)
+			}
+			push.streams[labels] = append(push.streams[labels], lokiEntry{
+				time: time.Unix(int64(sec), int64(nsec)).UTC(),
+				line: string(entry[2][0].([]byte)),
+			})
+		}
+	}
+}
+
+// protoFields decodes one protobuf message into its varint (uint64) and
+// length-delimited ([]byte) fields by number.
+func protoFields(t *testing.T, b []byte) map[int][]any {
+	t.Helper()
+	fields := map[int][]any{}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad field key")
+		}
+		b = b[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatalf("bad varint in field %d", field)
+			}
+			fields[field] = append(fields[field], v)
+			b = b[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || int(size) > len(b)-n {
+				t.Fatalf("bad length in field %d", field)
+			}
+			fields[field] = append(fields[field], b[n:n+int(size)])
+			b = b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+// selectorOf renders labels the way Loki.labels does.
+func selectorOf(labels map[string]string) string {
+	s := &Loki{spec: config.Sink{Labels: labels}}
+	return s.labels(&record.Record{})
+}
+
+func (f *fakeLoki) pushes() []lokiPush {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]lokiPush(nil), f.reqs...)
+}
+
+func newLoki(t *testing.T, f *fakeLoki, configure func(*config.Sink)) (*Loki, *metrics.Registry) {
+	t.Helper()
+	spec := testSpec("loki", config.SinkLoki, encode.FormatLogfmt)
+	spec.Labels = map[string]string{"job": "loggen"}
+	spec.LabelFields = []string{"level", "random_string"}
+	spec.Encoding = config.LokiProtobuf
+	return newHTTPSink(t, f, config.DefaultLokiPath, spec, NewLoki, configure)
+}
+
+func lokiRecord(count uint64, level zapcore.Level, str string) *record.Record {
+	rec := newRecord(count)
+	rec.Level = level
+	rec.Time = t0.Add(time.Duration(count) * time.Second)
+	rec.Fields = append(rec.Fields, record.Field{Key: "random_string", Value: str})
+	return rec
+}
+
+func TestLoki_Push(t *testing.T) {
+	for _, encoding := range []string{config.LokiProtobuf, config.LokiJSON} {
+		t.Run(encoding, func(t *testing.T) {
+			f := newFakeLoki(t)
+			s, reg := newLoki(t, f, func(spec *config.Sink) {
+				spec.Encoding = encoding
+				spec.TenantID = "team-a"
+				spec.Username, spec.Password = "user", "pass"
+			})
+
+			recs := []*record.Record{
+				lokiRecord(1, zapcore.InfoLevel, "alpha"),
+				lokiRecord(2, zapcore.ErrorLevel, "alpha"),
+				lokiRecord(3, zapcore.InfoLevel, "alpha"),
+				lokiRecord(4, zapcore.InfoLevel, `be"ta`),
+				newRecord(5),
+			}
+			for _, rec := range recs {
+				if err := s.Write(rec); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if got := f.pushes(); len(got) != 0 {
+				t.Fatalf("pushed %d requests before the batch was full or closed", len(got))
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			pushes := f.pushes()
+			if len(pushes) != 1 {
+				t.Fatalf("got %d pushes, want 1", len(pushes))
+			}
+			p := pushes[0]
+			if p.header.Get("X-Scope-OrgID") != "team-a" {
+				t.Errorf("X-Scope-OrgID = %q, want team-a", p.header.Get("X-Scope-OrgID"))
+			}
+			if user, pass, _ := (&http.Request{Header: p.header}).BasicAuth(); user != "user" || pass != "pass" {
+				t.Errorf("basic auth = %q:%q, want user:pass", user, pass)
+			}
+
+			wantOrder := []string{
+				`{job="loggen", level="info", random_string="alpha"}`,
+				`{job="loggen", level="error", random_string="alpha"}`,
+				`{job="loggen", level="info", random_string="be\"ta"}`,
+				`{job="loggen", level="info"}`,
+			}
+			if strings.Join(p.order, "\n") != strings.Join(wantOrder, "\n") {
+				t.Errorf("streams =\n%s\nwant\n%s", strings.Join(p.order, "\n"), strings.Join(wantOrder, "\n"))
+			}
+			info := p.streams[wantOrder[0]]
+			if len(info) != 2 || !info[0].time.Equal(t0.Add(time.Second)) || !info[1].time.Equal(t0.Add(3*time.Second)) {
+				t.Fatalf("info/alpha entries = %+v, want counts 1 and 3 at their record times", info)
+			}
+			if !strings.Contains(info[1].line, "msg=tick count=3 random_string=alpha") {
+				t.Errorf("line = %q, want the logfmt record", info[1].line)
+			}
+			if got := sinkCounter(reg, "loggen_sink_records_sent_total", "loki"); got != 5 {
+				t.Errorf("records sent metric = %v, want 5", got)
+			}
+		})
+	}
+}
+
+func TestLoki_BatchSize(t *testing.T) {
+	f := newFakeLoki(t)
+	s, reg := newLoki(t, f, func(spec *config.Sink) { spec.BatchSize = 2 })
+
+	for i := range 5 {
+		if err := s.Write(lokiRecord(uint64(i), zapcore.InfoLevel, "alpha")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if got := len(f.pushes()); got != 2 {
+		t.Errorf("pushes before close = %d, want 2 full batches", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := len(f.pushes()); got != 3 {
+		t.Errorf("pushes after close = %d, want 3", got)
+	}
+	if got := sinkCounter(reg, "loggen_sink_batches_total", "loki"); got != 3 {
+		t.Errorf("batches metric = %v, want 3", got)
+	}
+}
+
+func TestLoki_BatchWait(t *testing.T) {
+	f := newFakeLoki(t)
+	s, _ := newLoki(t, f, func(spec *config.Sink) { spec.BatchWait = config.Duration(20 * time.Millisecond) })
+	defer s.Close()
+
+	if err := s.Write(lokiRecord(1, zapcore.InfoLevel, "alpha")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(f.pushes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := len(f.pushes()); got != 1 {
+		t.Errorf("pushes after batch_wait = %d, want 1", got)
+	}
+}
+
+func TestLoki_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       []int
+		wantErr    string
+		wantPushes int
+	}{
+		{"retried server error", []int{http.StatusServiceUnavailable}, "", 1},
+		{"rate limited twice", []int{http.StatusTooManyRequests, http.StatusTooManyRequests}, "429 Too Many Requests", 0},
+		{"rejected", []int{http.StatusBadRequest}, "400 Bad Request: entry out of order", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeLoki(t, tt.fail...)
+			s, reg := newLoki(t, f, nil)
+			if err := s.Write(lokiRecord(1, zapcore.InfoLevel, "alpha")); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			err := s.Close()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Close() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if got := len(f.pushes()); got != tt.wantPushes {
+				t.Errorf("accepted pushes = %d, want %d", got, tt.wantPushes)
+			}
+			failed := sinkCounter(reg, "loggen_sink_records_failed_total", "loki")
+			if want := float64(1 - tt.wantPushes); failed != want {
+				t.Errorf("records failed metric = %v, want %v", failed, want)
+			}
+		})
+	}
+}
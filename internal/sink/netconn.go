@@ -0,0 +1,145 @@
+package sink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+)
+
+// netConn is a connection that is dialled on the first write and redialled
+// after an error. It is not safe for concurrent use.
+type netConn struct {
+	network string
+	address string
+	tls     *tls.Config
+	timeout time.Duration
+	conn    net.Conn
+
+	connects *metrics.Counter
+	retries  *metrics.Counter
+}
+
+// newNetConn creates a connection to address over network, tcp, udp or
+// unix, wrapped in TLS when tlsConfig is set. Connections and retries are
+// counted in reg under the sink name.
+func newNetConn(name, network, address string, tlsConfig *tls.Config, timeout time.Duration, reg *metrics.Registry) *netConn {
+	connects, retries := connMetrics(reg, name)
+	return &netConn{
+		network:  network,
+		address:  address,
+		tls:      tlsConfig,
+		timeout:  timeout,
+		connects: connects,
+		retries:  retries,
+	}
+}
+
+// connMetrics returns the connection and retry counters of a network sink.
+func connMetrics(reg *metrics.Registry, name string) (connects, retries *metrics.Counter) {
+	connects = reg.Counter("loggen_sink_connects_total", "Network sink connections opened.", "sink", name)
+	retries = reg.Counter("loggen_sink_retries_total", "Network sink writes resent after a failure.", "sink", name)
+	return connects, retries
+}
+
+// write sends b in a single write. After an error it reconnects and sends
+// b once more, so a receiver restart costs at most the writes the kernel
+// had already accepted on the old connection. A datagram too large for the
+// socket fails without reconnecting.
+func (c *netConn) write(b []byte) error {
+	err := c.writeOnce(b)
+	if err == nil || errors.Is(err, syscall.EMSGSIZE) {
+		return err
+	}
+	c.close()
+	c.retries.Inc()
+	if err := c.writeOnce(b); err != nil {
+		c.close()
+		return err
+	}
+	return nil
+}
+
+func (c *netConn) writeOnce(b []byte) error {
+	if c.conn == nil {
+		if err := c.dial(); err != nil {
+			return err
+		}
+	}
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+func (c *netConn) dial() error {
+	dialer := &net.Dialer{Timeout: c.timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if c.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, c.network, c.address, c.tls)
+	} else {
+		conn, err = dialer.Dial(c.network, c.address)
+	}
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.connects.Inc()
+	return nil
+}
+
+// close closes the connection, if any; the next write redials.
+func (c *netConn) close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// tlsClientConfig builds the client TLS settings of spec: the CA bundle
+// that verifies the server, an optional client certificate and the server
+// name.
+func tlsClientConfig(spec config.Sink) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         spec.TLSServerName,
+		InsecureSkipVerify: spec.TLSSkipVerify,
+	}
+	if cfg.ServerName == "" {
+		if host, _, err := net.SplitHostPort(spec.Address); err == nil {
+			cfg.ServerName = host
+		}
+	}
+	if spec.TLSCA != "" {
+		data, err := os.ReadFile(spec.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading tls_ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("tls_ca contains no PEM certificates")
+		}
+		cfg.RootCAs = pool
+	}
+	if spec.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(spec.TLSCert, spec.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading tls_cert: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
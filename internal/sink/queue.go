@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// Reasons a queue drops a record, the reason label of
+// loggen_queue_dropped_total.
+const (
+	dropFull    = "full"
+	dropOldest  = "oldest"
+	dropSampled = "sampled"
+)
+
+// queue is the bounded buffer of records between the loop and one sink's
+// goroutine. Only the loop pushes, so the overflow policies need nothing
+// beyond the channel.
+type queue struct {
+	ch       chan *record.Record
+	overflow string
+
+	// sample is the fraction admitted past half full, and credit the
+	// share of a record accumulated towards admitting the next one.
+	sample float64
+	credit float64
+
+	depth   *metrics.Gauge
+	blocked *metrics.Counter
+	full    *metrics.Counter
+	oldest  *metrics.Counter
+	sampled *metrics.Counter
+}
+
+// newQueue creates the queue of the named sink. A nil spec means the
+// config.Queue defaults.
+func newQueue(name string, spec *config.Queue, reg *metrics.Registry) *queue {
+	q := &queue{overflow: config.OverflowDropNewest}
+	size := config.DefaultQueueSize
+	if spec != nil {
+		if spec.Size > 0 {
+			size = spec.Size
+		}
+		if spec.Overflow != "" {
+			q.overflow = spec.Overflow
+		}
+		q.sample = spec.Sample
+	}
+	if q.overflow == config.OverflowSample && q.sample == 0 {
+		q.sample = config.DefaultQueueSample
+	}
+	q.ch = make(chan *record.Record, size)
+
+	dropped := func(reason string) *metrics.Counter {
+		return reg.Counter("loggen_queue_dropped_total", "Records dropped by a sink queue, by reason.", "sink", name, "reason", reason)
+	}
+	q.full = dropped(dropFull)
+	q.oldest = dropped(dropOldest)
+	q.sampled = dropped(dropSampled)
+	q.depth = reg.Gauge("loggen_queue_depth", "Records waiting in a sink queue.", "sink", name)
+	q.blocked = reg.Counter("loggen_queue_blocked_seconds_total", "Time the loop waited for room in a sink queue.", "sink", name)
+	return q
+}
+
+// push adds rec under the overflow policy and returns the number of
+// records dropped to do so.
+func (q *queue) push(rec *record.Record) int {
+	defer func() { q.depth.Set(float64(len(q.ch))) }()
+
+	switch q.overflow {
+	case config.OverflowBlock:
+		select {
+		case q.ch <- rec:
+		default:
+			start := time.Now()
+			q.ch <- rec
+			q.blocked.Add(time.Since(start).Seconds())
+		}
+		return 0
+
+	case config.OverflowDropOldest:
+		dropped := 0
+		for {
+			select {
+			case q.ch <- rec:
+				return dropped
+			default:
+			}
+			select {
+			case <-q.ch:
+				q.oldest.Inc()
+				dropped++
+			default:
+			}
+		}
+
+	case config.OverflowSample:
+		if len(q.ch) >= cap(q.ch)/2 {
+			q.credit += q.sample
+			if q.credit < 1 {
+				q.sampled.Inc()
+				return 1
+			}
+			q.credit--
+		}
+	}
+
+	select {
+	case q.ch <- rec:
+		return 0
+	default:
+		q.full.Inc()
+		return 1
+	}
+}
+
+// pop returns the next record, or false once the queue is closed and
+// empty.
+func (q *queue) pop() (*record.Record, bool) {
+	rec, ok := <-q.ch
+	q.depth.Set(float64(len(q.ch)))
+	return rec, ok
+}
+
+// close ends the queue; records already in it are still popped.
+func (q *queue) close() {
+	close(q.ch)
+}
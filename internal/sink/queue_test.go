@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+)
+
+// drain pops every record of a closed queue and returns their counts.
+func drain(q *queue) []uint64 {
+	q.close()
+	var counts []uint64
+	for {
+		rec, ok := q.pop()
+		if !ok {
+			return counts
+		}
+		count, _ := recordCount(rec)
+		counts = append(counts, count)
+	}
+}
+
+func dropCounter(reg *metrics.Registry, sink, reason string) float64 {
+	return reg.Counter("loggen_queue_dropped_total", "", "sink", sink, "reason", reason).Value()
+}
+
+func TestQueue_Overflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    config.Queue
+		pushes  int
+		want    []uint64
+		dropped int
+		reasons map[string]float64
+	}{
+		{
+			name:    "drop newest",
+			spec:    config.Queue{Size: 2, Overflow: config.OverflowDropNewest},
+			pushes:  4,
+			want:    []uint64{1, 2},
+			dropped: 2,
+			reasons: map[string]float64{dropFull: 2},
+		},
+		{
+			name:    "default",
+			spec:    config.Queue{Size: 2},
+			pushes:  3,
+			want:    []uint64{1, 2},
+			dropped: 1,
+			reasons: map[string]float64{dropFull: 1},
+		},
+		{
+			name:    "drop oldest",
+			spec:    config.Queue{Size: 2, Overflow: config.OverflowDropOldest},
+			pushes:  5,
+			want:    []uint64{4, 5},
+			dropped: 3,
+			reasons: map[string]float64{dropOldest: 3},
+		},
+		{
+			// Past half full every other record is admitted, until the
+			// queue is full and the admitted one is dropped too.
+			name:    "sample",
+			spec:    config.Queue{Size: 4, Overflow: config.OverflowSample, Sample: 0.5},
+			pushes:  8,
+			want:    []uint64{1, 2, 4, 6},
+			dropped: 4,
+			reasons: map[string]float64{dropSampled: 3, dropFull: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := metrics.NewRegistry()
+			q := newQueue("s", &tt.spec, reg)
+			dropped := 0
+			for i := range tt.pushes {
+				dropped += q.push(newRecord(uint64(i + 1)))
+			}
+			if dropped != tt.dropped {
+				t.Errorf("push() dropped %d records, want %d", dropped, tt.dropped)
+			}
+			if got := reg.Gauge("loggen_queue_depth", "", "sink", "s").Value(); got != float64(len(tt.want)) {
+				t.Errorf("depth = %v, want %d", got, len(tt.want))
+			}
+			for _, reason := range []string{dropFull, dropOldest, dropSampled} {
+				if got := dropCounter(reg, "s", reason); got != tt.reasons[reason] {
+					t.Errorf("dropped for %s = %v, want %v", reason, got, tt.reasons[reason])
+				}
+			}
+			if got := drain(q); !slices.Equal(got, tt.want) {
+				t.Errorf("queue holds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueue_Block(t *testing.T) {
+	reg := metrics.NewRegistry()
+	q := newQueue("s", &config.Queue{Size: 1, Overflow: config.OverflowBlock}, reg)
+	q.push(newRecord(1))
+
+	pushed := make(chan int)
+	go func() { pushed <- q.push(newRecord(2)) }()
+	select {
+	case <-pushed:
+		t.Fatal("push() to a full queue returned without waiting")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if rec, _ := q.pop(); rec.Fields[0].Value != uint64(1) {
+		t.Errorf("pop() = %v, want record 1", rec.Fields[0].Value)
+	}
+	if dropped := <-pushed; dropped != 0 {
+		t.Errorf("push() dropped %d records", dropped)
+	}
+	if got := sinkCounter(reg, "loggen_queue_blocked_seconds_total", "s"); got < 0.01 {
+		t.Errorf("blocked seconds = %v, want the time push waited", got)
+	}
+	if got := drain(q); !slices.Equal(got, []uint64{2}) {
+		t.Errorf("queue holds %v, want [2]", got)
+	}
+	for _, reason := range []string{dropFull, dropOldest, dropSampled} {
+		if got := dropCounter(reg, "s", reason); got != 0 {
+			t.Errorf("dropped for %s = %v, want 0", reason, got)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+)
+
+// rotatedTime is the suffix layout the kubelet gives rotated CRI logs,
+// also used for rotated file sinks.
+const rotatedTime = "20060102-150405"
+
+// Rotation reasons reported in metrics.
+const (
+	reasonSize = "size"
+	reasonTime = "time"
+)
+
+// rotationMetrics counts the rotations of one sink.
+type rotationMetrics struct {
+	size       *metrics.Counter
+	time       *metrics.Counter
+	errors     *metrics.Counter
+	removed    *metrics.Counter
+	compressed *metrics.Counter
+	bytes      *metrics.Gauge
+}
+
+func newRotationMetrics(reg *metrics.Registry, name string) rotationMetrics {
+	const rotations = "loggen_sink_rotations_total"
+	const rotationsHelp = "File rotations by reason."
+	return rotationMetrics{
+		size:       reg.Counter(rotations, rotationsHelp, "sink", name, "reason", reasonSize),
+		time:       reg.Counter(rotations, rotationsHelp, "sink", name, "reason", reasonTime),
+		errors:     reg.Counter("loggen_sink_rotation_errors_total", "Failed file rotations.", "sink", name),
+		removed:    reg.Counter("loggen_sink_files_removed_total", "Rotated files removed by retention.", "sink", name),
+		compressed: reg.Counter("loggen_sink_files_compressed_total", "Rotated files compressed.", "sink", name),
+		bytes:      reg.Gauge("loggen_sink_file_bytes", "Size of the live file in bytes.", "sink", name),
+	}
+}
+
+// rotatingFile is an append-only file that rotates by size or on interval
+// boundaries of the record time. Rotated files are named with a timestamp
+// suffix, or numbered .1, .2 like Docker's json-file driver; the live file
+// is either renamed or copied and truncated in place. Rotated files other
+// than the newest can be compressed, since a collector may still be reading
+// the newest after a rename, and the oldest are removed to keep maxFiles
+// files including the live one.
+type rotatingFile struct {
+	mu           sync.Mutex
+	path         string
+	maxSize      int64
+	every        time.Duration
+	maxFiles     int
+	compression  string
+	copyTruncate bool
+	numbered     bool
+	m            rotationMetrics
+
+	f      *os.File
+	size   int64
+	period time.Time
+}
+
+// openRotating opens the file of spec for appending, creating its
+// directory. A nil reg keeps the metrics private.
+func openRotating(spec config.Sink, reg *metrics.Registry) (*rotatingFile, error) {
+	if reg == nil {
+		reg = metrics.NewRegistry()
+	}
+	r := &rotatingFile{
+		path:         spec.Path,
+		maxSize:      int64(spec.MaxSize),
+		every:        time.Duration(spec.RotateEvery),
+		maxFiles:     spec.MaxFiles,
+		compression:  spec.Compression,
+		copyTruncate: spec.RotateMode == config.RotateCopyTruncate,
+		numbered:     spec.Type == config.SinkDocker,
+		m:            newRotationMetrics(reg, spec.Name),
+	}
+	if r.maxFiles < 2 {
+		r.maxFiles = config.DefaultMaxFiles
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	r.m.bytes.Set(float64(r.size))
+	return nil
+}
+
+// write appends b, which holds whole lines written at now, rotating first
+// when now is in a new interval and afterwards when the file is full.
+func (r *rotatingFile) write(b []byte, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return os.ErrClosed
+	}
+
+	if r.every > 0 {
+		period := now.Truncate(r.every)
+		switch {
+		case r.period.IsZero():
+			r.period = period
+		case period.After(r.period):
+			r.period = period
+			if r.size > 0 {
+				if err := r.rotate(now, reasonTime); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	r.m.bytes.Set(float64(r.size))
+	if err != nil {
+		return err
+	}
+	if r.maxSize > 0 && r.size >= r.maxSize {
+		return r.rotate(now, reasonSize)
+	}
+	return nil
+}
+
+// rotate moves the live file aside. Called with r.mu held.
+func (r *rotatingFile) rotate(now time.Time, reason string) error {
+	err := r.moveAside(now)
+	if err == nil {
+		err = r.retain()
+	}
+	if r.f == nil {
+		if oerr := r.open(); err == nil {
+			err = oerr
+		}
+	}
+	if err != nil {
+		r.m.errors.Inc()
+		return fmt.Errorf("rotate %s: %w", r.path, err)
+	}
+	if reason == reasonTime {
+		r.m.time.Inc()
+	} else {
+		r.m.size.Inc()
+	}
+	return nil
+}
+
+// moveAside renames the live file, or copies and truncates it, to the
+// next rotated name.
+func (r *rotatingFile) moveAside(now time.Time) error {
+	var dst string
+	if r.numbered {
+		if err := r.shiftNumbered(); err != nil {
+			return err
+		}
+		dst = r.numberedName(1)
+	} else {
+		ts := now.UTC()
+		dst = r.path + "." + ts.Format(rotatedTime)
+		for existsRotated(dst) {
+			// The kubelet checks every 10s; rotating faster can reuse a
+			// second, so move on to the next free one.
+			ts = ts.Add(time.Second)
+			dst = r.path + "." + ts.Format(rotatedTime)
+		}
+	}
+
+	if r.copyTruncate {
+		if err := copyFile(r.path, dst); err != nil {
+			return err
+		}
+		if err := r.f.Truncate(0); err != nil {
+			return err
+		}
+		r.size = 0
+		r.m.bytes.Set(0)
+		return nil
+	}
+
+	err := r.f.Close()
+	r.f = nil
+	if err != nil {
+		return err
+	}
+	return os.Rename(r.path, dst)
+}
+
+// shiftNumbered moves path.N to path.N+1, dropping the last.
+func (r *rotatingFile) shiftNumbered() error {
+	last := r.maxFiles - 1
+	for _, suffix := range rotatedSuffixes {
+		if exists(r.numberedName(last) + suffix) {
+			if err := os.Remove(r.numberedName(last) + suffix); err != nil {
+				return err
+			}
+			r.m.removed.Inc()
+		}
+	}
+	for i := last - 1; i >= 1; i-- {
+		for _, suffix := range rotatedSuffixes {
+			src := r.numberedName(i) + suffix
+			if exists(src) {
+				if err := os.Rename(src, r.numberedName(i+1)+suffix); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *rotatingFile) numberedName(i int) string {
+	return r.path + "." + strconv.Itoa(i)
+}
+
+// retain compresses rotated files other than the newest and removes the
+// oldest timestamped ones beyond maxFiles.
+func (r *rotatingFile) retain() error {
+	if r.numbered {
+		if r.compresses() && exists(r.numberedName(2)) {
+			return r.compress(r.numberedName(2))
+		}
+		return nil
+	}
+
+	rotated, err := r.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	for len(rotated) > r.maxFiles-1 {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		r.m.removed.Inc()
+		rotated = rotated[1:]
+	}
+	if r.compresses() && len(rotated) > 0 {
+		for _, p := range rotated[:len(rotated)-1] {
+			if !compressed(p) {
+				if err := r.compress(p); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// rotatedFiles lists the timestamped rotated files, oldest first. Other
+// files that merely share the prefix are left alone.
+func (r *rotatingFile) rotatedFiles() ([]string, error) {
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	prefix := r.path + "."
+	matches = slices.DeleteFunc(matches, func(p string) bool {
+		suffix := trimCompressed(strings.TrimPrefix(p, prefix))
+		_, err := time.Parse(rotatedTime, suffix)
+		return err != nil
+	})
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// compresses reports whether rotated files are compressed.
+func (r *rotatingFile) compresses() bool {
+	return r.compression != config.CompressionNone && r.compression != ""
+}
+
+func (r *rotatingFile) compress(path string) error {
+	if err := compressFile(path, r.compression); err != nil {
+		return err
+	}
+	r.m.compressed.Inc()
+	return nil
+}
+
+func (r *rotatingFile) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// copyFile copies src to a new file dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
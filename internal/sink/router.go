@@ -0,0 +1,240 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// Route outcomes, the result label of loggen_route_records_total.
+const (
+	routeDelivered  = "delivered"
+	routeFailed     = "failed"
+	routeFiltered   = "filtered"
+	routeSampledOut = "sampled_out"
+)
+
+// Router fans records out to sinks. Each sink has a route that selects its
+// records and a queue drained by its own goroutine, so a slow or failing
+// sink delays only its own records. What happens once a queue is full
+// depends on its overflow policy: only the block policy makes the loop,
+// and with it the other sinks, wait.
+type Router struct {
+	routes  []*route
+	metrics *metrics.Registry
+}
+
+// route delivers the records its filter selects to one sink.
+type route struct {
+	name   string
+	sink   Sink
+	filter *config.Route
+
+	// sample is the hash threshold below which records pass, or
+	// math.MaxUint64 for every record.
+	sample uint64
+
+	queue *queue
+	done  chan struct{}
+
+	// err is the error of the last failed delivery, returned by the next
+	// Write or Close since no caller was waiting for it.
+	mu  sync.Mutex
+	err error
+
+	delivered  *metrics.Counter
+	failed     *metrics.Counter
+	filtered   *metrics.Counter
+	sampledOut *metrics.Counter
+}
+
+// NewRouter creates a router without sinks. Its routes are counted in reg.
+func NewRouter(reg *metrics.Registry) *Router {
+	return &Router{metrics: reg}
+}
+
+// Add routes the records selected by filter to s through a queue built
+// from spec. The router then owns and closes s. A nil filter selects every
+// record and a nil spec means the queue defaults. Add must not be called
+// after the first Write.
+func (r *Router) Add(name string, s Sink, filter *config.Route, spec *config.Queue) {
+	counter := func(result string) *metrics.Counter {
+		return r.metrics.Counter("loggen_route_records_total", "Records offered to each sink's route, by outcome.", "route", name, "result", result)
+	}
+	rt := &route{
+		name:       name,
+		sink:       s,
+		filter:     filter,
+		sample:     math.MaxUint64,
+		queue:      newQueue(name, spec, r.metrics),
+		done:       make(chan struct{}),
+		delivered:  counter(routeDelivered),
+		failed:     counter(routeFailed),
+		filtered:   counter(routeFiltered),
+		sampledOut: counter(routeSampledOut),
+	}
+	if filter != nil && filter.Sample > 0 && filter.Sample < 1 {
+		rt.sample = uint64(filter.Sample * (1 << 64))
+	}
+	r.routes = append(r.routes, rt)
+	go rt.run()
+}
+
+// Write implements Sink. It queues rec for every sink whose route selects
+// it and returns without waiting for delivery. Delivery errors since the
+// previous Write are returned instead.
+func (r *Router) Write(rec *record.Record) error {
+	var errs []error
+	for _, rt := range r.routes {
+		if err := rt.takeErr(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", rt.name, err))
+		}
+		rt.offer(rec)
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements Sink. It waits for every queued record to be delivered,
+// then closes the sinks.
+func (r *Router) Close() error {
+	for _, rt := range r.routes {
+		rt.queue.close()
+	}
+	var errs []error
+	for _, rt := range r.routes {
+		<-rt.done
+		if err := rt.takeErr(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", rt.name, err))
+		}
+		if err := rt.sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", rt.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// offer queues rec if the route selects it. Records the queue drops are
+// counted by the queue, in loggen_queue_dropped_total.
+func (rt *route) offer(rec *record.Record) {
+	if !rt.matches(rec) {
+		rt.filtered.Inc()
+		return
+	}
+	if !rt.sampled(rec) {
+		rt.sampledOut.Inc()
+		return
+	}
+	rt.queue.push(rec)
+}
+
+// matches reports whether rec meets the level and field conditions.
+func (rt *route) matches(rec *record.Record) bool {
+	if rt.filter == nil {
+		return true
+	}
+	if rt.filter.MinLevel != nil && rec.Level < *rt.filter.MinLevel {
+		return false
+	}
+	for key, values := range rt.filter.Fields {
+		if !fieldIn(rec, key, values) {
+			return false
+		}
+	}
+	return true
+}
+
+// sampled reports whether rec falls in the route's sample. Records without
+// a count cannot be picked consistently and always pass.
+func (rt *route) sampled(rec *record.Record) bool {
+	if rt.sample == math.MaxUint64 {
+		return true
+	}
+	count, ok := recordCount(rec)
+	if !ok {
+		return true
+	}
+	return mix64(count) < rt.sample
+}
+
+func (rt *route) run() {
+	defer close(rt.done)
+	for {
+		rec, ok := rt.queue.pop()
+		if !ok {
+			return
+		}
+		if err := rt.sink.Write(rec); err != nil {
+			rt.failed.Inc()
+			rt.mu.Lock()
+			rt.err = err
+			rt.mu.Unlock()
+			continue
+		}
+		rt.delivered.Inc()
+	}
+}
+
+func (rt *route) takeErr() error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	err := rt.err
+	rt.err = nil
+	return err
+}
+
+// fieldIn reports whether the top-level field key of rec holds one of
+// values.
+func fieldIn(rec *record.Record, key string, values []string) bool {
+	for _, f := range rec.Fields {
+		if f.Key != key {
+			continue
+		}
+		text, ok := scalarText(f.Value)
+		if !ok {
+			return false
+		}
+		for _, v := range values {
+			if text == v {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// recordCount returns the count field of rec.
+func recordCount(rec *record.Record) (uint64, bool) {
+	for _, f := range rec.Fields {
+		if f.Key != "count" {
+			continue
+		}
+		switch v := f.Value.(type) {
+		case uint64:
+			return v, true
+		case int64:
+			return uint64(v), true
+		case int:
+			return uint64(v), true
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+// mix64 is the SplitMix64 finalizer. It spreads consecutive counts evenly
+// over the uint64 range, so comparing against a threshold picks a stable
+// fraction of them.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
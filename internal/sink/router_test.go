@@ -0,0 +1,234 @@
+package sink
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// collector is a sink that keeps the count of every record it receives.
+// With gate set, each Write first signals entered and waits for gate.
+type collector struct {
+	gate    chan struct{}
+	entered chan struct{}
+
+	mu     sync.Mutex
+	counts []uint64
+	closed bool
+}
+
+func (c *collector) Write(rec *record.Record) error {
+	if c.gate != nil {
+		c.entered <- struct{}{}
+		<-c.gate
+	}
+	count, _ := recordCount(rec)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = append(c.counts, count)
+	return nil
+}
+
+func (c *collector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *collector) received() []uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]uint64(nil), c.counts...)
+}
+
+func routeCounter(reg *metrics.Registry, route, result string) float64 {
+	return reg.Counter("loggen_route_records_total", "", "route", route, "result", result).Value()
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRouter_Filters(t *testing.T) {
+	errorLevel := zapcore.ErrorLevel
+	routes := map[string]*config.Route{
+		"all":      nil,
+		"errors":   {MinLevel: &errorLevel, Sample: 1},
+		"fields":   {Fields: map[string][]string{"random_string": {"alpha", "beta"}}, Sample: 1},
+		"both":     {MinLevel: &errorLevel, Fields: map[string][]string{"random_string": {"alpha"}}, Sample: 1},
+		"sample10": {Sample: 0.1},
+		"sample1":  {Sample: 0.01},
+	}
+	strs := []string{"alpha", "beta", "gamma", "delta"}
+	const n = 10000
+
+	reg := metrics.NewRegistry()
+	r := NewRouter(reg)
+	sinks := map[string]*collector{}
+	for name, filter := range routes {
+		sinks[name] = &collector{}
+		r.Add(name, sinks[name], filter, &config.Queue{Size: n})
+	}
+	for i := range uint64(n) {
+		rec := newRecord(i + 1)
+		if i%10 == 0 {
+			rec.Level = zapcore.ErrorLevel
+		}
+		rec.Fields = append(rec.Fields, record.Field{Key: "random_string", Value: strs[i%4]})
+		if err := r.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := map[string]int{"all": n, "errors": n / 10, "fields": n / 2, "both": n / 20}
+	for name, w := range want {
+		if got := len(sinks[name].received()); got != w {
+			t.Errorf("%s received %d records, want %d", name, got, w)
+		}
+		if got := routeCounter(reg, name, routeFiltered); got != float64(n-w) {
+			t.Errorf("%s filtered %v records, want %d", name, got, n-w)
+		}
+	}
+	for name, s := range sinks {
+		if !s.closed {
+			t.Errorf("%s not closed", name)
+		}
+		if got := routeCounter(reg, name, routeDelivered); got != float64(len(s.received())) {
+			t.Errorf("%s delivered %v, received %d", name, got, len(s.received()))
+		}
+	}
+
+	// Sampling keeps about the configured fraction, and a smaller sample
+	// is a subset of a larger one since both hash the same counts.
+	ten, one := sinks["sample10"].received(), sinks["sample1"].received()
+	if len(ten) < 900 || len(ten) > 1100 {
+		t.Errorf("10%% sample kept %d of %d", len(ten), n)
+	}
+	if len(one) < 60 || len(one) > 140 {
+		t.Errorf("1%% sample kept %d of %d", len(one), n)
+	}
+	inTen := map[uint64]bool{}
+	for _, c := range ten {
+		inTen[c] = true
+	}
+	for _, c := range one {
+		if !inTen[c] {
+			t.Errorf("count %d in the 1%% sample but not the 10%% sample", c)
+		}
+	}
+	if got := routeCounter(reg, "sample10", routeSampledOut); got != float64(n-len(ten)) {
+		t.Errorf("sampled_out = %v, want %d", got, n-len(ten))
+	}
+}
+
+func TestRouter_SampleDeterministic(t *testing.T) {
+	pick := func() []uint64 {
+		r := NewRouter(metrics.NewRegistry())
+		c := &collector{}
+		r.Add("loki", c, &config.Route{Sample: 0.05}, &config.Queue{Size: 1000})
+		for i := range uint64(1000) {
+			_ = r.Write(newRecord(i))
+		}
+		_ = r.Close()
+		return c.received()
+	}
+	a, b := pick(), pick()
+	if len(a) == 0 || !slices.Equal(a, b) {
+		t.Errorf("runs picked %v and %v, want the same records", a, b)
+	}
+
+	// Records without a count cannot be sampled and always pass.
+	r := NewRouter(metrics.NewRegistry())
+	c := &collector{}
+	r.Add("loki", c, &config.Route{Sample: 0.01}, &config.Queue{Size: 10})
+	_ = r.Write(&record.Record{Time: t0, Raw: "no count"})
+	_ = r.Close()
+	if len(c.received()) != 1 {
+		t.Errorf("record without count was sampled out")
+	}
+}
+
+func TestRouter_Backpressure(t *testing.T) {
+	reg := metrics.NewRegistry()
+	r := NewRouter(reg)
+	slow := &collector{gate: make(chan struct{}), entered: make(chan struct{}, 16)}
+	fast := &collector{}
+	r.Add("slow", slow, nil, &config.Queue{Size: 4})
+	r.Add("fast", fast, nil, &config.Queue{Size: 64})
+
+	// The first record holds the slow sink; four more fill its buffer and
+	// the rest are dropped for it alone.
+	_ = r.Write(newRecord(1))
+	<-slow.entered
+	for i := range uint64(10) {
+		if err := r.Write(newRecord(i + 2)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	eventually(t, func() bool { return len(fast.received()) == 11 })
+
+	if got := dropCounter(reg, "slow", dropFull); got != 6 {
+		t.Errorf("slow dropped %v records, want 6", got)
+	}
+	if got := dropCounter(reg, "fast", dropFull); got != 0 {
+		t.Errorf("fast dropped %v records, want 0", got)
+	}
+
+	close(slow.gate)
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := slow.received(); len(got) != 5 || got[4] != 5 {
+		t.Errorf("slow received %v, want records 1 to 5", got)
+	}
+}
+
+func TestRouter_Errors(t *testing.T) {
+	reg := metrics.NewRegistry()
+	r := NewRouter(reg)
+	bad := &failing{}
+	good := &collector{}
+	r.Add("bad", bad, nil, &config.Queue{Size: 4})
+	r.Add("good", good, nil, &config.Queue{Size: 4})
+
+	if err := r.Write(newRecord(1)); err != nil {
+		t.Fatalf("first Write() error = %v", err)
+	}
+	eventually(t, func() bool { return routeCounter(reg, "bad", routeFailed) == 1 })
+
+	// The failure is reported by the next write, which still reaches the
+	// healthy sink.
+	err := r.Write(newRecord(2))
+	if err == nil || !strings.Contains(err.Error(), "sink bad: boom") {
+		t.Errorf("second Write() error = %v, want sink bad: boom", err)
+	}
+	err = r.Close()
+	if err == nil || !strings.Contains(err.Error(), "sink bad: boom") {
+		t.Errorf("Close() error = %v, want the failure of the last record", err)
+	}
+	if !bad.closed || !good.closed {
+		t.Error("sinks not closed")
+	}
+	if got := good.received(); len(got) != 2 {
+		t.Errorf("good received %v, want 2 records", got)
+	}
+}
@@ -0,0 +1,189 @@
+// Package sink writes generated records to their destinations: stdout,
+// rotating files, container logs, raw sockets, Fluent forward inputs,
+// syslog, journald, Kafka, and the Loki, Elasticsearch and Splunk HTTP
+// APIs. Each sink encodes records in its own format, and a Router hands
+// each sink the records its route selects.
+package sink
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/shape"
+)
+
+// Sink is a destination for generated records. Records with Raw set are
+// written as they are; every other record is encoded by the sink.
+type Sink interface {
+	Write(rec *record.Record) error
+	Close() error
+}
+
+// options holds the settings shared by every sink.
+type options struct {
+	stdout  io.Writer
+	encOpts []encode.Option
+	metrics *metrics.Registry
+}
+
+// Option customises the sinks built by Open.
+type Option func(*options)
+
+// WithStdout sets the writer of stdout sinks. It defaults to os.Stdout;
+// pass a locked writer so the lines of several stdout sinks never
+// interleave.
+func WithStdout(w io.Writer) Option {
+	return func(o *options) {
+		o.stdout = w
+	}
+}
+
+// WithEncoderOptions passes opts to the encoder of every sink.
+func WithEncoderOptions(opts ...encode.Option) Option {
+	return func(o *options) {
+		o.encOpts = append(o.encOpts, opts...)
+	}
+}
+
+// WithMetrics registers the sink metrics, such as file rotations, in reg.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(o *options) {
+		o.metrics = reg
+	}
+}
+
+// Open builds the sinks declared in specs behind a Router, each with the
+// route and queue of its spec. If any sink fails to open, the ones already opened
+// are closed.
+func Open(specs []config.Sink, opts ...Option) (*Router, error) {
+	o := options{stdout: os.Stdout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.metrics == nil {
+		o.metrics = metrics.NewRegistry()
+	}
+
+	router := NewRouter(o.metrics)
+	for _, spec := range specs {
+		s, err := open(spec, &o)
+		if err != nil {
+			_ = router.Close()
+			return nil, fmt.Errorf("sink %s: %w", spec.Name, err)
+		}
+		router.Add(spec.Name, s, spec.Route, spec.Queue)
+	}
+	return router, nil
+}
+
+func open(spec config.Sink, o *options) (Sink, error) {
+	format, err := encode.New(spec.Format, o.encOpts...)
+	if err != nil {
+		return nil, err
+	}
+	enc := &meteredEncoder{Encoder: format, meter: shape.NewMeter(o.metrics, "sink", spec.Name)}
+	switch spec.Type {
+	case config.SinkStdout:
+		return NewWriter(o.stdout, enc), nil
+	case config.SinkCRI, config.SinkDocker:
+		return NewContainer(spec, enc, o.metrics)
+	case config.SinkFile:
+		return NewFile(spec, enc, o.metrics)
+	case config.SinkForward:
+		return NewForward(spec, enc, o.metrics)
+	case config.SinkSyslog:
+		return NewSyslog(spec, enc, o.metrics)
+	case config.SinkLoki:
+		return NewLoki(spec, enc, o.metrics)
+	case config.SinkElasticsearch:
+		return NewElasticsearch(spec, enc, o.metrics)
+	case config.SinkSplunk:
+		return NewSplunk(spec, enc, o.metrics)
+	case config.SinkKafka:
+		return NewKafka(spec, enc, o.metrics)
+	case config.SinkSocket:
+		return NewSocket(spec, enc, o.metrics)
+	case config.SinkJournald:
+		return NewJournald(spec, enc, o.metrics)
+	default:
+		return nil, fmt.Errorf("unknown type %q", spec.Type)
+	}
+}
+
+// Line returns rec as a line without the trailing newline: Raw as it is,
+// or the record encoded by enc. Lines of the sinks built by Open are
+// counted in the output metrics of their sink.
+func Line(enc encode.Encoder, rec *record.Record) ([]byte, error) {
+	m, metered := enc.(*meteredEncoder)
+	if metered {
+		enc = m.Encoder
+	}
+	line, err := encodeLine(enc, rec)
+	if err != nil {
+		return nil, err
+	}
+	if metered {
+		m.meter.Observe(len(line))
+	}
+	return line, nil
+}
+
+// observe counts a record of n bytes that a sink encoded without Line.
+func observe(enc encode.Encoder, n int) {
+	if m, ok := enc.(*meteredEncoder); ok {
+		m.meter.Observe(n)
+	}
+}
+
+func encodeLine(enc encode.Encoder, rec *record.Record) ([]byte, error) {
+	if rec.Raw != "" {
+		return []byte(rec.Raw), nil
+	}
+	line, err := enc.Encode(rec)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+// meteredEncoder is the encoder Open hands to a sink, so Line can count
+// the sink's records whether they are Raw or encoded.
+type meteredEncoder struct {
+	encode.Encoder
+	meter *shape.Meter
+}
+
+// Writer writes one line per record to an io.Writer.
+type Writer struct {
+	w   io.Writer
+	enc encode.Encoder
+}
+
+// NewWriter creates a sink writing records encoded by enc to w. Closing it
+// does not close w.
+func NewWriter(w io.Writer, enc encode.Encoder) *Writer {
+	return &Writer{w: w, enc: enc}
+}
+
+// Write implements Sink. Each record is written with a single call.
+func (s *Writer) Write(rec *record.Record) error {
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close implements Sink.
+func (s *Writer) Close() error {
+	return nil
+}
@@ -0,0 +1,188 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+var t0 = time.Date(2026, 2, 18, 12, 0, 0, 123456789, time.UTC)
+
+func newRecord(count uint64) *record.Record {
+	return &record.Record{
+		Time:    t0,
+		Level:   zapcore.InfoLevel,
+		Message: "tick",
+		Fields:  []record.Field{{Key: "count", Value: count}},
+	}
+}
+
+// testSpec returns the settings every sink test shares: a one second
+// timeout, and batches that go out only when full or on Close. Each test
+// file adds the settings of its own sink.
+func testSpec(name, typ, format string) config.Sink {
+	return config.Sink{
+		Name:      name,
+		Type:      typ,
+		Format:    format,
+		BatchSize: 100,
+		BatchWait: config.Duration(time.Hour),
+		Timeout:   config.Duration(time.Second),
+	}
+}
+
+// newSink builds a sink for spec with build, which is one of the sink
+// constructors, and a registry of its own.
+func newSink[S Sink](t *testing.T, spec config.Sink, build func(config.Sink, encode.Encoder, *metrics.Registry) (S, error)) (S, *metrics.Registry) {
+	t.Helper()
+	reg := metrics.NewRegistry()
+	s, err := build(spec, mustEncoder(t, spec.Format), reg)
+	if err != nil {
+		t.Fatalf("creating %s sink: %v", spec.Type, err)
+	}
+	return s, reg
+}
+
+// fakeServer is embedded by the fake HTTP destinations: the test their
+// handler reports to and the lock guarding what they received.
+type fakeServer struct {
+	t  *testing.T
+	mu sync.Mutex
+}
+
+// newHTTPSink serves h on a test server and builds a sink for spec with
+// build, its URL pointing at path on the server. configure, if not nil,
+// adjusts spec for one test.
+func newHTTPSink[S Sink](t *testing.T, h http.Handler, path string, spec config.Sink, build func(config.Sink, encode.Encoder, *metrics.Registry) (S, error), configure func(*config.Sink)) (S, *metrics.Registry) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	spec.URL = srv.URL + path
+	if configure != nil {
+		configure(&spec)
+	}
+	return newSink(t, spec, build)
+}
+
+// sinkCounter returns the value of a counter labelled with the sink name.
+func sinkCounter(reg *metrics.Registry, metric, sink string) float64 {
+	return reg.Counter(metric, "", "sink", sink).Value()
+}
+
+func mustEncoder(t *testing.T, format string) encode.Encoder {
+	t.Helper()
+	enc, err := encode.New(format, encode.WithHost("node-1"))
+	if err != nil {
+		t.Fatalf("encode.New() error = %v", err)
+	}
+	return enc
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewWriter(&buf, mustEncoder(t, encode.FormatLogfmt))
+	if err := s.Write(newRecord(1)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := s.Write(&record.Record{Time: t0, Raw: "127.0.0.1 - - raw line"}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	want := "ts=2026-02-18T12:00:00.123456789Z level=info msg=tick count=1\n127.0.0.1 - - raw line\n"
+	if buf.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", buf.String(), want)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+// failing is a sink that always fails.
+type failing struct{ closed bool }
+
+func (f *failing) Write(*record.Record) error { return errors.New("boom") }
+func (f *failing) Close() error               { f.closed = true; return nil }
+
+func TestOpen(t *testing.T) {
+	var stdout bytes.Buffer
+	dir := t.TempDir()
+	specs := []config.Sink{
+		{Name: "console", Type: config.SinkStdout, Format: encode.FormatPlain},
+		{Name: "pod", Type: config.SinkCRI, Format: encode.FormatJSON, Path: filepath.Join(dir, "pod", "0.log")},
+	}
+
+	s, err := Open(specs, WithStdout(&stdout))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if len(s.routes) != 2 {
+		t.Errorf("Open() of two sinks has %d routes", len(s.routes))
+	}
+	if err := s.Write(newRecord(1)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !strings.HasPrefix(stdout.String(), "2026-02-18T12:00:00.123Z INFO tick count=1") {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+
+	bad := []config.Sink{
+		specs[0],
+		{Name: "blocked", Type: config.SinkCRI, Format: encode.FormatJSON, Path: filepath.Join(dir, "pod", "0.log", "x")},
+	}
+	if _, err := Open(bad); err == nil || !strings.Contains(err.Error(), "sink blocked") {
+		t.Errorf("Open() error = %v, want failure naming the sink", err)
+	}
+}
+
+func TestOpen_Metering(t *testing.T) {
+	var stdout bytes.Buffer
+	reg := metrics.NewRegistry()
+	errorsOnly := zapcore.ErrorLevel
+	specs := []config.Sink{
+		{Name: "all", Type: config.SinkStdout, Format: encode.FormatPlain},
+		{Name: "errors", Type: config.SinkStdout, Format: encode.FormatPlain, Route: &config.Route{MinLevel: &errorsOnly}},
+	}
+
+	s, err := Open(specs, WithStdout(&stdout), WithMetrics(reg))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for _, rec := range []*record.Record{newRecord(1), {Time: t0, Raw: "127.0.0.1 - - raw line"}} {
+		if err := s.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Sizes exclude the newline each stdout line ends with.
+	text := reg.Text()
+	for _, want := range []string{
+		`loggen_output_records_total{sink="all"} 2` + "\n",
+		fmt.Sprintf(`loggen_output_bytes_total{sink="all"} %d`+"\n", stdout.Len()-2),
+		`loggen_record_size_bytes_count{sink="all"} 2` + "\n",
+		`loggen_output_records_total{sink="errors"} 0` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics missing %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"sync"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// Socket writes each record as a line, in the sink's format, to a TCP or
+// Unix stream socket, or as one datagram holding the line to a UDP or
+// Unix datagram socket. It connects on the first write and reconnects
+// after an error, resending the failed line once, like the syslog sink.
+type Socket struct {
+	enc encode.Encoder
+
+	mu   sync.Mutex
+	conn *netConn
+}
+
+// NewSocket creates a socket sink for spec.
+func NewSocket(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*Socket, error) {
+	return &Socket{
+		enc:  enc,
+		conn: newNetConn(spec.Name, spec.Network, spec.Address, nil, time.Duration(spec.Timeout), reg),
+	}, nil
+}
+
+// Write implements Sink. Each line is sent with a single write, so a
+// datagram never holds part of a record.
+func (s *Socket) Write(rec *record.Record) error {
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.write(append(line, '\n'))
+}
+
+// Close implements Sink.
+func (s *Socket) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.close()
+}
@@ -0,0 +1,192 @@
+package sink
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+)
+
+func newSocket(t *testing.T, network, address string) (*Socket, *metrics.Registry) {
+	t.Helper()
+	spec := testSpec("socket", config.SinkSocket, encode.FormatLogfmt)
+	spec.Network = network
+	spec.Address = address
+	s, reg := newSink(t, spec, NewSocket)
+	t.Cleanup(func() { _ = s.Close() })
+	return s, reg
+}
+
+// socketPath returns a Unix socket path short enough for sun_path.
+func socketPath(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "s.sock")
+}
+
+func TestSocket_Stream(t *testing.T) {
+	for _, network := range []string{config.NetworkTCP, config.NetworkUnix} {
+		t.Run(network, func(t *testing.T) {
+			address := "127.0.0.1:0"
+			if network == config.NetworkUnix {
+				address = socketPath(t)
+			}
+			ln, err := net.Listen(network, address)
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			defer ln.Close()
+			received := acceptAll(t, ln)
+
+			s, _ := newSocket(t, network, ln.Addr().String())
+			for i := range 3 {
+				if err := s.Write(newRecord(uint64(i))); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			raw := newRecord(3)
+			raw.Raw = "raw line"
+			_ = s.Write(raw)
+			_ = s.Close()
+
+			lines := strings.Split(strings.TrimSuffix(<-received, "\n"), "\n")
+			if len(lines) != 4 || lines[3] != "raw line" {
+				t.Fatalf("received %q, want four lines", lines)
+			}
+			if want := "msg=tick count=1"; !strings.Contains(lines[1], want) {
+				t.Errorf("line 1 = %q, want it to contain %q", lines[1], want)
+			}
+		})
+	}
+}
+
+func TestSocket_Datagram(t *testing.T) {
+	for _, network := range []string{config.NetworkUDP, config.NetworkUnixgram} {
+		t.Run(network, func(t *testing.T) {
+			address := "127.0.0.1:0"
+			if network == config.NetworkUnixgram {
+				address = socketPath(t)
+			}
+			pc, err := net.ListenPacket(network, address)
+			if err != nil {
+				t.Fatalf("ListenPacket() error = %v", err)
+			}
+			defer pc.Close()
+
+			s, reg := newSocket(t, network, pc.LocalAddr().String())
+			for i := range 3 {
+				if err := s.Write(newRecord(uint64(i))); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+
+			buf := make([]byte, 2048)
+			for i := range 3 {
+				_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+				n, _, err := pc.ReadFrom(buf)
+				if err != nil {
+					t.Fatalf("ReadFrom() error = %v", err)
+				}
+				got := string(buf[:n])
+				if !strings.HasSuffix(got, "msg=tick count="+strconv.Itoa(i)+"\n") || strings.Count(got, "\n") != 1 {
+					t.Errorf("datagram %d = %q, want one record with its newline", i, got)
+				}
+			}
+			if got := sinkCounter(reg, "loggen_sink_connects_total", "socket"); got != 1 {
+				t.Errorf("connects metric = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestSocket_ReconnectUnixgram(t *testing.T) {
+	path := socketPath(t)
+	pc, err := net.ListenPacket(config.NetworkUnixgram, path)
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	s, reg := newSocket(t, config.NetworkUnixgram, path)
+	if err := s.Write(newRecord(1)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// The receiver restarts, binding a new socket at the same path. The
+	// old connection now fails and the record is resent on a new one.
+	_ = pc.Close()
+	_ = os.Remove(path)
+	pc, err = net.ListenPacket(config.NetworkUnixgram, path)
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer pc.Close()
+	if err := s.Write(newRecord(2)); err != nil {
+		t.Fatalf("Write() after restart error = %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	if got := string(buf[:n]); !strings.Contains(got, "count=2") {
+		t.Errorf("datagram = %q, want the record written after the restart", got)
+	}
+	if got := sinkCounter(reg, "loggen_sink_retries_total", "socket"); got != 1 {
+		t.Errorf("retries metric = %v, want 1", got)
+	}
+}
+
+func TestSocket_ReconnectStream(t *testing.T) {
+	path := socketPath(t)
+	ln, err := net.Listen(config.NetworkUnix, path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	// The first connection is dropped after one line.
+	dropped := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			dropped <- ""
+			return
+		}
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		_ = conn.Close()
+		dropped <- line
+	}()
+
+	s, reg := newSocket(t, config.NetworkUnix, path)
+	if err := s.Write(newRecord(1)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := <-dropped; !strings.Contains(got, "count=1") {
+		t.Fatalf("first connection got %q", got)
+	}
+
+	second := acceptAll(t, ln)
+	retries := reg.Counter("loggen_sink_retries_total", "", "sink", "socket")
+	var last uint64
+	for i := uint64(2); retries.Value() == 0 && i < 100; i++ {
+		if err := s.Write(newRecord(i)); err != nil {
+			t.Fatalf("Write(%d) error = %v", i, err)
+		}
+		last = i
+	}
+	if retries.Value() != 1 {
+		t.Fatalf("retries metric = %v, want 1", retries.Value())
+	}
+	_ = s.Close()
+
+	if got := <-second; !strings.Contains(got, "count="+strconv.FormatUint(last, 10)+"\n") {
+		t.Errorf("second connection got %q, want the resent record %d", got, last)
+	}
+}
@@ -0,0 +1,327 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// Splunk sends batches of records to a Splunk HTTP Event Collector, as
+// events with metadata or as raw lines. Every request carries the sink's
+// channel. With require_ack, the ackId of each accepted batch is polled in
+// the background until the indexers acknowledge it or ack_timeout passes,
+// so records_sent counts what HEC accepted and records_acked what was
+// indexed.
+type Splunk struct {
+	spec     config.Sink
+	enc      encode.Encoder
+	client   *http.Client
+	batch    *batcher[[]byte]
+	hostname string
+	channel  string
+	ackURL   string
+
+	mu      sync.Mutex
+	pending []hecPending
+	pollErr error
+	stop    chan struct{}
+	done    chan struct{}
+
+	acked      *metrics.Counter
+	unacked    *metrics.Counter
+	pendingAck *metrics.Gauge
+}
+
+// hecPending is a batch waiting for its indexer acknowledgement.
+type hecPending struct {
+	id      int64
+	records int
+	sent    time.Time
+}
+
+// hecResponse is the reply of HEC to events and acknowledgement polls.
+type hecResponse struct {
+	Text  string          `json:"text"`
+	Code  int             `json:"code"`
+	AckID *int64          `json:"ackId"`
+	Acks  map[string]bool `json:"acks"`
+}
+
+// NewSplunk creates a Splunk sink for spec.
+func NewSplunk(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*Splunk, error) {
+	client, err := newHTTPClient(spec)
+	if err != nil {
+		return nil, err
+	}
+	ackURL, err := hecAckURL(spec.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Splunk{
+		spec:       spec,
+		enc:        enc,
+		client:     client,
+		hostname:   hostname(),
+		channel:    spec.Channel,
+		ackURL:     ackURL,
+		acked:      reg.Counter("loggen_sink_records_acked_total", "Records the Splunk indexers acknowledged.", "sink", spec.Name),
+		unacked:    reg.Counter("loggen_sink_records_unacked_total", "Records not acknowledged within ack_timeout.", "sink", spec.Name),
+		pendingAck: reg.Gauge("loggen_sink_records_pending_ack", "Records accepted by HEC and waiting for acknowledgement.", "sink", spec.Name),
+	}
+	if s.channel == "" {
+		s.channel = newGUID()
+	}
+	s.batch = newBatcher(spec.Name, spec.BatchSize, time.Duration(spec.BatchWait), s.post, reg)
+
+	if spec.RequireAck {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.pollAcks()
+	}
+	return s, nil
+}
+
+// Write implements Sink. It batches rec as a HEC event, or as a bare line
+// for the raw endpoint.
+func (s *Splunk) Write(rec *record.Record) error {
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return err
+	}
+	if s.spec.Endpoint == config.SplunkRaw {
+		return s.batch.add(line)
+	}
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	event, err := s.event(line, rec.Raw != "", ts)
+	if err != nil {
+		return err
+	}
+	return s.batch.add(event)
+}
+
+// Close implements Sink. It sends the records still batched and, with
+// require_ack, waits up to ack_timeout for the outstanding acks.
+func (s *Splunk) Close() error {
+	err := s.batch.close()
+	if !s.spec.RequireAck {
+		return err
+	}
+
+	close(s.stop)
+	<-s.done
+	deadline := time.Now().Add(time.Duration(s.spec.AckTimeout))
+	for s.pendingRecords() > 0 && time.Now().Before(deadline) {
+		s.poll(time.Now())
+		if s.pendingRecords() > 0 {
+			time.Sleep(time.Duration(s.spec.AckInterval))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.pending {
+		s.unacked.Add(float64(p.records))
+	}
+	s.pending = nil
+	s.pendingAck.Set(0)
+	return errors.Join(err, s.pollErr)
+}
+
+// event renders one /event entry: the record as a JSON object when it is
+// one, otherwise as a string.
+func (s *Splunk) event(line []byte, raw bool, ts time.Time) ([]byte, error) {
+	var event any = string(line)
+	if !raw && len(line) > 0 && line[0] == '{' && json.Valid(line) {
+		event = json.RawMessage(line)
+	}
+	return json.Marshal(struct {
+		Time       json.Number `json:"time"`
+		Host       string      `json:"host"`
+		Source     string      `json:"source,omitempty"`
+		SourceType string      `json:"sourcetype,omitempty"`
+		Index      string      `json:"index,omitempty"`
+		Event      any         `json:"event"`
+	}{
+		Time:       json.Number(strconv.FormatFloat(float64(ts.UnixMicro())/1e6, 'f', 6, 64)),
+		Host:       s.hostname,
+		Source:     s.spec.Source,
+		SourceType: s.spec.SourceType,
+		Index:      s.spec.Index,
+		Event:      event,
+	})
+}
+
+// post sends one batch and remembers its ackId.
+func (s *Splunk) post(items [][]byte) error {
+	target := s.spec.URL
+	if s.spec.Endpoint == config.SplunkRaw {
+		// Raw lines carry no metadata, so it goes in the query.
+		u, err := url.Parse(target)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		q.Set("channel", s.channel)
+		q.Set("host", s.hostname)
+		for key, value := range map[string]string{"source": s.spec.Source, "sourcetype": s.spec.SourceType, "index": s.spec.Index} {
+			if value != "" {
+				q.Set(key, value)
+			}
+		}
+		u.RawQuery = q.Encode()
+		target = u.String()
+	}
+
+	body := bytes.Join(items, []byte("\n"))
+	contentType := "application/json"
+	if s.spec.Endpoint == config.SplunkRaw {
+		contentType = "text/plain"
+	}
+	resp, err := s.request(target, contentType, body)
+	if err != nil {
+		return err
+	}
+	if !s.spec.RequireAck {
+		return nil
+	}
+	if resp.AckID == nil {
+		// HEC accepted the records, so they are not failed, but nothing
+		// can be acknowledged.
+		return &partialError{err: errors.New("HEC returned no ackId: indexer acknowledgement is not enabled for the token")}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, hecPending{id: *resp.AckID, records: len(items), sent: time.Now()})
+	s.pendingAck.Add(float64(len(items)))
+	return nil
+}
+
+// request POSTs body to target with the token and channel.
+func (s *Splunk) request(target, contentType string, body []byte) (*hecResponse, error) {
+	data, err := do(s.client, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Splunk "+s.spec.Token)
+		req.Header.Set("X-Splunk-Request-Channel", s.channel)
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp hecResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decoding HEC response: %w", err)
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("HEC code %d: %s", resp.Code, resp.Text)
+	}
+	return &resp, nil
+}
+
+func (s *Splunk) pollAcks() {
+	defer close(s.done)
+	ticker := time.NewTicker(time.Duration(s.spec.AckInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.poll(now)
+		}
+	}
+}
+
+// poll asks HEC which pending batches are indexed, and gives up on the
+// ones older than ack_timeout.
+func (s *Splunk) poll(now time.Time) {
+	s.mu.Lock()
+	ids := make([]int64, 0, len(s.pending))
+	for _, p := range s.pending {
+		ids = append(ids, p.id)
+	}
+	s.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	body, _ := json.Marshal(map[string][]int64{"acks": ids})
+	resp, err := s.request(s.ackURL, "application/json", body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pollErr = err
+	kept := s.pending[:0]
+	for _, p := range s.pending {
+		switch {
+		case resp != nil && resp.Acks[strconv.FormatInt(p.id, 10)]:
+			s.acked.Add(float64(p.records))
+			s.pendingAck.Add(-float64(p.records))
+		case now.Sub(p.sent) >= time.Duration(s.spec.AckTimeout):
+			s.unacked.Add(float64(p.records))
+			s.pendingAck.Add(-float64(p.records))
+		default:
+			kept = append(kept, p)
+		}
+	}
+	s.pending = kept
+}
+
+func (s *Splunk) pendingRecords() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, p := range s.pending {
+		n += p.records
+	}
+	return n
+}
+
+// hecAckURL returns the acknowledgement endpoint of the HEC endpoint
+// target: /services/collector/ack under the same prefix, whatever follows
+// the collector path, such as /event/1.0. A URL without the collector path,
+// as a proxy may expose, gets ack next to its last element.
+func hecAckURL(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	const collector = "/services/collector"
+	if i := strings.Index(u.Path, collector); i >= 0 {
+		u.Path = u.Path[:i+len(collector)] + "/ack"
+	} else {
+		u.Path = path.Join(path.Dir(u.Path), "ack")
+	}
+	return u.String(), nil
+}
+
+// newGUID returns a random version 4 UUID.
+func newGUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,327 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+const hecToken = "11111111-2222-3333-4444-555555555555"
+
+// fakeHEC is a Splunk HTTP Event Collector. With acks it hands out
+// increasing ackIds and acknowledges a batch on the poll after ackAfter
+// polls, except batches listed in lost.
+type fakeHEC struct {
+	fakeServer
+	acks     bool
+	ackAfter int
+	lost     map[int64]bool
+
+	channels map[string]bool
+	events   []map[string]any
+	raw      []string
+	query    []string
+	nextAck  int64
+	polls    map[int64]int
+}
+
+func newFakeHEC(t *testing.T, acks bool) *fakeHEC {
+	return &fakeHEC{fakeServer: fakeServer{t: t}, acks: acks, lost: map[int64]bool{}, channels: map[string]bool{}, polls: map[int64]int{}}
+}
+
+func (f *fakeHEC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Splunk "+hecToken {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"text":"Invalid token","code":4}`))
+		return
+	}
+	channel := r.Header.Get("X-Splunk-Request-Channel")
+	if f.acks && channel == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"text":"Data channel is missing","code":10}`))
+		return
+	}
+	f.channels[channel] = true
+
+	// The versioned paths, such as /services/collector/event/1.0, are the
+	// same endpoints.
+	endpoint := strings.TrimSuffix(r.URL.Path, "/1.0")
+	wantType := "application/json"
+	if endpoint == "/services/collector/raw" {
+		wantType = "text/plain"
+	}
+	if got := r.Header.Get("Content-Type"); got != wantType {
+		f.t.Errorf("%s Content-Type = %q, want %q", r.URL.Path, got, wantType)
+	}
+
+	switch endpoint {
+	case "/services/collector/event":
+		dec := json.NewDecoder(r.Body)
+		for {
+			var e map[string]any
+			if err := dec.Decode(&e); err == io.EOF {
+				break
+			} else if err != nil {
+				f.t.Errorf("decoding event: %v", err)
+				return
+			}
+			f.events = append(f.events, e)
+		}
+	case "/services/collector/raw":
+		body, _ := io.ReadAll(r.Body)
+		f.raw = append(f.raw, strings.Split(string(body), "\n")...)
+		f.query = append(f.query, r.URL.RawQuery)
+	case "/services/collector/ack":
+		var req struct {
+			Acks []int64 `json:"acks"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			f.t.Errorf("decoding ack request: %v", err)
+		}
+		acks := map[string]bool{}
+		for _, id := range req.Acks {
+			f.polls[id]++
+			acks[strconv.FormatInt(id, 10)] = !f.lost[id] && f.polls[id] > f.ackAfter
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"acks": acks})
+		return
+	default:
+		f.t.Errorf("unexpected path %s", r.URL.Path)
+	}
+
+	resp := map[string]any{"text": "Success", "code": 0}
+	if f.acks {
+		resp["ackId"] = f.nextAck
+		f.nextAck++
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func newSplunk(t *testing.T, f http.Handler, configure func(*config.Sink)) (*Splunk, *metrics.Registry) {
+	t.Helper()
+	spec := testSpec("hec", config.SinkSplunk, encode.FormatJSON)
+	spec.Endpoint = config.SplunkEvent
+	spec.Token = hecToken
+	spec.SourceType = "_json"
+	spec.Index = "loggen"
+	spec.BatchSize = 2
+	s, reg := newHTTPSink(t, f, "/services/collector/event", spec, NewSplunk, configure)
+	s.hostname = "node-1"
+	return s, reg
+}
+
+func TestSplunk_Event(t *testing.T) {
+	f := newFakeHEC(t, false)
+	s, reg := newSplunk(t, f, nil)
+
+	raw := newRecord(3)
+	raw.Raw = `10.0.0.1 - - "GET / HTTP/1.1" 200`
+	for _, rec := range []*record.Record{newRecord(1), newRecord(2), raw} {
+		if err := s.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if len(f.events) != 3 {
+		t.Fatalf("got %d events, want 3", len(f.events))
+	}
+	e := f.events[0]
+	if e["time"] != 1771416000.123456 || e["host"] != "node-1" || e["sourcetype"] != "_json" || e["index"] != "loggen" {
+		t.Errorf("event metadata = %v", e)
+	}
+	if event, _ := e["event"].(map[string]any); event["msg"] != "tick" || event["count"] != float64(1) {
+		t.Errorf("event = %v, want the record object", e["event"])
+	}
+	if f.events[2]["event"] != raw.Raw {
+		t.Errorf("raw event = %v, want the line as a string", f.events[2]["event"])
+	}
+	if len(f.channels) != 1 {
+		t.Errorf("channels = %v, want one per sink", f.channels)
+	}
+	for ch := range f.channels {
+		if !validTestGUID(ch) {
+			t.Errorf("channel = %q, want a GUID", ch)
+		}
+	}
+	if got := sinkCounter(reg, "loggen_sink_records_sent_total", "hec"); got != 3 {
+		t.Errorf("records sent metric = %v, want 3", got)
+	}
+}
+
+func validTestGUID(s string) bool {
+	parts := strings.Split(s, "-")
+	return len(s) == 36 && len(parts) == 5 && len(parts[2]) == 4 && parts[2][0] == '4'
+}
+
+func TestSplunk_Raw(t *testing.T) {
+	f := newFakeHEC(t, false)
+	s, _ := newSplunk(t, f, func(spec *config.Sink) {
+		spec.Endpoint = config.SplunkRaw
+		spec.URL = strings.Replace(spec.URL, "/event", "/raw", 1)
+		spec.Format = encode.FormatLogfmt
+		spec.Source = "loggen"
+		spec.Channel = "0aeeac95-ac74-4aa9-b30d-6c4c0ac581ba"
+	})
+
+	for i := range 2 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if len(f.raw) != 2 || !strings.Contains(f.raw[1], "msg=tick count=1") {
+		t.Errorf("raw lines = %q, want two logfmt records", f.raw)
+	}
+	want := "channel=0aeeac95-ac74-4aa9-b30d-6c4c0ac581ba&host=node-1&index=loggen&source=loggen&sourcetype=_json"
+	if len(f.query) != 1 || f.query[0] != want {
+		t.Errorf("query = %q, want %q", f.query, want)
+	}
+}
+
+func TestSplunk_Acks(t *testing.T) {
+	f := newFakeHEC(t, true)
+	f.ackAfter = 1
+	s, reg := newSplunk(t, f, func(spec *config.Sink) {
+		// Acks are polled at /services/collector/ack, not next to the
+		// versioned endpoint.
+		spec.URL += "/1.0"
+		spec.RequireAck = true
+		spec.AckInterval = config.Duration(10 * time.Millisecond)
+		spec.AckTimeout = config.Duration(5 * time.Second)
+	})
+
+	for i := range 5 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if got := reg.Gauge("loggen_sink_records_pending_ack", "", "sink", "hec").Value(); got > 4 {
+		t.Errorf("pending ack gauge = %v, want at most the 4 records sent so far", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := sinkCounter(reg, "loggen_sink_records_acked_total", "hec"); got != 5 {
+		t.Errorf("records acked metric = %v, want 5", got)
+	}
+	if got := sinkCounter(reg, "loggen_sink_records_unacked_total", "hec"); got != 0 {
+		t.Errorf("records unacked metric = %v, want 0", got)
+	}
+	if got := reg.Gauge("loggen_sink_records_pending_ack", "", "sink", "hec").Value(); got != 0 {
+		t.Errorf("pending ack gauge after close = %v, want 0", got)
+	}
+	for id := range int64(3) {
+		if f.polls[id] < 2 {
+			t.Errorf("batch %d polled %d times, want until acknowledged", id, f.polls[id])
+		}
+	}
+}
+
+func TestSplunk_AckTimeout(t *testing.T) {
+	f := newFakeHEC(t, true)
+	f.lost[1] = true
+	s, reg := newSplunk(t, f, func(spec *config.Sink) {
+		spec.RequireAck = true
+		spec.AckInterval = config.Duration(5 * time.Millisecond)
+		spec.AckTimeout = config.Duration(50 * time.Millisecond)
+	})
+
+	for i := range 4 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	start := time.Now()
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if waited := time.Since(start); waited > 2*time.Second {
+		t.Errorf("Close() waited %v, want about ack_timeout", waited)
+	}
+
+	acked, unacked := sinkCounter(reg, "loggen_sink_records_acked_total", "hec"), sinkCounter(reg, "loggen_sink_records_unacked_total", "hec")
+	if acked != 2 || unacked != 2 {
+		t.Errorf("acked, unacked = %v, %v, want 2, 2", acked, unacked)
+	}
+}
+
+func TestSplunk_NoAckID(t *testing.T) {
+	// HEC accepts the batch but the token has acknowledgement disabled.
+	f := newFakeHEC(t, false)
+	s, reg := newSplunk(t, f, func(spec *config.Sink) {
+		spec.RequireAck = true
+		spec.AckInterval = config.Duration(10 * time.Millisecond)
+		spec.AckTimeout = config.Duration(time.Second)
+	})
+
+	_ = s.Write(newRecord(1))
+	err := s.Write(newRecord(2))
+	var pe *partialError
+	if !errors.As(err, &pe) || !strings.Contains(err.Error(), "no ackId") {
+		t.Errorf("Write() error = %v, want a missing ackId error", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if sent, failed := sinkCounter(reg, "loggen_sink_records_sent_total", "hec"), sinkCounter(reg, "loggen_sink_records_failed_total", "hec"); sent != 2 || failed != 0 {
+		t.Errorf("sent, failed = %v, %v, want 2, 0", sent, failed)
+	}
+}
+
+func TestSplunk_Rejected(t *testing.T) {
+	f := newFakeHEC(t, false)
+	s, reg := newSplunk(t, f, func(spec *config.Sink) { spec.Token = "wrong" })
+
+	_ = s.Write(newRecord(1))
+	err := s.Write(newRecord(2))
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden") || !strings.Contains(err.Error(), "Invalid token") {
+		t.Errorf("Write() error = %v, want the HEC rejection", err)
+	}
+	_ = s.Close()
+	if got := sinkCounter(reg, "loggen_sink_records_failed_total", "hec"); got != 2 {
+		t.Errorf("records failed metric = %v, want 2", got)
+	}
+}
+
+func TestHECAckURL(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"https://splunk:8088/services/collector/event", "https://splunk:8088/services/collector/ack"},
+		{"https://splunk:8088/services/collector/event/1.0", "https://splunk:8088/services/collector/ack"},
+		{"https://splunk:8088/services/collector/raw/1.0", "https://splunk:8088/services/collector/ack"},
+		{"https://splunk:8088/services/collector", "https://splunk:8088/services/collector/ack"},
+		{"https://proxy/splunk/services/collector/raw", "https://proxy/splunk/services/collector/ack"},
+		{"https://proxy/hec/event", "https://proxy/hec/ack"},
+	}
+	for _, tt := range tests {
+		got, err := hecAckURL(tt.target)
+		if err != nil {
+			t.Fatalf("hecAckURL(%q) error = %v", tt.target, err)
+		}
+		if got != tt.want {
+			t.Errorf("hecAckURL(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package sink
+
+import (
+	"crypto/tls"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+// sdID is the RFC 5424 structured data ID of the record fields. 32473 is
+// the private enterprise number reserved for documentation (RFC 5612).
+const sdID = "loggen@32473"
+
+// Syslog sends records as RFC 5424 or RFC 3164 messages over UDP, TCP or
+// TLS. The message text is the record encoded in the sink's format; RFC
+// 5424 messages also carry the top-level scalar fields, such as count,
+// random_number and random_string, as structured data. The severity
+// follows transform.lua: dpanic, panic and fatal are critical.
+type Syslog struct {
+	spec     config.Sink
+	enc      encode.Encoder
+	facility int
+	hostname string
+	procID   string
+
+	mu   sync.Mutex
+	conn *netConn
+	buf  []byte
+}
+
+// NewSyslog creates a syslog sink for spec. Like the forward sink it
+// connects on the first write.
+func NewSyslog(spec config.Sink, enc encode.Encoder, reg *metrics.Registry) (*Syslog, error) {
+	network := spec.Network
+	var tlsConfig *tls.Config
+	if network == config.NetworkTLS {
+		var err error
+		if tlsConfig, err = tlsClientConfig(spec); err != nil {
+			return nil, err
+		}
+		network = config.NetworkTCP
+	}
+	facility, _ := encode.SyslogFacility(spec.Facility)
+	return &Syslog{
+		spec:     spec,
+		enc:      enc,
+		facility: facility,
+		hostname: hostname(),
+		procID:   strconv.Itoa(os.Getpid()),
+		conn:     newNetConn(spec.Name, network, spec.Address, tlsConfig, time.Duration(spec.Timeout), reg),
+	}, nil
+}
+
+// Write implements Sink.
+func (s *Syslog) Write(rec *record.Record) error {
+	line, err := Line(s.enc, rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = s.frame(s.buf[:0], rec, line)
+	return s.conn.write(s.buf)
+}
+
+// Close implements Sink.
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.close()
+}
+
+// frame appends the message for rec, framed for the sink's network, to b.
+func (s *Syslog) frame(b []byte, rec *record.Record, line []byte) []byte {
+	switch s.spec.Framing {
+	case config.FramingOctetCounting:
+		msg := s.message(nil, rec, line)
+		b = strconv.AppendInt(b, int64(len(msg)), 10)
+		b = append(b, ' ')
+		return append(b, msg...)
+	case config.FramingNewline:
+		// A newline would end the message early, so embedded ones are
+		// escaped the way rsyslog shows control characters.
+		start := len(b)
+		b = s.message(b, rec, line)
+		escaped := strings.ReplaceAll(string(b[start:]), "\n", "#012")
+		return append(append(b[:start], escaped...), '\n')
+	default:
+		return s.message(b, rec, line)
+	}
+}
+
+// message appends the syslog message for rec, without framing, to b.
+func (s *Syslog) message(b []byte, rec *record.Record, line []byte) []byte {
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	b = append(b, '<')
+	b = strconv.AppendInt(b, int64(s.facility*8+encode.SyslogSeverity(rec.Level)), 10)
+	b = append(b, '>')
+
+	if s.spec.Protocol == config.SyslogRFC3164 {
+		b = ts.AppendFormat(b, time.Stamp)
+		b = append(b, ' ')
+		b = append(b, s.hostname...)
+		b = append(b, ' ')
+		b = append(b, s.spec.Tag...)
+		b = append(b, '[')
+		b = append(b, s.procID...)
+		b = append(b, "]: "...)
+		return append(b, line...)
+	}
+
+	b = append(b, "1 "...)
+	b = ts.UTC().AppendFormat(b, "2006-01-02T15:04:05.000000Z07:00")
+	b = append(b, ' ')
+	b = append(b, s.hostname...)
+	b = append(b, ' ')
+	b = append(b, s.spec.Tag...)
+	b = append(b, ' ')
+	b = append(b, s.procID...)
+	b = append(b, " - "...)
+	b = appendStructuredData(b, rec.Fields)
+	b = append(b, ' ')
+	return append(b, line...)
+}
+
+// appendStructuredData appends the top-level scalar fields as one SD
+// element, or the nil value "-" when there are none. Nested objects and
+// arrays are left to the message text.
+func appendStructuredData(b []byte, fields []record.Field) []byte {
+	start := len(b)
+	b = append(b, '[')
+	b = append(b, sdID...)
+	params := 0
+	for _, f := range fields {
+		v, ok := scalarText(f.Value)
+		if !ok {
+			continue
+		}
+		name := sdName(f.Key)
+		if name == "" {
+			continue
+		}
+		b = append(b, ' ')
+		b = append(b, name...)
+		b = append(b, `="`...)
+		for _, r := range strings.ToValidUTF8(v, "\ufffd") {
+			if r == '"' || r == '\\' || r == ']' {
+				b = append(b, '\\')
+			}
+			b = utf8.AppendRune(b, r)
+		}
+		b = append(b, '"')
+		params++
+	}
+	if params == 0 {
+		return append(b[:start], '-')
+	}
+	return append(b, ']')
+}
+
+// sdName makes key a valid PARAM-NAME: at most 32 printable ASCII
+// characters other than '=', ']', '"' and space, which become '_'.
+func sdName(key string) string {
+	var sb strings.Builder
+	for _, r := range key {
+		if sb.Len() == 32 {
+			break
+		}
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// scalarText renders a scalar field value, and reports false for objects and
+// arrays.
+func scalarText(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case int:
+		return strconv.Itoa(v), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'g', -1, 64), true
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,421 @@
+package sink
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/config"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/encode"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/metrics"
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/record"
+)
+
+func newSyslog(t *testing.T, spec config.Sink) (*Syslog, *metrics.Registry) {
+	t.Helper()
+	spec.Name = "syslog"
+	spec.Type = config.SinkSyslog
+	if spec.Format == "" {
+		spec.Format = encode.FormatPlain
+	}
+	if spec.Protocol == "" {
+		spec.Protocol = config.SyslogRFC5424
+	}
+	if spec.Facility == "" {
+		spec.Facility = "local0"
+	}
+	if spec.Tag == "" {
+		spec.Tag = "loggen"
+	}
+	spec.Timeout = config.Duration(time.Second)
+
+	s, reg := newSink(t, spec, NewSyslog)
+	s.hostname = "node-1"
+	s.procID = "42"
+	t.Cleanup(func() { _ = s.Close() })
+	return s, reg
+}
+
+func syslogRecord() *record.Record {
+	rec := newRecord(7)
+	rec.Fields = append(rec.Fields,
+		record.Field{Key: "random_number", Value: int64(4242)},
+		record.Field{Key: "random_string", Value: `a"b]c\d`},
+		record.Field{Key: "request", Value: []record.Field{{Key: "status", Value: int64(200)}}},
+	)
+	return rec
+}
+
+func TestSyslog_Message(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		format   string
+		rec      func() *record.Record
+		want     string
+	}{
+		{
+			name:     "rfc5424",
+			protocol: config.SyslogRFC5424,
+			format:   encode.FormatJSON,
+			rec:      syslogRecord,
+			want: `<134>1 2026-02-18T12:00:00.123456Z node-1 loggen 42 - [loggen@32473 count="7" random_number="4242" random_string="a\"b\]c\\d"] ` +
+				`{"level":"info","ts":1771416000.1234567,"msg":"tick","count":7,"random_number":4242,"random_string":"a\"b]c\\d","request":{"status":200}}`,
+		},
+		{
+			name:     "rfc5424 without scalar fields",
+			protocol: config.SyslogRFC5424,
+			format:   encode.FormatPlain,
+			rec: func() *record.Record {
+				rec := newRecord(0)
+				rec.Fields = nil
+				return rec
+			},
+			want: `<134>1 2026-02-18T12:00:00.123456Z node-1 loggen 42 - - 2026-02-18T12:00:00.123Z INFO tick`,
+		},
+		{
+			name:     "rfc3164",
+			protocol: config.SyslogRFC3164,
+			format:   encode.FormatPlain,
+			rec: func() *record.Record {
+				rec := newRecord(7)
+				rec.Time = time.Date(2026, 2, 8, 9, 5, 1, 0, time.UTC)
+				return rec
+			},
+			want: `<134>Feb  8 09:05:01 node-1 loggen[42]: 2026-02-08T09:05:01.000Z INFO tick count=7`,
+		},
+		{
+			name:     "raw",
+			protocol: config.SyslogRFC3164,
+			format:   encode.FormatJSON,
+			rec: func() *record.Record {
+				rec := newRecord(7)
+				rec.Raw = `10.0.0.1 - - "GET / HTTP/1.1" 200`
+				return rec
+			},
+			want: `<134>Feb 18 12:00:00 node-1 loggen[42]: 10.0.0.1 - - "GET / HTTP/1.1" 200`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newSyslog(t, config.Sink{Protocol: tt.protocol, Format: tt.format, Address: "127.0.0.1:1"})
+			rec := tt.rec()
+			line, err := Line(s.enc, rec)
+			if err != nil {
+				t.Fatalf("Line() error = %v", err)
+			}
+			if got := string(s.message(nil, rec, line)); got != tt.want {
+				t.Errorf("message =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslog_Severity(t *testing.T) {
+	// local0 (16) * 8 + severity, with the levels transform.lua reports as
+	// FATAL sent as critical.
+	tests := []struct {
+		level zapcore.Level
+		pri   int
+	}{
+		{zapcore.DebugLevel, 135},
+		{zapcore.InfoLevel, 134},
+		{zapcore.WarnLevel, 132},
+		{zapcore.ErrorLevel, 131},
+		{zapcore.DPanicLevel, 130},
+		{zapcore.PanicLevel, 130},
+		{zapcore.FatalLevel, 130},
+	}
+
+	s, _ := newSyslog(t, config.Sink{Address: "127.0.0.1:1"})
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			rec := newRecord(1)
+			rec.Level = tt.level
+			got := string(s.message(nil, rec, nil))
+			if want := "<" + strconv.Itoa(tt.pri) + ">"; !strings.HasPrefix(got, want) {
+				t.Errorf("message = %q, want PRI %s", got, want)
+			}
+		})
+	}
+}
+
+func TestSyslog_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer pc.Close()
+
+	s, reg := newSyslog(t, config.Sink{Network: config.NetworkUDP, Address: pc.LocalAddr().String()})
+	for i := range 2 {
+		if err := s.Write(newRecord(uint64(i))); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	buf := make([]byte, 2048)
+	for i := range 2 {
+		_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom() error = %v", err)
+		}
+		want := `[loggen@32473 count="` + strconv.Itoa(i) + `"] 2026-02-18T12:00:00.123Z INFO tick count=` + strconv.Itoa(i)
+		if got := string(buf[:n]); !strings.HasPrefix(got, "<134>1 ") || !strings.HasSuffix(got, want) {
+			t.Errorf("datagram %d = %q, want a message ending %q", i, got, want)
+		}
+	}
+	if got := sinkCounter(reg, "loggen_sink_connects_total", "syslog"); got != 1 {
+		t.Errorf("connects metric = %v, want 1", got)
+	}
+}
+
+// acceptAll accepts one connection on ln and returns everything it
+// receives until the sink closes it.
+func acceptAll(t *testing.T, ln net.Listener) <-chan string {
+	t.Helper()
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			out <- ""
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		out <- string(data)
+	}()
+	return out
+}
+
+// readOctetFrames splits RFC 6587 octet-counted frames.
+func readOctetFrames(t *testing.T, data string) []string {
+	t.Helper()
+	var frames []string
+	r := bufio.NewReader(strings.NewReader(data))
+	for {
+		size, err := r.ReadString(' ')
+		if err == io.EOF {
+			return frames
+		}
+		if err != nil {
+			t.Fatalf("reading frame length: %v", err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil {
+			t.Fatalf("frame length %q: %v", size, err)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatalf("reading frame: %v", err)
+		}
+		frames = append(frames, string(msg))
+	}
+}
+
+func multilineRecord() *record.Record {
+	rec := newRecord(3)
+	rec.Raw = "panic: boom\n\ngoroutine 1 [running]:"
+	return rec
+}
+
+func TestSyslog_TCPFraming(t *testing.T) {
+	tests := []struct {
+		framing string
+		check   func(t *testing.T, data string)
+	}{
+		{config.FramingOctetCounting, func(t *testing.T, data string) {
+			frames := readOctetFrames(t, data)
+			if len(frames) != 2 {
+				t.Fatalf("got %d frames, want 2: %q", len(frames), data)
+			}
+			if !strings.HasSuffix(frames[0], "INFO tick count=1") {
+				t.Errorf("frame 0 = %q", frames[0])
+			}
+			if !strings.HasSuffix(frames[1], "loggen[42]: panic: boom\n\ngoroutine 1 [running]:") {
+				t.Errorf("frame 1 = %q, want the stack trace intact", frames[1])
+			}
+		}},
+		{config.FramingNewline, func(t *testing.T, data string) {
+			lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("got %d lines, want 2: %q", len(lines), data)
+			}
+			if !strings.HasSuffix(lines[1], "loggen[42]: panic: boom#012#012goroutine 1 [running]:") {
+				t.Errorf("line 1 = %q, want escaped newlines", lines[1])
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.framing, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			defer ln.Close()
+			received := acceptAll(t, ln)
+
+			s, _ := newSyslog(t, config.Sink{
+				Network:  config.NetworkTCP,
+				Protocol: config.SyslogRFC3164,
+				Framing:  tt.framing,
+				Address:  ln.Addr().String(),
+			})
+			for _, rec := range []*record.Record{newRecord(1), multilineRecord()} {
+				if err := s.Write(rec); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			tt.check(t, <-received)
+		})
+	}
+}
+
+func TestSyslog_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", srv.TLS)
+	if err != nil {
+		t.Fatalf("tls.Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(ca, certPEM, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	t.Run("verified", func(t *testing.T) {
+		received := acceptAll(t, ln)
+		s, _ := newSyslog(t, config.Sink{
+			Network: config.NetworkTLS,
+			Framing: config.FramingOctetCounting,
+			Address: ln.Addr().String(),
+			TLSCA:   ca,
+		})
+		if err := s.Write(newRecord(5)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		_ = s.Close()
+		frames := readOctetFrames(t, <-received)
+		if len(frames) != 1 || !strings.Contains(frames[0], `count="5"`) {
+			t.Errorf("frames = %q, want one record with count 5", frames)
+		}
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		received := acceptAll(t, ln)
+		s, _ := newSyslog(t, config.Sink{
+			Network: config.NetworkTLS,
+			Framing: config.FramingOctetCounting,
+			Address: ln.Addr().String(),
+		})
+		if err := s.Write(newRecord(5)); err == nil {
+			t.Error("Write() to a server signed by an unknown CA succeeded, want error")
+		}
+		_ = s.Close()
+		<-received
+	})
+}
+
+func TestSyslog_BadTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		spec config.Sink
+		want string
+	}{
+		{"missing ca", config.Sink{TLSCA: filepath.Join(dir, "missing.pem")}, "reading tls_ca"},
+		{"invalid ca", config.Sink{TLSCA: notPEM}, "no PEM certificates"},
+		{"invalid cert", config.Sink{TLSCert: notPEM, TLSKey: notPEM}, "loading tls_cert"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			spec.Name, spec.Type, spec.Network, spec.Address = "syslog", config.SinkSyslog, config.NetworkTLS, "127.0.0.1:6514"
+			_, err := NewSyslog(spec, mustEncoder(t, encode.FormatJSON), metrics.NewRegistry())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewSyslog() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslog_Reconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	// The first connection is dropped after one line, as when the
+	// receiver restarts.
+	dropped := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			dropped <- ""
+			return
+		}
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		_ = conn.Close()
+		dropped <- line
+	}()
+
+	s, reg := newSyslog(t, config.Sink{Network: config.NetworkTCP, Framing: config.FramingNewline, Address: ln.Addr().String()})
+	if err := s.Write(newRecord(1)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := <-dropped; !strings.Contains(got, "count=1") {
+		t.Fatalf("first connection got %q", got)
+	}
+
+	// Writes to the dead connection may be accepted by the kernel until
+	// the reset arrives; the first failing one is resent on a new
+	// connection.
+	second := acceptAll(t, ln)
+	retries := reg.Counter("loggen_sink_retries_total", "", "sink", "syslog")
+	var last uint64
+	for i := uint64(2); retries.Value() == 0 && i < 100; i++ {
+		if err := s.Write(newRecord(i)); err != nil {
+			t.Fatalf("Write(%d) error = %v", i, err)
+		}
+		last = i
+		time.Sleep(5 * time.Millisecond)
+	}
+	if retries.Value() != 1 {
+		t.Fatalf("retries metric = %v, want 1", retries.Value())
+	}
+	_ = s.Close()
+
+	if got := <-second; !strings.HasSuffix(got, "count="+strconv.FormatUint(last, 10)+"\n") {
+		t.Errorf("second connection got %q, want it to end with record %d", got, last)
+	}
+	if got := sinkCounter(reg, "loggen_sink_connects_total", "syslog"); got != 2 {
+		t.Errorf("connects metric = %v, want 2", got)
+	}
+}
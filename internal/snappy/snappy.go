@@ -0,0 +1,186 @@
+// Package snappy implements the Snappy block format, the compression Loki
+// and Kafka expect, with a greedy hash-table compressor. It does not
+// implement the framing format.
+package snappy
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+
+	// maxBlockSize bounds the window searched for matches, so every copy
+	// offset fits in two bytes.
+	maxBlockSize = 1 << 16
+
+	// minMatch is the shortest match worth a copy element.
+	minMatch = 4
+
+	tableBits = 14
+)
+
+// ErrCorrupt reports invalid compressed input.
+var ErrCorrupt = errors.New("snappy: corrupt input")
+
+// Encode appends the compressed form of src to dst and returns it.
+func Encode(dst, src []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+	for len(src) > 0 {
+		n := min(len(src), maxBlockSize)
+		dst = encodeBlock(dst, src[:n])
+		src = src[n:]
+	}
+	return dst
+}
+
+func encodeBlock(dst, src []byte) []byte {
+	if len(src) < minMatch+1 {
+		return emitLiteral(dst, src)
+	}
+
+	var table [1 << tableBits]int32
+	lit := 0
+	for i := 0; i+minMatch <= len(src); {
+		cur := binary.LittleEndian.Uint32(src[i:])
+		h := hash(cur)
+		cand := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if cand < 0 || binary.LittleEndian.Uint32(src[cand:]) != cur {
+			i++
+			continue
+		}
+
+		end := i + minMatch
+		for end < len(src) && src[end] == src[end-i+cand] {
+			end++
+		}
+		if lit < i {
+			dst = emitLiteral(dst, src[lit:i])
+		}
+		dst = emitCopy(dst, i-cand, end-i)
+		i, lit = end, end
+	}
+	if lit < len(src) {
+		dst = emitLiteral(dst, src[lit:])
+	}
+	return dst
+}
+
+func hash(v uint32) uint32 {
+	return (v * 0x1e35a7bd) >> (32 - tableBits)
+}
+
+func emitLiteral(dst, lit []byte) []byte {
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	default:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	}
+	return append(dst, lit...)
+}
+
+// emitCopy emits a copy of length bytes from offset back, splitting it
+// into elements of at most 64 bytes.
+func emitCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		dst = append(dst, 59<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|tagCopy1, byte(offset))
+}
+
+// DecodedLen returns the uncompressed length recorded in src.
+func DecodedLen(src []byte) (int, error) {
+	n, size := binary.Uvarint(src)
+	if size <= 0 || n > 1<<32-1 {
+		return 0, ErrCorrupt
+	}
+	return int(n), nil
+}
+
+// Decode returns the decompressed form of src.
+func Decode(src []byte) ([]byte, error) {
+	n, size := binary.Uvarint(src)
+	if size <= 0 || n > 1<<32-1 {
+		return nil, ErrCorrupt
+	}
+	src = src[size:]
+	dst := make([]byte, 0, n)
+
+	for len(src) > 0 {
+		tag := src[0]
+		var offset, length int
+		switch tag & 0x03 {
+		case tagLiteral:
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, ErrCorrupt
+				}
+				length = 0
+				for i := extra - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			length++
+			if length > len(src) || len(dst)+length > int(n) {
+				return nil, ErrCorrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case tagCopy1:
+			if len(src) < 2 {
+				return nil, ErrCorrupt
+			}
+			length = 4 + int(tag>>2)&0x07
+			offset = int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+		case tagCopy2:
+			if len(src) < 3 {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case tagCopy4:
+			if len(src) < 5 {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) || len(dst)+length > int(n) {
+			return nil, ErrCorrupt
+		}
+		// Copies may overlap their own output, so go byte by byte.
+		start := len(dst) - offset
+		for i := range length {
+			dst = append(dst, dst[start+i])
+		}
+	}
+	if len(dst) != int(n) {
+		return nil, ErrCorrupt
+	}
+	return dst, nil
+}
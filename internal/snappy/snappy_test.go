@@ -0,0 +1,87 @@
+package snappy
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	random := make([]byte, 200000)
+	for i := range random {
+		random[i] = byte(rng.IntN(256))
+	}
+	lines := strings.Repeat(`{"level":"info","ts":1771416000.123,"msg":"tick","count":12345,"random_string":"alpha"}`+"\n", 3000)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		maxRatio float64
+	}{
+		{"empty", nil, 0},
+		{"short", []byte("abc"), 0},
+		{"run", bytes.Repeat([]byte{'a'}, 1000), 0.06},
+		{"log lines", []byte(lines), 0.1},
+		{"random", random, 1.01},
+		{"overlapping copy", []byte("abcabcabcabcabcabcabcabcabcabcX"), 0.8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := Encode(nil, tt.data)
+			if n, err := DecodedLen(enc); err != nil || n != len(tt.data) {
+				t.Errorf("DecodedLen() = %d, %v, want %d", n, err, len(tt.data))
+			}
+			got, err := Decode(enc)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Fatalf("round trip changed %d bytes of input", len(tt.data))
+			}
+			if tt.maxRatio > 0 {
+				if ratio := float64(len(enc)) / float64(len(tt.data)); ratio > tt.maxRatio {
+					t.Errorf("compression ratio = %.3f, want at most %.3f", ratio, tt.maxRatio)
+				}
+			}
+		})
+	}
+}
+
+func TestDecode_Reference(t *testing.T) {
+	// "Wikipedia" followed by a copy of its first four bytes, written by
+	// hand from the format description: length 13, a 9-byte literal and
+	// a copy-1 of length 4 at offset 9.
+	src := []byte{13, 8 << 2, 'W', 'i', 'k', 'i', 'p', 'e', 'd', 'i', 'a', 0x01, 9}
+	got, err := Decode(src)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if string(got) != "WikipediaWiki" {
+		t.Errorf("Decode() = %q, want WikipediaWiki", got)
+	}
+}
+
+func TestDecode_Corrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no length", nil},
+		{"short literal", []byte{5, 4 << 2, 'a'}},
+		{"offset before start", []byte{4, 0x01, 1}},
+		{"too long", []byte{1, 1 << 2, 'a', 'b'}},
+		{"too short", []byte{3, 0, 'a'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("Decode() error = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
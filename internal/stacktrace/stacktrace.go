@@ -0,0 +1,179 @@
+// Package stacktrace generates multi-line Java exceptions, Python
+// tracebacks and Go panics as real services print them, for testing
+// multiline parsing in the log pipeline. Traces are drawn from the caller's
+// *rand.Rand, so a seeded loop emits the same traces on every run.
+package stacktrace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// Trace languages.
+const (
+	Java   = "java"
+	Python = "python"
+	Go     = "go"
+)
+
+// Languages lists every supported language.
+var Languages = []string{Java, Python, Go}
+
+// Valid reports whether lang is a supported language.
+func Valid(lang string) bool {
+	switch lang {
+	case Java, Python, Go:
+		return true
+	}
+	return false
+}
+
+// Trace is one multi-line stack trace.
+type Trace struct {
+	Language string
+	Lines    []string
+}
+
+// Text returns the trace as one string with newline separated lines, which
+// is what a correctly reassembled log record body contains.
+func (t *Trace) Text() string {
+	return strings.Join(t.Lines, "\n")
+}
+
+// SHA256 returns the hex SHA-256 of Text, so a verifier can match the
+// reassembled body without storing the whole trace.
+func (t *Trace) SHA256() string {
+	sum := sha256.Sum256([]byte(t.Text()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generate returns a trace in lang. seq is embedded in the exception
+// message so every line group can be tied back to its record.
+func Generate(rng *rand.Rand, lang string, seq uint64) Trace {
+	switch lang {
+	case Python:
+		return Trace{Language: Python, Lines: python(rng, seq)}
+	case Go:
+		return Trace{Language: Go, Lines: goPanic(rng, seq)}
+	default:
+		return Trace{Language: Java, Lines: java(rng, seq)}
+	}
+}
+
+var (
+	javaExceptions = []string{
+		"java.lang.IllegalStateException",
+		"java.lang.NullPointerException",
+		"java.util.concurrent.TimeoutException",
+		"java.io.UncheckedIOException",
+	}
+	javaCauses = []string{
+		"java.net.SocketTimeoutException: Read timed out",
+		"java.sql.SQLTransientConnectionException: HikariPool-1 - Connection is not available",
+		"java.io.IOException: Broken pipe",
+	}
+	javaFrames = []string{
+		"com.example.orders.OrderService.placeOrder(OrderService.java:%d)",
+		"com.example.orders.OrderController.create(OrderController.java:%d)",
+		"com.example.inventory.StockClient.reserve(StockClient.java:%d)",
+		"org.springframework.web.servlet.FrameworkServlet.service(FrameworkServlet.java:%d)",
+		"jakarta.servlet.http.HttpServlet.service(HttpServlet.java:%d)",
+		"org.apache.catalina.core.ApplicationFilterChain.doFilter(ApplicationFilterChain.java:%d)",
+		"java.base/java.lang.Thread.run(Thread.java:%d)",
+	}
+)
+
+func java(rng *rand.Rand, seq uint64) []string {
+	lines := []string{fmt.Sprintf("Exception in thread \"http-nio-8080-exec-%d\" %s: tick %d failed",
+		1+rng.IntN(200), pick(rng, javaExceptions), seq)}
+	lines = append(lines, javaStack(rng, 3+rng.IntN(len(javaFrames)-2))...)
+
+	if rng.IntN(2) == 0 {
+		lines = append(lines, "Caused by: "+pick(rng, javaCauses))
+		lines = append(lines, javaStack(rng, 2)...)
+		lines = append(lines, fmt.Sprintf("\t... %d more", 3+rng.IntN(20)))
+	}
+	return lines
+}
+
+func javaStack(rng *rand.Rand, n int) []string {
+	start := rng.IntN(len(javaFrames) - n + 1)
+	out := make([]string, n)
+	for i := range out {
+		out[i] = "\tat " + fmt.Sprintf(javaFrames[start+i], 20+rng.IntN(800))
+	}
+	return out
+}
+
+var (
+	pythonFrames = []struct{ file, fn, code string }{
+		{"/usr/local/lib/python3.12/site-packages/flask/app.py", "wsgi_app", "response = self.full_dispatch_request()"},
+		{"/usr/local/lib/python3.12/site-packages/flask/app.py", "dispatch_request", "return self.ensure_sync(self.view_functions[rule.endpoint])(**view_args)"},
+		{"/app/orders/views.py", "create_order", "order = service.place(payload)"},
+		{"/app/orders/service.py", "place", "stock.reserve(item.sku, item.quantity)"},
+		{"/app/inventory/client.py", "reserve", "resp.raise_for_status()"},
+	}
+	pythonErrors = []string{"ValueError", "KeyError", "RuntimeError", "ConnectionError", "TimeoutError"}
+)
+
+func python(rng *rand.Rand, seq uint64) []string {
+	lines := []string{"Traceback (most recent call last):"}
+	n := 2 + rng.IntN(len(pythonFrames)-1)
+	for _, f := range pythonFrames[len(pythonFrames)-n:] {
+		lines = append(lines,
+			fmt.Sprintf("  File %q, line %d, in %s", f.file, 10+rng.IntN(500), f.fn),
+			"    "+f.code,
+		)
+	}
+	return append(lines, fmt.Sprintf("%s: tick %d failed", pick(rng, pythonErrors), seq))
+}
+
+var (
+	goPanics = []string{
+		"runtime error: invalid memory address or nil pointer dereference",
+		"runtime error: index out of range [%d] with length %d",
+		"assignment to entry in nil map",
+	}
+	goFrames = []struct{ fn, file string }{
+		{"main.(*OrderService).Place", "/app/internal/orders/service.go"},
+		{"main.(*Handler).ServeHTTP", "/app/internal/orders/handler.go"},
+		{"net/http.serverHandler.ServeHTTP", "/usr/local/go/src/net/http/server.go"},
+		{"net/http.(*conn).serve", "/usr/local/go/src/net/http/server.go"},
+	}
+)
+
+// goPanic prints a panic followed by goroutine dumps. The blank lines
+// between goroutines are part of the trace and a common splitting bug.
+func goPanic(rng *rand.Rand, seq uint64) []string {
+	msg := pick(rng, goPanics)
+	if strings.Contains(msg, "%d") {
+		msg = fmt.Sprintf(msg, 5+rng.IntN(5), rng.IntN(5))
+	}
+	lines := []string{fmt.Sprintf("panic: %s [tick %d]", msg, seq)}
+	if strings.HasPrefix(msg, "runtime error: invalid memory") {
+		lines = append(lines, fmt.Sprintf("[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x%x]", 0x600000+rng.IntN(0xfffff)))
+	}
+
+	goroutines := 1 + rng.IntN(2)
+	for g := 0; g < goroutines; g++ {
+		state := "running"
+		if g > 0 {
+			state = "IO wait"
+		}
+		lines = append(lines, "", fmt.Sprintf("goroutine %d [%s]:", 1+rng.IntN(300), state))
+		for _, f := range goFrames[:2+rng.IntN(len(goFrames)-1)] {
+			lines = append(lines,
+				fmt.Sprintf("%s(0x%x, 0x%x)", f.fn, 0xc000010000+rng.IntN(0xffff), 0xc000100000+rng.IntN(0xffff)),
+				fmt.Sprintf("\t%s:%d +0x%x", f.file, 20+rng.IntN(3000), rng.IntN(0x400)),
+			)
+		}
+	}
+	return lines
+}
+
+func pick(rng *rand.Rand, values []string) string {
+	return values[rng.IntN(len(values))]
+}
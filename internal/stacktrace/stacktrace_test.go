@@ -0,0 +1,98 @@
+package stacktrace
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_Shapes(t *testing.T) {
+	tests := []struct {
+		lang  string
+		first *regexp.Regexp
+		rest  *regexp.Regexp
+		last  *regexp.Regexp
+	}{
+		{
+			lang:  Java,
+			first: regexp.MustCompile(`^Exception in thread "[^"]+" java\.[\w.]+: tick 7 failed$`),
+			rest:  regexp.MustCompile(`^(\tat \S+\(\w+\.java:\d+\)|Caused by: .+|\t\.\.\. \d+ more)$`),
+		},
+		{
+			lang:  Python,
+			first: regexp.MustCompile(`^Traceback \(most recent call last\):$`),
+			rest:  regexp.MustCompile(`^(  File "[^"]+", line \d+, in \w+|    .+|\w+Error: tick 7 failed)$`),
+			last:  regexp.MustCompile(`^\w+Error: tick 7 failed$`),
+		},
+		{
+			lang:  Go,
+			first: regexp.MustCompile(`^panic: .+ \[tick 7\]$`),
+			rest:  regexp.MustCompile(`^(|\[signal SIGSEGV.*\]|goroutine \d+ \[[\w ]+\]:|\S+\(0x[0-9a-f]+, 0x[0-9a-f]+\)|\t/\S+\.go:\d+ \+0x[0-9a-f]+)$`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(1, 2))
+			for i := 0; i < 50; i++ {
+				tr := Generate(rng, tt.lang, 7)
+				if tr.Language != tt.lang {
+					t.Fatalf("Language = %q, want %q", tr.Language, tt.lang)
+				}
+				if len(tr.Lines) < 3 {
+					t.Fatalf("trace has %d lines, want a multi-line trace:\n%s", len(tr.Lines), tr.Text())
+				}
+				if !tt.first.MatchString(tr.Lines[0]) {
+					t.Fatalf("first line = %q", tr.Lines[0])
+				}
+				for _, line := range tr.Lines[1:] {
+					if !tt.rest.MatchString(line) {
+						t.Fatalf("unexpected line %q in:\n%s", line, tr.Text())
+					}
+				}
+				if tt.last != nil && !tt.last.MatchString(tr.Lines[len(tr.Lines)-1]) {
+					t.Fatalf("last line = %q", tr.Lines[len(tr.Lines)-1])
+				}
+			}
+		})
+	}
+}
+
+func TestGenerate_GoBlankLines(t *testing.T) {
+	tr := Generate(rand.New(rand.NewPCG(1, 2)), Go, 1)
+	if !strings.Contains(tr.Text(), "\n\ngoroutine ") {
+		t.Errorf("Go panic lacks the blank line before the goroutine dump:\n%s", tr.Text())
+	}
+}
+
+func TestGenerate_Reproducible(t *testing.T) {
+	for _, lang := range Languages {
+		a := Generate(rand.New(rand.NewPCG(9, 9)), lang, 3)
+		b := Generate(rand.New(rand.NewPCG(9, 9)), lang, 3)
+		if a.SHA256() != b.SHA256() {
+			t.Errorf("%s traces differ with the same seed", lang)
+		}
+	}
+}
+
+func TestTrace_SHA256(t *testing.T) {
+	tr := Trace{Lines: []string{"a", "b"}}
+	if got, want := tr.SHA256(), "7e18f737311b2dc3b2f269dd78396b0351f14fb66efa879f768cb23181883c78"; got != want {
+		t.Errorf("SHA256() = %q, want sha256(\"a\\nb\") = %q", got, want)
+	}
+	if tr.Text() != "a\nb" {
+		t.Errorf("Text() = %q", tr.Text())
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, lang := range Languages {
+		if !Valid(lang) {
+			t.Errorf("Valid(%q) = false", lang)
+		}
+	}
+	if Valid("cobol") {
+		t.Error("Valid(cobol) = true")
+	}
+}
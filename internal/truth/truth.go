@@ -0,0 +1,332 @@
+// Package truth writes a ground-truth manifest describing exactly what
+// loggen generated, so alert and detection queries run against ClickHouse
+// can be scored. The manifest is JSON Lines: one run header, one line per
+// time window with expected counts and histograms, one line per injected
+// anomaly window and one line per multi-line stack trace.
+package truth
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/scenario"
+)
+
+// DefaultWindow is the default aggregation window.
+const DefaultWindow = time.Minute
+
+// Line kinds written to the manifest.
+const (
+	KindRun     = "run"
+	KindWindow  = "window"
+	KindAnomaly = "anomaly"
+	KindTrace   = "trace"
+)
+
+// Run is the manifest header.
+type Run struct {
+	Kind   string    `json:"kind"`
+	Start  time.Time `json:"start"`
+	Seed   uint64    `json:"seed"`
+	Window string    `json:"window"`
+}
+
+// NumberStats summarises the values of a numeric column in a window.
+type NumberStats struct {
+	Min int   `json:"min"`
+	Max int   `json:"max"`
+	Sum int64 `json:"sum"`
+}
+
+// Window holds the expected counts for one aggregation window. Windows
+// with no records are still written so silences are explicit.
+type Window struct {
+	Kind         string         `json:"kind"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Records      uint64         `json:"records"`
+	FirstSeq     uint64         `json:"first_seq,omitempty"`
+	LastSeq      uint64         `json:"last_seq,omitempty"`
+	Levels       map[string]int `json:"levels"`
+	RandomString map[string]int `json:"random_string"`
+	RandomNumber *NumberStats   `json:"random_number,omitempty"`
+	Status       map[string]int `json:"status,omitempty"`
+	LatencyMs    *NumberStats   `json:"latency_ms,omitempty"`
+	Cases        map[string]int `json:"cases,omitempty"`
+	Traces       int            `json:"traces,omitempty"`
+	Scenarios    []string       `json:"scenarios,omitempty"`
+}
+
+// Anomaly is an injected scenario window.
+type Anomaly struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Intensity float64   `json:"intensity"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// Trace describes one multi-line stack trace, so a verifier can check the
+// pipeline reassembled its lines into a single record.
+type Trace struct {
+	Kind     string    `json:"kind"`
+	Time     time.Time `json:"time"`
+	Seq      uint64    `json:"seq"`
+	Language string    `json:"language"`
+	Lines    int       `json:"lines"`
+	First    string    `json:"first_line"`
+	SHA256   string    `json:"sha256"`
+}
+
+// Writer aggregates generated records into windows and writes the
+// manifest. It is safe for concurrent use.
+type Writer struct {
+	mu     sync.Mutex
+	out    *bufio.Writer
+	closer io.Closer
+	enc    *json.Encoder
+	window time.Duration
+
+	cur       *Window
+	anomalies []scenario.Window
+	err       error
+}
+
+// Create opens path for writing, truncating any existing manifest.
+func Create(path string, window time.Duration) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("ground truth: %w", err)
+	}
+	return NewWriter(f, window), nil
+}
+
+// NewWriter writes the manifest to w. If w is an io.Closer it is closed by
+// Close. A non-positive window uses DefaultWindow.
+func NewWriter(w io.Writer, window time.Duration) *Writer {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	bw := bufio.NewWriter(w)
+	tw := &Writer{
+		out:    bw,
+		enc:    json.NewEncoder(bw),
+		window: window,
+	}
+	if c, ok := w.(io.Closer); ok {
+		tw.closer = c
+	}
+	return tw
+}
+
+// Begin writes the run header.
+func (w *Writer) Begin(start time.Time, seed uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.encode(Run{
+		Kind:   KindRun,
+		Start:  start.UTC(),
+		Seed:   seed,
+		Window: w.window.String(),
+	})
+	w.cur = w.newWindow(start)
+}
+
+// Record adds one generated record to the current window.
+func (w *Writer) Record(t time.Time, seq uint64, level zapcore.Level, num int, str string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	win := w.count(t, seq, level)
+	win.RandomString[str]++
+	win.RandomNumber = win.RandomNumber.add(num)
+}
+
+// RecordCustom adds one record of a custom schema without the builtin
+// random_number and random_string fields, counting only its level.
+func (w *Writer) RecordCustom(t time.Time, seq uint64, level zapcore.Level) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.count(t, seq, level)
+}
+
+// RecordAccess adds one access log record to the current window, counting
+// its HTTP status and latency.
+func (w *Writer) RecordAccess(t time.Time, seq uint64, level zapcore.Level, status int, latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	win := w.count(t, seq, level)
+	if win.Status == nil {
+		win.Status = make(map[string]int)
+	}
+	win.Status[strconv.Itoa(status)]++
+	win.LatencyMs = win.LatencyMs.add(int(latency.Milliseconds()))
+}
+
+// RecordCase adds one adversarial record to the current window, counting
+// the catalog case it came from.
+func (w *Writer) RecordCase(t time.Time, seq uint64, level zapcore.Level, name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	win := w.count(t, seq, level)
+	if win.Cases == nil {
+		win.Cases = make(map[string]int)
+	}
+	win.Cases[name]++
+}
+
+// RecordTrace adds a multi-line stack trace to the current window and
+// writes its trace line. tr.Kind and tr.Time are filled in.
+func (w *Writer) RecordTrace(t time.Time, level zapcore.Level, tr Trace) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.count(t, tr.Seq, level).Traces++
+
+	tr.Kind = KindTrace
+	tr.Time = t.UTC()
+	w.encode(tr)
+}
+
+// ScenarioStarted implements scenario.Observer.
+func (w *Writer) ScenarioStarted(s scenario.Window) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.anomalies = append(w.anomalies, s)
+}
+
+// ScenarioEnded implements scenario.Observer.
+func (w *Writer) ScenarioEnded(s scenario.Window) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.anomalies {
+		if w.anomalies[i].Name == s.Name {
+			w.anomalies[i] = s
+		}
+	}
+
+	w.encode(Anomaly{
+		Kind:      KindAnomaly,
+		Name:      s.Name,
+		Type:      s.Type,
+		Intensity: s.Intensity,
+		Start:     s.Start.UTC(),
+		End:       s.End.UTC(),
+		Truncated: s.Truncated,
+	})
+}
+
+// Close flushes the final, possibly partial, window ending at end and
+// closes the underlying file.
+func (w *Writer) Close(end time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cur != nil {
+		w.cur.End = end.UTC()
+		w.flush(w.cur)
+		w.cur = nil
+	}
+
+	if err := w.out.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if w.closer != nil {
+		if err := w.closer.Close(); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+	return w.err
+}
+
+// advance flushes every window that ends at or before t, including empty
+// ones, and makes the window containing t current.
+func (w *Writer) advance(t time.Time) {
+	if w.cur == nil {
+		w.cur = w.newWindow(t)
+		return
+	}
+	for !t.Before(w.cur.End) {
+		w.flush(w.cur)
+		w.cur = w.newWindow(w.cur.End)
+	}
+}
+
+// count advances to the window of t and counts one record in it.
+func (w *Writer) count(t time.Time, seq uint64, level zapcore.Level) *Window {
+	w.advance(t)
+
+	win := w.cur
+	win.Records++
+	if win.FirstSeq == 0 {
+		win.FirstSeq = seq
+	}
+	win.LastSeq = seq
+	win.Levels[level.String()]++
+	return win
+}
+
+// add returns s with v included, allocating s for the first value.
+func (s *NumberStats) add(v int) *NumberStats {
+	if s == nil {
+		s = &NumberStats{Min: v, Max: v}
+	}
+	s.Min = min(s.Min, v)
+	s.Max = max(s.Max, v)
+	s.Sum += int64(v)
+	return s
+}
+
+func (w *Writer) newWindow(t time.Time) *Window {
+	start := t.Truncate(w.window).UTC()
+	win := &Window{
+		Kind:         KindWindow,
+		Start:        start,
+		End:          start.Add(w.window),
+		Levels:       make(map[string]int),
+		RandomString: make(map[string]int),
+	}
+	return win
+}
+
+// flush tags win with every scenario overlapping it and writes it.
+func (w *Writer) flush(win *Window) {
+	for _, a := range w.anomalies {
+		if a.Start.Before(win.End) && a.End.After(win.Start) && !slices.Contains(win.Scenarios, a.Name) {
+			win.Scenarios = append(win.Scenarios, a.Name)
+		}
+	}
+	slices.Sort(win.Scenarios)
+	w.encode(win)
+}
+
+func (w *Writer) encode(v any) {
+	if w.err != nil {
+		return
+	}
+	if err := w.enc.Encode(v); err != nil {
+		w.err = err
+		return
+	}
+	// Flush every line so the manifest can be followed while loggen runs.
+	if err := w.out.Flush(); err != nil {
+		w.err = err
+	}
+}
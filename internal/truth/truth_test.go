@@ -0,0 +1,261 @@
+package truth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/randomizedcoder/clickhouse-otel-example/internal/scenario"
+)
+
+var t0 = time.Date(2026, 2, 18, 12, 0, 0, 0, time.UTC)
+
+// decodeLines splits a manifest into generic JSON objects.
+func decodeLines(t *testing.T, data []byte) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestWriter_Windows(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, time.Minute)
+
+	w.Begin(t0.Add(10*time.Second), 42)
+	w.Record(t0.Add(15*time.Second), 1, zapcore.InfoLevel, 10, "alpha")
+	w.Record(t0.Add(30*time.Second), 2, zapcore.ErrorLevel, 90, "alpha")
+	w.Record(t0.Add(45*time.Second), 3, zapcore.InfoLevel, 50, "beta")
+	// Nothing in the 12:01 window, then one record at 12:02.
+	w.Record(t0.Add(2*time.Minute+5*time.Second), 4, zapcore.WarnLevel, 7, "gamma")
+	if err := w.Close(t0.Add(2*time.Minute + 30*time.Second)); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := decodeLines(t, buf.Bytes())
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4 (run + 3 windows):\n%s", len(lines), buf.String())
+	}
+
+	run := lines[0]
+	if run["kind"] != KindRun || run["seed"] != float64(42) || run["window"] != "1m0s" {
+		t.Errorf("run header = %v", run)
+	}
+
+	first := lines[1]
+	if first["kind"] != KindWindow || first["records"] != float64(3) {
+		t.Errorf("first window = %v, want 3 records", first)
+	}
+	if first["first_seq"] != float64(1) || first["last_seq"] != float64(3) {
+		t.Errorf("first window seq = %v..%v, want 1..3", first["first_seq"], first["last_seq"])
+	}
+	if first["start"] != "2026-02-18T12:00:00Z" || first["end"] != "2026-02-18T12:01:00Z" {
+		t.Errorf("first window bounds = %v..%v", first["start"], first["end"])
+	}
+	levels := first["levels"].(map[string]any)
+	if levels["info"] != float64(2) || levels["error"] != float64(1) {
+		t.Errorf("first window levels = %v", levels)
+	}
+	strs := first["random_string"].(map[string]any)
+	if strs["alpha"] != float64(2) || strs["beta"] != float64(1) {
+		t.Errorf("first window random_string = %v", strs)
+	}
+	nums := first["random_number"].(map[string]any)
+	if nums["min"] != float64(10) || nums["max"] != float64(90) || nums["sum"] != float64(150) {
+		t.Errorf("first window random_number = %v", nums)
+	}
+
+	empty := lines[2]
+	if empty["records"] != float64(0) {
+		t.Errorf("empty window = %v, want 0 records", empty)
+	}
+	if _, ok := empty["first_seq"]; ok {
+		t.Errorf("empty window has a sequence range: %v", empty)
+	}
+
+	last := lines[3]
+	if last["records"] != float64(1) || last["end"] != "2026-02-18T12:02:30Z" {
+		t.Errorf("final partial window = %v", last)
+	}
+}
+
+func TestWriter_Custom(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, time.Minute)
+
+	w.Begin(t0, 1)
+	w.RecordCustom(t0.Add(time.Second), 1, zapcore.InfoLevel)
+	w.RecordCustom(t0.Add(2*time.Second), 2, zapcore.WarnLevel)
+	if err := w.Close(t0.Add(time.Minute)); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := decodeLines(t, buf.Bytes())
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want run and 1 window:\n%s", len(lines), buf.String())
+	}
+	win := lines[1]
+	if win["records"] != float64(2) || win["last_seq"] != float64(2) {
+		t.Errorf("custom window = %v, want records 1..2", win)
+	}
+	if levels := win["levels"].(map[string]any); levels["info"] != float64(1) || levels["warn"] != float64(1) {
+		t.Errorf("custom window levels = %v", levels)
+	}
+	if strs := win["random_string"].(map[string]any); len(strs) != 0 {
+		t.Errorf("custom window random_string = %v, want none", strs)
+	}
+	if _, ok := win["random_number"]; ok {
+		t.Errorf("custom window has random_number: %v", win)
+	}
+}
+
+func TestWriter_Anomalies(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, time.Minute)
+
+	w.Begin(t0, 1)
+	w.ScenarioStarted(scenario.Window{Name: "outage", Type: "silence", Intensity: 1, Start: t0.Add(30 * time.Second), End: t0.Add(90 * time.Second)})
+	w.ScenarioEnded(scenario.Window{Name: "outage", Type: "silence", Intensity: 1, Start: t0.Add(30 * time.Second), End: t0.Add(90 * time.Second)})
+	w.Record(t0.Add(2*time.Minute), 1, zapcore.InfoLevel, 1, "alpha")
+	if err := w.Close(t0.Add(3 * time.Minute)); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := decodeLines(t, buf.Bytes())
+	var anomalies, tagged, untagged int
+	for _, l := range lines {
+		switch l["kind"] {
+		case KindAnomaly:
+			anomalies++
+			if l["name"] != "outage" || l["start"] != "2026-02-18T12:00:30Z" || l["end"] != "2026-02-18T12:01:30Z" {
+				t.Errorf("anomaly = %v", l)
+			}
+		case KindWindow:
+			if _, ok := l["scenarios"]; ok {
+				tagged++
+			} else {
+				untagged++
+			}
+		}
+	}
+	if anomalies != 1 {
+		t.Errorf("got %d anomaly lines, want 1", anomalies)
+	}
+	// 12:00:30-12:01:30 overlaps the 12:00 and 12:01 windows only.
+	if tagged != 2 || untagged != 1 {
+		t.Errorf("got %d tagged and %d untagged windows, want 2 and 1:\n%s", tagged, untagged, buf.String())
+	}
+}
+
+func TestWriter_Traces(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, time.Minute)
+
+	w.Begin(t0, 1)
+	w.Record(t0.Add(time.Second), 1, zapcore.InfoLevel, 5, "alpha")
+	w.RecordTrace(t0.Add(2*time.Second), zapcore.ErrorLevel, Trace{
+		Seq: 2, Language: "python", Lines: 6, First: "Traceback (most recent call last):", SHA256: "abc",
+	})
+	if err := w.Close(t0.Add(time.Minute)); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := decodeLines(t, buf.Bytes())
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want run, trace and window:\n%s", len(lines), buf.String())
+	}
+	tr := lines[1]
+	if tr["kind"] != KindTrace || tr["seq"] != float64(2) || tr["lines"] != float64(6) ||
+		tr["language"] != "python" || tr["time"] != "2026-02-18T12:00:02Z" {
+		t.Errorf("trace line = %v", tr)
+	}
+	win := lines[2]
+	if win["records"] != float64(2) || win["traces"] != float64(1) || win["last_seq"] != float64(2) {
+		t.Errorf("window = %v, want 2 records including 1 trace", win)
+	}
+	if levels := win["levels"].(map[string]any); levels["error"] != float64(1) {
+		t.Errorf("window levels = %v, want one error", levels)
+	}
+}
+
+func TestWriter_AccessAndCases(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, time.Minute)
+
+	w.Begin(t0, 1)
+	w.RecordAccess(t0.Add(time.Second), 1, zapcore.InfoLevel, 200, 12*time.Millisecond)
+	w.RecordAccess(t0.Add(2*time.Second), 2, zapcore.ErrorLevel, 503, 2*time.Second)
+	w.RecordAccess(t0.Add(3*time.Second), 3, zapcore.InfoLevel, 200, 30*time.Millisecond)
+	w.RecordCase(t0.Add(time.Minute+time.Second), 4, zapcore.InfoLevel, "nul_byte")
+	w.RecordCase(t0.Add(time.Minute+2*time.Second), 5, zapcore.InfoLevel, "nul_byte")
+	if err := w.Close(t0.Add(2 * time.Minute)); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := decodeLines(t, buf.Bytes())
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want run and 2 windows:\n%s", len(lines), buf.String())
+	}
+
+	access := lines[1]
+	if access["records"] != float64(3) {
+		t.Errorf("access window = %v, want 3 records", access)
+	}
+	status := access["status"].(map[string]any)
+	if status["200"] != float64(2) || status["503"] != float64(1) {
+		t.Errorf("access window status = %v", status)
+	}
+	latency := access["latency_ms"].(map[string]any)
+	if latency["min"] != float64(12) || latency["max"] != float64(2000) || latency["sum"] != float64(2042) {
+		t.Errorf("access window latency_ms = %v", latency)
+	}
+	if _, ok := access["random_number"]; ok {
+		t.Errorf("access window has random_number: %v", access)
+	}
+
+	cases := lines[2]
+	if c := cases["cases"].(map[string]any); c["nul_byte"] != float64(2) {
+		t.Errorf("adversarial window cases = %v", c)
+	}
+	if _, ok := cases["status"]; ok {
+		t.Errorf("adversarial window has status: %v", cases)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "truth.jsonl")
+	w, err := Create(path, 0)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	w.Begin(t0, 7)
+	if err := w.Close(t0.Add(time.Second)); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	lines := decodeLines(t, data)
+	if len(lines) != 2 || lines[0]["window"] != DefaultWindow.String() {
+		t.Errorf("manifest = %s", data)
+	}
+
+	if _, err := Create(filepath.Join(t.TempDir(), "missing", "truth.jsonl"), time.Minute); err == nil {
+		t.Error("Create() in missing directory succeeded, want error")
+	}
+}
@@ -0,0 +1,164 @@
+package zstd
+
+import "math/bits"
+
+// Baselines and extra bits of the literal length codes.
+var (
+	llBase = [36]uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	llBits = [36]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+)
+
+// Baselines and extra bits of the match length codes.
+var (
+	mlBase = [53]uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	mlBits = [53]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+// The predefined distributions of RFC 8878 section 3.1.1.3.2.2. A count of
+// -1 is a symbol with less than one state's worth of probability.
+var (
+	llNorm = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	mlNorm = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	ofNorm = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+)
+
+var (
+	llTable = newFSETable(llNorm, 6)
+	mlTable = newFSETable(mlNorm, 6)
+	ofTable = newFSETable(ofNorm, 5)
+)
+
+// fseTable is the encoding table of one distribution.
+type fseTable struct {
+	log uint
+	// next maps a symbol's slot to the state that follows it.
+	next []uint16
+	syms []symbolTransform
+}
+
+// symbolTransform turns a state into the bits to write and the next state
+// for one symbol.
+type symbolTransform struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+}
+
+// spread places every symbol of norm in the table the way the decoder
+// does, with the low-probability symbols at the end.
+func spread(norm []int16, log uint) []uint8 {
+	size := 1 << log
+	symbols := make([]uint8, size)
+	high := size - 1
+	for s, n := range norm {
+		if n == -1 {
+			symbols[high] = uint8(s)
+			high--
+		}
+	}
+	step := size>>1 + size>>3 + 3
+	pos := 0
+	for s, n := range norm {
+		for range max(n, 0) {
+			symbols[pos] = uint8(s)
+			pos = (pos + step) & (size - 1)
+			for pos > high {
+				pos = (pos + step) & (size - 1)
+			}
+		}
+	}
+	return symbols
+}
+
+func newFSETable(norm []int16, log uint) fseTable {
+	size := 1 << log
+	t := fseTable{log: log, next: make([]uint16, size), syms: make([]symbolTransform, len(norm))}
+
+	cumul := make([]int, len(norm)+1)
+	for s, n := range norm {
+		cumul[s+1] = cumul[s] + max(int(n), 1)
+	}
+	for u, s := range spread(norm, log) {
+		t.next[cumul[s]] = uint16(size + u)
+		cumul[s]++
+	}
+
+	total := int32(0)
+	for s, n := range norm {
+		if n == -1 || n == 1 {
+			t.syms[s] = symbolTransform{
+				deltaNbBits:    uint32(log)<<16 - uint32(size),
+				deltaFindState: total - 1,
+			}
+			total++
+			continue
+		}
+		maxBitsOut := uint32(log) - uint32(bits.Len16(uint16(n-1))-1)
+		minStatePlus := uint32(n) << maxBitsOut
+		t.syms[s] = symbolTransform{
+			deltaNbBits:    maxBitsOut<<16 - minStatePlus,
+			deltaFindState: total - int32(n),
+		}
+		total += int32(n)
+	}
+	return t
+}
+
+// fseState is an encoder state over one table.
+type fseState struct {
+	t     *fseTable
+	value uint32
+}
+
+// init starts the state at the first symbol, which is the last one the
+// decoder reads, without writing bits.
+func (st *fseState) init(t *fseTable, sym uint8) {
+	st.t = t
+	tr := t.syms[sym]
+	nbBits := (tr.deltaNbBits + 1<<15) >> 16
+	v := nbBits<<16 - tr.deltaNbBits
+	st.value = uint32(t.next[int32(v>>nbBits)+tr.deltaFindState])
+}
+
+// encode writes the bits that lead the decoder from sym to the current
+// state.
+func (st *fseState) encode(w *bitWriter, sym uint8) {
+	tr := st.t.syms[sym]
+	nbBits := (st.value + tr.deltaNbBits) >> 16
+	w.add(uint64(st.value), uint(nbBits))
+	st.value = uint32(st.t.next[int32(st.value>>nbBits)+tr.deltaFindState])
+}
+
+// flush writes the state the decoder starts from.
+func (st *fseState) flush(w *bitWriter) {
+	w.add(uint64(st.value), st.t.log)
+}
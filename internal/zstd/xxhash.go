@@ -0,0 +1,98 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// XXH64 primes.
+const (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+// digest computes XXH64 with seed 0, the content checksum of a frame.
+type digest struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int
+}
+
+func (d *digest) reset() {
+	// The initial lanes wrap around, which constant arithmetic rejects.
+	p1, p2 := prime1, prime2
+	d.v = [4]uint64{p1 + p2, p2, 0, -p1}
+	d.total = 0
+	d.n = 0
+}
+
+func (d *digest) write(b []byte) {
+	d.total += uint64(len(b))
+	if d.n > 0 {
+		k := copy(d.mem[d.n:], b)
+		d.n += k
+		b = b[k:]
+		if d.n < len(d.mem) {
+			return
+		}
+		d.stripe(d.mem[:])
+		d.n = 0
+	}
+	for ; len(b) >= 32; b = b[32:] {
+		d.stripe(b)
+	}
+	d.n = copy(d.mem[:], b)
+}
+
+func (d *digest) stripe(b []byte) {
+	for i := range d.v {
+		d.v[i] = round(d.v[i], binary.LittleEndian.Uint64(b[8*i:]))
+	}
+}
+
+func (d *digest) sum() uint64 {
+	var h uint64
+	if d.total >= 32 {
+		v := d.v
+		h = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) +
+			bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for _, x := range v {
+			h = (h^round(0, x))*prime1 + prime4
+		}
+	} else {
+		h = prime5
+	}
+	h += d.total
+
+	b := d.mem[:d.n]
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= round(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*prime1 + prime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * prime1
+		h = bits.RotateLeft64(h, 23)*prime2 + prime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * prime5
+		h = bits.RotateLeft64(h, 11) * prime1
+	}
+
+	h ^= h >> 33
+	h *= prime2
+	h ^= h >> 29
+	h *= prime3
+	h ^= h >> 32
+	return h
+}
+
+func round(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime1
+}
@@ -0,0 +1,325 @@
+// Package zstd writes the Zstandard frame format (RFC 8878) with a greedy
+// hash-table compressor, so rotated files can be compressed without a
+// third-party dependency. Literals are stored as they are and sequences use
+// the predefined FSE tables, which costs some ratio against the reference
+// encoder but keeps the format simple. Any zstd decoder reads the output.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+const (
+	magic = 0xFD2FB528
+
+	// frameDescriptor sets only Content_Checksum_flag: the content size is
+	// not known up front, there is no dictionary and the frame is not a
+	// single segment, so a window descriptor follows.
+	frameDescriptor = 1 << 2
+
+	// blockSize is the largest block the format allows. Matches never
+	// cross a block, so it is also the window: exponent 7, mantissa 0
+	// encodes 1<<(10+7) bytes.
+	blockSize        = 1 << 17
+	windowDescriptor = 7 << 3
+
+	blockRaw        = 0
+	blockRLE        = 1
+	blockCompressed = 2
+
+	// minMatch is the shortest match worth a sequence.
+	minMatch = 4
+
+	tableBits = 15
+)
+
+// ErrClosed reports a write to a closed Writer.
+var ErrClosed = errors.New("zstd: writer is closed")
+
+// Writer compresses the bytes written to it into a single zstd frame.
+// Input is buffered into blocks, so the frame is complete only after Close.
+type Writer struct {
+	w      io.Writer
+	buf    []byte
+	out    []byte
+	digest digest
+	enc    encoder
+
+	started bool
+	closed  bool
+	err     error
+}
+
+// NewWriter returns a Writer compressing to w.
+func NewWriter(w io.Writer) *Writer {
+	z := &Writer{w: w, buf: make([]byte, 0, blockSize)}
+	z.digest.reset()
+	return z
+}
+
+// Write implements io.Writer.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, ErrClosed
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		// A full block is written only once more input arrives, since
+		// the last block of the frame has to be marked as such.
+		if len(z.buf) == blockSize {
+			if err := z.flush(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		k := min(blockSize-len(z.buf), len(p))
+		z.buf = append(z.buf, p[:k]...)
+		p = p[k:]
+	}
+	return n, nil
+}
+
+// Close writes the last block and the content checksum. It does not close
+// the underlying writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	z.closed = true
+	if z.err != nil {
+		return z.err
+	}
+	return z.flush(true)
+}
+
+func (z *Writer) flush(last bool) error {
+	if !z.started {
+		z.out = binary.LittleEndian.AppendUint32(z.out, magic)
+		z.out = append(z.out, frameDescriptor, windowDescriptor)
+		z.started = true
+	}
+	z.digest.write(z.buf)
+	z.out = z.enc.appendBlock(z.out, z.buf, last)
+	if last {
+		z.out = binary.LittleEndian.AppendUint32(z.out, uint32(z.digest.sum()))
+	}
+	_, z.err = z.w.Write(z.out)
+	z.out = z.out[:0]
+	z.buf = z.buf[:0]
+	return z.err
+}
+
+// sequence copies lit literals, then match bytes from offset back.
+type sequence struct {
+	lit, match, offset uint32
+}
+
+// encoder holds the scratch space of block compression.
+type encoder struct {
+	table    [1 << tableBits]int32
+	seqs     []sequence
+	literals []byte
+	body     []byte
+}
+
+// appendBlock appends src as one block, in whichever of the RLE,
+// compressed and raw forms is smallest.
+func (e *encoder) appendBlock(dst, src []byte, last bool) []byte {
+	if len(src) > 1 && allSame(src) {
+		dst = appendBlockHeader(dst, last, blockRLE, len(src))
+		return append(dst, src[0])
+	}
+	if body := e.compress(src); body != nil && len(body) < len(src) {
+		dst = appendBlockHeader(dst, last, blockCompressed, len(body))
+		return append(dst, body...)
+	}
+	dst = appendBlockHeader(dst, last, blockRaw, len(src))
+	return append(dst, src...)
+}
+
+func appendBlockHeader(dst []byte, last bool, typ, size int) []byte {
+	h := typ<<1 | size<<3
+	if last {
+		h |= 1
+	}
+	return append(dst, byte(h), byte(h>>8), byte(h>>16))
+}
+
+func allSame(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// compress returns the content of a compressed block for src, or nil if
+// src has no matches.
+func (e *encoder) compress(src []byte) []byte {
+	e.findSequences(src)
+	if len(e.seqs) == 0 {
+		return nil
+	}
+
+	b := appendLiteralsHeader(e.body[:0], len(e.literals))
+	b = append(b, e.literals...)
+
+	n := len(e.seqs)
+	switch {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n < 0x7F00:
+		b = append(b, byte(n>>8|0x80), byte(n))
+	default:
+		b = append(b, 0xFF, byte(n-0x7F00), byte((n-0x7F00)>>8))
+	}
+	// Predefined_Mode for literal lengths, offsets and match lengths.
+	b = append(b, 0)
+	b = e.appendSequences(b)
+
+	e.body = b
+	return b
+}
+
+// findSequences splits src into sequences and trailing literals with a
+// greedy search for earlier occurrences of the next four bytes.
+func (e *encoder) findSequences(src []byte) {
+	e.seqs = e.seqs[:0]
+	e.literals = e.literals[:0]
+	clear(e.table[:])
+
+	lit := 0
+	for i := 0; i+minMatch <= len(src); {
+		cur := binary.LittleEndian.Uint32(src[i:])
+		h := hash(cur)
+		cand := int(e.table[h]) - 1
+		e.table[h] = int32(i + 1)
+		if cand < 0 || binary.LittleEndian.Uint32(src[cand:]) != cur {
+			i++
+			continue
+		}
+
+		end := i + minMatch
+		for end < len(src) && src[end] == src[end-i+cand] {
+			end++
+		}
+		e.seqs = append(e.seqs, sequence{lit: uint32(i - lit), match: uint32(end - i), offset: uint32(i - cand)})
+		e.literals = append(e.literals, src[lit:i]...)
+		i, lit = end, end
+	}
+	e.literals = append(e.literals, src[lit:]...)
+}
+
+func hash(v uint32) uint32 {
+	return (v * 0x9E3779B1) >> (32 - tableBits)
+}
+
+// appendLiteralsHeader appends the header of a Raw_Literals_Block of n
+// bytes.
+func appendLiteralsHeader(dst []byte, n int) []byte {
+	switch {
+	case n < 1<<5:
+		return append(dst, byte(n<<3))
+	case n < 1<<12:
+		return append(dst, byte(1<<2|n<<4), byte(n>>4))
+	default:
+		return append(dst, byte(3<<2|n<<4), byte(n>>4), byte(n>>12))
+	}
+}
+
+// appendSequences appends the FSE bitstream of the sequences. The decoder
+// reads it backwards, so the last sequence is encoded first.
+func (e *encoder) appendSequences(dst []byte) []byte {
+	w := bitWriter{b: dst}
+	var ll, ml, of fseState
+
+	last := e.seqs[len(e.seqs)-1]
+	llc, mlc, ofc := llCode(last.lit), mlCode(last.match), ofCode(last.offset)
+	ml.init(&mlTable, mlc)
+	of.init(&ofTable, ofc)
+	ll.init(&llTable, llc)
+	w.addSequenceBits(last, llc, mlc, ofc)
+
+	for i := len(e.seqs) - 2; i >= 0; i-- {
+		s := e.seqs[i]
+		llc, mlc, ofc := llCode(s.lit), mlCode(s.match), ofCode(s.offset)
+		of.encode(&w, ofc)
+		ml.encode(&w, mlc)
+		ll.encode(&w, llc)
+		w.addSequenceBits(s, llc, mlc, ofc)
+	}
+
+	ml.flush(&w)
+	of.flush(&w)
+	ll.flush(&w)
+	return w.close()
+}
+
+// addSequenceBits adds the extra bits of the literal length, match length
+// and offset of s.
+func (w *bitWriter) addSequenceBits(s sequence, llc, mlc, ofc uint8) {
+	w.add(uint64(s.lit-llBase[llc]), uint(llBits[llc]))
+	w.add(uint64(s.match-mlBase[mlc]), uint(mlBits[mlc]))
+	// Offset values 1 to 3 name repeated offsets, so new offsets are
+	// stored plus 3.
+	w.add(uint64(s.offset+3), uint(ofc))
+}
+
+func llCode(v uint32) uint8 {
+	if v >= 64 {
+		return uint8(bits.Len32(v) - 1 + 19)
+	}
+	c := uint8(0)
+	for c+1 < uint8(len(llBase)) && llBase[c+1] <= v {
+		c++
+	}
+	return c
+}
+
+func mlCode(v uint32) uint8 {
+	if v-3 >= 128 {
+		return uint8(bits.Len32(v-3) - 1 + 36)
+	}
+	c := uint8(0)
+	for c+1 < uint8(len(mlBase)) && mlBase[c+1] <= v {
+		c++
+	}
+	return c
+}
+
+func ofCode(offset uint32) uint8 {
+	return uint8(bits.Len32(offset+3) - 1)
+}
+
+// bitWriter collects a little-endian bitstream.
+type bitWriter struct {
+	b   []byte
+	acc uint64
+	n   uint
+}
+
+func (w *bitWriter) add(v uint64, n uint) {
+	w.acc |= (v & (1<<n - 1)) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.b = append(w.b, byte(w.acc))
+		w.acc >>= 8
+		w.n -= 8
+	}
+}
+
+// close adds the end mark the decoder finds the stream start by, pads
+// the last byte and returns the stream.
+func (w *bitWriter) close() []byte {
+	w.add(1, 1)
+	if w.n > 0 {
+		w.b = append(w.b, byte(w.acc))
+	}
+	return w.b
+}
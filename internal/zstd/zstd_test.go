@@ -0,0 +1,325 @@
+package zstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+	"testing"
+)
+
+func compress(t *testing.T, data []byte, chunk int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	z := NewWriter(&buf)
+	for p := data; len(p) > 0; {
+		k := min(chunk, len(p))
+		if _, err := z.Write(p[:k]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		p = p[k:]
+	}
+	if err := z.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func logLines(n int) []byte {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var b bytes.Buffer
+	for i := range n {
+		fmt.Fprintf(&b, `{"level":"info","ts":%d.%03d,"msg":"tick","count":%d,"random_number":%d,"random_string":"str%d"}`+"\n",
+			1771416000+i, rng.IntN(1000), i, rng.IntN(1000), rng.IntN(20))
+	}
+	return b.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	random := make([]byte, 200_000)
+	for i := range random {
+		random[i] = byte(rng.Uint32())
+	}
+	logs := logLines(5000)
+
+	tests := []struct {
+		name  string
+		data  []byte
+		chunk int
+	}{
+		{"empty", nil, 1},
+		{"one byte", []byte("a"), 1},
+		{"short repeat", []byte("abcdefgh-abcdefgh-abcdefgh-abcdefgh-xyz"), 7},
+		{"long matches", bytes.Repeat([]byte("0123456789abcdefghijklmnopqrstuvwxyz!"), 5000), 4096},
+		{"zeros", make([]byte, 300_000), 1 << 20},
+		{"random", random, 65536},
+		{"logs", logs, 1000},
+		{"exactly one block", logs[:blockSize], blockSize},
+		{"exactly two blocks", logs[:2*blockSize], 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decode(compress(t, tt.data, tt.chunk))
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("round trip of %d bytes returned %d different bytes", len(tt.data), len(got))
+			}
+		})
+	}
+}
+
+func TestWriter_Ratio(t *testing.T) {
+	logs := logLines(5000)
+	out := compress(t, logs, 4096)
+	if len(out)*3 > len(logs) {
+		t.Errorf("compressed %d bytes of log lines to %d, want at least 3x smaller", len(logs), len(out))
+	}
+
+	rng := rand.New(rand.NewPCG(5, 6))
+	random := make([]byte, 100_000)
+	for i := range random {
+		random[i] = byte(rng.Uint32())
+	}
+	// Incompressible blocks are stored raw: frame header, block header
+	// and checksum only.
+	if out := compress(t, random, 4096); len(out) != len(random)+6+3+4 {
+		t.Errorf("random input grew from %d to %d bytes", len(random), len(out))
+	}
+}
+
+// TestWriter_Reference checks a frame against the output of the reference
+// encoder (zstd -1), which for short input also stores the literals raw
+// and uses the predefined tables. Only the window descriptor differs: the
+// reference encoder sizes the window to the input.
+func TestWriter_Reference(t *testing.T) {
+	got := compress(t, []byte("abcdefgh-abcdefgh-abcdefgh-abcdefgh-xyz-qwertyu-qwertyu-qwertyu-qwertyu"), 1<<10)
+	want := []byte{
+		0x28, 0xb5, 0x2f, 0xfd, 0x04, 0x38, 0xe5, 0x00, 0x00, 0xa0, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66,
+		0x67, 0x68, 0x2d, 0x78, 0x79, 0x7a, 0x2d, 0x71, 0x77, 0x65, 0x72, 0x74, 0x79, 0x75, 0x02, 0x00,
+		0x2b, 0xa0, 0x67, 0x58, 0x8e, 0x84, 0x88, 0x58, 0xbd,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("frame =\n% x\nwant\n% x", got, want)
+	}
+}
+
+func TestWriter_Closed(t *testing.T) {
+	var buf bytes.Buffer
+	z := NewWriter(&buf)
+	if err := z.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := z.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if _, err := z.Write([]byte("x")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write() after Close error = %v, want ErrClosed", err)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	// Reference values of XXH64 with seed 0.
+	tests := []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	}
+	for _, tt := range tests {
+		var d digest
+		d.reset()
+		// Feed byte by byte to exercise the stripe buffer.
+		for i := range len(tt.in) {
+			d.write([]byte{tt.in[i]})
+		}
+		if got := d.sum(); got != tt.want {
+			t.Errorf("xxh64(%q) = %#x, want %#x", tt.in, got, tt.want)
+		}
+	}
+}
+
+// decode reads the frames Writer produces: raw, RLE and compressed blocks
+// with raw literals and predefined sequence tables. It is not a general
+// zstd decoder.
+func decode(src []byte) ([]byte, error) {
+	if len(src) < 6 || binary.LittleEndian.Uint32(src) != magic {
+		return nil, errors.New("bad magic")
+	}
+	if src[4] != frameDescriptor || src[5] != windowDescriptor {
+		return nil, fmt.Errorf("unexpected frame header % x", src[4:6])
+	}
+	src = src[6:]
+
+	var out []byte
+	for {
+		if len(src) < 3 {
+			return nil, errors.New("truncated block header")
+		}
+		h := int(src[0]) | int(src[1])<<8 | int(src[2])<<16
+		last, typ, size := h&1 == 1, h>>1&3, h>>3
+		src = src[3:]
+		switch typ {
+		case blockRaw:
+			if len(src) < size {
+				return nil, errors.New("truncated raw block")
+			}
+			out = append(out, src[:size]...)
+			src = src[size:]
+		case blockRLE:
+			if len(src) < 1 {
+				return nil, errors.New("truncated RLE block")
+			}
+			out = append(out, bytes.Repeat(src[:1], size)...)
+			src = src[1:]
+		case blockCompressed:
+			if len(src) < size {
+				return nil, errors.New("truncated compressed block")
+			}
+			var err error
+			if out, err = decodeBlock(out, src[:size]); err != nil {
+				return nil, err
+			}
+			src = src[size:]
+		default:
+			return nil, fmt.Errorf("reserved block type")
+		}
+		if last {
+			break
+		}
+	}
+
+	if len(src) != 4 {
+		return nil, errors.New("missing checksum")
+	}
+	var d digest
+	d.reset()
+	d.write(out)
+	if binary.LittleEndian.Uint32(src) != uint32(d.sum()) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return out, nil
+}
+
+func decodeBlock(out, src []byte) ([]byte, error) {
+	if src[0]&3 != 0 {
+		return nil, errors.New("only raw literals are supported")
+	}
+	var n, hlen int
+	switch src[0] >> 2 & 3 {
+	case 0, 2:
+		n, hlen = int(src[0]>>3), 1
+	case 1:
+		n, hlen = int(src[0]>>4)|int(src[1])<<4, 2
+	case 3:
+		n, hlen = int(src[0]>>4)|int(src[1])<<4|int(src[2])<<12, 3
+	}
+	literals := src[hlen : hlen+n]
+	src = src[hlen+n:]
+
+	var nbSeq int
+	switch {
+	case src[0] < 0x80:
+		nbSeq, src = int(src[0]), src[1:]
+	case src[0] < 0xFF:
+		nbSeq, src = int(src[0]-0x80)<<8|int(src[1]), src[2:]
+	default:
+		nbSeq, src = int(src[1])|int(src[2])<<8+0x7F00, src[3:]
+	}
+	if src[0] != 0 {
+		return nil, errors.New("only predefined sequence tables are supported")
+	}
+
+	r, err := newBitReader(src[1:])
+	if err != nil {
+		return nil, err
+	}
+	llDec, ofDec, mlDec := decodeTable(llNorm, 6), decodeTable(ofNorm, 5), decodeTable(mlNorm, 6)
+	ll, of, ml := r.read(6), r.read(5), r.read(6)
+	for i := range nbSeq {
+		ofc, mlc, llc := ofDec[of].sym, mlDec[ml].sym, llDec[ll].sym
+		offset := 1<<ofc + r.read(uint(ofc))
+		if offset <= 3 {
+			return nil, errors.New("repeat offsets are not supported")
+		}
+		offset -= 3
+		matchLen := int(mlBase[mlc]) + r.read(uint(mlBits[mlc]))
+		litLen := int(llBase[llc]) + r.read(uint(llBits[llc]))
+		if i < nbSeq-1 {
+			ll = llDec[ll].base + r.read(llDec[ll].bits)
+			ml = mlDec[ml].base + r.read(mlDec[ml].bits)
+			of = ofDec[of].base + r.read(ofDec[of].bits)
+		}
+
+		if litLen > len(literals) || offset > len(out)+litLen {
+			return nil, errors.New("sequence out of range")
+		}
+		out = append(out, literals[:litLen]...)
+		literals = literals[litLen:]
+		start := len(out) - offset
+		for j := range matchLen {
+			out = append(out, out[start+j])
+		}
+	}
+	if r.pos != 0 || r.overread {
+		return nil, errors.New("bitstream not fully consumed")
+	}
+	return append(out, literals...), nil
+}
+
+type decodeEntry struct {
+	sym  uint8
+	bits uint
+	base int
+}
+
+func decodeTable(norm []int16, log uint) []decodeEntry {
+	size := 1 << log
+	next := make([]int, len(norm))
+	for s, n := range norm {
+		next[s] = max(int(n), 1)
+	}
+	table := make([]decodeEntry, size)
+	for u, s := range spread(norm, log) {
+		x := next[s]
+		next[s]++
+		nb := log - uint(bits.Len(uint(x))-1)
+		table[u] = decodeEntry{sym: s, bits: nb, base: x<<nb - size}
+	}
+	return table
+}
+
+// bitReader reads a bitstream backwards from its end mark.
+type bitReader struct {
+	b        []byte
+	pos      int
+	overread bool
+}
+
+func newBitReader(b []byte) (*bitReader, error) {
+	if len(b) == 0 || b[len(b)-1] == 0 {
+		return nil, errors.New("missing end mark")
+	}
+	return &bitReader{b: b, pos: (len(b)-1)*8 + bits.Len8(b[len(b)-1]) - 1}, nil
+}
+
+func (r *bitReader) read(n uint) int {
+	r.pos -= int(n)
+	if r.pos < 0 {
+		r.overread = true
+		return 0
+	}
+	v := 0
+	for i := range int(n) {
+		p := r.pos + i
+		v |= int(r.b[p/8]>>(p%8)&1) << i
+	}
+	return v
+}
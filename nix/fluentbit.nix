@@ -50,6 +50,14 @@ INPUTCONF
 
     # Filters configuration
     cat > $out/etc/fluent-bit/filters.conf << 'FILTERCONF'
+# Operational logs go to stderr and are named "loggen" or
+# "loggen.<sub-logger>"; keep them out of otel_logs so only generated
+# records are stored.
+[FILTER]
+    Name          grep
+    Match         kube.loggen.*
+    Exclude       stream ^stderr$
+
 [FILTER]
     Name          parser
     Match         kube.loggen.*
@@ -57,6 +65,11 @@ INPUTCONF
     Parser        json
     Reserve_Data  On
 
+[FILTER]
+    Name          grep
+    Match         kube.loggen.*
+    Exclude       logger ^loggen(\.|$)
+
 [FILTER]
     Name          lua
     Match         kube.loggen.*